@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"math/rand"
+
+	"github.com/pion/opus"
+)
+
+const (
+	// Opus is always decoded at 48 kHz, granule positions and the
+	// pre-skip count samples at this rate
+	//
+	// https://datatracker.ietf.org/doc/html/rfc7845#section-4
+	decodeSampleRate = 48000
+
+	// Samples are read from the stream in blocks of 20 ms
+	readSamples = decodeSampleRate * 20 / 1000
+)
+
+var (
+	errInvalidRate       = errors.New("invalid sample rate")
+	errInvalidPacketLoss = errors.New("packet loss must be between 0 and 100")
+	errLayoutChanged     = errors.New("a link of the chained stream changes the channel layout")
+	errTruncated         = errors.New("the stream ends in the middle of a page")
+)
+
+// decode decodes the Ogg Opus stream read from in, and writes it to out. A
+// stream that ends in the middle of a page is written up to the last
+// complete page, and reported with errTruncated.
+func decode(in io.Reader, out io.Writer, opts options) error {
+	stream, err := opus.NewOggReader(in, opus.Options{})
+	if err != nil {
+		return err
+	}
+
+	if opts.packetLoss > 0 {
+		random := rand.New(rand.NewSource(opts.seed))
+		stream.SetPacketLoss(func() bool {
+			return random.Float64()*100 < opts.packetLoss
+		})
+	}
+
+	header := stream.Header()
+	writer := newSampleWriter(out, header, opts)
+	if err := writer.start(opts.raw); err != nil {
+		return err
+	}
+
+	samples := make([]float32, readSamples*stream.Channels())
+	for {
+		n, err := stream.ReadFloat32(samples)
+		switch {
+		case errors.Is(err, io.EOF):
+			return writer.finish()
+		case errors.Is(err, io.ErrUnexpectedEOF):
+			if err := writer.finish(); err != nil {
+				return err
+			}
+			return errTruncated
+		case err != nil:
+			return err
+		}
+
+		// The links of a chained stream are written to the same file
+		if link := stream.Header(); link.Channels != header.Channels || link.ChannelMap != header.ChannelMap {
+			return errLayoutChanged
+		}
+
+		if err := writer.write(samples[:n]); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/pion/opus"
+	"github.com/pion/opus/pkg/wav"
+)
+
+const (
+	headerTypeBOS = 0x02
+	headerTypeEOS = 0x04
+	preSkip       = 312
+
+	// The last page is trimmed to 5 ms
+	testSampleCount = 1920 + 240
+)
+
+func appendUint16(b []byte, v uint16) []byte {
+	return append(b, byte(v), byte(v>>8))
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	return appendUint32(appendUint32(b, uint32(v)), uint32(v>>32))
+}
+
+// oggPage builds an Ogg page with a valid checksum
+func oggPage(headerType byte, granule uint64, sequence uint32, segments ...[]byte) []byte {
+	page := []byte("OggS")
+	page = append(page, 0, headerType)
+	page = appendUint64(page, granule)
+	page = appendUint32(page, 0x1234)
+	page = appendUint32(page, sequence)
+	page = append(page, 0, 0, 0, 0, byte(len(segments)))
+	for _, segment := range segments {
+		page = append(page, byte(len(segment)))
+	}
+	for _, segment := range segments {
+		page = append(page, segment...)
+	}
+
+	var checksum uint32
+	for _, v := range page {
+		checksum ^= uint32(v) << 24
+		for i := 0; i < 8; i++ {
+			if checksum&0x80000000 != 0 {
+				checksum = (checksum << 1) ^ 0x04c11db7
+			} else {
+				checksum <<= 1
+			}
+		}
+	}
+	binary.LittleEndian.PutUint32(page[22:], checksum)
+
+	return page
+}
+
+// opusHead builds an ID header for a single mono stream, every channel of
+// a stream with more channels is mapped to it with channel mapping family 1
+func opusHead(channels byte, outputGain uint16) []byte {
+	head := []byte("OpusHead")
+	head = append(head, 1, channels)
+	head = appendUint16(head, preSkip)
+	head = appendUint32(head, 16000)
+	head = appendUint16(head, outputGain)
+
+	if channels == 1 {
+		return append(head, 0)
+	}
+
+	head = append(head, 1, 1, 0)
+	return append(head, make([]byte, channels)...)
+}
+
+// A 20 ms wideband SILK-only packet
+func testPacket() []byte {
+	return []byte{0x48, 0x0B, 0xE4, 0xC1, 0x36, 0xEC, 0xC5, 0x80}
+}
+
+func testStream(channels byte, outputGain uint16) []byte {
+	var stream []byte
+	for _, page := range [][]byte{
+		oggPage(headerTypeBOS, 0, 0, opusHead(channels, outputGain)),
+		oggPage(0, 0, 1, []byte("OpusTags\x00\x00\x00\x00\x00\x00\x00\x00")),
+		oggPage(0, 1920, 2, testPacket(), testPacket()),
+		oggPage(headerTypeEOS, preSkip+testSampleCount, 3, testPacket()),
+	} {
+		stream = append(stream, page...)
+	}
+
+	return stream
+}
+
+// expectedSamples decodes the test stream with opus.Decoder, and applies
+// the pre-skip and end trimming
+func expectedSamples(t *testing.T) []float32 {
+	decoder := opus.NewDecoder()
+	samples := make([]float32, 3*960)
+	for i := 0; i < 3; i++ {
+		if _, _, err := decoder.DecodeFloat32(testPacket(), samples[i*960:]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return samples[preSkip : preSkip+testSampleCount]
+}
+
+func decodeFloat32(t *testing.T, stream []byte, opts options) []float32 {
+	var out bytes.Buffer
+	opts.float, opts.raw = true, true
+	if err := decode(bytes.NewReader(stream), &out, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	samples := make([]float32, out.Len()/4)
+	for i := range samples {
+		samples[i] = math.Float32frombits(binary.LittleEndian.Uint32(out.Bytes()[i*4:]))
+	}
+
+	return samples
+}
+
+func TestDecode(t *testing.T) {
+	expected := expectedSamples(t)
+	opts := options{rate: decodeSampleRate, seed: 1}
+
+	if samples := decodeFloat32(t, testStream(1, 0), opts); !reflect.DeepEqual(samples, expected) {
+		t.Fatal("decoded samples mismatch")
+	}
+
+	// Both channels carry the mono stream, with the output gain of
+	// +6.02 dB applied
+	samples := decodeFloat32(t, testStream(2, 0x0605), opts)
+	if len(samples) != 2*testSampleCount {
+		t.Fatal(len(samples))
+	}
+	for i, sample := range expected {
+		if samples[2*i] != samples[2*i+1] || math.Abs(float64(samples[2*i]-2*sample)) > 1e-6 {
+			t.Fatalf("%d: %f %f != %f", i, samples[2*i], samples[2*i+1], 2*sample)
+		}
+	}
+
+	// Lost packets are concealed, which keeps the length of the stream
+	opts.packetLoss = 100
+	if samples := decodeFloat32(t, testStream(1, 0), opts); len(samples) != testSampleCount {
+		t.Fatal(len(samples))
+	} else if reflect.DeepEqual(samples, expected) {
+		t.Fatal("no packets were concealed")
+	}
+
+	opts.packetLoss = 0
+	opts.rate = 16000
+	if samples := decodeFloat32(t, testStream(1, 0), opts); len(samples) != testSampleCount/3 {
+		t.Fatal(len(samples))
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	// A truncated stream is written up to its last complete page
+	stream := testStream(1, 0)
+	var out bytes.Buffer
+	opts := options{rate: decodeSampleRate, float: true, raw: true}
+	if err := decode(bytes.NewReader(stream[:len(stream)-4]), &out, opts); !errors.Is(err, errTruncated) {
+		t.Fatal(err)
+	} else if out.Len() != 4*(1920-preSkip) {
+		t.Fatal(out.Len())
+	}
+
+	// Every link of a chained stream is written to the same file
+	out.Reset()
+	if err := decode(bytes.NewReader(append(testStream(1, 0), testStream(1, 0)...)), &out, opts); err != nil {
+		t.Fatal(err)
+	} else if out.Len() != 2*4*testSampleCount {
+		t.Fatal(out.Len())
+	}
+
+	if err := decode(bytes.NewReader(append(testStream(1, 0), testStream(2, 0)...)), &out, opts); !errors.Is(err, errLayoutChanged) {
+		t.Fatal(err)
+	}
+}
+
+func TestDecodeInt16(t *testing.T) {
+	expected := expectedSamples(t)
+
+	var out bytes.Buffer
+	if err := decode(bytes.NewReader(testStream(1, 0)), &out, options{rate: decodeSampleRate, noDither: true, raw: true}); err != nil {
+		t.Fatal(err)
+	} else if out.Len() != 2*testSampleCount {
+		t.Fatal(out.Len())
+	}
+
+	for i, sample := range expected {
+		if actual := int16(binary.LittleEndian.Uint16(out.Bytes()[i*2:])); actual != int16(math.Round(float64(sample)*32768)) {
+			t.Fatalf("%d: %d != %f", i, actual, sample)
+		}
+	}
+}
+
+func TestResampler(t *testing.T) {
+	// A 1 kHz sine is kept when resampling to 16 kHz, in chunks of
+	// varying size
+	in := make([]float32, 4800)
+	for i := range in {
+		in[i] = float32(math.Sin(2 * math.Pi * 1000 * float64(i) / decodeSampleRate))
+	}
+
+	r := newResampler(1, decodeSampleRate, 16000)
+	var out []float32
+	for offset, size := 0, 1; offset < len(in); offset, size = offset+size, size*2 {
+		if offset+size > len(in) {
+			size = len(in) - offset
+		}
+		out = r.process(in[offset:offset+size], out)
+	}
+	out = r.flush(out)
+
+	if len(out) != 1600 {
+		t.Fatal(len(out))
+	}
+	for i := resamplerZeroCrossings; i < len(out)-resamplerZeroCrossings; i++ {
+		expected := math.Sin(2 * math.Pi * 1000 * float64(i) / 16000)
+		if math.Abs(float64(out[i])-expected) > 0.01 {
+			t.Fatalf("%d: %f != %f", i, out[i], expected)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "test.opus")
+	out := filepath.Join(dir, "test.wav")
+	if err := os.WriteFile(in, testStream(1, 0), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := run(in, out, options{rate: decodeSampleRate, seed: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The header holds the length of the data once it's known
+	file, err := os.Open(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	reader, err := wav.NewReader(file)
+	switch {
+	case err != nil:
+		t.Fatal(err)
+	case reader.Format() != wav.Format{SampleFormat: wav.Int16, Channels: 1, SampleRate: decodeSampleRate}:
+		t.Fatalf("%+v", reader.Format())
+	case reader.DataSize() != 2*testSampleCount:
+		t.Fatal(reader.DataSize())
+	}
+
+	if err := run(in, out, options{rate: 0}); err == nil {
+		t.Fatal("invalid rate accepted")
+	}
+}
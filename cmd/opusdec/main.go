@@ -0,0 +1,113 @@
+// Command opusdec decodes Ogg Opus files to WAV or raw PCM, like the
+// opusdec tool of opus-tools.
+//
+//	opusdec [flags] <file.opus> <file.wav>
+//
+// The file is decoded by opus.Stream, which applies the pre-skip and output
+// gain of the ID header and trims the stream to its granule positions.
+// Files with coupled stereo streams aren't supported by opus.Stream. WAV
+// files order the channels by speaker position, multichannel files use
+// WAVE_FORMAT_EXTENSIBLE with the channel mask of the Vorbis channel order.
+// A file of "-" reads from stdin or writes to stdout. A file that ends in
+// the middle of a page is written up to its last complete page, and
+// reported as an error.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// options configure how a stream is decoded and written
+type options struct {
+	// Sample rate of the output, the stream is decoded at 48 kHz and
+	// resampled when it differs
+	rate int
+
+	// Write 32-bit float samples instead of 16-bit integers
+	float bool
+
+	// Gain in dB, added to the output gain of the ID header
+	gain float64
+
+	// Disable the dither added when quantizing to 16 bits
+	noDither bool
+
+	// Percentage of packets that are dropped and concealed instead
+	packetLoss float64
+	seed       int64
+
+	// Write raw samples without a WAV header
+	raw bool
+}
+
+func main() {
+	var opts options
+	flag.IntVar(&opts.rate, "rate", decodeSampleRate, "sample rate of the output in Hz")
+	flag.BoolVar(&opts.float, "float", false, "write 32-bit float samples")
+	flag.Float64Var(&opts.gain, "gain", 0, "gain in dB, added to the output gain of the file")
+	flag.BoolVar(&opts.noDither, "no-dither", false, "don't dither 16-bit output")
+	flag.Float64Var(&opts.packetLoss, "packet-loss", 0, "percentage of packets to drop and conceal")
+	flag.Int64Var(&opts.seed, "seed", 1, "seed of the packet loss simulation")
+	flag.BoolVar(&opts.raw, "raw", false, "write raw samples without a WAV header")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] <file.opus> <file.wav>\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := run(flag.Arg(0), flag.Arg(1), opts); err != nil {
+		fmt.Fprintf(os.Stderr, "opusdec: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(inPath, outPath string, opts options) error {
+	if err := opts.validate(); err != nil {
+		return err
+	}
+
+	in := io.Reader(os.Stdin)
+	if inPath != "-" {
+		file, err := os.Open(inPath)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		in = file
+	}
+
+	if outPath == "-" {
+		return decode(in, os.Stdout, opts)
+	}
+
+	file, err := os.Create(outPath)
+	if err != nil {
+		return err
+	}
+
+	if err := decode(in, file, opts); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+func (o options) validate() error {
+	switch {
+	case o.rate <= 0:
+		return fmt.Errorf("%w: %d", errInvalidRate, o.rate)
+	case o.packetLoss < 0 || o.packetLoss > 100:
+		return fmt.Errorf("%w: %g", errInvalidPacketLoss, o.packetLoss)
+	}
+
+	return nil
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"math"
+)
+
+// Every output sample is interpolated from the input samples within this
+// many zero crossings of the sinc function on either side
+const resamplerZeroCrossings = 16
+
+// resampler converts interleaved samples between sample rates with a
+// Hann windowed sinc interpolator. When downsampling the cutoff is lowered
+// to the Nyquist frequency of the output to prevent aliasing.
+type resampler struct {
+	channels int
+	inRate   int64
+	outRate  int64
+
+	// cutoff relative to the Nyquist frequency of the input, and the
+	// number of input samples on either side of an output sample
+	cutoff   float64
+	halfTaps int64
+	weights  []float64
+
+	// history holds the input samples that are still needed, starting at
+	// the input sample offset
+	history     []float32
+	offset      int64
+	inputCount  int64
+	outputCount int64
+}
+
+func newResampler(channels, inRate, outRate int) *resampler {
+	cutoff := 1.0
+	if outRate < inRate {
+		cutoff = float64(outRate) / float64(inRate)
+	}
+	halfTaps := int64(math.Ceil(resamplerZeroCrossings / cutoff))
+
+	return &resampler{
+		channels: channels,
+		inRate:   int64(inRate),
+		outRate:  int64(outRate),
+		cutoff:   cutoff,
+		halfTaps: halfTaps,
+		weights:  make([]float64, 2*halfTaps),
+	}
+}
+
+// process appends the output samples that can be computed from the input
+// so far to out
+func (r *resampler) process(in []float32, out []float32) []float32 {
+	r.history = append(r.history, in...)
+	r.inputCount += int64(len(in) / r.channels)
+
+	return r.resample(out, false)
+}
+
+// flush appends the remaining output samples to out, the input is padded
+// with silence
+func (r *resampler) flush(out []float32) []float32 {
+	return r.resample(out, true)
+}
+
+func (r *resampler) resample(out []float32, flush bool) []float32 {
+	// The output covers the same duration as the input
+	outputEnd := (r.inputCount*r.outRate + r.inRate - 1) / r.inRate
+
+	for ; r.outputCount < outputEnd; r.outputCount++ {
+		// The position of the output sample in input samples
+		center := r.outputCount * r.inRate / r.outRate
+		fraction := float64(r.outputCount*r.inRate%r.outRate) / float64(r.outRate)
+		if !flush && center+r.halfTaps >= r.inputCount {
+			break
+		}
+
+		first := center - r.halfTaps + 1
+		for i := range r.weights {
+			x := float64(first+int64(i)-center) - fraction
+			r.weights[i] = r.cutoff * sinc(r.cutoff*x) * (0.5 + 0.5*math.Cos(math.Pi*x/float64(r.halfTaps)))
+		}
+
+		for c := 0; c < r.channels; c++ {
+			sum := 0.0
+			for i, weight := range r.weights {
+				index := first + int64(i)
+				if index < r.offset || index >= r.inputCount {
+					continue
+				}
+				sum += weight * float64(r.history[(index-r.offset)*int64(r.channels)+int64(c)])
+			}
+			out = append(out, float32(sum))
+		}
+	}
+
+	// Drop the input samples before the first one needed by the next
+	// output sample
+	first := r.outputCount*r.inRate/r.outRate - r.halfTaps + 1
+	if drop := first - r.offset; drop > 0 {
+		if available := int64(len(r.history) / r.channels); drop > available {
+			drop = available
+		}
+		r.history = r.history[:copy(r.history, r.history[drop*int64(r.channels):])]
+		r.offset += drop
+	}
+
+	return out
+}
+
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+
+	return math.Sin(math.Pi*x) / (math.Pi * x)
+}
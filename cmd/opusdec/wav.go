@@ -0,0 +1,41 @@
+package main
+
+import (
+	"github.com/pion/opus/pkg/wav"
+)
+
+// Channel mapping family 1 defines the channel order for up to eight
+// channels
+//
+// https://datatracker.ietf.org/doc/html/rfc7845#section-5.1.1.2
+const maxWAVOrderChannels = 8
+
+// The speaker positions of the channel orders of channel mapping family 1
+// for one to eight channels
+var wavChannelMasks = [maxWAVOrderChannels]uint32{
+	wav.SpeakerFrontCenter,
+	wav.SpeakerFrontLeft | wav.SpeakerFrontRight,
+	wav.SpeakerFrontLeft | wav.SpeakerFrontRight | wav.SpeakerFrontCenter,
+	wav.SpeakerFrontLeft | wav.SpeakerFrontRight | wav.SpeakerBackLeft | wav.SpeakerBackRight,
+	wav.SpeakerFrontLeft | wav.SpeakerFrontRight | wav.SpeakerFrontCenter | wav.SpeakerBackLeft | wav.SpeakerBackRight,
+	wav.SpeakerFrontLeft | wav.SpeakerFrontRight | wav.SpeakerFrontCenter | wav.SpeakerLowFrequency |
+		wav.SpeakerBackLeft | wav.SpeakerBackRight,
+	wav.SpeakerFrontLeft | wav.SpeakerFrontRight | wav.SpeakerFrontCenter | wav.SpeakerLowFrequency |
+		wav.SpeakerBackCenter | wav.SpeakerSideLeft | wav.SpeakerSideRight,
+	wav.SpeakerFrontLeft | wav.SpeakerFrontRight | wav.SpeakerFrontCenter | wav.SpeakerLowFrequency |
+		wav.SpeakerBackLeft | wav.SpeakerBackRight | wav.SpeakerSideLeft | wav.SpeakerSideRight,
+}
+
+// WAV files order the channels by their speaker position, while Opus uses
+// the Vorbis channel order. Every entry maps a WAV channel to the channel
+// of the decoded stream.
+var wavChannelOrders = [maxWAVOrderChannels][]int{
+	{0},
+	{0, 1},
+	{0, 2, 1},
+	{0, 1, 2, 3},
+	{0, 2, 1, 3, 4},
+	{0, 2, 1, 5, 3, 4},
+	{0, 2, 1, 6, 5, 3, 4},
+	{0, 2, 1, 7, 5, 6, 3, 4},
+}
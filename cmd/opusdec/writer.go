@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+	"math/rand"
+
+	"github.com/pion/opus/pkg/oggreader"
+	"github.com/pion/opus/pkg/wav"
+)
+
+// sampleWriter applies the gain to the decoded samples, resamples them to
+// the output rate and writes them as 16-bit integers or 32-bit floats
+type sampleWriter struct {
+	out      io.Writer
+	channels int
+	float    bool
+
+	// wav is nil when raw samples are written
+	wav    *wav.Writer
+	format wav.Format
+
+	// Linear gain of the gain option, the output gain of the ID header is
+	// applied by the stream
+	gain float32
+
+	// order maps every output channel to a decoded channel, it's nil when
+	// the channels are written in decoding order
+	order []int
+
+	// resampler is nil when the output is written at 48 kHz
+	resampler *resampler
+	resampled []float32
+
+	// TPDF dither is added before quantizing to 16 bits unless disabled
+	dither bool
+	random *rand.Rand
+
+	buffer []byte
+}
+
+func newSampleWriter(out io.Writer, header *oggreader.OggHeader, opts options) *sampleWriter {
+	w := &sampleWriter{
+		out:      out,
+		channels: int(header.Channels),
+		float:    opts.float,
+		format: wav.Format{
+			SampleFormat: wav.Int16,
+			Channels:     int(header.Channels),
+			SampleRate:   opts.rate,
+		},
+		gain:   float32(math.Pow(10, opts.gain/20)),
+		dither: !opts.float && !opts.noDither,
+		random: rand.New(rand.NewSource(opts.seed)),
+	}
+	if opts.float {
+		w.format.SampleFormat = wav.Float32
+	}
+
+	// Only the channels of families 0 and 1 have speaker positions, the
+	// mask is only needed for more than two channels
+	if w.channels <= maxWAVOrderChannels && (header.ChannelMap == oggreader.ChannelMappingFamilyRTP || header.ChannelMap == oggreader.ChannelMappingFamilyVorbis) {
+		if w.channels > 2 {
+			w.format.ChannelMask = wavChannelMasks[w.channels-1]
+		}
+		if !opts.raw {
+			w.order = wavChannelOrders[w.channels-1]
+		}
+	}
+
+	if opts.rate != decodeSampleRate {
+		w.resampler = newResampler(w.channels, decodeSampleRate, opts.rate)
+	}
+
+	return w
+}
+
+// start writes the WAV header, unless raw samples are written
+func (w *sampleWriter) start(raw bool) error {
+	if raw {
+		return nil
+	}
+
+	writer, err := wav.NewWriter(w.out, w.format)
+	if err != nil {
+		return err
+	}
+
+	w.wav, w.out = writer, writer
+	return nil
+}
+
+// write writes samples at 48 kHz, the gain is applied in place
+func (w *sampleWriter) write(samples []float32) error {
+	if w.gain != 1 {
+		for i := range samples {
+			samples[i] *= w.gain
+		}
+	}
+
+	if w.resampler != nil {
+		w.resampled = w.resampler.process(samples, w.resampled[:0])
+		samples = w.resampled
+	}
+
+	return w.encode(samples)
+}
+
+// finish writes the samples held back by the resampler and completes the
+// WAV file
+func (w *sampleWriter) finish() error {
+	if w.resampler != nil {
+		w.resampled = w.resampler.flush(w.resampled[:0])
+		if err := w.encode(w.resampled); err != nil {
+			return err
+		}
+	}
+
+	if w.wav != nil {
+		return w.wav.Close()
+	}
+
+	return nil
+}
+
+func (w *sampleWriter) encode(samples []float32) error {
+	bytesPerSample := w.format.SampleFormat.BytesPerSample()
+	if cap(w.buffer) < len(samples)*bytesPerSample {
+		w.buffer = make([]byte, len(samples)*bytesPerSample)
+	}
+	out := w.buffer[:len(samples)*bytesPerSample]
+
+	for i := 0; i < len(samples); i += w.channels {
+		for c := 0; c < w.channels; c++ {
+			sample := samples[i+c]
+			if w.order != nil {
+				sample = samples[i+w.order[c]]
+			}
+
+			offset := (i + c) * bytesPerSample
+			if w.float {
+				binary.LittleEndian.PutUint32(out[offset:], math.Float32bits(sample))
+			} else {
+				binary.LittleEndian.PutUint16(out[offset:], uint16(w.quantize(sample)))
+			}
+		}
+	}
+
+	_, err := w.out.Write(out)
+	return err
+}
+
+// quantize converts a sample to 16 bits. The dither has a triangular
+// distribution with a peak amplitude of one step.
+func (w *sampleWriter) quantize(sample float32) int16 {
+	v := float64(sample) * 32768
+	if w.dither {
+		v += w.random.Float64() - w.random.Float64()
+	}
+
+	return int16(math.Max(math.MinInt16, math.Min(math.Round(v), math.MaxInt16)))
+}
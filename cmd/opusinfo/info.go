@@ -0,0 +1,383 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/pion/opus"
+	"github.com/pion/opus/pkg/oggreader"
+)
+
+const (
+	// Granule positions count samples at 48 kHz
+	//
+	// https://datatracker.ietf.org/doc/html/rfc7845#section-4
+	granuleRate = 48000
+
+	maxSilkOnlyConfiguration = 11
+	maxHybridConfiguration   = 15
+)
+
+var (
+	errBadTagsSignature = errors.New("OpusTags packet has a bad signature")
+	errShortTags        = errors.New("OpusTags packet is too short")
+)
+
+// streamInfo is everything opusinfo reports about an Ogg Opus stream
+type streamInfo struct {
+	Head headInfo  `json:"head"`
+	Tags *tagsInfo `json:"tags,omitempty"`
+
+	Pages   pageInfo   `json:"pages"`
+	Packets packetInfo `json:"packets"`
+
+	// The duration of the decoded audio after pre-skip and end trimming,
+	// in seconds
+	Duration float64     `json:"duration"`
+	Bitrate  bitrateInfo `json:"bitrate"`
+
+	Histograms histograms `json:"histograms"`
+
+	// Problems that didn't stop reading the stream
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// https://datatracker.ietf.org/doc/html/rfc7845#section-5.1
+type headInfo struct {
+	Version         uint8   `json:"version"`
+	Channels        uint8   `json:"channels"`
+	PreSkip         uint16  `json:"preSkip"`
+	InputSampleRate uint32  `json:"inputSampleRate"`
+	OutputGain      float64 `json:"outputGain"`
+
+	ChannelMappingFamily uint8   `json:"channelMappingFamily"`
+	StreamCount          uint8   `json:"streamCount,omitempty"`
+	CoupledCount         uint8   `json:"coupledCount,omitempty"`
+	ChannelMapping       []uint8 `json:"channelMapping,omitempty"`
+}
+
+// https://datatracker.ietf.org/doc/html/rfc7845#section-5.2
+type tagsInfo struct {
+	Vendor   string   `json:"vendor"`
+	Comments []string `json:"comments"`
+}
+
+type pageInfo struct {
+	Count          int `json:"count"`
+	SequenceGaps   int `json:"sequenceGaps"`
+	GranuleErrors  int `json:"granuleErrors"`
+	ChecksumErrors int `json:"checksumErrors"`
+}
+
+type packetInfo struct {
+	Count   int `json:"count"`
+	Invalid int `json:"invalid"`
+	Bytes   int `json:"bytes"`
+}
+
+// Bitrates in bits per second, the minimum and maximum are those of a
+// single packet
+type bitrateInfo struct {
+	Min     float64 `json:"min"`
+	Average float64 `json:"average"`
+	Max     float64 `json:"max"`
+}
+
+type histograms struct {
+	Configuration histogram `json:"configuration"`
+	Mode          histogram `json:"mode"`
+	Bandwidth     histogram `json:"bandwidth"`
+	FrameSize     histogram `json:"frameSize"`
+	FrameCode     histogram `json:"frameCode"`
+}
+
+type histogram []histogramBin
+
+type histogramBin struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+
+	order int
+}
+
+// add counts value, bins are sorted by order
+func (h *histogram) add(order int, value string) {
+	for i := range *h {
+		if (*h)[i].Value == value {
+			(*h)[i].Count++
+			return
+		}
+	}
+
+	*h = append(*h, histogramBin{Value: value, Count: 1, order: order})
+	sort.SliceStable(*h, func(i, j int) bool {
+		return (*h)[i].order < (*h)[j].order
+	})
+}
+
+// inspector collects the streamInfo while the pages of a stream are read
+type inspector struct {
+	info   streamInfo
+	header *oggreader.OggHeader
+
+	packets     oggreader.PacketAssembler
+	havePackets bool
+
+	haveSequence bool
+	sequence     uint32
+
+	haveGranule    bool
+	granule        uint64
+	packetDuration time.Duration
+	totalDuration  time.Duration
+}
+
+// inspect reads an Ogg Opus stream from in and reports on it
+func inspect(in io.Reader) (*streamInfo, error) {
+	reader, header, err := oggreader.NewWith(in)
+	if err != nil {
+		return nil, err
+	}
+
+	i := &inspector{header: header}
+	i.info.Head = headInfo{
+		Version:              header.Version,
+		Channels:             header.Channels,
+		PreSkip:              header.PreSkip,
+		InputSampleRate:      header.SampleRate,
+		OutputGain:           float64(int16(header.OutputGain)) / 256,
+		ChannelMappingFamily: header.ChannelMap,
+		StreamCount:          header.StreamCount,
+		CoupledCount:         header.CoupledCount,
+		ChannelMapping:       header.ChannelMapping,
+	}
+	i.info.Pages.Count = 1
+
+	for {
+		segments, pageHeader, err := reader.ParseNextPageNoCopy()
+		switch {
+		case errors.Is(err, io.EOF):
+			i.finish()
+			return &i.info, nil
+		case errors.Is(err, io.ErrUnexpectedEOF):
+			i.warn("the stream ends in the middle of a page")
+			i.finish()
+			return &i.info, nil
+		case errors.Is(err, oggreader.ErrChecksumMismatch):
+			// The page is lost, and with it any packet that continues on
+			// the next page
+			i.info.Pages.Count++
+			i.info.Pages.ChecksumErrors++
+			i.haveSequence = false
+			i.packets.DropPage()
+			continue
+		case err != nil:
+			return nil, err
+		}
+
+		if err := i.inspectPage(segments, pageHeader); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (i *inspector) inspectPage(segments [][]byte, pageHeader *oggreader.OggPageHeader) error {
+	i.info.Pages.Count++
+
+	if i.haveSequence && pageHeader.PageSequence() != i.sequence+1 {
+		i.info.Pages.SequenceGaps++
+	}
+	i.haveSequence = true
+	i.sequence = pageHeader.PageSequence()
+
+	if !pageHeader.IsContinuation() && i.packets.Incomplete() {
+		i.warn("a packet is missing its continuation")
+	}
+
+	pageDuration := time.Duration(0)
+	packetsEnded := 0
+	err := i.packets.AddPage(segments, pageHeader, func(packet []byte) error {
+		pageDuration += i.inspectPacket(packet)
+		packetsEnded++
+		return nil
+	})
+
+	i.checkGranule(pageHeader, packetsEnded, pageDuration)
+	return err
+}
+
+// inspectPacket adds a packet to the statistics and returns its duration
+func (i *inspector) inspectPacket(in []byte) time.Duration {
+	// The first packet after the ID header is the comment header
+	if !i.havePackets {
+		tags, err := parseTags(in)
+		if err != nil {
+			i.warn(err.Error())
+		}
+		i.info.Tags = tags
+		i.havePackets = true
+		return 0
+	}
+
+	// In multistream packets all but the last stream use self-delimited
+	// framing, the first stream describes the packet
+	var (
+		packet opus.Packet
+		err    error
+	)
+	if i.header.StreamCount > 1 {
+		packet, _, err = opus.ParseSelfDelimitedPacket(in)
+	} else {
+		packet, err = opus.ParsePacket(in)
+	}
+
+	i.info.Packets.Count++
+	i.info.Packets.Bytes += len(in)
+	if err != nil {
+		i.info.Packets.Invalid++
+		return 0
+	}
+
+	duration := packet.Duration()
+	i.totalDuration += duration
+	if duration > 0 {
+		bitrate := float64(len(in)*8) / duration.Seconds()
+		if i.info.Bitrate.Max == 0 || bitrate < i.info.Bitrate.Min {
+			i.info.Bitrate.Min = bitrate
+		}
+		if bitrate > i.info.Bitrate.Max {
+			i.info.Bitrate.Max = bitrate
+		}
+	}
+
+	cfg := packet.Configuration()
+	h := &i.info.Histograms
+	h.Configuration.add(int(cfg), strconv.Itoa(int(cfg)))
+	switch {
+	case cfg <= maxSilkOnlyConfiguration:
+		h.Mode.add(0, "SILK-only")
+	case cfg <= maxHybridConfiguration:
+		h.Mode.add(1, "Hybrid")
+	default:
+		h.Mode.add(2, "CELT-only")
+	}
+	h.Bandwidth.add(int(packet.Bandwidth()), packet.Bandwidth().String())
+	h.FrameSize.add(int(packet.FrameDuration()), packet.FrameDuration().String())
+
+	frameCode := int(packet.TOC & 0b11)
+	h.FrameCode.add(frameCode, frameCodeLabels[frameCode])
+
+	return duration
+}
+
+// https://datatracker.ietf.org/doc/html/rfc6716#section-3.2
+var frameCodeLabels = [...]string{
+	"0 (1 frame)",
+	"1 (2 frames, equal size)",
+	"2 (2 frames, different size)",
+	"3 (arbitrary frames)",
+}
+
+// The granule position of a page is the position of the last sample of
+// the last packet that ends on it. It grows by the duration of the packets
+// ending on the page, except that the first audio page may start at a later
+// position, and the last page may be trimmed.
+//
+// https://datatracker.ietf.org/doc/html/rfc7845#section-4
+func (i *inspector) checkGranule(pageHeader *oggreader.OggPageHeader, packetsEnded int, pageDuration time.Duration) {
+	granule := pageHeader.GranulePosition
+	if packetsEnded == 0 {
+		if granule != oggreader.NoGranulePosition {
+			i.info.Pages.GranuleErrors++
+		}
+		return
+	}
+
+	// The page of the comment header has a granule position of zero
+	if pageDuration == 0 && !i.haveGranule {
+		if granule != 0 {
+			i.info.Pages.GranuleErrors++
+		}
+		return
+	}
+
+	expected := i.granule + uint64(pageDuration*granuleRate/time.Second)
+	switch {
+	case !i.haveGranule:
+		i.haveGranule = true
+	case granule == expected:
+	case granule < expected && pageHeader.IsEndOfStream():
+	default:
+		i.info.Pages.GranuleErrors++
+	}
+
+	i.granule = granule
+}
+
+func (i *inspector) finish() {
+	if i.packets.Incomplete() {
+		i.warn("the last packet is incomplete")
+	}
+
+	if preSkip := uint64(i.header.PreSkip); i.granule > preSkip {
+		i.info.Duration = float64(i.granule-preSkip) / granuleRate
+	}
+
+	if i.totalDuration > 0 {
+		i.info.Bitrate.Average = float64(i.info.Packets.Bytes*8) / i.totalDuration.Seconds()
+	}
+}
+
+func (i *inspector) warn(warning string) {
+	i.info.Warnings = append(i.info.Warnings, warning)
+}
+
+// The comment header consists of the "OpusTags" signature, followed by a
+// vendor string and a list of user comments. Every string is preceded by
+// its length as a 32-bit little-endian integer.
+//
+// https://datatracker.ietf.org/doc/html/rfc7845#section-5.2
+func parseTags(in []byte) (*tagsInfo, error) {
+	if !oggreader.IsCommentHeader(in) {
+		return nil, errBadTagsSignature
+	}
+	in = in[len(oggreader.CommentHeaderSignature):]
+
+	readString := func() (string, bool) {
+		if len(in) < 4 {
+			return "", false
+		}
+		length := binary.LittleEndian.Uint32(in)
+		in = in[4:]
+		if uint64(len(in)) < uint64(length) {
+			return "", false
+		}
+
+		s := string(in[:length])
+		in = in[length:]
+		return s, true
+	}
+
+	vendor, ok := readString()
+	if !ok || len(in) < 4 {
+		return nil, errShortTags
+	}
+
+	tags := &tagsInfo{Vendor: vendor, Comments: []string{}}
+	count := binary.LittleEndian.Uint32(in)
+	in = in[4:]
+	for j := uint32(0); j < count; j++ {
+		comment, ok := readString()
+		if !ok {
+			return tags, fmt.Errorf("%w: %d of %d comments", errShortTags, j, count)
+		}
+		tags.Comments = append(tags.Comments, comment)
+	}
+
+	return tags, nil
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pion/opus/pkg/oggreader"
+)
+
+const (
+	headerTypeContinuation = 0x01
+	headerTypeBOS          = 0x02
+	headerTypeEOS          = 0x04
+	preSkip                = 312
+
+	// A packet continues in the next segment when the lacing value of its
+	// segment is 255
+	maxSegmentSize = 255
+)
+
+func appendUint16(b []byte, v uint16) []byte {
+	return append(b, byte(v), byte(v>>8))
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	return appendUint32(appendUint32(b, uint32(v)), uint32(v>>32))
+}
+
+// lace splits a packet into segments, a packet that fills its last segment
+// is terminated by an empty one
+func lace(packet []byte) [][]byte {
+	var segments [][]byte
+	for len(packet) >= maxSegmentSize {
+		segments = append(segments, packet[:maxSegmentSize])
+		packet = packet[maxSegmentSize:]
+	}
+
+	return append(segments, packet)
+}
+
+// oggPage builds an Ogg page with a valid checksum
+func oggPage(headerType byte, granule uint64, sequence uint32, segments ...[]byte) []byte {
+	page := []byte("OggS")
+	page = append(page, 0, headerType)
+	page = appendUint64(page, granule)
+	page = appendUint32(page, 0x1234)
+	page = appendUint32(page, sequence)
+	page = append(page, 0, 0, 0, 0, byte(len(segments)))
+	for _, segment := range segments {
+		page = append(page, byte(len(segment)))
+	}
+	for _, segment := range segments {
+		page = append(page, segment...)
+	}
+
+	var checksum uint32
+	for _, v := range page {
+		checksum ^= uint32(v) << 24
+		for i := 0; i < 8; i++ {
+			if checksum&0x80000000 != 0 {
+				checksum = (checksum << 1) ^ 0x04c11db7
+			} else {
+				checksum <<= 1
+			}
+		}
+	}
+	binary.LittleEndian.PutUint32(page[22:], checksum)
+
+	return page
+}
+
+func opusHead() []byte {
+	head := []byte("OpusHead")
+	head = append(head, 1, 1)
+	head = appendUint16(head, preSkip)
+	head = appendUint32(head, 16000)
+	head = appendUint16(head, 0x0180)
+
+	return append(head, 0)
+}
+
+func opusTags(vendor string, comments ...string) []byte {
+	tags := []byte(oggreader.CommentHeaderSignature)
+	tags = appendUint32(tags, uint32(len(vendor)))
+	tags = append(tags, vendor...)
+	tags = appendUint32(tags, uint32(len(comments)))
+	for _, comment := range comments {
+		tags = appendUint32(tags, uint32(len(comment)))
+		tags = append(tags, comment...)
+	}
+
+	return tags
+}
+
+// A 20 ms wideband SILK-only packet, and a 10 ms fullband CELT-only packet
+// with two frames of equal size
+func testPackets() (silk, celt []byte) {
+	silk = []byte{0x48, 0x0B, 0xE4, 0xC1, 0x36, 0xEC, 0xC5, 0x80}
+	celt = []byte{0xF1, 0x01, 0x02, 0x03, 0x04}
+	return
+}
+
+func testStream() []byte {
+	silk, celt := testPackets()
+	largePacket := append([]byte{0x48}, bytes.Repeat([]byte{0xAA}, 599)...)
+	largeSegments := lace(largePacket)
+
+	var stream []byte
+	for _, page := range [][]byte{
+		oggPage(headerTypeBOS, 0, 0, opusHead()),
+		oggPage(0, 0, 1, lace(opusTags("test vendor", "TITLE=Test", "ARTIST=Tester"))...),
+		oggPage(0, preSkip+1920, 2, silk, silk),
+		oggPage(0, oggreader.NoGranulePosition, 3, largeSegments[:2]...),
+		oggPage(headerTypeContinuation, preSkip+1920+1920, 4, largeSegments[2], celt),
+		// The last page is trimmed to 5 ms
+		oggPage(headerTypeEOS, preSkip+1920+1920+240, 5, silk),
+	} {
+		stream = append(stream, page...)
+	}
+
+	return stream
+}
+
+func TestInspect(t *testing.T) {
+	info, err := inspect(bytes.NewReader(testStream()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	switch {
+	case !reflect.DeepEqual(info.Head, headInfo{Version: 1, Channels: 1, PreSkip: preSkip, InputSampleRate: 16000, OutputGain: 1.5}):
+		t.Fatalf("%+v", info.Head)
+	case info.Tags == nil || info.Tags.Vendor != "test vendor":
+		t.Fatal(info.Tags)
+	case !reflect.DeepEqual(info.Tags.Comments, []string{"TITLE=Test", "ARTIST=Tester"}):
+		t.Fatal(info.Tags.Comments)
+	case info.Pages != pageInfo{Count: 6}:
+		t.Fatalf("%+v", info.Pages)
+	case info.Packets != packetInfo{Count: 5, Bytes: 3*8 + 600 + 5}:
+		t.Fatalf("%+v", info.Packets)
+	case info.Duration != float64(1920+1920+240)/granuleRate:
+		t.Fatal(info.Duration)
+	case info.Bitrate.Min != 8*5/0.02 || info.Bitrate.Max != 8*600/0.02:
+		t.Fatalf("%+v", info.Bitrate)
+	case len(info.Warnings) != 0:
+		t.Fatal(info.Warnings)
+	}
+
+	for _, test := range []struct {
+		Histogram histogram
+		Expected  histogram
+	}{
+		{info.Histograms.Configuration, histogram{{Value: "9", Count: 4, order: 9}, {Value: "30", Count: 1, order: 30}}},
+		{info.Histograms.Mode, histogram{{Value: "SILK-only", Count: 4, order: 0}, {Value: "CELT-only", Count: 1, order: 2}}},
+		{info.Histograms.Bandwidth, histogram{{Value: "Wideband", Count: 4, order: 3}, {Value: "Fullband", Count: 1, order: 5}}},
+		{info.Histograms.FrameCode, histogram{{Value: frameCodeLabels[0], Count: 4, order: 0}, {Value: frameCodeLabels[1], Count: 1, order: 1}}},
+	} {
+		if !reflect.DeepEqual(test.Histogram, test.Expected) {
+			t.Fatalf("%+v != %+v", test.Histogram, test.Expected)
+		}
+	}
+
+	if sizes := info.Histograms.FrameSize; len(sizes) != 2 || sizes[0].Value != "10ms" || sizes[1].Value != "20ms" {
+		t.Fatalf("%+v", sizes)
+	}
+}
+
+func TestInspectErrors(t *testing.T) {
+	silk, _ := testPackets()
+
+	corrupted := oggPage(0, preSkip+1920, 2, silk, silk)
+	corrupted[len(corrupted)-1] ^= 0xFF
+
+	var stream []byte
+	for _, page := range [][]byte{
+		oggPage(headerTypeBOS, 0, 0, opusHead()),
+		oggPage(0, 0, 1, opusTags("test vendor")),
+		corrupted,
+		oggPage(0, preSkip+1920+960, 3, silk),
+		// Page 4 is missing, and the granule position should grow by 960
+		oggPage(0, preSkip+1920+960+1000, 5, silk),
+		oggPage(0, preSkip+1920+960+1000, 6, []byte{0x4B}),
+	} {
+		stream = append(stream, page...)
+	}
+	// The last page is cut off
+	stream = append(stream, oggPage(0, 0, 7, silk)[:10]...)
+
+	info, err := inspect(bytes.NewReader(stream))
+	switch {
+	case err != nil:
+		t.Fatal(err)
+	case info.Pages != pageInfo{Count: 6, SequenceGaps: 1, GranuleErrors: 1, ChecksumErrors: 1}:
+		t.Fatalf("%+v", info.Pages)
+	case info.Packets.Count != 3 || info.Packets.Invalid != 1:
+		t.Fatalf("%+v", info.Packets)
+	case len(info.Warnings) != 1:
+		t.Fatal(info.Warnings)
+	}
+}
+
+func TestRun(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.opus")
+	if err := os.WriteFile(path, testStream(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := run(path, true, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	var info streamInfo
+	switch err := json.Unmarshal(out.Bytes(), &info); {
+	case err != nil:
+		t.Fatal(err)
+	case info.Packets.Count != 5 || info.Tags.Vendor != "test vendor":
+		t.Fatal(out.String())
+	case len(info.Histograms.Mode) != 2 || info.Histograms.Mode[1].Value != "CELT-only":
+		t.Fatal(out.String())
+	}
+
+	out.Reset()
+	if err := run(path, false, &out); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{"Pre-skip: 312", "TITLE=Test", "Pages: 6 (0 sequence gaps", "CELT-only"} {
+		if !strings.Contains(out.String(), expected) {
+			t.Fatalf("%q is missing %q", out.String(), expected)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+// Command opusinfo prints information about Ogg Opus files, like the
+// opusinfo tool of opus-tools.
+//
+//	opusinfo [-json] <file.opus>
+//
+// It prints the ID and comment headers, checks the page sequence numbers,
+// granule positions and checksums, and counts the configurations, modes,
+// bandwidths, frame sizes and frame codes of the packets. A file of "-"
+// reads from stdin.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+func main() {
+	jsonOutput := flag.Bool("json", false, "print the information as JSON")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [-json] <file.opus>\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := run(flag.Arg(0), *jsonOutput, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "opusinfo: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(path string, jsonOutput bool, out io.Writer) error {
+	in := io.Reader(os.Stdin)
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		in = file
+	}
+
+	info, err := inspect(in)
+	if err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(info)
+	}
+
+	return printInfo(out, info)
+}
+
+func printInfo(out io.Writer, info *streamInfo) error {
+	var b strings.Builder
+
+	h := info.Head
+	fmt.Fprintf(&b, "Opus stream\n")
+	fmt.Fprintf(&b, "\tVersion: %d\n", h.Version)
+	fmt.Fprintf(&b, "\tChannels: %d\n", h.Channels)
+	fmt.Fprintf(&b, "\tPre-skip: %d\n", h.PreSkip)
+	fmt.Fprintf(&b, "\tInput sample rate: %d Hz\n", h.InputSampleRate)
+	fmt.Fprintf(&b, "\tOutput gain: %.2f dB\n", h.OutputGain)
+	fmt.Fprintf(&b, "\tChannel mapping family: %d\n", h.ChannelMappingFamily)
+	if h.ChannelMappingFamily != 0 {
+		fmt.Fprintf(&b, "\tStreams: %d (%d coupled)\n", h.StreamCount, h.CoupledCount)
+		if h.ChannelMapping != nil {
+			fmt.Fprintf(&b, "\tChannel mapping: %v\n", h.ChannelMapping)
+		}
+	}
+
+	if info.Tags != nil {
+		fmt.Fprintf(&b, "Tags\n")
+		fmt.Fprintf(&b, "\tVendor: %s\n", info.Tags.Vendor)
+		for _, comment := range info.Tags.Comments {
+			fmt.Fprintf(&b, "\t%s\n", comment)
+		}
+	}
+
+	p := info.Pages
+	fmt.Fprintf(&b, "Pages: %d (%d sequence gaps, %d granule position errors, %d checksum errors)\n",
+		p.Count, p.SequenceGaps, p.GranuleErrors, p.ChecksumErrors)
+	fmt.Fprintf(&b, "Packets: %d (%d invalid), %d bytes\n", info.Packets.Count, info.Packets.Invalid, info.Packets.Bytes)
+	fmt.Fprintf(&b, "Duration: %.3f s\n", info.Duration)
+	fmt.Fprintf(&b, "Bitrate: %.1f kbit/s average, %.1f kbit/s min, %.1f kbit/s max\n",
+		info.Bitrate.Average/1000, info.Bitrate.Min/1000, info.Bitrate.Max/1000)
+
+	for _, histogram := range []struct {
+		Name string
+		Bins histogram
+	}{
+		{"Configuration", info.Histograms.Configuration},
+		{"Mode", info.Histograms.Mode},
+		{"Bandwidth", info.Histograms.Bandwidth},
+		{"Frame size", info.Histograms.FrameSize},
+		{"Frame code", info.Histograms.FrameCode},
+	} {
+		fmt.Fprintf(&b, "%s\n", histogram.Name)
+		for _, bin := range histogram.Bins {
+			fmt.Fprintf(&b, "\t%-30s %8d (%5.1f%%)\n", bin.Value, bin.Count, 100*float64(bin.Count)/float64(info.Packets.Count))
+		}
+	}
+
+	for _, warning := range info.Warnings {
+		fmt.Fprintf(&b, "Warning: %s\n", warning)
+	}
+
+	_, err := io.WriteString(out, b.String())
+	return err
+}
@@ -2,56 +2,370 @@ package opus
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/pion/opus/internal/bitdepth"
 	"github.com/pion/opus/internal/silk"
 )
 
+const (
+	// The decoder always outputs 48 kHz, 16-bit mono PCM
+	outputSampleRate = 48000
+	bytesPerSample   = 2
+
+	// Frames this short carry no audio, they signal DTX
+	maxDTXFrameSize = 1
+)
+
 // Decoder decodes the Opus bitstream into PCM
 type Decoder struct {
 	silkDecoder silk.Decoder
 	silkBuffer  []float32
+
+	// Frames of the packet being decoded, reused between packets
+	frames [][]byte
+
+	// The configuration of the most recently decoded packet, used
+	// to conceal lost packets.
+	haveDecoded           bool
+	previousConfiguration Configuration
+
+	// Is the stream in a period of discontinuous transmission?
+	inDTX bool
+
+	finalRange uint32
+
+	// Did any frame of the most recent packet have its VAD flag set?
+	voiceActivity bool
+
+	fixedPoint bool
+
+	// The SILK output of the fixed-point decoder resampled to 48 kHz
+	resampled []float32
+}
+
+// Options configures a Decoder
+type Options struct {
+	// FixedPoint selects a reconstruction of SILK frames with integer
+	// arithmetic modeled on the fixed-point reference decoder, resampled to
+	// 48 kHz by a port of the libopus resampler. Whether the output is
+	// bit-exact with libopus built with --enable-fixed-point is only
+	// checked against reference output, see pkg/conformance.
+	FixedPoint bool
 }
 
 // NewDecoder creates a new Opus Decoder
 func NewDecoder() Decoder {
-	return Decoder{
-		silkDecoder: silk.NewDecoder(),
+	return NewDecoderWithOptions(Options{})
+}
+
+// NewDecoderWithOptions creates a new Opus Decoder configured by options
+func NewDecoderWithOptions(options Options) Decoder {
+	silkDecoder := silk.NewDecoder()
+	if options.FixedPoint {
+		silkDecoder = silk.NewFixedPointDecoder()
+	}
+
+	d := Decoder{
+		silkDecoder: silkDecoder,
 		silkBuffer:  make([]float32, 320),
+		fixedPoint:  options.FixedPoint,
+	}
+	if options.FixedPoint {
+		d.resampled = make([]float32, 6*len(d.silkBuffer))
 	}
+
+	return d
 }
 
 // Decode decodes the Opus bitstream into PCM
 func (d *Decoder) Decode(in []byte, out []byte) (bandwidth Bandwidth, isStereo bool, err error) {
-	if len(in) < 1 {
-		return 0, false, errTooShortForTableOfContentsHeader
+	return d.decode(in, output{pcm: out})
+}
+
+// DecodeFloat32 decodes the Opus bitstream into float32 samples at 48 kHz,
+// between -1 and 1. The samples aren't quantized to 16 bits, which keeps
+// the precision for further processing like gain or resampling.
+func (d *Decoder) DecodeFloat32(in []byte, out []float32) (bandwidth Bandwidth, isStereo bool, err error) {
+	return d.decode(in, output{float: out})
+}
+
+func (d *Decoder) decode(in []byte, out output) (bandwidth Bandwidth, isStereo bool, err error) {
+	packet, _, err := parsePacket(in, false, d.frames)
+	if err != nil {
+		return 0, false, err
+	}
+	d.frames = packet.Frames
+
+	return d.decodeFrames(packet, out, d.decodeFrame)
+}
+
+// DecodeSelfDelimited decodes a packet using the self-delimiting framing
+// from the start of in. It returns the number of bytes the packet occupied,
+// which allows decoding a stream of back-to-back packets
+//
+//	for len(in) != 0 {
+//		n, _, _, err := decoder.DecodeSelfDelimited(in, out)
+//		...
+//		in = in[n:]
+//	}
+//
+// https://datatracker.ietf.org/doc/html/rfc6716#appendix-B
+func (d *Decoder) DecodeSelfDelimited(in []byte, out []byte) (n int, bandwidth Bandwidth, isStereo bool, err error) {
+	return d.decodeSelfDelimited(in, output{pcm: out})
+}
+
+// DecodeSelfDelimitedFloat32 is like DecodeSelfDelimited, but produces
+// float32 samples like DecodeFloat32
+func (d *Decoder) DecodeSelfDelimitedFloat32(in []byte, out []float32) (n int, bandwidth Bandwidth, isStereo bool, err error) {
+	return d.decodeSelfDelimited(in, output{float: out})
+}
+
+func (d *Decoder) decodeSelfDelimited(in []byte, out output) (n int, bandwidth Bandwidth, isStereo bool, err error) {
+	packet, n, err := parsePacket(in, true, d.frames)
+	if err != nil {
+		return 0, 0, false, err
+	}
+	d.frames = packet.Frames
+
+	bandwidth, isStereo, err = d.decodeFrames(packet, out, d.decodeFrame)
+	return n, bandwidth, isStereo, err
+}
+
+// DecodeFEC decodes the in-band forward error correction data of a packet.
+// The FEC data is a lower quality copy of the frame that preceded the
+// packet, so after a packet is lost its audio can be recovered from the
+// packet that followed it. The output contains a single frame of the same
+// duration as the frames of in. If in doesn't contain FEC data an error is
+// returned, and Conceal should be used instead.
+//
+// https://datatracker.ietf.org/doc/html/rfc6716#section-2.1.7
+func (d *Decoder) DecodeFEC(in []byte, out []byte) (bandwidth Bandwidth, isStereo bool, err error) {
+	return d.decodeFEC(in, output{pcm: out})
+}
+
+// DecodeFECFloat32 is like DecodeFEC, but produces float32 samples like
+// DecodeFloat32
+func (d *Decoder) DecodeFECFloat32(in []byte, out []float32) (bandwidth Bandwidth, isStereo bool, err error) {
+	return d.decodeFEC(in, output{float: out})
+}
+
+func (d *Decoder) decodeFEC(in []byte, out output) (bandwidth Bandwidth, isStereo bool, err error) {
+	packet, _, err := parsePacket(in, false, d.frames)
+	if err != nil {
+		return 0, false, err
+	}
+	d.frames = packet.Frames
+
+	// Only the first frame of a packet carries FEC data for the preceding frame
+	packet.Frames = packet.Frames[:1]
+	return d.decodeFrames(packet, out, func(in []byte, out []float32, isStereo bool, nanoseconds int, bandwidth silk.Bandwidth) error {
+		if err := d.silkDecoder.DecodeFEC(in, out, isStereo, nanoseconds, bandwidth); err != nil {
+			return err
+		}
+
+		d.voiceActivity = d.silkDecoder.VoiceActivityDetected()
+		return nil
+	})
+}
+
+// Conceal generates a frame of audio to replace a lost packet. The frame
+// has the configuration of the most recently decoded packet. Packet loss
+// concealment is not normative, the result only attempts to be a plausible
+// continuation of the previous audio.
+//
+// https://datatracker.ietf.org/doc/html/rfc6716#section-4.4
+func (d *Decoder) Conceal(out []byte) (bandwidth Bandwidth, isStereo bool, err error) {
+	return d.conceal(output{pcm: out})
+}
+
+// ConcealFloat32 is like Conceal, but produces float32 samples like
+// DecodeFloat32
+func (d *Decoder) ConcealFloat32(out []float32) (bandwidth Bandwidth, isStereo bool, err error) {
+	return d.conceal(output{float: out})
+}
+
+func (d *Decoder) conceal(out output) (bandwidth Bandwidth, isStereo bool, err error) {
+	if !d.haveDecoded {
+		return 0, false, errNoPacketDecoded
+	}
+
+	conceal := func(_ []byte, out []float32, isStereo bool, nanoseconds int, bandwidth silk.Bandwidth) error {
+		d.finalRange = 0
+		return d.silkDecoder.Conceal(out, nanoseconds, bandwidth)
+	}
+
+	// Packets missing during DTX are expected, the comfort noise continues
+	if d.inDTX {
+		conceal = d.decodeFrame
 	}
 
-	tocHeader := tableOfContentsHeader(in[0])
-	cfg := tocHeader.configuration()
+	d.frames = append(d.frames[:0], nil)
+	packet := Packet{
+		TOC:    byte(d.previousConfiguration) << 3,
+		Frames: d.frames,
+	}
+
+	return d.decodeFrames(packet, out, conceal)
+}
+
+// ComfortNoise fills out with duration of comfort noise, to cover a period
+// of discontinuous transmission (DTX) of any length. The noise matches the
+// background of the inactive frames decoded before the period started. It
+// returns the number of bytes written.
+//
+// https://datatracker.ietf.org/doc/html/rfc6716#section-2.1.9
+func (d *Decoder) ComfortNoise(out []byte, duration time.Duration) (n int, err error) {
+	if !d.haveDecoded {
+		return 0, errNoPacketDecoded
+	}
+
+	bandwidth := d.previousConfiguration.bandwidth()
+	sampleCount := int(time.Duration(bandwidth.SampleRate()) * duration / time.Second)
+	resampleCount := outputSampleRate / bandwidth.SampleRate()
+
+	n = sampleCount * resampleCount * bytesPerSample
+	if len(out) < n {
+		return 0, errOutBufferTooSmall
+	}
+
+	for offset := 0; sampleCount > 0; {
+		chunk := sampleCount
+		if chunk > len(d.silkBuffer) {
+			chunk = len(d.silkBuffer)
+		}
+
+		if err := d.silkDecoder.ComfortNoise(d.silkBuffer[:chunk], silk.Bandwidth(bandwidth)); err != nil {
+			return 0, err
+		}
+
+		if err := d.convertSamples(d.silkBuffer[:chunk], output{pcm: out}, offset, bandwidth); err != nil {
+			return 0, err
+		}
 
-	var encodedFrames [][]byte
-	switch tocHeader.frameCode() {
-	case frameCodeOneFrame:
-		encodedFrames = append(encodedFrames, in[1:])
-	default:
-		return 0, false, fmt.Errorf("%w: %d", errUnsupportedFrameCode, tocHeader.frameCode())
+		offset += chunk * resampleCount
+		sampleCount -= chunk
 	}
 
+	return n, nil
+}
+
+// FinalRange returns the final state of the range decoder after decoding the
+// most recent frame, or zero if the frame carried no data. Encoders report
+// the same value, comparing them verifies the frame was decoded correctly.
+//
+// https://datatracker.ietf.org/doc/html/rfc6716#section-4.1.6
+func (d *Decoder) FinalRange() uint32 {
+	return d.finalRange
+}
+
+// VoiceActivity reports if the encoder detected voice activity in any frame
+// of the most recently decoded packet. Concealed frames and comfort noise
+// have no voice activity.
+//
+// https://datatracker.ietf.org/doc/html/rfc6716#section-4.2.3
+func (d *Decoder) VoiceActivity() bool {
+	return d.voiceActivity
+}
+
+// decodeFrame decodes a single SILK frame. Frames of at most one byte carry
+// no audio, they are sent by encoders using DTX, and comfort noise is
+// produced in their place.
+func (d *Decoder) decodeFrame(in []byte, out []float32, isStereo bool, nanoseconds int, bandwidth silk.Bandwidth) error {
+	if len(in) <= maxDTXFrameSize {
+		d.inDTX = true
+		d.finalRange = 0
+
+		sampleCount := Bandwidth(bandwidth).SampleRate() * nanoseconds / int(time.Second)
+		if len(out) < sampleCount {
+			return errOutBufferTooSmall
+		}
+		return d.silkDecoder.ComfortNoise(out[:sampleCount], bandwidth)
+	}
+
+	d.inDTX = false
+	if err := d.silkDecoder.Decode(in, out, isStereo, nanoseconds, bandwidth); err != nil {
+		return err
+	}
+
+	d.finalRange = d.silkDecoder.FinalRange()
+	d.voiceActivity = d.voiceActivity || d.silkDecoder.VoiceActivityDetected()
+	return nil
+}
+
+func (d *Decoder) decodeFrames(
+	packet Packet, out output,
+	decodeFrame func(in []byte, out []float32, isStereo bool, nanoseconds int, bandwidth silk.Bandwidth) error,
+) (bandwidth Bandwidth, isStereo bool, err error) {
+	cfg := packet.Configuration()
 	if cfg.mode() != configurationModeSilkOnly {
 		return 0, false, fmt.Errorf("%w: %d", errUnsupportedConfigurationMode, cfg.mode())
 	}
 
-	for _, encodedFrame := range encodedFrames {
-		err := d.silkDecoder.Decode(encodedFrame, d.silkBuffer, tocHeader.isStereo(), cfg.frameDuration().nanoseconds(), silk.Bandwidth(cfg.bandwidth()))
+	// The SILK decoder produces audio at the internal sample rate of the
+	// bandwidth, which is converted to 48 kHz by convertSamples.
+	silkSampleCount := cfg.bandwidth().SampleRate() * int(packet.FrameDuration().Milliseconds()) / 1000
+	resampleCount := outputSampleRate / cfg.bandwidth().SampleRate()
+	frameSize := silkSampleCount * resampleCount
+
+	if out.sampleCount() < frameSize*len(packet.Frames) {
+		return 0, false, errOutBufferTooSmall
+	}
+
+	d.voiceActivity = false
+
+	for i, encodedFrame := range packet.Frames {
+		err := decodeFrame(encodedFrame, d.silkBuffer, packet.IsStereo(), cfg.frameDuration().nanoseconds(), silk.Bandwidth(cfg.bandwidth()))
 		if err != nil {
 			return 0, false, err
 		}
+
+		if err := d.convertSamples(d.silkBuffer[:silkSampleCount], out, i*frameSize, cfg.bandwidth()); err != nil {
+			return 0, false, err
+		}
 	}
 
-	if err := bitdepth.ConvertFloat32LittleEndianToSigned16LittleEndian(d.silkBuffer, out, 3); err != nil {
-		return 0, false, err
+	d.haveDecoded = true
+	d.previousConfiguration = cfg
+
+	return cfg.bandwidth(), packet.IsStereo(), nil
+}
+
+// output is the buffer decoded samples are written to, either 16-bit PCM
+// or float32 samples
+type output struct {
+	pcm   []byte
+	float []float32
+}
+
+func (o output) sampleCount() int {
+	if o.float != nil {
+		return len(o.float)
+	}
+
+	return len(o.pcm) / bytesPerSample
+}
+
+// convertSamples writes the SILK output at the sample rate of bandwidth to
+// out starting at sample offset. Every sample is repeated to reach 48 kHz,
+// the output of the fixed-point decoder is resampled like libopus does
+// instead, and converted back to the exact 16-bit values.
+func (d *Decoder) convertSamples(in []float32, out output, offset int, bandwidth Bandwidth) error {
+	resampleCount := outputSampleRate / bandwidth.SampleRate()
+	if d.fixedPoint {
+		resampled := d.resampled[:len(in)*resampleCount]
+		if err := d.silkDecoder.Resample(in, resampled, silk.Bandwidth(bandwidth)); err != nil {
+			return err
+		}
+		in, resampleCount = resampled, 1
+	}
+
+	switch {
+	case out.float != nil:
+		return bitdepth.RepeatFloat32(in, out.float[offset:], resampleCount)
+	case d.fixedPoint:
+		return bitdepth.ConvertFixedPointToSigned16LittleEndian(in, out.pcm[offset*bytesPerSample:], resampleCount)
 	}
 
-	return cfg.bandwidth(), tocHeader.isStereo(), nil
+	return bitdepth.ConvertFloat32LittleEndianToSigned16LittleEndian(in, out.pcm[offset*bytesPerSample:], resampleCount)
 }
@@ -0,0 +1,308 @@
+package opus
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pion/opus/internal/silk"
+)
+
+// testSilkFrame is a 20ms wideband SILK frame
+func testSilkFrame() []byte {
+	return []byte{0x0B, 0xE4, 0xC1, 0x36, 0xEC, 0xC5, 0x80}
+}
+
+// testVoicedSilkFrame is a 20ms wideband SILK frame with voice activity
+func testVoicedSilkFrame() []byte {
+	return []byte{0xac, 0xbd, 0xa9, 0xf7, 0x26, 0x24, 0x5a, 0xa4, 0x00, 0x37, 0xbf, 0x9c, 0xde, 0xe, 0xcf, 0x94, 0x64, 0xaa, 0xf9, 0x87, 0xd0, 0x79, 0x19, 0xa8, 0x21, 0xc0}
+}
+
+func TestDecoderDecodeSelfDelimited(t *testing.T) {
+	packet := append([]byte{0x48}, testSilkFrame()...)
+	selfDelimited := append([]byte{0x48, byte(len(testSilkFrame()))}, testSilkFrame()...)
+
+	expected := make([]byte, 1920)
+	decoder := NewDecoder()
+	if _, _, err := decoder.Decode(packet, expected); err != nil {
+		t.Fatal(err)
+	}
+
+	// Decode the same packet twice from a single buffer
+	stream := append(append([]byte{}, selfDelimited...), selfDelimited...)
+	out := make([]byte, 1920)
+	decoder = NewDecoder()
+
+	n, bandwidth, isStereo, err := decoder.DecodeSelfDelimited(stream, out)
+	switch {
+	case err != nil:
+		t.Fatal(err)
+	case n != len(selfDelimited):
+		t.Fatal()
+	case bandwidth != BandwidthWideband:
+		t.Fatal()
+	case isStereo:
+		t.Fatal()
+	case !bytes.Equal(out, expected):
+		t.Fatal("self-delimited packet decoded differently")
+	}
+
+	if n, _, _, err = decoder.DecodeSelfDelimited(stream[n:], out); err != nil {
+		t.Fatal(err)
+	} else if n != len(selfDelimited) {
+		t.Fatal()
+	}
+
+	expectedFloat := make([]float32, 960)
+	floatOut := make([]float32, 960)
+	decoder = NewDecoder()
+	if _, _, err = decoder.DecodeFloat32(packet, expectedFloat); err != nil {
+		t.Fatal(err)
+	}
+	decoder = NewDecoder()
+	if n, _, _, err = decoder.DecodeSelfDelimitedFloat32(stream, floatOut); err != nil {
+		t.Fatal(err)
+	} else if n != len(selfDelimited) || !reflect.DeepEqual(floatOut, expectedFloat) {
+		t.Fatal("self-delimited packet decoded differently")
+	}
+}
+
+func TestDecoderOutBufferTooSmall(t *testing.T) {
+	decoder := NewDecoder()
+	packet := append([]byte{0x49}, append(testSilkFrame(), testSilkFrame()...)...)
+
+	if _, _, err := decoder.Decode(packet, make([]byte, 1920)); !errors.Is(err, errOutBufferTooSmall) {
+		t.Fatal(err)
+	}
+
+	if _, _, err := decoder.Decode(packet, make([]byte, 3840)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDecoderConceal(t *testing.T) {
+	decoder := NewDecoder()
+	out := make([]byte, 1920)
+
+	if _, _, err := decoder.Conceal(out); !errors.Is(err, errNoPacketDecoded) {
+		t.Fatal(err)
+	}
+
+	packet := append([]byte{0x48}, testSilkFrame()...)
+	if _, _, err := decoder.Decode(packet, out); err != nil {
+		t.Fatal(err)
+	}
+
+	// The test frame doesn't carry LBRR data
+	if _, _, err := decoder.DecodeFEC(packet, out); err == nil {
+		t.Fatal("decoded FEC from a packet without LBRR data")
+	}
+
+	concealed := make([]byte, 1920)
+	bandwidth, isStereo, err := decoder.Conceal(concealed)
+	switch {
+	case err != nil:
+		t.Fatal(err)
+	case bandwidth != BandwidthWideband:
+		t.Fatal()
+	case isStereo:
+		t.Fatal()
+	case bytes.Equal(concealed, make([]byte, 1920)):
+		t.Fatal("concealment produced silence")
+	}
+}
+
+func TestDecoderDTX(t *testing.T) {
+	decoder := NewDecoder()
+	out := make([]byte, 1920)
+	silence := make([]byte, 1920)
+
+	if _, err := decoder.ComfortNoise(out, 20*time.Millisecond); !errors.Is(err, errNoPacketDecoded) {
+		t.Fatal(err)
+	}
+
+	// testSilkFrame is an inactive frame, it sets up the comfort noise
+	if _, _, err := decoder.Decode(append([]byte{0x48}, testSilkFrame()...), out); err != nil {
+		t.Fatal(err)
+	}
+
+	dtx := []byte{0x48}
+	packet, err := ParsePacket(dtx)
+	if err != nil {
+		t.Fatal(err)
+	} else if !packet.IsDTX() {
+		t.Fatal("packet without audio isn't DTX")
+	}
+
+	if _, _, err := decoder.Decode(dtx, out); err != nil {
+		t.Fatal(err)
+	} else if bytes.Equal(out, silence) {
+		t.Fatal("DTX packet decoded to silence")
+	}
+
+	// Missing packets during DTX continue the comfort noise
+	if _, _, err := decoder.Conceal(out); err != nil {
+		t.Fatal(err)
+	} else if bytes.Equal(out, silence) {
+		t.Fatal("concealment during DTX produced silence")
+	}
+
+	gap := make([]byte, 4800*2)
+	if n, err := decoder.ComfortNoise(gap, 100*time.Millisecond); err != nil {
+		t.Fatal(err)
+	} else if n != len(gap) {
+		t.Fatalf("wrote %d bytes, expected %d", n, len(gap))
+	}
+
+	if _, err := decoder.ComfortNoise(gap, time.Second); !errors.Is(err, errOutBufferTooSmall) {
+		t.Fatal(err)
+	}
+}
+
+func TestDecoderVoiceActivity(t *testing.T) {
+	decoder := NewDecoder()
+	out := make([]byte, 1920)
+
+	for _, test := range []struct {
+		Packet        []byte
+		VoiceActivity bool
+	}{
+		{append([]byte{0x48}, testSilkFrame()...), false},
+		{append([]byte{0x48}, testVoicedSilkFrame()...), true},
+		{nil, false},
+		{[]byte{0x48}, false},
+	} {
+		var err error
+		if test.Packet == nil {
+			_, _, err = decoder.Conceal(out)
+		} else {
+			_, _, err = decoder.Decode(test.Packet, out)
+		}
+
+		switch {
+		case err != nil:
+			t.Fatal(err)
+		case decoder.VoiceActivity() != test.VoiceActivity:
+			t.Fatal(test.Packet)
+		}
+	}
+}
+
+func TestDecoderFloat32(t *testing.T) {
+	decoder := NewDecoder()
+	floatDecoder := NewDecoder()
+	packet := append([]byte{0x48}, testSilkFrame()...)
+	out := make([]byte, 1920)
+	floatOut := make([]float32, 960)
+
+	if _, _, err := floatDecoder.DecodeFloat32(packet, floatOut[:959]); !errors.Is(err, errOutBufferTooSmall) {
+		t.Fatal(err)
+	}
+
+	for _, concealed := range []bool{false, true} {
+		var err, floatErr error
+		if concealed {
+			_, _, err = decoder.Conceal(out)
+			_, _, floatErr = floatDecoder.ConcealFloat32(floatOut)
+		} else {
+			_, _, err = decoder.Decode(packet, out)
+			_, _, floatErr = floatDecoder.DecodeFloat32(packet, floatOut)
+		}
+		if err != nil || floatErr != nil {
+			t.Fatal(err, floatErr)
+		}
+
+		for i, sample := range floatOut {
+			if expected := int16(binary.LittleEndian.Uint16(out[i*2:])); int16(math.Floor(float64(sample*32767))) != expected {
+				t.Fatalf("%d (%f) != (%d)", i, sample, expected)
+			}
+		}
+	}
+}
+
+func TestDecoderFixedPoint(t *testing.T) {
+	decoder := NewDecoderWithOptions(Options{FixedPoint: true})
+	silkDecoder := silk.NewFixedPointDecoder()
+	out := make([]byte, 1920)
+	silkOut := make([]float32, 320)
+	resampled := make([]float32, 960)
+
+	for i := 0; i < 3; i++ {
+		if _, _, err := decoder.Decode(append([]byte{0x48}, testSilkFrame()...), out); err != nil {
+			t.Fatal(err)
+		}
+		if err := silkDecoder.Decode(testSilkFrame(), silkOut, false, 20000000, silk.BandwidthWideband); err != nil {
+			t.Fatal(err)
+		}
+
+		// The wideband samples are resampled to 48 kHz
+		if err := silkDecoder.Resample(silkOut, resampled, silk.BandwidthWideband); err != nil {
+			t.Fatal(err)
+		}
+		for j, expected := range resampled {
+			if sample := int16(binary.LittleEndian.Uint16(out[j*2:])); sample != int16(expected*32768) {
+				t.Fatalf("%d (%d) != (%f)", j, sample, expected)
+			}
+		}
+	}
+}
+
+func TestDecoderAllocs(t *testing.T) {
+	for _, options := range []Options{{}, {FixedPoint: true}} {
+		decoder := NewDecoderWithOptions(options)
+		out := make([]byte, 1920*2)
+		floatOut := make([]float32, 960)
+		packets := [][]byte{
+			append([]byte{0x48}, testSilkFrame()...),
+			append([]byte{0x49}, append(testSilkFrame(), testSilkFrame()...)...),
+			{0x48},
+		}
+
+		decode := func() {
+			for _, packet := range packets {
+				if _, _, err := decoder.Decode(packet, out); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if _, _, err := decoder.DecodeFloat32(packets[0], floatOut); err != nil {
+				t.Fatal(err)
+			}
+			// testSilkFrame carries no FEC data, only the attempt is measured
+			_, _, _ = decoder.DecodeFEC(packets[0], out)
+			if _, _, err := decoder.Conceal(out); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := decoder.ComfortNoise(out, 20*time.Millisecond); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		// The first packets size the buffers of the decoder
+		decode()
+
+		if allocs := testing.AllocsPerRun(100, decode); allocs != 0 {
+			t.Fatalf("%+v: Decoder allocated %f times per run", options, allocs)
+		}
+	}
+}
+
+func FuzzDecoderDecode(f *testing.F) {
+	f.Add(append([]byte{0x48}, testSilkFrame()...))
+	f.Add(append([]byte{0x49}, append(testSilkFrame(), testSilkFrame()...)...))
+	f.Add([]byte{0x4B, 0x82, 0x07, 0x07, 0x03})
+	f.Add([]byte{0x48})
+	f.Add([]byte{})
+
+	out := make([]byte, 5760*bytesPerSample)
+	f.Fuzz(func(t *testing.T, in []byte) {
+		decoder := NewDecoder()
+		_, _, _ = decoder.Decode(in, out)
+		_, _, _ = decoder.DecodeFEC(in, out)
+		_, _, _ = decoder.Conceal(out)
+		_, _, _, _ = decoder.DecodeSelfDelimited(in, out)
+	})
+}
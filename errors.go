@@ -5,10 +5,35 @@ import "errors"
 var (
 	errTooShortForTableOfContentsHeader = errors.New("Packet is too short to contain table of contents header")
 
-	errUnsupportedFrameCode                   = errors.New("unsupported frame code")
-	errTooShortForArbitraryLengthFrames       = errors.New("packet is too short to contain arbitrary length frames")
-	errArbitraryLengthFrameVBRUnsupported     = errors.New("arbitrary length frames with VBR is unsupported")
-	errArbitraryLengthFramePaddingUnsupported = errors.New("arbitrary length frames with padding is unsupported")
+	errTooShortForArbitraryLengthFrames = errors.New("packet is too short to contain arbitrary length frames")
+	errArbitraryLengthFramesCBRMismatch = errors.New("arbitrary length frames with CBR do not evenly divide the packet")
+	errTwoEqualFramesOddLength          = errors.New("packet with two equal frames has an odd length")
+	errTooShortForFrameLength           = errors.New("packet is too short to contain frame length")
+	errTooShortForFrame                 = errors.New("packet is too short to contain frame")
+	errFrameTooLarge                    = errors.New("frame is larger than 1275 bytes")
+	errInvalidFrameCount                = errors.New("packet must contain at least one frame")
+	errPacketTooLong                    = errors.New("packet duration exceeds 120ms")
+
+	errTooShortForExtension  = errors.New("padding is too short to contain extension")
+	errInvalidExtensionFrame = errors.New("extension frame is outside of the packet")
+	errInvalidExtensionID    = errors.New("extension ID must be between 3 and 127")
+	errExtensionDataTooLong  = errors.New("extension with ID below 32 carries more than one byte")
+
+	errRepacketizerConfigurationMismatch = errors.New("packet configuration differs from the collected frames")
+	errInvalidFrameRange                 = errors.New("frame range is outside of the collected frames")
+
+	errTargetLengthTooShort = errors.New("target length is shorter than the packet")
+	errInvalidStreamCount   = errors.New("multistream packet must contain at least one stream")
 
 	errUnsupportedConfigurationMode = errors.New("unsupported configuration mode")
+	errOutBufferTooSmall            = errors.New("out isn't large enough")
+	errNoPacketDecoded              = errors.New("no packet has been decoded yet")
+
+	errUnsupportedChannelMappingFamily = errors.New("unsupported channel mapping family")
+	errInvalidChannelCount             = errors.New("channel count is invalid for channel mapping family")
+	errUnsupportedCoupledStreams       = errors.New("coupled stereo streams are not supported")
+	errStreamDurationMismatch          = errors.New("the streams of a packet have different durations")
+
+	errUnsupportedStateVersion = errors.New("unsupported decoder state version")
+	errInvalidState            = errors.New("invalid decoder state")
 )
@@ -1,13 +1,10 @@
 package main
 
 import (
-	"bytes"
-	"errors"
 	"io"
 	"os"
 
 	"github.com/pion/opus"
-	"github.com/pion/opus/pkg/oggreader"
 )
 
 func main() {
@@ -20,38 +17,21 @@ func main() {
 		panic(err)
 	}
 
-	ogg, _, err := oggreader.NewWith(file)
+	stream, err := opus.NewOggReader(file, opus.Options{})
 	if err != nil {
 		panic(err)
 	}
 
-	out := make([]byte, 1920)
 	f, err := os.Create(os.Args[2])
 	if err != nil {
 		panic(err)
 	}
 
-	decoder := opus.NewDecoder()
-	x := 0
-	for {
-		segments, _, err := ogg.ParseNextPage()
-
-		if errors.Is(err, io.EOF) {
-			break
-		} else if bytes.HasPrefix(segments[0], []byte("OpusTags")) {
-			continue
-		}
-
-		if err != nil {
-			panic(err)
-		}
-
-		for i := range segments {
-			if _, _, err = decoder.Decode(segments[i], out); err != nil {
-				panic(err)
-			}
+	if _, err = io.Copy(f, stream); err != nil {
+		panic(err)
+	}
 
-			f.Write(out)
-		}
+	if err = f.Close(); err != nil {
+		panic(err)
 	}
 }
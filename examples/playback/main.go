@@ -1,7 +1,6 @@
 package main
 
 import (
-	"bytes"
 	"io"
 	"os"
 	"time"
@@ -9,48 +8,8 @@ import (
 	"github.com/faiface/beep"
 	"github.com/faiface/beep/speaker"
 	"github.com/pion/opus"
-	"github.com/pion/opus/pkg/oggreader"
 )
 
-type opusReader struct {
-	oggFile     *oggreader.OggReader
-	opusDecoder opus.Decoder
-
-	decodeBuffer       []byte
-	decodeBufferOffset int
-
-	segmentBuffer [][]byte
-}
-
-func (o *opusReader) Read(p []byte) (n int, err error) {
-	if o.decodeBufferOffset == 0 || o.decodeBufferOffset >= len(o.decodeBuffer) {
-		if len(o.segmentBuffer) == 0 {
-			for {
-				o.segmentBuffer, _, err = o.oggFile.ParseNextPage()
-				if err != nil {
-					return 0, err
-				} else if bytes.HasPrefix(o.segmentBuffer[0], []byte("OpusTags")) {
-					continue
-				}
-
-				break
-			}
-		}
-
-		var segment []byte
-		segment, o.segmentBuffer = o.segmentBuffer[0], o.segmentBuffer[1:]
-
-		o.decodeBufferOffset = 0
-		if _, _, err = o.opusDecoder.Decode(segment, o.decodeBuffer); err != nil {
-			panic(err)
-		}
-	}
-
-	n = copy(p, o.decodeBuffer[o.decodeBufferOffset:])
-	o.decodeBufferOffset += n
-	return n, nil
-}
-
 func main() {
 	if len(os.Args) != 2 {
 		panic("Usage: <in-file>")
@@ -61,20 +20,14 @@ func main() {
 		panic(err)
 	}
 
-	oggFile, _, err := oggreader.NewWith(file)
+	stream, err := opus.NewOggReader(file, opus.Options{})
 	if err != nil {
 		panic(err)
 	}
 
-	r := &opusReader{
-		decodeBuffer: make([]byte, 1920),
-		oggFile:      oggFile,
-		opusDecoder:  opus.NewDecoder(),
-	}
-
 	format := beep.Format{
 		SampleRate:  beep.SampleRate(48000),
-		NumChannels: 1,
+		NumChannels: stream.Channels(),
 		Precision:   2,
 	}
 
@@ -82,7 +35,7 @@ func main() {
 
 	done := make(chan struct{})
 	speaker.Play(beep.Seq(&pcmStream{
-		r:   r,
+		r:   stream,
 		f:   format,
 		buf: make([]byte, 512*format.Width()),
 	}, beep.Callback(func() {
@@ -0,0 +1,262 @@
+package opus
+
+import "sort"
+
+// Extension IDs with a meaning of their own in the extension framework, IDs
+// from 3 to 31 carry at most one byte of data, larger IDs carry data of any
+// length.
+//
+// https://datatracker.ietf.org/doc/html/draft-ietf-mlcodec-opus-extension
+const (
+	extensionIDPadding        = 0
+	extensionIDFrameSeparator = 1
+	extensionIDRepeat         = 2
+	extensionIDMinShort       = 3
+	extensionIDMinLong        = 32
+	extensionIDMax            = 127
+)
+
+// Extension is an extension carried in the Opus padding of a code 3 packet,
+// like the Deep Redundancy (DRED) data of newer encoders
+//
+// https://datatracker.ietf.org/doc/html/draft-ietf-mlcodec-opus-extension
+type Extension struct {
+	// ID identifies the extension, from 3 to 127
+	ID uint8
+
+	// Frame is the index of the frame of the packet the extension belongs to
+	Frame int
+
+	// Data is the payload of the extension, IDs below 32 carry at most one
+	// byte
+	Data []byte
+}
+
+// Extensions parses the extensions in the padding of the packet. Padding
+// written by encoders that don't know about extensions is filled with zeros,
+// which carries no extensions.
+func (p Packet) Extensions() ([]Extension, error) {
+	return ParseExtensions(p.Padding, len(p.Frames))
+}
+
+// ParseExtensions parses the extensions in the padding of a packet of
+// frameCount frames. Each extension starts with a byte holding its ID in
+// the upper seven bits and a length flag L in the lowest bit.
+//
+// https://datatracker.ietf.org/doc/html/draft-ietf-mlcodec-opus-extension#section-3
+func ParseExtensions(padding []byte, frameCount int) ([]Extension, error) {
+	var extensions []Extension
+	frame := 0
+
+	// The extensions since the last frame separator or repeat marker, which
+	// a repeat marker repeats
+	groupStart := 0
+
+	for offset := 0; offset < len(padding); {
+		header := padding[offset]
+		id, l := header>>1, header&1
+		offset++
+
+		switch id {
+		case extensionIDPadding:
+			// With L=1 the byte is padding, with L=0 the rest of the data is
+			if l == 0 {
+				return extensions, nil
+			}
+		case extensionIDFrameSeparator:
+			// With L=0 the next frame starts, with L=1 the following byte
+			// holds the number of frames to advance
+			increment := 1
+			if l == 1 {
+				if offset >= len(padding) {
+					return nil, errTooShortForExtension
+				}
+				increment = int(padding[offset])
+				offset++
+			}
+			if increment == 0 {
+				continue
+			}
+
+			if frame += increment; frame >= frameCount {
+				return nil, errInvalidExtensionFrame
+			}
+			groupStart = offset
+		case extensionIDRepeat:
+			var err error
+			if extensions, offset, err = repeatExtensions(extensions, padding, groupStart, offset, l, frame, frameCount); err != nil {
+				return nil, err
+			}
+			groupStart = offset
+
+			// With L=0 the data of the last frame extends to the end, so
+			// nothing follows for the current frame
+			if l == 0 {
+				if frame++; frame >= frameCount {
+					return extensions, nil
+				}
+			}
+		default:
+			data, n, err := extensionData(padding[offset:], id, l, 0)
+			if err != nil {
+				return nil, err
+			}
+			offset += n
+
+			if frame >= frameCount {
+				return nil, errInvalidExtensionFrame
+			}
+			extensions = append(extensions, Extension{ID: id, Frame: frame, Data: data})
+		}
+	}
+
+	return extensions, nil
+}
+
+// repeatExtensions repeats the extensions of padding[groupStart:markerEnd-1]
+// for every frame after frame. Only the data of the repeated extensions is
+// coded, in the order of the group, starting at markerEnd. If the L flag of
+// the repeat marker is 0 the last long extension of the last frame extends
+// to the end of the padding, except for the data of the short extensions
+// that follow it.
+//
+// https://datatracker.ietf.org/doc/html/draft-ietf-mlcodec-opus-extension#section-3.3
+func repeatExtensions(extensions []Extension, padding []byte, groupStart, markerEnd int, markerL byte, frame, frameCount int) ([]Extension, int, error) {
+	var group []byte
+	lastLong, trailing := -1, 0
+	for offset := groupStart; offset < markerEnd-1; {
+		header := padding[offset]
+		id, l := header>>1, header&1
+		offset++
+
+		switch {
+		case id == extensionIDPadding:
+		case id == extensionIDFrameSeparator:
+			offset += int(l)
+		default:
+			_, n, err := extensionData(padding[offset:markerEnd-1], id, l, 0)
+			if err != nil {
+				return nil, 0, err
+			}
+			offset += n
+
+			if id >= extensionIDMinLong {
+				lastLong, trailing = len(group), 0
+			} else {
+				trailing += int(l)
+			}
+			group = append(group, header)
+		}
+	}
+
+	offset := markerEnd
+	for f := frame + 1; f < frameCount; f++ {
+		for i, header := range group {
+			id, l := header>>1, header&1
+			if markerL == 0 && f == frameCount-1 && i == lastLong {
+				l = 0
+			}
+
+			data, n, err := extensionData(padding[offset:], id, l, trailing)
+			if err != nil {
+				return nil, 0, err
+			}
+			offset += n
+
+			extensions = append(extensions, Extension{ID: id, Frame: f, Data: data})
+		}
+	}
+
+	return extensions, offset, nil
+}
+
+// extensionData returns the data of an extension at the start of in, and
+// the number of bytes it occupies. A long extension with L=0 extends to the
+// end of in, except for the last trailing bytes. A long extension with L=1
+// is preceded by its length, coded as a sequence of bytes that continues
+// while they are 255.
+func extensionData(in []byte, id, l byte, trailing int) (data []byte, n int, err error) {
+	if id < extensionIDMinLong {
+		if len(in) < int(l) {
+			return nil, 0, errTooShortForExtension
+		}
+
+		return in[:l], int(l), nil
+	}
+
+	if l == 0 {
+		if len(in) < trailing {
+			return nil, 0, errTooShortForExtension
+		}
+
+		return in[:len(in)-trailing], len(in) - trailing, nil
+	}
+
+	length := 0
+	for {
+		if n >= len(in) {
+			return nil, 0, errTooShortForExtension
+		}
+
+		value := int(in[n])
+		n++
+		length += value
+		if value != 255 {
+			break
+		}
+	}
+
+	if len(in)-n < length {
+		return nil, 0, errTooShortForExtension
+	}
+
+	return in[n : n+length], n + length, nil
+}
+
+// AppendExtensions appends the coded extensions of a packet of frameCount
+// frames to padding. Every extension codes its length, so the padding can
+// be extended with zeros to reach a target size.
+//
+// https://datatracker.ietf.org/doc/html/draft-ietf-mlcodec-opus-extension#section-3
+func AppendExtensions(padding []byte, extensions []Extension, frameCount int) ([]byte, error) {
+	sorted := append([]Extension{}, extensions...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Frame < sorted[j].Frame
+	})
+
+	frame := 0
+	for _, extension := range sorted {
+		switch {
+		case extension.ID < extensionIDMinShort || extension.ID > extensionIDMax:
+			return nil, errInvalidExtensionID
+		case extension.Frame < 0 || extension.Frame >= frameCount:
+			return nil, errInvalidExtensionFrame
+		case extension.ID < extensionIDMinLong && len(extension.Data) > 1:
+			return nil, errExtensionDataTooLong
+		}
+
+		switch increment := extension.Frame - frame; {
+		case increment == 1:
+			padding = append(padding, extensionIDFrameSeparator<<1)
+		case increment > 1:
+			padding = append(padding, extensionIDFrameSeparator<<1|1, byte(increment))
+		}
+		frame = extension.Frame
+
+		if extension.ID < extensionIDMinLong {
+			padding = append(padding, extension.ID<<1|byte(len(extension.Data)))
+			padding = append(padding, extension.Data...)
+			continue
+		}
+
+		padding = append(padding, extension.ID<<1|1)
+		length := len(extension.Data)
+		for ; length >= 255; length -= 255 {
+			padding = append(padding, 255)
+		}
+		padding = append(padding, byte(length))
+		padding = append(padding, extension.Data...)
+	}
+
+	return padding, nil
+}
@@ -0,0 +1,158 @@
+package opus
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseExtensions(t *testing.T) {
+	t.Run("Zero Padding", func(t *testing.T) {
+		extensions, err := ParseExtensions(make([]byte, 10), 1)
+		switch {
+		case err != nil:
+			t.Fatal(err)
+		case len(extensions) != 0:
+			t.Fatal(extensions)
+		}
+	})
+
+	t.Run("Frame Separators", func(t *testing.T) {
+		padding := []byte{
+			// ID 3 with a byte, then the next frame
+			3<<1 | 1, 0xAA, 1 << 1,
+			// ID 32 of two bytes, then advance by two frames
+			32<<1 | 1, 2, 0x01, 0x02, 1<<1 | 1, 2,
+			// ID 4 without data, a byte of padding and ID 33 extending to
+			// the end
+			4 << 1, 1, 33 << 1, 0x09, 0x09,
+		}
+
+		extensions, err := ParseExtensions(padding, 4)
+		switch {
+		case err != nil:
+			t.Fatal(err)
+		case !reflect.DeepEqual(extensions, []Extension{
+			{ID: 3, Frame: 0, Data: []byte{0xAA}},
+			{ID: 32, Frame: 1, Data: []byte{0x01, 0x02}},
+			{ID: 4, Frame: 3, Data: []byte{}},
+			{ID: 33, Frame: 3, Data: []byte{0x09, 0x09}},
+		}):
+			t.Fatalf("%+v", extensions)
+		}
+	})
+
+	t.Run("Repeat", func(t *testing.T) {
+		padding := []byte{
+			5<<1 | 1, 0x10,
+			40<<1 | 1, 1, 0x20,
+			6<<1 | 1, 0x30,
+			// Repeat for the other frames, the last ID 40 extends to the
+			// data of the last ID 6
+			2 << 1,
+			0x11, 1, 0x21, 0x31,
+			0x12, 0x22, 0x23, 0x32,
+		}
+
+		extensions, err := ParseExtensions(padding, 3)
+		switch {
+		case err != nil:
+			t.Fatal(err)
+		case !reflect.DeepEqual(extensions, []Extension{
+			{ID: 5, Frame: 0, Data: []byte{0x10}},
+			{ID: 40, Frame: 0, Data: []byte{0x20}},
+			{ID: 6, Frame: 0, Data: []byte{0x30}},
+			{ID: 5, Frame: 1, Data: []byte{0x11}},
+			{ID: 40, Frame: 1, Data: []byte{0x21}},
+			{ID: 6, Frame: 1, Data: []byte{0x31}},
+			{ID: 5, Frame: 2, Data: []byte{0x12}},
+			{ID: 40, Frame: 2, Data: []byte{0x22, 0x23}},
+			{ID: 6, Frame: 2, Data: []byte{0x32}},
+		}):
+			t.Fatalf("%+v", extensions)
+		}
+
+		// With L=1 the extensions that follow belong to the current frame
+		padding = []byte{5<<1 | 1, 0x10, 2<<1 | 1, 0x11, 7 << 1, 1 << 1, 8 << 1}
+		extensions, err = ParseExtensions(padding, 2)
+		switch {
+		case err != nil:
+			t.Fatal(err)
+		case !reflect.DeepEqual(extensions, []Extension{
+			{ID: 5, Frame: 0, Data: []byte{0x10}},
+			{ID: 5, Frame: 1, Data: []byte{0x11}},
+			{ID: 7, Frame: 0, Data: []byte{}},
+			{ID: 8, Frame: 1, Data: []byte{}},
+		}):
+			t.Fatalf("%+v", extensions)
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		if _, err := ParseExtensions([]byte{1 << 1}, 1); !errors.Is(err, errInvalidExtensionFrame) {
+			t.Fatal(err)
+		}
+		if _, err := ParseExtensions([]byte{32<<1 | 1, 3, 0x01}, 1); !errors.Is(err, errTooShortForExtension) {
+			t.Fatal(err)
+		}
+		if _, err := ParseExtensions([]byte{3<<1 | 1}, 1); !errors.Is(err, errTooShortForExtension) {
+			t.Fatal(err)
+		}
+		if _, err := ParseExtensions([]byte{5<<1 | 1, 0x10, 2 << 1}, 2); !errors.Is(err, errTooShortForExtension) {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("Packet", func(t *testing.T) {
+		// Code 3 CBR packet of two frames with four bytes of padding
+		p, err := ParsePacket([]byte{0x4b, 0x42, 0x04, 0x01, 0x02, 3<<1 | 1, 0xAA, 1 << 1, 4 << 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		extensions, err := p.Extensions()
+		switch {
+		case err != nil:
+			t.Fatal(err)
+		case !reflect.DeepEqual(extensions, []Extension{
+			{ID: 3, Frame: 0, Data: []byte{0xAA}},
+			{ID: 4, Frame: 1, Data: []byte{}},
+		}):
+			t.Fatalf("%+v", extensions)
+		}
+	})
+}
+
+func TestAppendExtensions(t *testing.T) {
+	extensions := []Extension{
+		{ID: 3, Frame: 0, Data: []byte{0xAA}},
+		{ID: 4, Frame: 3, Data: []byte{}},
+		{ID: 32, Frame: 1, Data: bytes.Repeat([]byte{0x01}, 300)},
+		{ID: 127, Frame: 3, Data: []byte{0x02}},
+	}
+
+	padding, err := AppendExtensions(nil, extensions, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Zeros appended to the extensions are padding
+	parsed, err := ParseExtensions(append(padding, 0, 0), 4)
+	switch {
+	case err != nil:
+		t.Fatal(err)
+	case !reflect.DeepEqual(parsed, []Extension{extensions[0], extensions[2], extensions[1], extensions[3]}):
+		t.Fatalf("%+v", parsed)
+	}
+
+	if _, err := AppendExtensions(nil, []Extension{{ID: 2}}, 1); !errors.Is(err, errInvalidExtensionID) {
+		t.Fatal(err)
+	}
+	if _, err := AppendExtensions(nil, []Extension{{ID: 3, Frame: 1}}, 1); !errors.Is(err, errInvalidExtensionFrame) {
+		t.Fatal(err)
+	}
+	if _, err := AppendExtensions(nil, []Extension{{ID: 3, Data: []byte{1, 2}}}, 1); !errors.Is(err, errExtensionDataTooLong) {
+		t.Fatal(err)
+	}
+}
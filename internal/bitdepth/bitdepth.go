@@ -5,6 +5,10 @@ import (
 )
 
 func ConvertFloat32LittleEndianToSigned16LittleEndian(in []float32, out []byte, resampleCount int) error {
+	if len(out) < len(in)*resampleCount*2 {
+		return errOutBufferTooSmall
+	}
+
 	currIndex := 0
 	for i := range in {
 		res := int16(math.Floor(float64(in[i] * 32767)))
@@ -20,3 +24,45 @@ func ConvertFloat32LittleEndianToSigned16LittleEndian(in []float32, out []byte,
 
 	return nil
 }
+
+// ConvertFixedPointToSigned16LittleEndian converts samples that are
+// multiples of 1/32768, as produced by the fixed-point decoder, back to
+// the 16-bit samples they represent without losing precision.
+func ConvertFixedPointToSigned16LittleEndian(in []float32, out []byte, resampleCount int) error {
+	if len(out) < len(in)*resampleCount*2 {
+		return errOutBufferTooSmall
+	}
+
+	currIndex := 0
+	for i := range in {
+		res := int16(math.Max(math.MinInt16, math.Min(math.Round(float64(in[i])*32768), math.MaxInt16)))
+
+		for j := resampleCount; j > 0; j-- {
+			out[currIndex] = byte(res & 0b11111111)
+			currIndex++
+
+			out[currIndex] = (byte(res >> 8))
+			currIndex++
+		}
+	}
+
+	return nil
+}
+
+// RepeatFloat32 copies in to out, repeating every sample resampleCount
+// times
+func RepeatFloat32(in, out []float32, resampleCount int) error {
+	if len(out) < len(in)*resampleCount {
+		return errOutBufferTooSmall
+	}
+
+	currIndex := 0
+	for i := range in {
+		for j := resampleCount; j > 0; j-- {
+			out[currIndex] = in[i]
+			currIndex++
+		}
+	}
+
+	return nil
+}
@@ -2,6 +2,8 @@ package bitdepth
 
 import (
 	"bytes"
+	"errors"
+	"reflect"
 	"testing"
 )
 
@@ -14,3 +16,38 @@ func TestConvertFloat32LittleEndianToSigned16LittleEndian(t *testing.T) {
 		t.Fatal("buffer mismatch")
 	}
 }
+
+func TestConvertFloat32LittleEndianToSigned16LittleEndianOutBufferTooSmall(t *testing.T) {
+	if err := ConvertFloat32LittleEndianToSigned16LittleEndian([]float32{0.3, 0}, make([]byte, 7), 2); !errors.Is(err, errOutBufferTooSmall) {
+		t.Fatal(err)
+	}
+}
+
+func TestConvertFixedPointToSigned16LittleEndian(t *testing.T) {
+	in := []float32{1.0 / 32768, 0, -1, -3.0 / 32768, 1}
+	out := make([]byte, len(in)*2*2)
+	if err := ConvertFixedPointToSigned16LittleEndian(in, out, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal([]byte{
+		0x01, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x80,
+		0x00, 0x80, 0xfd, 0xff, 0xfd, 0xff, 0xff, 0x7f, 0xff, 0x7f,
+	}, out) {
+		t.Fatal("buffer mismatch")
+	}
+}
+
+func TestRepeatFloat32(t *testing.T) {
+	out := make([]float32, 6)
+	if err := RepeatFloat32([]float32{0.5, -0.25}, out, 3); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(out, []float32{0.5, 0.5, 0.5, -0.25, -0.25, -0.25}) {
+		t.Fatal(out)
+	}
+
+	if err := RepeatFloat32([]float32{0.5, -0.25}, out[:5], 3); !errors.Is(err, errOutBufferTooSmall) {
+		t.Fatal(err)
+	}
+}
@@ -4,4 +4,5 @@ import "errors"
 
 var (
 	errBufferLengthMismatch = errors.New("length of in and out buffer are not equal")
+	errOutBufferTooSmall    = errors.New("out isn't large enough")
 )
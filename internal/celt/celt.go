@@ -0,0 +1,72 @@
+// Package celt implements the CELT layer of Opus. Only the start of a
+// frame is decoded so far, its header flags and coarse band energies.
+//
+// https://datatracker.ietf.org/doc/html/rfc6716#section-4.3
+package celt
+
+// Bandwidth for CELT can be NB (narrowband), WB (wideband), SWB
+// (superwideband) or FB (fullband). MB (medium-band) is only used by SILK.
+type Bandwidth byte
+
+// Bandwidth constants, in the same order as the bandwidths of the TOC byte
+const (
+	BandwidthNarrowband Bandwidth = iota + 1
+	BandwidthMediumband
+	BandwidthWideband
+	BandwidthSuperwideband
+	BandwidthFullband
+)
+
+const (
+	bandCount   = 21
+	maxChannels = 2
+
+	nanoseconds2500Us = 2500000
+	nanoseconds5Ms    = 5000000
+	nanoseconds10Ms   = 10000000
+	nanoseconds20Ms   = 20000000
+)
+
+// The number of coded bands, which CELT limits to the bandwidth of the
+// packet
+//
+// https://datatracker.ietf.org/doc/html/rfc6716#section-4.3
+func (b Bandwidth) endBand() int {
+	switch b {
+	case BandwidthNarrowband:
+		return 13
+	case BandwidthWideband:
+		return 17
+	case BandwidthSuperwideband:
+		return 19
+	case BandwidthFullband:
+		return 21
+	}
+
+	return 0
+}
+
+// The frame size is coded as LM, the base-2 logarithm of the number of
+// 2.5 ms short blocks in the frame. It selects the probability models and
+// prediction coefficients of the coarse energies.
+func frameSizeLog(nanoseconds int) (int, bool) {
+	switch nanoseconds {
+	case nanoseconds2500Us:
+		return 0, true
+	case nanoseconds5Ms:
+		return 1, true
+	case nanoseconds10Ms:
+		return 2, true
+	case nanoseconds20Ms:
+		return 3, true
+	}
+
+	return 0, false
+}
+
+func maxFloat32(a, b float32) float32 {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,166 @@
+package celt
+
+import (
+	"github.com/pion/opus/internal/rangecoding"
+)
+
+// icdfTapset is the PDF of the post-filter tapset, {2, 1, 1}/4
+//
+// https://datatracker.ietf.org/doc/html/rfc6716#section-4.3
+var icdfTapset = []uint{4, 2, 3, 4}
+
+// FrameHeader is the start of a CELT frame, its header flags and coarse
+// band energies
+//
+// https://datatracker.ietf.org/doc/html/rfc6716#section-4.3
+type FrameHeader struct {
+	// A silent frame codes no other symbols, every band has the lowest
+	// energy
+	Silence bool
+
+	// The period, gain and tapset of the pitch post-filter, only coded
+	// when the post-filter is enabled
+	//
+	// https://datatracker.ietf.org/doc/html/rfc6716#section-4.3.7.1
+	PostFilter       bool
+	PostFilterPeriod int
+	PostFilterGain   float32
+	PostFilterTapset int
+
+	// Transient frames use short MDCTs, intra frames predict the coarse
+	// energies without the previous frame
+	Transient bool
+	Intra     bool
+
+	// The coarse energy of the first Bands bands of each channel, as the
+	// base-2 logarithm of the amplitude of the band. Without the fine
+	// energies the prediction from the previous frame drifts from the
+	// reference decoder, so they are estimates accurate to a few dB.
+	//
+	// https://datatracker.ietf.org/doc/html/rfc6716#section-4.3.2.1
+	Bands          int
+	CoarseEnergies [maxChannels][bandCount]float32
+}
+
+// Decoder maintains the state needed to decode a stream of CELT frames
+type Decoder struct {
+	rangeDecoder rangecoding.Decoder
+
+	// The size of the frame being decoded in bits, and if it is silent
+	totalBits int
+	silence   bool
+
+	// The coarse energy of each band of the previous frame, relative to
+	// the mean energy of the band
+	energy [maxChannels][bandCount]float32
+}
+
+// NewDecoder creates a new CELT Decoder
+func NewDecoder() Decoder {
+	return Decoder{}
+}
+
+// DecodeHeader decodes the header flags and coarse band energies of a
+// CELT-only frame, without decoding the rest of the frame. The energies
+// are predicted from those of the previous frame, so every frame of the
+// stream must be passed in order.
+func (d *Decoder) DecodeHeader(in []byte, isStereo bool, nanoseconds int, bandwidth Bandwidth) (FrameHeader, error) {
+	lm, ok := frameSizeLog(nanoseconds)
+	endBand := bandwidth.endBand()
+	switch {
+	case !ok:
+		return FrameHeader{}, errUnsupportedFrameDuration
+	case endBand == 0:
+		return FrameHeader{}, errUnsupportedBandwidth
+	}
+
+	channels := 1
+	if isStereo {
+		channels = 2
+	}
+
+	d.rangeDecoder.Init(in)
+	d.totalBits = len(in) * 8
+
+	// A mono frame is predicted from the louder channel of a previous
+	// stereo frame
+	if channels == 1 {
+		for band := range d.energy[0] {
+			d.energy[0][band] = maxFloat32(d.energy[0][band], d.energy[1][band])
+		}
+	}
+
+	header := d.decodeFrameFlags(lm)
+	d.decodeCoarseEnergy(endBand, channels, lm, header.Intra)
+
+	if header.Silence {
+		for channel := range d.energy {
+			for band := range d.energy[channel] {
+				d.energy[channel][band] = silenceEnergy
+			}
+		}
+	}
+
+	if channels == 1 {
+		d.energy[1] = d.energy[0]
+	}
+
+	// The bands that weren't coded aren't predicted from
+	for channel := range d.energy {
+		for band := endBand; band < bandCount; band++ {
+			d.energy[channel][band] = 0
+		}
+	}
+
+	header.Bands = endBand
+	for channel := 0; channel < channels; channel++ {
+		for band := 0; band < endBand; band++ {
+			header.CoarseEnergies[channel][band] = d.energy[channel][band] + energyMeans[band]
+		}
+	}
+
+	return header, nil
+}
+
+// Each flag of the frame header is only coded if enough bits remain for
+// it, a frame that is too short for the silence flag is silent
+//
+// https://datatracker.ietf.org/doc/html/rfc6716#section-4.3
+func (d *Decoder) decodeFrameFlags(lm int) (header FrameHeader) {
+	d.silence = false
+	switch tell := int(d.rangeDecoder.Tell()); {
+	case tell >= d.totalBits:
+		header.Silence = true
+	case tell == 1:
+		header.Silence = d.rangeDecoder.DecodeSymbolLogP(15) == 1
+	}
+
+	// Nothing else is decoded from a silent frame
+	d.silence = header.Silence
+
+	if d.remainingBits() >= 16 && d.rangeDecoder.DecodeSymbolLogP(1) == 1 {
+		header.PostFilter = true
+		octave := d.rangeDecoder.DecodeUniform(6)
+		header.PostFilterPeriod = (16 << octave) + int(d.rangeDecoder.DecodeRawBits(4+uint(octave))) - 1
+		header.PostFilterGain = 0.09375 * float32(d.rangeDecoder.DecodeRawBits(3)+1)
+		if d.remainingBits() >= 2 {
+			header.PostFilterTapset = int(d.rangeDecoder.DecodeSymbolWithICDF(icdfTapset))
+		}
+	}
+
+	if lm > 0 && d.remainingBits() >= 3 {
+		header.Transient = d.rangeDecoder.DecodeSymbolLogP(3) == 1
+	}
+	if d.remainingBits() >= 3 {
+		header.Intra = d.rangeDecoder.DecodeSymbolLogP(3) == 1
+	}
+
+	return header
+}
+
+func (d *Decoder) remainingBits() int {
+	if d.silence {
+		return 0
+	}
+	return d.totalBits - int(d.rangeDecoder.Tell())
+}
@@ -0,0 +1,95 @@
+package celt
+
+import (
+	"errors"
+	"testing"
+)
+
+// A frame of zeros codes no flags, and a coarse energy of 0 for every band
+func testZeroFrame() []byte {
+	return make([]byte, 64)
+}
+
+func TestDecodeHeader(t *testing.T) {
+	d := NewDecoder()
+
+	// A frame that is too short for the silence flag is silent, like a
+	// frame with the silence flag set
+	for _, frame := range [][]byte{{}, {0xff, 0xff}} {
+		header, err := d.DecodeHeader(frame, false, nanoseconds20Ms, BandwidthFullband)
+		switch {
+		case err != nil:
+			t.Fatal(err)
+		case !header.Silence || header.PostFilter || header.Transient || header.Intra:
+			t.Fatalf("%+v", header)
+		case header.Bands != 21:
+			t.Fatal(header.Bands)
+		case header.CoarseEnergies[0][0] != silenceEnergy+energyMeans[0]:
+			t.Fatal(header.CoarseEnergies[0][0])
+		}
+	}
+
+	// The energies are predicted from the previous frame, the energy of
+	// 20 ms frames decays by half
+	for _, expected := range []float32{-4.5, -2.25} {
+		header, err := d.DecodeHeader(testZeroFrame(), false, nanoseconds20Ms, BandwidthWideband)
+		switch {
+		case err != nil:
+			t.Fatal(err)
+		case header.Silence || header.PostFilter || header.Transient || header.Intra:
+			t.Fatalf("%+v", header)
+		case header.Bands != 17:
+			t.Fatal(header.Bands)
+		}
+
+		for band := 0; band < header.Bands; band++ {
+			if energy := header.CoarseEnergies[0][band] - energyMeans[band]; energy != expected {
+				t.Fatal(band, energy)
+			}
+		}
+	}
+
+	// The second channel is predicted from the mono frame before, and the
+	// bands above the bandwidth that weren't coded from 0
+	header, err := d.DecodeHeader(testZeroFrame(), true, nanoseconds20Ms, BandwidthFullband)
+	switch {
+	case err != nil:
+		t.Fatal(err)
+	case header.CoarseEnergies[1][0]-energyMeans[0] != -1.125:
+		t.Fatal(header.CoarseEnergies[1][0])
+	case header.CoarseEnergies[0][16]-energyMeans[16] != -1.125:
+		t.Fatal(header.CoarseEnergies[0][16])
+	case header.CoarseEnergies[0][20]-energyMeans[20] != 0:
+		t.Fatal(header.CoarseEnergies[0][20])
+	}
+}
+
+func TestDecodeHeaderPostFilter(t *testing.T) {
+	frame := testZeroFrame()
+	frame[0] = 0xc0
+	frame[len(frame)-1] = 0xa5
+
+	// The period and gain are raw bits read from the end of the frame
+	d := NewDecoder()
+	header, err := d.DecodeHeader(frame, false, nanoseconds20Ms, BandwidthFullband)
+	switch {
+	case err != nil:
+		t.Fatal(err)
+	case header.Silence || !header.PostFilter:
+		t.Fatalf("%+v", header)
+	case header.PostFilterPeriod != (16<<3)+0x25-1:
+		t.Fatal(header.PostFilterPeriod)
+	case header.PostFilterGain != 0.09375*2:
+		t.Fatal(header.PostFilterGain)
+	}
+}
+
+func TestDecodeHeaderErrors(t *testing.T) {
+	d := NewDecoder()
+	if _, err := d.DecodeHeader(testZeroFrame(), false, 3*nanoseconds20Ms, BandwidthFullband); !errors.Is(err, errUnsupportedFrameDuration) {
+		t.Fatal(err)
+	}
+	if _, err := d.DecodeHeader(testZeroFrame(), false, nanoseconds20Ms, BandwidthMediumband); !errors.Is(err, errUnsupportedBandwidth) {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,142 @@
+package celt
+
+var (
+	// The parameters of the Laplace distribution of the coarse energy of
+	// each band, the probability of 0 in Q8 followed by the decay in Q7,
+	// for each frame size and for inter and intra prediction. They are
+	// e_prob_model in quant_bands.c.
+	//
+	// https://datatracker.ietf.org/doc/html/rfc6716#section-4.3.2.1
+	energyProbabilityModel = [4][2][2 * bandCount]uint32{
+		// 2.5 ms
+		{
+			// Inter
+			{
+				72, 127, 65, 129, 66, 128, 65, 128, 64, 128, 62, 128, 64, 128,
+				64, 128, 92, 78, 92, 79, 92, 78, 90, 79, 116, 41, 115, 40,
+				114, 40, 132, 26, 132, 26, 145, 17, 161, 12, 176, 10, 177, 11,
+			},
+			// Intra
+			{
+				24, 179, 48, 138, 54, 135, 54, 132, 53, 134, 56, 133, 55, 132,
+				55, 132, 61, 114, 70, 96, 74, 88, 75, 88, 87, 74, 89, 66,
+				91, 67, 100, 59, 108, 50, 120, 40, 122, 37, 97, 43, 78, 50,
+			},
+		},
+		// 5 ms
+		{
+			// Inter
+			{
+				83, 78, 84, 81, 88, 75, 86, 74, 87, 71, 90, 73, 93, 74,
+				93, 74, 109, 40, 114, 36, 117, 34, 117, 34, 143, 17, 145, 18,
+				146, 19, 162, 12, 165, 10, 178, 7, 189, 6, 190, 8, 177, 9,
+			},
+			// Intra
+			{
+				23, 178, 54, 115, 63, 102, 66, 98, 69, 99, 74, 89, 71, 91,
+				73, 91, 78, 89, 86, 80, 92, 66, 93, 64, 102, 59, 103, 60,
+				104, 60, 117, 52, 123, 44, 138, 35, 133, 31, 97, 38, 77, 45,
+			},
+		},
+		// 10 ms
+		{
+			// Inter
+			{
+				61, 90, 93, 60, 105, 42, 107, 41, 110, 45, 116, 38, 113, 38,
+				112, 38, 124, 26, 132, 27, 136, 19, 140, 20, 155, 14, 159, 16,
+				158, 18, 170, 13, 177, 10, 187, 8, 192, 6, 175, 9, 159, 10,
+			},
+			// Intra
+			{
+				21, 178, 59, 110, 71, 86, 75, 85, 84, 83, 91, 66, 88, 73,
+				87, 72, 92, 75, 98, 72, 105, 58, 107, 54, 115, 52, 114, 55,
+				112, 56, 129, 51, 132, 40, 150, 33, 140, 29, 98, 35, 77, 42,
+			},
+		},
+		// 20 ms
+		{
+			// Inter
+			{
+				42, 121, 96, 66, 108, 43, 111, 40, 117, 44, 123, 32, 120, 36,
+				119, 33, 127, 33, 134, 34, 139, 21, 147, 23, 152, 20, 158, 25,
+				154, 26, 166, 21, 173, 16, 184, 13, 184, 10, 150, 13, 139, 15,
+			},
+			// Intra
+			{
+				22, 178, 63, 114, 74, 82, 84, 83, 92, 82, 103, 62, 96, 72,
+				96, 67, 101, 73, 107, 72, 113, 55, 118, 52, 125, 52, 118, 52,
+				117, 55, 135, 49, 137, 39, 157, 32, 145, 29, 97, 33, 77, 40,
+			},
+		},
+	}
+
+	// When few bits remain the coarse energy is limited to -1, 0 and 1
+	// with the PDF {2, 1, 1}/4
+	icdfSmallEnergy = []uint{4, 2, 3, 4}
+
+	// The prediction coefficients of inter prediction, alpha and beta for
+	// each frame size, and beta of intra prediction where alpha is 0
+	//
+	// https://datatracker.ietf.org/doc/html/rfc6716#section-4.3.2.1
+	energyPredictionCoefficients = [4]float32{29440.0 / 32768, 26112.0 / 32768, 21248.0 / 32768, 16384.0 / 32768}
+	energyBetaCoefficients       = [4]float32{30147.0 / 32768, 22282.0 / 32768, 12124.0 / 32768, 6554.0 / 32768}
+	energyBetaIntra              = float32(4915.0 / 32768)
+
+	// The mean energy of each band, the coded energies are relative to it
+	energyMeans = [bandCount]float32{
+		6.437500, 6.250000, 5.750000, 5.312500, 5.062500,
+		4.812500, 4.500000, 4.375000, 4.875000, 4.687500,
+		4.562500, 4.437500, 4.875000, 4.625000, 4.312500,
+		4.500000, 4.375000, 4.625000, 4.750000, 4.437500,
+		3.750000,
+	}
+)
+
+const (
+	// The lowest energy a band is predicted from, and the energy of every
+	// band after a silent frame
+	minPredictionEnergy = -9
+	silenceEnergy       = -28
+)
+
+// The coarse energy of each band is coded in steps of 6 dB, as the
+// difference to a prediction from the previous band (in frequency) and
+// the same band of the previous frame. The energies are base-2 logarithms
+// of the amplitude of a band, relative to its mean energy. The prediction
+// of intra frames doesn't use the previous frame.
+//
+// The coarse energy is decoded by unquant_coarse_energy() (quant_bands.c).
+//
+// https://datatracker.ietf.org/doc/html/rfc6716#section-4.3.2.1
+func (d *Decoder) decodeCoarseEnergy(endBand, channels, lm int, intra bool) {
+	probabilityModel := energyProbabilityModel[lm][0]
+	alpha, beta := energyPredictionCoefficients[lm], energyBetaCoefficients[lm]
+	if intra {
+		probabilityModel = energyProbabilityModel[lm][1]
+		alpha, beta = 0, energyBetaIntra
+	}
+
+	prediction := [maxChannels]float32{}
+	for band := 0; band < endBand; band++ {
+		for channel := 0; channel < channels; channel++ {
+			var qi int
+			switch remaining := d.remainingBits(); {
+			case remaining >= 15:
+				qi = d.rangeDecoder.DecodeLaplace(probabilityModel[2*band]<<7, probabilityModel[2*band+1]<<6)
+			case remaining >= 2:
+				// 0, -1, 1
+				qi = int(d.rangeDecoder.DecodeSymbolWithICDF(icdfSmallEnergy))
+				qi = (qi >> 1) ^ -(qi & 1)
+			case remaining >= 1:
+				qi = -int(d.rangeDecoder.DecodeSymbolLogP(1))
+			default:
+				qi = -1
+			}
+			q := float32(qi)
+
+			energy := maxFloat32(minPredictionEnergy, d.energy[channel][band])
+			d.energy[channel][band] = alpha*energy + prediction[channel] + q
+			prediction[channel] += q - beta*q
+		}
+	}
+}
@@ -0,0 +1,8 @@
+package celt
+
+import "errors"
+
+var (
+	errUnsupportedFrameDuration = errors.New("celt frames must have a duration of 2.5, 5, 10 or 20ms")
+	errUnsupportedBandwidth     = errors.New("celt decoder does not support bandwidth")
+)
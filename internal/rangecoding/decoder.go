@@ -2,6 +2,7 @@ package rangecoding
 
 import (
 	"math"
+	"math/bits"
 )
 
 // Decoder implements rfc6716#section-4.1
@@ -73,6 +74,11 @@ type Decoder struct {
 	data     []byte
 	bitsRead uint
 
+	// The raw bits read from the end of the data, and the total number of
+	// bits read as counted by ec_tell()
+	rawBitsRead uint
+	bitsTotal   uint
+
 	rangeSize              uint32 // rng in RFC 6716
 	highAndCodedDifference uint32 // val in RFC 6716
 }
@@ -91,6 +97,8 @@ type Decoder struct {
 func (r *Decoder) Init(data []byte) {
 	r.data = data
 	r.bitsRead = 0
+	r.rawBitsRead = 0
+	r.bitsTotal = 9
 
 	r.rangeSize = 128
 	r.highAndCodedDifference = 127 - r.getBits(7)
@@ -148,11 +156,117 @@ func (r *Decoder) DecodeSymbolLogP(logp uint) uint32 {
 	return k
 }
 
+// DecodeUniform decodes an integer between 0 and ft-1 with a uniform
+// distribution. Integers of more than 8 bits are split, only the top 8
+// bits are range coded and the remaining bits are raw bits.
+//
+// https://datatracker.ietf.org/doc/html/rfc6716#section-4.1.5
+func (r *Decoder) DecodeUniform(ft uint32) uint32 {
+	ftb := bits.Len32(ft - 1)
+	if ftb <= 8 {
+		scale := r.rangeSize / ft
+		symbol := ft - min32(r.highAndCodedDifference/scale+1, ft)
+		r.update(scale, symbol, symbol+1, ft)
+		return symbol
+	}
+
+	ftb -= 8
+	total := ((ft - 1) >> ftb) + 1
+	scale := r.rangeSize / total
+	symbol := total - min32(r.highAndCodedDifference/scale+1, total)
+	r.update(scale, symbol, symbol+1, total)
+
+	// Like the reference decoder, values that are out of range are
+	// clamped to ft-1
+	t := symbol<<ftb | r.DecodeRawBits(uint(ftb))
+	if t >= ft {
+		return ft - 1
+	}
+	return t
+}
+
+// DecodeRawBits decodes n raw bits, which are read backwards from the end
+// of the data. Reading past the start of the data yields zeros.
+//
+// https://datatracker.ietf.org/doc/html/rfc6716#section-4.1.4
+func (r *Decoder) DecodeRawBits(n uint) uint32 {
+	value := uint32(0)
+	for i := uint(0); i < n; i++ {
+		index := len(r.data) - 1 - int(r.rawBitsRead/8)
+		if index >= 0 {
+			value |= uint32((r.data[index]>>(r.rawBitsRead%8))&1) << i
+		}
+		r.rawBitsRead++
+	}
+
+	r.bitsTotal += n
+	return value
+}
+
+// DecodeLaplace decodes an integer with the Laplace-like distribution of
+// the CELT coarse energies, implemented by ec_laplace_decode() (laplace.c).
+// fs is the probability of 0 and decay the decay of the probability of
+// larger magnitudes, both in Q15. Every magnitude has a probability of at
+// least 1/32768.
+//
+// https://datatracker.ietf.org/doc/html/rfc6716#section-4.3.2.1
+func (r *Decoder) DecodeLaplace(fs, decay uint32) int {
+	const (
+		total       = 1 << 15
+		minimumProb = 1
+		minimumSize = 16
+	)
+
+	scale := r.rangeSize >> 15
+	fm := total - min32(r.highAndCodedDifference/scale+1, total)
+
+	value, fl := 0, uint32(0)
+	if fm >= fs {
+		value++
+		fl = fs
+		fs = ((total-2*minimumSize*minimumProb-fs)*(16384-decay))>>15 + minimumProb
+
+		// Search the decaying part of the PDF
+		for fs > minimumProb && fm >= fl+2*fs {
+			fs *= 2
+			fl += fs
+			fs = ((fs-2*minimumProb)*decay)>>15 + minimumProb
+			value++
+		}
+
+		// Everything beyond that has the minimum probability
+		if fs <= minimumProb {
+			di := (fm - fl) >> 1
+			value += int(di)
+			fl += 2 * di * minimumProb
+		}
+
+		if fm < fl+fs {
+			value = -value
+		} else {
+			fl += fs
+		}
+	}
+
+	r.update(scale, fl, min32(fl+fs, total), total)
+	return value
+}
+
+// Tell returns the number of bits decoded so far rounded up, including
+// the raw bits, as implemented by ec_tell() (entcode.h). It is used to
+// find out how many bits of a frame remain.
+//
+// https://datatracker.ietf.org/doc/html/rfc6716#section-4.1.6
+func (r *Decoder) Tell() uint {
+	return r.bitsTotal - uint(bits.Len32(r.rangeSize))
+}
+
 func (r *Decoder) getBit() uint32 {
 	index := r.bitsRead / 8
 	offset := r.bitsRead % 8
 
-	if index > uint(len(r.data)-1) {
+	// Reading past the end of the data yields zeros
+	if index >= uint(len(r.data)) {
 		return 0
 	}
 
@@ -193,6 +307,7 @@ func (r *Decoder) getBits(n int) uint32 {
 func (r *Decoder) normalize() {
 	for float64(r.rangeSize) <= math.Pow(2, 23) {
 		r.rangeSize <<= 8
+		r.bitsTotal += 8
 		r.highAndCodedDifference = ((r.highAndCodedDifference << 8) + (255 - r.getBits(8))) & 0x7FFFFFFF
 	}
 }
@@ -208,6 +323,15 @@ func (r *Decoder) update(scale, low, high, total uint32) {
 	r.normalize()
 }
 
+// FinalRange returns the final state of rng after decoding a frame. The
+// encoder and the decoder end with the same state, so it can be used to
+// check that a frame was decoded correctly.
+//
+// https://datatracker.ietf.org/doc/html/rfc6716#section-4.1.6
+func (r *Decoder) FinalRange() uint32 {
+	return r.rangeSize
+}
+
 // SetInternalValues is used when using the RangeDecoder when testing
 func (r *Decoder) SetInternalValues(data []byte, bitsRead uint, rangeSize uint32, highAndCodedDifference uint32) {
 	r.data = data
@@ -216,6 +340,13 @@ func (r *Decoder) SetInternalValues(data []byte, bitsRead uint, rangeSize uint32
 	r.highAndCodedDifference = highAndCodedDifference
 }
 
+func min32(a, b uint32) uint32 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
 func min(a, b uint) uint {
 	if a < b {
 		return a
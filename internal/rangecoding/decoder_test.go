@@ -242,3 +242,62 @@ func TestDecoder(t *testing.T) {
 		t.Fatal("")
 	}
 }
+
+func TestDecoderCELTSymbols(t *testing.T) {
+	d := &Decoder{}
+
+	// Magnitudes beyond the first are found in the decaying part of the
+	// PDF, with a probability of at least 1/32768 each
+	for _, test := range []struct {
+		data     []byte
+		expected int
+		tell     uint
+	}{
+		{[]byte{0x00, 0x00, 0x00, 0x00}, 0, 3},
+		{[]byte{0x80, 0x00, 0x00, 0x00}, 1, 4},
+		{[]byte{0xff, 0xff, 0xff, 0xff}, 30, 16},
+	} {
+		d.Init(test.data)
+		if tell := d.Tell(); tell != 1 {
+			t.Fatal(tell)
+		}
+		if result := d.DecodeLaplace(72<<7, 127<<6); result != test.expected {
+			t.Fatal(result)
+		} else if tell := d.Tell(); tell != test.tell {
+			t.Fatal(tell)
+		}
+	}
+
+	// Raw bits are read from the end of the data, and integers of more
+	// than 8 bits end with raw bits
+	d.Init([]byte{0xff, 0xff, 0x00, 0xa5})
+	switch {
+	case d.DecodeUniform(6) != 5:
+		t.Fatal()
+	case d.DecodeRawBits(4) != 0x5:
+		t.Fatal()
+	case d.DecodeRawBits(4) != 0xa:
+		t.Fatal()
+	case d.DecodeUniform(1000) != 996:
+		t.Fatal()
+	case d.Tell() != 22:
+		t.Fatal(d.Tell())
+	}
+}
+
+func FuzzDecoder(f *testing.F) {
+	f.Add([]byte{0x0b, 0xe4, 0xc1, 0x36, 0xec, 0xc5, 0x80}, uint(1))
+	f.Add([]byte{}, uint(8))
+	f.Add([]byte{0xff}, uint(15))
+
+	f.Fuzz(func(t *testing.T, data []byte, logp uint) {
+		d := &Decoder{}
+		d.Init(data)
+
+		for i := 0; i < 8*len(data)+32; i++ {
+			d.DecodeSymbolLogP(logp % 16)
+			d.DecodeSymbolWithICDF(silkModelGainDelta)
+			d.DecodeSymbolWithICDF(silkModelLsfS1[i%2][i%2])
+		}
+	})
+}
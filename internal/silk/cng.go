@@ -0,0 +1,96 @@
+package silk
+
+import "math"
+
+const (
+	// Weight of the most recent inactive frame when smoothing the comfort
+	// noise parameters, the noise follows slow changes in the background.
+	comfortNoiseSmoothing = 0.25
+)
+
+// updateComfortNoise tracks the spectral envelope and gain of inactive
+// frames. Comfort noise generation is not normative in RFC 6716, like the
+// reference decoder the envelope is smoothed in the NLSF domain, which keeps
+// the resulting LPC filter stable, and the excitation is built from randomly
+// selected samples of the excitation of the most recent inactive frame.
+func (d *Decoder) updateComfortNoise(nlsfQ15 []int16, bandwidth Bandwidth) {
+	gain := rootMeanSquare(d.concealExcitation)
+
+	if d.comfortNoiseBandwidth != bandwidth || len(d.comfortNoiseNLSFQ15) != len(nlsfQ15) {
+		d.comfortNoiseNLSFQ15 = append(d.comfortNoiseNLSFQ15[:0], nlsfQ15...)
+		d.comfortNoiseGain = gain
+		d.comfortNoiseBandwidth = bandwidth
+	} else {
+		for k := range nlsfQ15 {
+			delta := float32(nlsfQ15[k]) - float32(d.comfortNoiseNLSFQ15[k])
+			d.comfortNoiseNLSFQ15[k] += int16(delta * comfortNoiseSmoothing)
+		}
+		d.comfortNoiseGain += (gain - d.comfortNoiseGain) * comfortNoiseSmoothing
+	}
+
+	d.comfortNoiseExcitation = append(d.comfortNoiseExcitation[:0], d.concealExcitation...)
+	aQ12 := d.generateAQ12(d.comfortNoiseNLSFQ15, bandwidth, d.scratch.aQ12Halves[:0])
+	d.comfortNoiseAQ12 = append(d.comfortNoiseAQ12[:0], aQ12[0]...)
+}
+
+// ComfortNoise fills out with comfort noise for a period of discontinuous
+// transmission (DTX), during which the encoder doesn't send frames. The
+// noise follows the background of the inactive frames decoded before the
+// period started, out may have any length. Silence is produced when no
+// inactive frame of the same bandwidth has been decoded.
+//
+// https://datatracker.ietf.org/doc/html/rfc6716#section-2.1.9
+func (d *Decoder) ComfortNoise(out []float32, bandwidth Bandwidth) error {
+	if d.samplesInSubframe(bandwidth) == 0 {
+		return errUnsupportedSilkBandwidth
+	}
+
+	excitationGain := rootMeanSquare(d.comfortNoiseExcitation)
+	if d.comfortNoiseBandwidth != bandwidth || excitationGain == 0 {
+		for i := range out {
+			out[i] = 0
+		}
+		d.updateFinalOutValues(out)
+		return nil
+	}
+
+	dLPC := len(d.comfortNoiseAQ12)
+	if len(d.previousFrameLPCValues) != dLPC {
+		d.previousFrameLPCValues = make([]float32, dLPC)
+	}
+
+	// The LPC filter state is kept in previousFrameLPCValues, so the noise
+	// continues seamlessly across calls and into the next decoded frame
+	lpcHistory := d.previousFrameLPCValues
+	scale := d.comfortNoiseGain / excitationGain
+
+	for i := range out {
+		d.comfortNoiseSeed = 196314165*d.comfortNoiseSeed + 907633515
+		excitation := d.comfortNoiseExcitation[(d.comfortNoiseSeed>>16)%uint32(len(d.comfortNoiseExcitation))]
+
+		lpcVal := excitation * scale
+		for k := 0; k < dLPC; k++ {
+			lpcVal += lpcHistory[dLPC-k-1] * (d.comfortNoiseAQ12[k] / 4096.0)
+		}
+
+		copy(lpcHistory, lpcHistory[1:])
+		lpcHistory[dLPC-1] = lpcVal
+		out[i] = clampFloat(-1.0, lpcVal, 1.0)
+	}
+
+	d.updateFinalOutValues(out)
+	return nil
+}
+
+func rootMeanSquare(in []float32) float32 {
+	if len(in) == 0 {
+		return 0
+	}
+
+	var energy float64
+	for _, v := range in {
+		energy += float64(v) * float64(v)
+	}
+
+	return float32(math.Sqrt(energy / float64(len(in))))
+}
@@ -0,0 +1,55 @@
+package silk
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestComfortNoise(t *testing.T) {
+	d := NewDecoder()
+	out := make([]float32, 500)
+
+	isSilent := func() bool {
+		for _, v := range out {
+			if v != 0 {
+				return false
+			}
+		}
+		return true
+	}
+
+	if err := d.ComfortNoise(out, Bandwidth(0)); !errors.Is(err, errUnsupportedSilkBandwidth) {
+		t.Fatal(err)
+	}
+
+	// Nothing to base the noise on yet
+	if err := d.ComfortNoise(out, BandwidthWideband); err != nil {
+		t.Fatal(err)
+	} else if !isSilent() {
+		t.Fatal("comfort noise generated before an inactive frame was decoded")
+	}
+
+	// testSilkFrame is an inactive frame
+	if err := d.Decode(testSilkFrame(), make([]float32, 320), false, nanoseconds20Ms, BandwidthWideband); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.ComfortNoise(out, BandwidthWideband); err != nil {
+		t.Fatal(err)
+	} else if isSilent() {
+		t.Fatal("no comfort noise generated")
+	}
+
+	for _, v := range out {
+		if v < -1 || v > 1 {
+			t.Fatalf("comfort noise sample %f out of range", v)
+		}
+	}
+
+	// The envelope of a wideband frame can't be used for other bandwidths
+	if err := d.ComfortNoise(out, BandwidthNarrowband); err != nil {
+		t.Fatal(err)
+	} else if !isSilent() {
+		t.Fatal("comfort noise generated for a different bandwidth")
+	}
+}
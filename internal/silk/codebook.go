@@ -692,6 +692,48 @@ var (
 		{15, 21, 35, 50, 61, 73, 86, 97, 110, 119, 129, 141, 175, 198, 218, 237},
 	}
 
+	// +-------------+-----------+-----+
+	// | Coefficient | NB and MB |  WB |
+	// +-------------+-----------+-----+
+	// |           0 |       250 | 100 |
+	// |             |           |     |
+	// |           1 |         3 |   3 |
+	// |             |           |     |
+	// |           2 |         6 |  40 |
+	// |             |           |     |
+	// |           3 |         3 |   3 |
+	// |             |           |     |
+	// |           4 |         3 |   3 |
+	// |             |           |     |
+	// |           5 |         3 |   3 |
+	// |             |           |     |
+	// |           6 |         4 |   5 |
+	// |             |           |     |
+	// |           7 |         3 |  14 |
+	// |             |           |     |
+	// |           8 |         3 |  14 |
+	// |             |           |     |
+	// |           9 |         3 |  10 |
+	// |             |           |     |
+	// |          10 |       461 |  11 |
+	// |             |           |     |
+	// |          11 |           |   3 |
+	// |             |           |     |
+	// |          12 |           |   8 |
+	// |             |           |     |
+	// |          13 |           |   9 |
+	// |             |           |     |
+	// |          14 |           |   7 |
+	// |             |           |     |
+	// |          15 |           |   3 |
+	// |             |           |     |
+	// |          16 |           | 347 |
+	// +-------------+-----------+-----+
+	// Table 25: Minimum Spacing for Normalized LSF Coefficients
+
+	minimumSpacingNormalizedLSFNarrowbandAndMediumband = []int32{250, 3, 6, 3, 3, 3, 4, 3, 3, 3, 461}
+	minimumSpacingNormalizedLSFWideband                = []int32{100, 3, 40, 3, 3, 3, 5, 14, 14, 10, 11, 3, 8, 9, 7, 3, 347}
+
 	//  +-------------+-----------+----+
 	//  | Coefficient | NB and MB | WB |
 	//  +-------------+-----------+----+
@@ -1,6 +1,10 @@
 package silk
 
 import (
+	"math"
+	"math/bits"
+	"sort"
+
 	"github.com/pion/opus/internal/rangecoding"
 )
 
@@ -12,6 +16,9 @@ type Decoder struct {
 	// Have we decoded a frame yet?
 	haveDecoded bool
 
+	// The bandwidth of the most recently decoded frame
+	bandwidth Bandwidth
+
 	// Is the previous frame a voiced frame?
 	isPreviousFrameVoiced bool
 
@@ -31,15 +38,99 @@ type Decoder struct {
 	// n0Q15 are the LSF coefficients decoded for the prior frame
 	// see normalizeLSFInterpolation
 	n0Q15 []int16
+
+	// The excitation, LPC coefficients and final pitch lag of the most
+	// recent frame, used to conceal lost frames. See Conceal.
+	concealExcitation []float32
+	concealAQ12       []float32
+	concealPitchLag   int
+
+	// Number of consecutive frames concealed since the last decoded frame
+	lostFrames int
+
+	// The smoothed envelope and gain of inactive frames, and the excitation
+	// of the most recent one, used to generate comfort noise. See
+	// ComfortNoise.
+	comfortNoiseBandwidth  Bandwidth
+	comfortNoiseNLSFQ15    []int16
+	comfortNoiseAQ12       []float32
+	comfortNoiseGain       float32
+	comfortNoiseExcitation []float32
+	comfortNoiseSeed       uint32
+
+	// The state of the fixed-point reconstruction, see
+	// silkFrameReconstructionFixedPoint
+	fixedPoint      bool
+	previousGainQ16 int32
+	lpcStateQ14     [maxLPCOrder]int32
+	outBuffer       [2 * maxFrameLength]int16
+
+	// The resampler to 48 kHz of the fixed-point decoder, and the last
+	// sample of the previous frame it is delayed by. See Resample.
+	resampler              resampler
+	resamplerDelayedSample int16
+
+	// The parameters of the most recently decoded frame, which outlive
+	// the buffers of the frame. See DecodeParameters.
+	parameters FrameParameters
+
+	// Buffers reused by every frame, so decoding doesn't allocate
+	scratch decoderScratch
+}
+
+// decoderScratch holds the per-frame buffers of the Decoder. They are sized
+// for the largest frame, a 20 ms WB frame, and sliced to the size needed.
+type decoderScratch struct {
+	gainQ16     [subframeCount]float32
+	resQ10      [maxLPCOrder]int16
+	nlsfQ15     [maxLPCOrder]int16
+	n1Q15       [maxLPCOrder]int16
+	a32Q17      [maxLPCOrder]int32
+	aQ12        [2][maxLPCOrder]float32
+	aQ12Halves  [2][]float32
+	pitchLags   [subframeCount]int
+	bQ7         [subframeCount][ltpFilterTaps]int8
+	bQ7Slices   [subframeCount][]int8
+	pulsecounts [maxShellblocks]uint8
+	lsbcounts   [maxShellblocks]uint8
+	eRaw        [maxFrameLength]int32
+	eQ23        [maxFrameLength]int32
+	lpc         [maxFrameLength]float32
+	res         [maxFrameLength]float32
+	resLag      [maxPitchLag + 2]float32
+
+	// Integer versions of the gains and LPC coefficients, and the buffers
+	// of the fixed-point reconstruction
+	fixedGainQ16 [subframeCount]int32
+	fixedAQ12    [2][maxLPCOrder]int16
+	excQ14       [maxFrameLength]int32
+	resQ14       [maxFrameLength]int32
+	xq           [maxFrameLength]int16
+	sLTP         [maxFrameLength]int16
+	sLTPQ15      [2 * maxFrameLength]int32
+	sLPCQ14      [maxFrameLength/subframeCount + maxLPCOrder]int32
+
+	// The input and output of the resampler, NB frames are resampled by 6
+	resampleIn  [maxFrameLength]int16
+	resampleOut [6 * maxFrameLength]int16
 }
 
 // NewDecoder creates a new Silk Decoder
 func NewDecoder() Decoder {
 	return Decoder{
-		finalOutValues: make([]float32, 306),
+		finalOutValues: make([]float32, finalOutValuesLength),
 	}
 }
 
+// NewFixedPointDecoder creates a new Silk Decoder that reconstructs frames
+// with integer arithmetic modeled on the fixed-point reference decoder.
+// Every sample is a multiple of 1/32768.
+func NewFixedPointDecoder() Decoder {
+	d := NewDecoder()
+	d.fixedPoint = true
+	return d
+}
+
 // The LP layer begins with two to eight header bits These consist of one
 // Voice Activity Detection (VAD) bit per frame (up to 3), followed by a
 // single flag indicating the presence of LBRR frames.
@@ -111,7 +202,7 @@ func (d *Decoder) determineFrameType(voiceActivityDetected bool) (signalType fra
 // https://datatracker.ietf.org/doc/html/rfc6716#section-4.2.7.4
 func (d *Decoder) decodeSubframeQuantizations(signalType frameSignalType) (gainQ16 []float32) {
 	var logGain, deltaGainIndex, gainIndex int32
-	gainQ16 = make([]float32, 4)
+	gainQ16 = d.scratch.gainQ16[:]
 
 	for subframeIndex := 0; subframeIndex < subframeCount; subframeIndex++ {
 
@@ -179,7 +270,8 @@ func (d *Decoder) decodeSubframeQuantizations(signalType frameSignalType) (gainQ
 		// between 81920 and 1686110208, inclusive (representing scale factors
 		// of 1.25 to 25728, respectively).
 
-		gainQ16[subframeIndex] = float32((1 << i) + ((-174*f*(128-f)>>16)+f)*((1<<i)>>7))
+		d.scratch.fixedGainQ16[subframeIndex] = (1 << i) + ((-174*f*(128-f)>>16)+f)*((1<<i)>>7)
+		gainQ16[subframeIndex] = float32(d.scratch.fixedGainQ16[subframeIndex])
 	}
 
 	return
@@ -233,7 +325,8 @@ func (d *Decoder) normalizeLineSpectralFrequencyStageTwo(bandwidth Bandwidth, I1
 		codebook = codebookNormalizedLSFStageTwoIndexNarrowbandOrMediumband
 	}
 
-	I2 := make([]int8, len(codebook[0]))
+	var I2Buffer [maxLPCOrder]int8
+	I2 := I2Buffer[:len(codebook[0])]
 	for i := 0; i < len(I2); i++ {
 		// the decoder reads a symbol using the PDF corresponding
 		// to I1 from either Table 17 or Table 18 and subtracts 4 from the
@@ -274,7 +367,7 @@ func (d *Decoder) normalizeLineSpectralFrequencyStageTwo(bandwidth Bandwidth, I1
 	}
 
 	// stage-2 residual
-	resQ10 = make([]int16, len(I2))
+	resQ10 = d.scratch.resQ10[:len(I2)]
 
 	// Let d_LPC be the order of the codebook, i.e., 10 for NB and MB, and 16 for WB
 	dLPC = len(I2)
@@ -322,9 +415,11 @@ func (d *Decoder) normalizeLineSpectralFrequencyStageTwo(bandwidth Bandwidth, I1
 //
 // https://datatracker.ietf.org/doc/html/rfc6716#section-4.2.7.5.3
 func (d *Decoder) normalizeLineSpectralFrequencyCoefficients(dLPC int, bandwidth Bandwidth, resQ10 []int16, I1 uint32) (nlsfQ15 []int16) {
-	nlsfQ15 = make([]int16, dLPC)
-	w2Q18 := make([]uint, dLPC)
-	wQ9 := make([]int16, dLPC)
+	var w2Q18Buffer [maxLPCOrder]uint
+	var wQ9Buffer [maxLPCOrder]int16
+	nlsfQ15 = d.scratch.nlsfQ15[:dLPC]
+	w2Q18 := w2Q18Buffer[:dLPC]
+	wQ9 := wQ9Buffer[:dLPC]
 
 	cb1Q8 := codebookNormalizedLSFStageOneNarrowbandOrMediumband
 	if bandwidth == BandwidthWideband {
@@ -397,8 +492,115 @@ func (d *Decoder) normalizeLineSpectralFrequencyCoefficients(dLPC int, bandwidth
 // percentile of a large training set).
 //
 // https://datatracker.ietf.org/doc/html/rfc6716#section-4.2.7.5.4
-func (d *Decoder) normalizeLSFStabilization(nlsfQ15 []int16) {
-	// TODO
+func (d *Decoder) normalizeLSFStabilization(nlsfQ15 []int16, bandwidth Bandwidth) {
+	// The procedure starts off by trying to make small adjustments that
+	// attempt to minimize the amount of distortion introduced.  After 20
+	// such adjustments, it falls back to a more direct method that
+	// guarantees the constraints are enforced but may require large
+	// adjustments.
+	const maxAdjustments = 20
+
+	NDeltaMinQ15 := minimumSpacingNormalizedLSFNarrowbandAndMediumband
+	if bandwidth == BandwidthWideband {
+		NDeltaMinQ15 = minimumSpacingNormalizedLSFWideband
+	}
+
+	dLPC := len(nlsfQ15)
+
+	// Let NLSF_Q15[-1] = 0 and NLSF_Q15[d_LPC] = 32768
+	nlsf := func(k int) int32 {
+		switch {
+		case k < 0:
+			return 0
+		case k >= dLPC:
+			return 32768
+		}
+		return int32(nlsfQ15[k])
+	}
+
+	for adjustment := 0; adjustment < maxAdjustments; adjustment++ {
+		// First, the procedure finds the index i where NLSF_Q15[i] -
+		// NLSF_Q15[i-1] - NDeltaMin_Q15[i] is the smallest, breaking ties by
+		// using the lower value of i.
+		i := 0
+		minDiff := int32(math.MaxInt32)
+		for k := 0; k <= dLPC; k++ {
+			if diff := nlsf(k) - nlsf(k-1) - NDeltaMinQ15[k]; diff < minDiff {
+				i, minDiff = k, diff
+			}
+		}
+
+		// If this value is non-negative, then the stabilization stops; the
+		// coefficients satisfy all the constraints.
+		if minDiff >= 0 {
+			return
+		}
+
+		switch i {
+		// if i == 0, it sets NLSF_Q15[0] to NDeltaMin_Q15[0]
+		case 0:
+			nlsfQ15[0] = int16(NDeltaMinQ15[0])
+
+		// if i == d_LPC, it sets NLSF_Q15[d_LPC-1] to
+		// (32768 - NDeltaMin_Q15[d_LPC])
+		case dLPC:
+			nlsfQ15[dLPC-1] = int16(32768 - NDeltaMinQ15[dLPC])
+
+		// For all other values of i, both NLSF_Q15[i-1] and NLSF_Q15[i] are
+		// updated as follows:
+		//                                          i-1
+		//                                          __
+		//  min_center_Q15 = (NDeltaMin_Q15[i]>>1) + \  NDeltaMin_Q15[k]
+		//                                          /_
+		//                                          k=0
+		//                                                 d_LPC
+		//                                                  __
+		//  max_center_Q15 = 32768 - (NDeltaMin_Q15[i]>>1) - \  NDeltaMin_Q15[k]
+		//                                                  /_
+		//                                                 k=i+1
+		//     center_freq_Q15 = clamp(min_center_Q15[i],
+		//                     (NLSF_Q15[i-1] + NLSF_Q15[i] + 1)>>1,
+		//                     max_center_Q15[i])
+		//
+		//    NLSF_Q15[i-1] = center_freq_Q15 - (NDeltaMin_Q15[i]>>1)
+		//
+		//    NLSF_Q15[i] = NLSF_Q15[i-1] + NDeltaMin_Q15[i]
+		default:
+			minCenterQ15 := NDeltaMinQ15[i] >> 1
+			for k := 0; k < i; k++ {
+				minCenterQ15 += NDeltaMinQ15[k]
+			}
+
+			maxCenterQ15 := 32768 - (NDeltaMinQ15[i] >> 1)
+			for k := i + 1; k <= dLPC; k++ {
+				maxCenterQ15 -= NDeltaMinQ15[k]
+			}
+
+			centerFreqQ15 := clamp(minCenterQ15, (nlsf(i-1)+nlsf(i)+1)>>1, maxCenterQ15)
+
+			nlsfQ15[i-1] = int16(centerFreqQ15 - (NDeltaMinQ15[i] >> 1))
+			nlsfQ15[i] = int16(int32(nlsfQ15[i-1]) + NDeltaMinQ15[i])
+		}
+	}
+
+	// After the 20th adjustment, the coefficients are sorted into ascending
+	// order, and then each one is clamped to be at least NDeltaMin_Q15[k]
+	// larger than the previous one, and then at least NDeltaMin_Q15[k+1]
+	// smaller than the next one.
+	sort.Slice(nlsfQ15, func(a, b int) bool { return nlsfQ15[a] < nlsfQ15[b] })
+
+	//    NLSF_Q15[k] = max(NLSF_Q15[k], NLSF_Q15[k-1] + NDeltaMin_Q15[k])
+	//
+	// The sum saturates like silk_ADD_SAT16 in libopus, the second pass
+	// then moves the coefficients back below 32768.
+	for k := 0; k < dLPC; k++ {
+		nlsfQ15[k] = sat16(maxInt32(nlsf(k), nlsf(k-1)+NDeltaMinQ15[k]))
+	}
+
+	//    NLSF_Q15[k] = min(NLSF_Q15[k], NLSF_Q15[k+1] - NDeltaMin_Q15[k+1])
+	for k := dLPC - 1; k >= 0; k-- {
+		nlsfQ15[k] = int16(minInt32(nlsf(k), nlsf(k+1)-NDeltaMinQ15[k+1]))
+	}
 }
 
 // https://datatracker.ietf.org/doc/html/rfc6716#section-4.2.7.5.5
@@ -415,9 +617,10 @@ func (d *Decoder) normalizeLSFInterpolation(n2Q15 []int16) (n1Q15 []int16, wQ2 i
 		return nil, wQ2
 	}
 
-	n1Q15 = make([]int16, len(n2Q15))
+	n1Q15 = d.scratch.n1Q15[:len(n2Q15)]
 	for k := range n1Q15 {
-		n1Q15[k] = d.n0Q15[k] + (wQ2 * (n2Q15[k] - d.n0Q15[k]) >> 2)
+		// The difference between the coefficients doesn't fit in 16 bits
+		n1Q15[k] = int16(int32(d.n0Q15[k]) + (int32(wQ2)*(int32(n2Q15[k])-int32(d.n0Q15[k])))>>2)
 	}
 
 	return
@@ -435,12 +638,19 @@ func (d *Decoder) generateAQ12(Q15 []int16, bandwidth Bandwidth, aQ12 [][]float3
 	d.limitLPCCoefficientsRange(a32Q17)
 
 	// https://www.rfc-editor.org/rfc/rfc6716.html#section-4.2.7.5.8
-	aQ12 = append(aQ12, d.limitLPCFilterPredictionGain(a32Q17))
-	return aQ12
+	fixedAQ12 := d.limitLPCFilterPredictionGain(a32Q17, d.scratch.fixedAQ12[len(aQ12)][:len(a32Q17)])
+
+	floatAQ12 := d.scratch.aQ12[len(aQ12)][:len(a32Q17)]
+	for n := range fixedAQ12 {
+		floatAQ12[n] = float32(fixedAQ12[n])
+	}
+
+	return append(aQ12, floatAQ12)
 }
 
 func (d *Decoder) convertNormalizedLSFsToLPCCoefficients(n1Q15 []int16, bandwidth Bandwidth) (a32Q17 []int32) {
-	cQ17 := make([]int32, len(n1Q15))
+	var cQ17Buffer [maxLPCOrder]int32
+	cQ17 := cQ17Buffer[:len(n1Q15)]
 	cosQ12 := q12CosineTableForLSFConverion
 
 	ordering := lsfOrderingForPolynomialEvaluationNarrowbandAndMediumband
@@ -470,8 +680,9 @@ func (d *Decoder) convertNormalizedLSFsToLPCCoefficients(n1Q15 []int16, bandwidt
 			(cosQ12[i+1]-cosQ12[i])*f + 4) >> 3
 	}
 
-	pQ16 := make([]int32, (len(n1Q15)/2)+1)
-	qQ16 := make([]int32, (len(n1Q15)/2)+1)
+	var pQ16Buffer, qQ16Buffer [maxLPCOrder/2 + 1]int32
+	pQ16 := pQ16Buffer[:(len(n1Q15)/2)+1]
+	qQ16 := qQ16Buffer[:(len(n1Q15)/2)+1]
 
 	// Given the list of cosine values compute the coefficients of P and Q,
 	// described here via a simple recurrence.  Let p_Q16[k][j] and q_Q16[k][j]
@@ -539,7 +750,7 @@ func (d *Decoder) convertNormalizedLSFsToLPCCoefficients(n1Q15 []int16, bandwidt
 	//
 	// https://datatracker.ietf.org/doc/html/rfc6716#section-4.2.7.5.6
 
-	a32Q17 = make([]int32, len(n1Q15))
+	a32Q17 = d.scratch.a32Q17[:len(n1Q15)]
 	for k := 0; k < d2; k++ {
 		a32Q17[k] = -(qQ16[k+1] - qQ16[k]) - (pQ16[k+1] + pQ16[k])
 		a32Q17[dLPC-k-1] = (qQ16[k+1] - qQ16[k]) - (pQ16[k+1] + pQ16[k])
@@ -618,8 +829,8 @@ func (d *Decoder) decodeRatelevel(voiceActivityDetected bool) uint32 {
 //
 // https://datatracker.ietf.org/doc/html/rfc6716#section-4.2.7.8.2
 func (d *Decoder) decodePulseAndLSBCounts(shellblocks int, rateLevel uint32) (pulsecounts []uint8, lsbcounts []uint8) {
-	pulsecounts = make([]uint8, shellblocks)
-	lsbcounts = make([]uint8, shellblocks)
+	pulsecounts = d.scratch.pulsecounts[:shellblocks]
+	lsbcounts = d.scratch.lsbcounts[:shellblocks]
 	for i := 0; i < shellblocks; i++ {
 		pulsecounts[i] = uint8(d.rangeDecoder.DecodeSymbolWithICDF(icdfPulseCount[rateLevel]))
 
@@ -664,7 +875,11 @@ func (d *Decoder) decodePulseAndLSBCounts(shellblocks int, rateLevel uint32) (pu
 //
 // https://datatracker.ietf.org/doc/html/rfc6716#section-4.2.7.8.3
 func (d *Decoder) decodePulseLocation(pulsecounts []uint8) (eRaw []int32) {
-	eRaw = make([]int32, len(pulsecounts)*pulsecountLargestPartitionSize)
+	eRaw = d.scratch.eRaw[:len(pulsecounts)*pulsecountLargestPartitionSize]
+	for i := range eRaw {
+		eRaw[i] = 0
+	}
+
 	for i := range pulsecounts {
 		// This process skips partitions without any pulses, i.e., where
 		// the initial pulse count from Section 4.2.7.8.2 was zero, or where the
@@ -676,22 +891,19 @@ func (d *Decoder) decodePulseLocation(pulsecounts []uint8) (eRaw []int32) {
 		}
 
 		eRawIndex := pulsecountLargestPartitionSize * i
-		samplePartition16 := make([]uint8, 2)
-		samplePartition8 := make([]uint8, 2)
-		samplePartition4 := make([]uint8, 2)
-		samplePartition2 := make([]uint8, 2)
+		var samplePartition16, samplePartition8, samplePartition4, samplePartition2 [2]uint8
 
 		// The location of pulses is coded by recursively partitioning each
 		// block into halves, and coding how many pulses fall on the left side
 		// of the split.  All remaining pulses must fall on the right side of
 		// the split.
-		d.partitionPulseCount(icdfPulseCountSplit16SamplePartitions, pulsecounts[i], samplePartition16)
+		d.partitionPulseCount(icdfPulseCountSplit16SamplePartitions, pulsecounts[i], samplePartition16[:])
 		for j := 0; j < 2; j++ {
-			d.partitionPulseCount(icdfPulseCountSplit8SamplePartitions, samplePartition16[j], samplePartition8)
+			d.partitionPulseCount(icdfPulseCountSplit8SamplePartitions, samplePartition16[j], samplePartition8[:])
 			for k := 0; k < 2; k++ {
-				d.partitionPulseCount(icdfPulseCountSplit4SamplePartitions, samplePartition8[k], samplePartition4)
+				d.partitionPulseCount(icdfPulseCountSplit4SamplePartitions, samplePartition8[k], samplePartition4[:])
 				for l := 0; l < 2; l++ {
-					d.partitionPulseCount(icdfPulseCountSplit2SamplePartitions, samplePartition4[l], samplePartition2)
+					d.partitionPulseCount(icdfPulseCountSplit2SamplePartitions, samplePartition4[l], samplePartition2[:])
 					eRaw[eRawIndex] = int32(samplePartition2[0])
 					eRawIndex++
 
@@ -865,21 +1077,11 @@ func (d *Decoder) decodeExcitationSign(eRaw []int32, signalType frameSignalType,
 
 }
 
-// SILK codes the excitation using a modified version of the Pyramid
-// Vector Quantizer (PVQ) codebook [PVQ].  The PVQ codebook is designed
-// for Laplace-distributed values and consists of all sums of K signed,
-// unit pulses in a vector of dimension N, where two pulses at the same
-// position are required to have the same sign.
+// excitationQuantizationOffsetQ23 returns the constant quantization offset
+// that is added to each sample of the excitation.
 //
-// https://datatracker.ietf.org/doc/html/rfc6716#section-4.2.7.8
-func (d *Decoder) decodeExcitation(signalType frameSignalType, quantizationOffsetType frameQuantizationOffsetType, seed uint32, pulsecounts, lsbcounts []uint8) (eQ23 []int32) {
-	// After the signs have been read, there is enough information to
-	// reconstruct the complete excitation signal.  This requires adding a
-	// constant quantization offset to each non-zero sample and then
-	// pseudorandomly inverting and offsetting every sample.
-	//
-	// https://datatracker.ietf.org/doc/html/rfc6716#section-4.2.7.8.6
-
+// https://datatracker.ietf.org/doc/html/rfc6716#section-4.2.7.8.6
+func excitationQuantizationOffsetQ23(signalType frameSignalType, quantizationOffsetType frameQuantizationOffsetType) int32 {
 	// The constant quantization offset varies depending on the signal type and
 	// quantization offset type
 
@@ -900,22 +1102,41 @@ func (d *Decoder) decodeExcitation(signalType frameSignalType, quantizationOffse
 	// | Voiced      | High                     |                       25 |
 	// +-------------+--------------------------+--------------------------+
 	// Table 53: Excitation Quantization Offsets
-	var offsetQ23 int32
 	switch {
 	case signalType == frameSignalTypeInactive && quantizationOffsetType == frameQuantizationOffsetTypeLow:
-		offsetQ23 = 25
+		return 25
 	case signalType == frameSignalTypeInactive && quantizationOffsetType == frameQuantizationOffsetTypeHigh:
-		offsetQ23 = 60
+		return 60
 	case signalType == frameSignalTypeUnvoiced && quantizationOffsetType == frameQuantizationOffsetTypeLow:
-		offsetQ23 = 25
+		return 25
 	case signalType == frameSignalTypeUnvoiced && quantizationOffsetType == frameQuantizationOffsetTypeHigh:
-		offsetQ23 = 25
+		return 60
 	case signalType == frameSignalTypeVoiced && quantizationOffsetType == frameQuantizationOffsetTypeLow:
-		offsetQ23 = 8
+		return 8
 	case signalType == frameSignalTypeVoiced && quantizationOffsetType == frameQuantizationOffsetTypeHigh:
-		offsetQ23 = 25
+		return 25
 	}
 
+	return 0
+}
+
+// SILK codes the excitation using a modified version of the Pyramid
+// Vector Quantizer (PVQ) codebook [PVQ].  The PVQ codebook is designed
+// for Laplace-distributed values and consists of all sums of K signed,
+// unit pulses in a vector of dimension N, where two pulses at the same
+// position are required to have the same sign.
+//
+// https://datatracker.ietf.org/doc/html/rfc6716#section-4.2.7.8
+func (d *Decoder) decodeExcitation(signalType frameSignalType, quantizationOffsetType frameQuantizationOffsetType, seed uint32, pulsecounts, lsbcounts []uint8) (eQ23 []int32) {
+	// After the signs have been read, there is enough information to
+	// reconstruct the complete excitation signal.  This requires adding a
+	// constant quantization offset to each non-zero sample and then
+	// pseudorandomly inverting and offsetting every sample.
+	//
+	// https://datatracker.ietf.org/doc/html/rfc6716#section-4.2.7.8.6
+
+	offsetQ23 := excitationQuantizationOffsetQ23(signalType, quantizationOffsetType)
+
 	// Let e_raw[i] be the raw excitation value at position i,
 	// with a magnitude composed of the pulses at that location (see Section 4.2.7.8.3)
 	eRaw := d.decodePulseLocation(pulsecounts)
@@ -926,7 +1147,7 @@ func (d *Decoder) decodeExcitation(signalType frameSignalType, quantizationOffse
 	// and with the corresponding sign decoded in Section 4.2.7.8.5.
 	d.decodeExcitationSign(eRaw, signalType, quantizationOffsetType, pulsecounts)
 
-	eQ23 = make([]int32, len(eRaw))
+	eQ23 = d.scratch.eQ23[:len(eRaw)]
 	for i := 0; i < len(eRaw); i++ {
 		// Additionally, let seed be the current pseudorandom seed, which is initialized to the
 		// value decoded from Section 4.2.7.7 for the first sample in the current SILK frame, and
@@ -1021,10 +1242,8 @@ func (d *Decoder) limitLPCCoefficientsRange(a32Q17 []int32) {
 		//
 		// https://datatracker.ietf.org/doc/html/rfc6716#section-4.2.7.5.7
 		if maxabsQ12 > 32767 {
-			scQ16 := make([]uint, len(a32Q17))
-
-			scQ16[0] = uint(65470)
-			scQ16[0] -= ((maxabsQ12 - 32767) << 14) / ((maxabsQ12 * (maxabsQ17K + 1)) >> 2)
+			scQ16 := uint(65470)
+			scQ16 -= ((maxabsQ12 - 32767) << 14) / ((maxabsQ12 * (maxabsQ17K + 1)) >> 2)
 
 			// silk_bwexpander_32() (bwexpander_32.c) performs the bandwidth
 			// expansion (again, only when maxabs_Q12 is greater than 32767) using
@@ -1035,11 +1254,10 @@ func (d *Decoder) limitLPCCoefficientsRange(a32Q17 []int32) {
 			//           sc_Q16[k+1] = (sc_Q16[0]*sc_Q16[k] + 32768) >> 16
 			//
 			// https://datatracker.ietf.org/doc/html/rfc6716#section-4.2.7.5.7
+			chirpQ16 := scQ16
 			for k := 0; k < len(a32Q17); k++ {
-				a32Q17[k] = (a32Q17[k] * int32(scQ16[k])) >> 16
-				if len(scQ16) <= k {
-					scQ16[k+1] = (scQ16[0]*scQ16[k] + 32768) >> 16
-				}
+				a32Q17[k] = int32((int64(a32Q17[k]) * int64(chirpQ16)) >> 16)
+				chirpQ16 = (scQ16*chirpQ16 + 32768) >> 16
 			}
 		} else {
 			break
@@ -1054,7 +1272,7 @@ func (d *Decoder) limitLPCCoefficientsRange(a32Q17 []int32) {
 	// Because this performs the actual saturation in the Q12 domain, but
 	// saturation is not performed if maxabs_Q12 drops to 32767 or less
 	// prior to the 10th round.
-	if bandwidthExpansionRound == 9 {
+	if bandwidthExpansionRound == 10 {
 		for k := 0; k < len(a32Q17); k++ {
 			a32Q17[k] = clamp(-32768, (a32Q17[k]+16)>>5, 32767) << 5
 		}
@@ -1070,9 +1288,9 @@ func (d *Decoder) limitLPCCoefficientsRange(a32Q17 []int32) {
 // gain.
 //
 // https://datatracker.ietf.org/doc/html/rfc6716#section-4.2.7.5.8
-func (d *Decoder) limitLPCFilterPredictionGain(a32Q17 []int32) (aQ12 []float32) {
-	aQ12 = make([]float32, len(a32Q17))
-
+//
+// The result is written to aQ12, which must have the length of a32Q17.
+func (d *Decoder) limitLPCFilterPredictionGain(a32Q17 []int32, aQ12 []int16) []int16 {
 	// However, silk_LPC_inverse_pred_gain_QA() approximates this using
 	// fixed-point arithmetic to guarantee reproducible results across
 	// platforms and implementations.  Since small changes in the
@@ -1084,10 +1302,102 @@ func (d *Decoder) limitLPCFilterPredictionGain(a32Q17 []int32) (aQ12 []float32)
 	//
 	// https://datatracker.ietf.org/doc/html/rfc6716#section-4.2.7.5.8
 	for n := range a32Q17 {
-		aQ12[n] = float32((a32Q17[n] + 16) >> 5)
+		aQ12[n] = int16((a32Q17[n] + 16) >> 5)
 	}
 
-	return
+	// If the filter is unstable, or its prediction gain is too large,
+	// silk_NLSF2A() applies bandwidth expansion to a32_Q17 with the chirp
+	// factor
+	//
+	//     sc_Q16[0] = 65536 - (2<<i)
+	//
+	// where i is the round number, and recomputes a32_Q12.  After 15
+	// rounds the coefficients are used as they are.
+	//
+	// https://datatracker.ietf.org/doc/html/rfc6716#section-4.2.7.5.8
+	for round := 0; round < 16 && lpcInversePredictionGain(aQ12) == 0; round++ {
+		scQ16 := int64(65536 - (2 << round))
+		chirpQ16 := scQ16
+		for k := range a32Q17 {
+			a32Q17[k] = int32((int64(a32Q17[k]) * chirpQ16) >> 16)
+			chirpQ16 = (scQ16*chirpQ16 + 32768) >> 16
+		}
+
+		for n := range a32Q17 {
+			aQ12[n] = int16((a32Q17[n] + 16) >> 5)
+		}
+	}
+
+	return aQ12
+}
+
+// lpcInversePredictionGain returns the inverse of the prediction gain of
+// the filter in Q30, or zero if the filter is unstable or its prediction
+// gain exceeds 10000. It follows silk_LPC_inverse_pred_gain(), which
+// converts the coefficients to reflection coefficients with the
+// Levinson recursion run backwards.
+//
+// https://datatracker.ietf.org/doc/html/rfc6716#section-4.2.7.5.8
+func lpcInversePredictionGain(aQ12 []int16) int32 {
+	const (
+		// 0.99975 in Q24, the largest magnitude of a reflection coefficient
+		reflectionCoefficientLimitQ24 = 16773022
+
+		// 1/10000 in Q30, the smallest inverse prediction gain
+		minInversePredictionGainQ30 = 107374
+	)
+
+	// The DC response of the filter is checked first
+	var aQ24 [maxLPCOrder]int32
+	dcResponse := int32(0)
+	for k, a := range aQ12 {
+		dcResponse += int32(a)
+		aQ24[k] = int32(a) << 12
+	}
+	if dcResponse >= 4096 {
+		return 0
+	}
+
+	inverseGainQ30 := int32(1 << 30)
+	for k := len(aQ12) - 1; k >= 0; k-- {
+		if aQ24[k] > reflectionCoefficientLimitQ24 || aQ24[k] < -reflectionCoefficientLimitQ24 {
+			return 0
+		}
+
+		rcQ31 := -(aQ24[k] << 7)
+		rcMult1Q30 := (1 << 30) - smmul(rcQ31, rcQ31)
+
+		inverseGainQ30 = smmul(inverseGainQ30, rcMult1Q30) << 2
+		if inverseGainQ30 < minInversePredictionGainQ30 {
+			return 0
+		}
+
+		if k == 0 {
+			break
+		}
+
+		mult2Q := 32 - int(bits.LeadingZeros32(uint32(rcMult1Q30)))
+		rcMult2 := inverse32VarQ(rcMult1Q30, mult2Q+30)
+
+		for n := 0; n < (k+1)>>1; n++ {
+			tmp1 := aQ24[n]
+			tmp2 := aQ24[k-n-1]
+
+			v := rshiftRound64(int64(subSat32(tmp1, mul32FracQ31(tmp2, rcQ31)))*int64(rcMult2), mult2Q)
+			if v > math.MaxInt32 || v < math.MinInt32 {
+				return 0
+			}
+			aQ24[n] = int32(v)
+
+			v = rshiftRound64(int64(subSat32(tmp2, mul32FracQ31(tmp1, rcQ31)))*int64(rcMult2), mult2Q)
+			if v > math.MaxInt32 || v < math.MinInt32 {
+				return 0
+			}
+			aQ24[k-n-1] = int32(v)
+		}
+	}
+
+	return inverseGainQ30
 }
 
 // https://www.rfc-editor.org/rfc/rfc6716.html#section-4.2.7.6.1
@@ -1230,7 +1540,7 @@ func (d *Decoder) decodePitchLags(signalType frameSignalType, bandwidth Bandwidt
 	//
 	//     pitch_lags[k] = clamp(lag_min, lag + lag_cb[contour_index][k],
 	//                           lag_max)
-	pitchLags = make([]int, subframeCount)
+	pitchLags = d.scratch.pitchLags[:]
 	for i := 0; i < subframeCount; i++ {
 		pitchLags[i] = int(clamp(
 			int32(lagMin),
@@ -1290,17 +1600,16 @@ func (d *Decoder) decodeLTPFilterCoefficients(signalType frameSignalType) (bQ7 [
 		return
 	}
 
-	bQ7 = [][]int8{
-		make([]int8, 5),
-		make([]int8, 5),
-		make([]int8, 5),
-		make([]int8, 5),
+	for i := range d.scratch.bQ7Slices {
+		d.scratch.bQ7Slices[i] = d.scratch.bQ7[i][:]
 	}
+	bQ7 = d.scratch.bQ7Slices[:]
 
 	// This is signaled with an explicitly-coded "periodicity index".  This
 	// immediately follows the subframe pitch lags, and is coded using the
 	// 3-entry PDF from Table 37.
 	periodicityIndex := d.rangeDecoder.DecodeSymbolWithICDF(icdfPeriodicityIndex)
+	d.parameters.PeriodicityIndex = periodicityIndex
 
 	// The indices of the filters for each subframe follow.  They are all
 	// coded using the PDF from Table 38 corresponding to the periodicity
@@ -1318,6 +1627,7 @@ func (d *Decoder) decodeLTPFilterCoefficients(signalType frameSignalType) (bQ7 [
 		}
 
 		filterIndex := d.rangeDecoder.DecodeSymbolWithICDF(filterIndiceIcdf)
+		d.parameters.LTPFilterIndices[i] = filterIndex
 		var LTPFilterCodebook [][]int8
 
 		switch periodicityIndex {
@@ -1526,8 +1836,10 @@ func (d *Decoder) lpcSynthesis(out []float32, bandwidth Bandwidth, n, s, dLPC in
 		for k := 0; k < dLPC; k++ {
 			if lpcIndex := sampleIndex - k - 1; lpcIndex >= 0 {
 				currentLPCVal = lpc[lpcIndex]
-			} else if i < len(d.previousFrameLPCValues) && s == 0 {
-				currentLPCVal = d.previousFrameLPCValues[len(d.previousFrameLPCValues)-1+(i-k)]
+			} else if historyIndex := len(d.previousFrameLPCValues) + lpcIndex; historyIndex >= 0 && s == 0 {
+				// The previous frame may have had a different bandwidth and
+				// fewer LPC coefficients
+				currentLPCVal = d.previousFrameLPCValues[historyIndex]
 			} else {
 				currentLPCVal = 0
 			}
@@ -1548,7 +1860,7 @@ func (d *Decoder) lpcSynthesis(out []float32, bandwidth Bandwidth, n, s, dLPC in
 		// next subframe.  This requires storage for up to 16 values of lpc[i]
 		// (for WB frames).
 		if len(out)-1 == i && d.haveDecoded {
-			d.previousFrameLPCValues = append([]float32{}, lpc[len(lpc)-dLPC:]...)
+			d.previousFrameLPCValues = append(d.previousFrameLPCValues[:0], lpc[len(lpc)-dLPC:]...)
 		}
 		d.finalOutValues[len(d.finalOutValues)-n+i] = out[i]
 	}
@@ -1582,7 +1894,10 @@ func (d *Decoder) silkFrameReconstruction(
 
 	// let lpc[i] be the result of LPC synthesis from the last d_LPC samples of the
 	//  previous subframe or zeros in the first subframe for this channel
-	lpc := make([]float32, n*subframeCount)
+	lpc := d.scratch.lpc[:n*subframeCount]
+	for i := range lpc {
+		lpc[i] = 0
+	}
 
 	// For unvoiced frames (see Section 4.2.7.3), the LPC residual for i
 	// such that j <= i < (j + n) is simply a normalized copy of the
@@ -1591,8 +1906,25 @@ func (d *Decoder) silkFrameReconstruction(
 	//               e_Q23[i]
 	//     res[i] = ---------
 	//               2.0**23
-	res := make([]float32, len(eQ23))
-	resLag := make([]float32, lagMax+1)
+	res := d.scratch.res[:len(eQ23)]
+
+	if d.fixedPoint {
+		d.silkFrameReconstructionFixedPoint(signalType, bandwidth, dLPC, bQ7, pitchLags, eQ23, LTPscaleQ14, len(aQ12) > 1, res, out)
+
+		// Concealment continues from the final output of the frame
+		d.previousFrameLPCValues = append(d.previousFrameLPCValues[:0], out[len(res)-dLPC:len(res)]...)
+		d.updateFinalOutValues(out[:len(res)])
+		d.saveConcealmentState(signalType, n, pitchLags, aQ12, res, gainQ16)
+		return
+	}
+
+	// The rewhitening in ltpSynthesis starts pitch_lags[s] + 2 samples
+	// before the frame, and pitch lags can be as long as lagMax
+	resLag := d.scratch.resLag[:lagMax+2]
+	for i := range resLag {
+		resLag[i] = 0
+	}
+
 	for i := range res {
 		res[i] = float32(eQ23[i]) / 8388608.0
 	}
@@ -1638,6 +1970,25 @@ func (d *Decoder) silkFrameReconstruction(
 		//https://www.rfc-editor.org/rfc/rfc6716.html#section-4.2.7.9.2
 		d.lpcSynthesis(out[n*s:], bandwidth, n, s, dLPC, aQ12[aQ12Index], res, gainQ16, lpc)
 	}
+
+	d.saveConcealmentState(signalType, n, pitchLags, aQ12, res, gainQ16)
+}
+
+func (d *Decoder) saveConcealmentState(signalType frameSignalType, n int, pitchLags []int, aQ12 [][]float32, res, gainQ16 []float32) {
+	// Keep the residual scaled by the subframe gains, and the filters of
+	// the last subframe to conceal a following lost frame.
+	if len(d.concealExcitation) != len(res) {
+		d.concealExcitation = make([]float32, len(res))
+	}
+	for i := range res {
+		d.concealExcitation[i] = res[i] * gainQ16[i/n] / 65536.0
+	}
+
+	d.concealAQ12 = append(d.concealAQ12[:0], aQ12[len(aQ12)-1]...)
+	d.concealPitchLag = 0
+	if signalType == frameSignalTypeVoiced {
+		d.concealPitchLag = pitchLags[subframeCount-1]
+	}
 }
 
 // Decode decodes many SILK subframes
@@ -1674,21 +2025,142 @@ func (d *Decoder) silkFrameReconstruction(
 //
 // https://datatracker.ietf.org/doc/html/rfc6716#section-4.2.1
 func (d *Decoder) Decode(in []byte, out []float32, isStereo bool, nanoseconds int, bandwidth Bandwidth) error {
-	subframeSize := d.samplesInSubframe(bandwidth)
+	if err := d.validateFrame(out, isStereo, nanoseconds, bandwidth); err != nil {
+		return err
+	}
+
+	voiceActivityDetected, _ := d.decodeFrameStart(in, nanoseconds, bandwidth)
+	d.decodeFrame(voiceActivityDetected, nanoseconds, bandwidth, out)
+	return nil
+}
+
+// DecodeFEC decodes the LBRR frame of a SILK frame instead of the regular
+// frame. The LBRR frame is a lower bitrate copy of the previous frame, so
+// it can be used to recover the audio of a lost packet from the packet
+// that followed it.
+//
+// https://datatracker.ietf.org/doc/html/rfc6716#section-4.2.4
+func (d *Decoder) DecodeFEC(in []byte, out []float32, isStereo bool, nanoseconds int, bandwidth Bandwidth) error {
+	if err := d.validateFrame(out, isStereo, nanoseconds, bandwidth); err != nil {
+		return err
+	}
+
+	d.rangeDecoder.Init(in)
+
+	if _, lowBitRateRedundancy := d.decodeHeaderBits(); !lowBitRateRedundancy {
+		return errNoLowBitRateRedundancy
+	}
+
+	// LBRR frames are only coded for frames with voice activity
+	d.decodeFrame(true, nanoseconds, bandwidth, out)
+	return nil
+}
+
+// FinalRange returns the final state of the range decoder after decoding the
+// most recent frame.
+func (d *Decoder) FinalRange() uint32 {
+	return d.rangeDecoder.FinalRange()
+}
+
+func (d *Decoder) validateFrame(out []float32, isStereo bool, nanoseconds int, bandwidth Bandwidth) error {
+	if err := d.validateFrameParameters(isStereo, nanoseconds, bandwidth); err != nil {
+		return err
+	}
+
+	if (d.samplesInSubframe(bandwidth) * subframeCount) > len(out) {
+		return errOutBufferTooSmall
+	}
+
+	return nil
+}
+
+func (d *Decoder) validateFrameParameters(isStereo bool, nanoseconds int, bandwidth Bandwidth) error {
 	switch {
 	case nanoseconds != nanoseconds20Ms:
 		return errUnsupportedSilkFrameDuration
 	case isStereo:
 		return errUnsupportedSilkStereo
-	case (subframeSize * subframeCount) > len(out):
-		return errOutBufferTooSmall
+	case d.samplesInSubframe(bandwidth) == 0:
+		return errUnsupportedSilkBandwidth
 	}
 
+	return nil
+}
+
+// resetBandwidth resets the state that carries over between frames when the
+// internal sample rate changes. The number of LPC coefficients and the
+// length of the buffered output depend on it, so like the reference decoder
+// the first frame at the new rate is decoded as if it were the first frame
+// of the stream.
+func (d *Decoder) resetBandwidth(bandwidth Bandwidth) {
+	d.bandwidth = bandwidth
+	d.haveDecoded = false
+	d.isPreviousFrameVoiced = false
+	d.previousLogGain = 0
+	d.previousFrameLPCValues = nil
+	d.n0Q15 = nil
+
+	for i := range d.finalOutValues {
+		d.finalOutValues[i] = 0
+	}
+
+	d.lpcStateQ14 = [maxLPCOrder]int32{}
+	d.outBuffer = [2 * maxFrameLength]int16{}
+}
+
+// When the LBRR flag is set the LBRR frames precede the regular SILK
+// frames. They are coded exactly like regular SILK frames, so all of their
+// symbols have to be decoded to reach the regular frame. The quantization
+// gain of an LBRR frame doesn't carry over to the regular frames.
+//
+// https://datatracker.ietf.org/doc/html/rfc6716#section-4.2.4
+func (d *Decoder) skipLowBitRateRedundancyFrame(nanoseconds int, bandwidth Bandwidth) {
+	previousLogGain, parameters := d.previousLogGain, d.parameters
+
+	signalType, quantizationOffsetType := d.determineFrameType(true)
+	d.decodeSubframeQuantizations(signalType)
+
+	I1 := d.normalizeLineSpectralFrequencyStageOne(signalType == frameSignalTypeVoiced, bandwidth)
+	d.normalizeLineSpectralFrequencyStageTwo(bandwidth, I1)
+	d.rangeDecoder.DecodeSymbolWithICDF(icdfNormalizedLSFInterpolationIndex)
+
+	d.decodePitchLags(signalType, bandwidth)
+	d.decodeLTPFilterCoefficients(signalType)
+	d.decodeLTPScalingParamater(signalType)
+	lcgSeed := d.decodeLinearCongruentialGeneratorSeed()
+
+	shellblocks := d.decodeShellblocks(nanoseconds, bandwidth)
+	rateLevel := d.decodeRatelevel(signalType == frameSignalTypeVoiced)
+	pulsecounts, lsbcounts := d.decodePulseAndLSBCounts(shellblocks, rateLevel)
+	d.decodeExcitation(signalType, quantizationOffsetType, lcgSeed, pulsecounts, lsbcounts)
+
+	d.previousLogGain, d.parameters = previousLogGain, parameters
+}
+
+// decodeFrameStart decodes the header bits of the frame in, and skips the
+// LBRR frame that precedes the regular frame. Like the reference decoder,
+// the state is reset for a new bandwidth before any of the frame is
+// decoded, so the LBRR frame and the regular frame are decoded alike.
+func (d *Decoder) decodeFrameStart(in []byte, nanoseconds int, bandwidth Bandwidth) (voiceActivityDetected, lowBitRateRedundancy bool) {
 	d.rangeDecoder.Init(in)
 
-	voiceActivityDetected, lowBitRateRedundancy := d.decodeHeaderBits()
+	if d.bandwidth != bandwidth {
+		d.resetBandwidth(bandwidth)
+	}
+
+	voiceActivityDetected, lowBitRateRedundancy = d.decodeHeaderBits()
 	if lowBitRateRedundancy {
-		return errUnsupportedSilkLowBitrateRedundancy
+		d.skipLowBitRateRedundancyFrame(nanoseconds, bandwidth)
+	}
+
+	return
+}
+
+// decodeFrame decodes the parameters of a SILK frame, and reconstructs its
+// audio into out unless out is nil.
+func (d *Decoder) decodeFrame(voiceActivityDetected bool, nanoseconds int, bandwidth Bandwidth, out []float32) {
+	if d.bandwidth != bandwidth {
+		d.resetBandwidth(bandwidth)
 	}
 
 	signalType, quantizationOffsetType := d.determineFrameType(voiceActivityDetected)
@@ -1706,7 +2178,7 @@ func (d *Decoder) Decode(in []byte, out []float32, isStereo bool, nanoseconds in
 	nlsfQ15 := d.normalizeLineSpectralFrequencyCoefficients(dLPC, bandwidth, resQ10, I1)
 
 	// https://datatracker.ietf.org/doc/html/rfc6716#section-4.2.7.5.4
-	d.normalizeLSFStabilization(nlsfQ15)
+	d.normalizeLSFStabilization(nlsfQ15, bandwidth)
 
 	// https://datatracker.ietf.org/doc/html/rfc6716#section-4.2.7.5.5
 	n1Q15, wQ2 := d.normalizeLSFInterpolation(nlsfQ15)
@@ -1717,7 +2189,7 @@ func (d *Decoder) Decode(in []byte, out []float32, isStereo bool, nanoseconds in
 	// (in the same channel) and the current frame
 	//
 	// https://datatracker.ietf.org/doc/html/rfc6716#section-4.2.7.5.5
-	aQ12 := [][]float32{}
+	aQ12 := d.scratch.aQ12Halves[:0]
 	aQ12 = d.generateAQ12(n1Q15, bandwidth, aQ12)
 	aQ12 = d.generateAQ12(nlsfQ15, bandwidth, aQ12)
 
@@ -1745,19 +2217,23 @@ func (d *Decoder) Decode(in []byte, out []float32, isStereo bool, nanoseconds in
 	// https://www.rfc-editor.org/rfc/rfc6716.html#section-4.2.7.8.6
 	eQ23 := d.decodeExcitation(signalType, quantizationOffsetType, lcgSeed, pulsecounts, lsbcounts)
 
+	d.saveFrameParameters(voiceActivityDetected, signalType, quantizationOffsetType, I1, nlsfQ15, wQ2, pitchLags, LTPscaleQ14, lcgSeed, pulsecounts, lsbcounts)
+
 	// https://www.rfc-editor.org/rfc/rfc6716.html#section-4.2.7.9
-	d.silkFrameReconstruction(
-		signalType, bandwidth,
-		dLPC,
-		lagMax,
-		bQ7,
-		pitchLags,
-		eQ23,
-		LTPscaleQ14,
-		wQ2,
-		aQ12,
-		gainQ16, out,
-	)
+	if out != nil {
+		d.silkFrameReconstruction(
+			signalType, bandwidth,
+			dLPC,
+			lagMax,
+			bQ7,
+			pitchLags,
+			eQ23,
+			LTPscaleQ14,
+			wQ2,
+			aQ12,
+			gainQ16, out,
+		)
+	}
 
 	// n0Q15 is the LSF coefficients decoded for the prior frame
 	// see normalizeLSFInterpolation.
@@ -1766,8 +2242,12 @@ func (d *Decoder) Decode(in []byte, out []float32, isStereo bool, nanoseconds in
 	}
 
 	copy(d.n0Q15, nlsfQ15)
+
+	if signalType == frameSignalTypeInactive {
+		d.updateComfortNoise(nlsfQ15, bandwidth)
+	}
+
 	d.isPreviousFrameVoiced = signalType == frameSignalTypeVoiced
 	d.haveDecoded = true
-
-	return nil
+	d.lostFrames = 0
 }
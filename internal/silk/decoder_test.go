@@ -2,6 +2,7 @@ package silk
 
 import (
 	"errors"
+	"math"
 	"reflect"
 	"testing"
 
@@ -102,6 +103,83 @@ func TestNormalizeLineSpectralFrequencyCoefficients(t *testing.T) {
 	}
 }
 
+func TestNormalizeLSFStabilization(t *testing.T) {
+	isStable := func(nlsfQ15 []int16, NDeltaMinQ15 []int32) bool {
+		previous := int32(0)
+		for k := range nlsfQ15 {
+			if int32(nlsfQ15[k])-previous < NDeltaMinQ15[k] {
+				return false
+			}
+			previous = int32(nlsfQ15[k])
+		}
+
+		return 32768-previous >= NDeltaMinQ15[len(nlsfQ15)]
+	}
+
+	t.Run("Stable", func(t *testing.T) {
+		d := &Decoder{}
+
+		nlsfQ15 := testNlsfQ1()
+		d.normalizeLSFStabilization(nlsfQ15, BandwidthWideband)
+		if !reflect.DeepEqual(nlsfQ15, testNlsfQ1()) {
+			t.Fatal("stable coefficients were modified")
+		}
+	})
+
+	t.Run("Adjust Pair", func(t *testing.T) {
+		d := &Decoder{}
+
+		nlsfQ15 := []int16{1000, 1001, 5000, 8000, 11000, 14000, 17000, 20000, 23000, 26000}
+		d.normalizeLSFStabilization(nlsfQ15, BandwidthNarrowband)
+
+		expected := []int16{1000, 1003, 5000, 8000, 11000, 14000, 17000, 20000, 23000, 26000}
+		if !reflect.DeepEqual(nlsfQ15, expected) {
+			t.Fatalf("%v != %v", nlsfQ15, expected)
+		}
+	})
+
+	t.Run("Adjust Edges", func(t *testing.T) {
+		d := &Decoder{}
+
+		nlsfQ15 := []int16{100, 3000, 5000, 8000, 11000, 14000, 17000, 20000, 23000, 32700}
+		d.normalizeLSFStabilization(nlsfQ15, BandwidthNarrowband)
+
+		expected := []int16{250, 3000, 5000, 8000, 11000, 14000, 17000, 20000, 23000, 32307}
+		if !reflect.DeepEqual(nlsfQ15, expected) {
+			t.Fatalf("%v != %v", nlsfQ15, expected)
+		}
+	})
+
+	t.Run("Fallback", func(t *testing.T) {
+		d := &Decoder{}
+
+		// Too many violations to be fixed by 20 adjustments
+		nlsfQ15 := make([]int16, 16)
+		d.normalizeLSFStabilization(nlsfQ15, BandwidthWideband)
+		if !isStable(nlsfQ15, minimumSpacingNormalizedLSFWideband) {
+			t.Fatalf("%v is not stable", nlsfQ15)
+		}
+
+		// Out of order coefficients are sorted
+		nlsfQ15 = []int16{26000, 23000, 20000, 17000, 14000, 11000, 8000, 5000, 3000, 1000}
+		d.normalizeLSFStabilization(nlsfQ15, BandwidthNarrowband)
+		if !isStable(nlsfQ15, minimumSpacingNormalizedLSFNarrowbandAndMediumband) {
+			t.Fatalf("%v is not stable", nlsfQ15)
+		}
+
+		// Coefficients near the top of the range that 20 adjustments
+		// don't fix saturate instead of wrapping
+		nlsfQ15 = []int16{
+			32545, 32524, 32719, 32502, 31827, 31297, 31528, 32649,
+			32544, 31389, 31635, 32637, 31440, 32766, 32765, 32580,
+		}
+		d.normalizeLSFStabilization(nlsfQ15, BandwidthWideband)
+		if !isStable(nlsfQ15, minimumSpacingNormalizedLSFWideband) {
+			t.Fatalf("%v is not stable", nlsfQ15)
+		}
+	})
+}
+
 func TestNormalizeLSFInterpolation(t *testing.T) {
 	t.Run("wQ2 == 4", func(t *testing.T) {
 		d := &Decoder{rangeDecoder: createRangeDecoder(testSilkFrame(), 55, 493249168, 174371199)}
@@ -163,6 +241,34 @@ func TestLimitLPCCoefficientsRange(t *testing.T) {
 	}
 
 	d.limitLPCCoefficientsRange(A32Q17)
+
+	// Coefficients that are still too large after 10 rounds of bandwidth
+	// expansion are saturated to 16 bits in Q12
+	A32Q17 = make([]int32, 16)
+	A32Q17[15] = math.MaxInt32
+	d.limitLPCCoefficientsRange(A32Q17)
+	if A32Q17[15] != 32767<<5 {
+		t.Fatal(A32Q17[15])
+	}
+}
+
+func TestExcitationQuantizationOffset(t *testing.T) {
+	for _, test := range []struct {
+		signalType             frameSignalType
+		quantizationOffsetType frameQuantizationOffsetType
+		offsetQ23              int32
+	}{
+		{frameSignalTypeInactive, frameQuantizationOffsetTypeLow, 25},
+		{frameSignalTypeInactive, frameQuantizationOffsetTypeHigh, 60},
+		{frameSignalTypeUnvoiced, frameQuantizationOffsetTypeLow, 25},
+		{frameSignalTypeUnvoiced, frameQuantizationOffsetTypeHigh, 60},
+		{frameSignalTypeVoiced, frameQuantizationOffsetTypeLow, 8},
+		{frameSignalTypeVoiced, frameQuantizationOffsetTypeHigh, 25},
+	} {
+		if offsetQ23 := excitationQuantizationOffsetQ23(test.signalType, test.quantizationOffsetType); offsetQ23 != test.offsetQ23 {
+			t.Fatalf("%d %d: %d != %d", test.signalType, test.quantizationOffsetType, offsetQ23, test.offsetQ23)
+		}
+	}
 }
 
 func TestExcitation(t *testing.T) {
@@ -210,17 +316,73 @@ func TestLimitLPCFilterPredictionGain(t *testing.T) {
 		-4493, -1614, -1960, -3112, -2153, -2898,
 	}
 
-	expectedAQ12 := []float32{
+	expectedAQ12 := []int16{
 		405, 305, 131, 114, -118, -138, -72, -146, -108, -120, -140, -50, -61,
 		-97, -67, -91,
 	}
 
-	aQ12 := d.limitLPCFilterPredictionGain(a32Q17)
+	aQ12 := d.limitLPCFilterPredictionGain(a32Q17, make([]int16, len(a32Q17)))
 	if !reflect.DeepEqual(aQ12, expectedAQ12) {
 		t.Fatal()
 	}
 }
 
+func TestLPCInversePredictionGain(t *testing.T) {
+	// The inverse of the prediction gain is the product of 1 - k^2 over
+	// the reflection coefficients k of the filter
+	expectedGain := func(aQ12 []int16) float64 {
+		a := make([]float64, len(aQ12))
+		for n := range aQ12 {
+			a[n] = float64(aQ12[n]) / 4096
+		}
+
+		gain := 1.0
+		for k := len(a) - 1; k >= 0; k-- {
+			rc := a[k]
+			gain *= 1 - rc*rc
+
+			previous := make([]float64, k)
+			for n := range previous {
+				previous[n] = (a[n] + rc*a[k-n-1]) / (1 - rc*rc)
+			}
+			a = previous
+		}
+
+		return gain
+	}
+
+	for _, test := range []struct {
+		name   string
+		aQ12   []int16
+		stable bool
+	}{
+		{"Stable", []int16{405, 305, 131, 114, -118, -138, -72, -146, -108, -120, -140, -50, -61, -97, -67, -91}, true},
+		{"First Order", []int16{2048}, true},
+		{"DC Response", []int16{2048, 2048}, false},
+		{"Reflection Coefficient", []int16{0, -4095}, false},
+	} {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			gainQ30 := lpcInversePredictionGain(test.aQ12)
+			switch {
+			case (gainQ30 != 0) != test.stable:
+				t.Fatal(gainQ30)
+			case test.stable && math.Abs(float64(gainQ30)/(1<<30)-expectedGain(test.aQ12)) > 1e-4:
+				t.Fatalf("%f != %f", float64(gainQ30)/(1<<30), expectedGain(test.aQ12))
+			}
+		})
+	}
+
+	t.Run("Limited", func(t *testing.T) {
+		d := &Decoder{}
+		a32Q17 := []int32{0, -4095 << 5}
+		aQ12 := d.limitLPCFilterPredictionGain(a32Q17, make([]int16, len(a32Q17)))
+		if lpcInversePredictionGain(aQ12) == 0 {
+			t.Fatal(aQ12)
+		}
+	})
+}
+
 func TestLPCSynthesis(t *testing.T) {
 	d := NewDecoder()
 
@@ -596,3 +758,54 @@ func TestDecode(t *testing.T) {
 		compareBuffer(out, expectedOut, t)
 	})
 }
+
+func FuzzDecode(f *testing.F) {
+	f.Add(testSilkFrame(), uint8(BandwidthWideband))
+	f.Add([]byte{0xac, 0xbd, 0xa9, 0xf7, 0x26, 0x24, 0x5a, 0xa4, 0x00, 0x37, 0xbf, 0x9c, 0xde, 0xe, 0xcf, 0x94, 0x64, 0xaa, 0xf9, 0x87, 0xd0, 0x79, 0x19, 0xa8, 0x21, 0xc0}, uint8(BandwidthWideband))
+	f.Add(testSilkFrame(), uint8(BandwidthNarrowband))
+	f.Add(testSilkFrame(), uint8(BandwidthMediumband))
+	f.Add([]byte{}, uint8(BandwidthNarrowband))
+
+	out := make([]float32, 320)
+	f.Fuzz(func(t *testing.T, in []byte, bandwidth uint8) {
+		// Every bandwidth is decoded, the input selects the first one
+		d := NewDecoder()
+		for i := uint8(0); i < 3; i++ {
+			b := Bandwidth((bandwidth+i)%3 + 1)
+			_ = d.Decode(in, out, false, nanoseconds20Ms, b)
+			_ = d.DecodeFEC(in, out, false, nanoseconds20Ms, b)
+			_ = d.Conceal(out, nanoseconds20Ms, b)
+			_ = d.ComfortNoise(out, b)
+		}
+	})
+}
+
+func TestDecodeAllocs(t *testing.T) {
+	d := NewDecoder()
+	out := make([]float32, 320)
+	frames := [][]byte{
+		testSilkFrame(),
+		{0xac, 0xbd, 0xa9, 0xf7, 0x26, 0x24, 0x5a, 0xa4, 0x00, 0x37, 0xbf, 0x9c, 0xde, 0xe, 0xcf, 0x94, 0x64, 0xaa, 0xf9, 0x87, 0xd0, 0x79, 0x19, 0xa8, 0x21, 0xc0},
+	}
+
+	decode := func() {
+		for _, frame := range frames {
+			if err := d.Decode(frame, out, false, nanoseconds20Ms, BandwidthWideband); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := d.Conceal(out, nanoseconds20Ms, BandwidthWideband); err != nil {
+			t.Fatal(err)
+		}
+		if err := d.ComfortNoise(out, BandwidthWideband); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The first call sizes the history buffers
+	decode()
+
+	if allocs := testing.AllocsPerRun(100, decode); allocs != 0 {
+		t.Fatalf("Decode allocated %f times per run", allocs)
+	}
+}
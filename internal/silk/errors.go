@@ -3,9 +3,11 @@ package silk
 import "errors"
 
 var (
-	errUnsupportedSilkFrameDuration        = errors.New("only silk frames with a duration of 20ms supported")
-	errUnsupportedSilkStereo               = errors.New("silk decoder does not support stereo")
-	errUnsupportedSilkLowBitrateRedundancy = errors.New("silk decoder does not low bit-rate redundancy")
-	errUnsupportedLSFInterpolation         = errors.New("silk decoder does not support LSF Interpolation")
-	errOutBufferTooSmall                   = errors.New("out isn't large enough")
+	errUnsupportedSilkFrameDuration = errors.New("only silk frames with a duration of 20ms supported")
+	errUnsupportedSilkStereo        = errors.New("silk decoder does not support stereo")
+	errNoLowBitRateRedundancy       = errors.New("silk frame does not contain low bit-rate redundancy")
+	errUnsupportedSilkBandwidth     = errors.New("silk decoder does not support bandwidth")
+	errOutBufferTooSmall            = errors.New("out isn't large enough")
+	errUnsupportedStateVersion      = errors.New("silk decoder state has an unsupported version")
+	errInvalidState                 = errors.New("silk decoder state is invalid")
 )
@@ -0,0 +1,308 @@
+package silk
+
+import (
+	"math"
+	"math/bits"
+)
+
+// The remainder of the reconstruction process for the frame does not
+// need to be bit-exact [...] Although the reference implementation only
+// includes a fixed-point version of the remaining steps, this section
+// describes them in terms of a floating-point version for simplicity.
+//
+// The fixed-point reconstruction follows silk_decode_core() (decode_core.c)
+// of the reference implementation instead, so every output sample is a
+// 16-bit integer, which Resample converts to 48 kHz like the reference
+// decoder. The conformance package compares the result with the output of
+// libopus built with --enable-fixed-point.
+//
+// https://www.rfc-editor.org/rfc/rfc6716.html#section-4.2.7.9
+func (d *Decoder) silkFrameReconstructionFixedPoint(
+	signalType frameSignalType, bandwidth Bandwidth,
+	dLPC int,
+	bQ7 [][]int8,
+	pitchLags []int,
+	eQ23 []int32,
+	LTPscaleQ14 float32,
+	isInterpolated bool,
+	res, out []float32,
+) {
+	n := d.samplesInSubframe(bandwidth)
+	frameLength := n * subframeCount
+
+	// The LTP memory of the reference decoder holds 20 ms of output,
+	// the length of a frame
+	ltpMemoryLength := frameLength
+
+	if d.previousGainQ16 == 0 {
+		d.previousGainQ16 = 1 << 16
+	}
+
+	// e_Q23 is the excitation in Q8, the reference decoder keeps it in Q14
+	excQ14 := d.scratch.excQ14[:frameLength]
+	for i := range excQ14 {
+		excQ14[i] = eQ23[i] << 6
+	}
+
+	sLTP := d.scratch.sLTP[:ltpMemoryLength]
+	sLTPQ15 := d.scratch.sLTPQ15[:ltpMemoryLength+frameLength]
+	sLTPBufferIndex := ltpMemoryLength
+
+	// The LPC filter state is kept in front of the samples of the current
+	// subframe
+	sLPCQ14 := d.scratch.sLPCQ14[:n+maxLPCOrder]
+	copy(sLPCQ14, d.lpcStateQ14[:])
+
+	xq := d.scratch.xq[:frameLength]
+	for s := 0; s < subframeCount; s++ {
+		// The first half of the frame may use interpolated coefficients,
+		// see silkFrameReconstruction
+		aQ12Index := 0
+		if s > 1 && isInterpolated {
+			aQ12Index = 1
+		}
+		aQ12 := d.scratch.fixedAQ12[aQ12Index][:dLPC]
+
+		subframeExcQ14 := excQ14[s*n : (s+1)*n]
+		resQ14 := d.scratch.resQ14[s*n : (s+1)*n]
+		gainQ16 := d.scratch.fixedGainQ16[s]
+		gainQ10 := gainQ16 >> 6
+		inverseGainQ31 := inverse32VarQ(gainQ16, 47)
+
+		// The filter states are scaled by the ratio of the previous and
+		// current gains
+		gainAdjustQ16 := int32(1 << 16)
+		if gainQ16 != d.previousGainQ16 {
+			gainAdjustQ16 = div32VarQ(d.previousGainQ16, gainQ16, 16)
+			for i := 0; i < maxLPCOrder; i++ {
+				sLPCQ14[i] = smulww(gainAdjustQ16, sLPCQ14[i])
+			}
+		}
+		d.previousGainQ16 = gainQ16
+
+		if signalType == frameSignalTypeVoiced {
+			lag := pitchLags[s]
+
+			// The LTP state is rewhitened with the LPC filter of the first
+			// subframe, and again in the third subframe when the first half
+			// of the frame used interpolated coefficients.
+			if s == 0 || (s == 2 && isInterpolated) {
+				startIndex := ltpMemoryLength - lag - dLPC - ltpFilterTaps/2
+				if s == 2 {
+					copy(d.outBuffer[ltpMemoryLength:], xq[:2*n])
+				}
+
+				lpcAnalysisFilter(sLTP[startIndex:], d.outBuffer[startIndex+s*n:], aQ12, ltpMemoryLength-startIndex)
+
+				// The LTP state of the first subframe is scaled down to
+				// limit the propagation of errors between packets
+				if s == 0 {
+					inverseGainQ31 = smulwb(inverseGainQ31, int32(LTPscaleQ14)) << 2
+				}
+				for i := 0; i < lag+ltpFilterTaps/2; i++ {
+					sLTPQ15[sLTPBufferIndex-i-1] = smulwb(inverseGainQ31, int32(sLTP[ltpMemoryLength-i-1]))
+				}
+			} else if gainAdjustQ16 != 1<<16 {
+				for i := 0; i < lag+ltpFilterTaps/2; i++ {
+					sLTPQ15[sLTPBufferIndex-i-1] = smulww(gainAdjustQ16, sLTPQ15[sLTPBufferIndex-i-1])
+				}
+			}
+
+			// Long-term prediction, the LTP filter coefficients are used
+			// in Q14
+			for i := 0; i < n; i++ {
+				predictionLagIndex := sLTPBufferIndex - lag + ltpFilterTaps/2
+
+				// Starting at 2 avoids a bias, as smlawb rounds towards
+				// negative infinity
+				ltpPredictionQ13 := int32(2)
+				for k := 0; k < ltpFilterTaps; k++ {
+					ltpPredictionQ13 = smlawb(ltpPredictionQ13, sLTPQ15[predictionLagIndex-k], int32(bQ7[s][k])<<7)
+				}
+
+				resQ14[i] = subframeExcQ14[i] + ltpPredictionQ13<<1
+				sLTPQ15[sLTPBufferIndex] = resQ14[i] << 1
+				sLTPBufferIndex++
+			}
+		} else {
+			copy(resQ14, subframeExcQ14)
+		}
+
+		// Short-term prediction
+		for i := 0; i < n; i++ {
+			lpcPredictionQ10 := int32(dLPC >> 1)
+			for k := 0; k < dLPC; k++ {
+				lpcPredictionQ10 = smlawb(lpcPredictionQ10, sLPCQ14[maxLPCOrder+i-k-1], int32(aQ12[k]))
+			}
+
+			sLPCQ14[maxLPCOrder+i] = addSat32(resQ14[i], lshiftSat32(lpcPredictionQ10, 4))
+			xq[s*n+i] = sat16(rshiftRound(smulww(sLPCQ14[maxLPCOrder+i], gainQ10), 8))
+		}
+
+		copy(sLPCQ14, sLPCQ14[n:n+maxLPCOrder])
+	}
+
+	copy(d.lpcStateQ14[:], sLPCQ14[:maxLPCOrder])
+	copy(d.outBuffer[:ltpMemoryLength], xq)
+
+	// The residual is kept in the scale of the floating-point
+	// reconstruction for packet loss concealment, which is shared by both
+	for i := range res {
+		res[i] = float32(d.scratch.resQ14[i]) / (1 << 29)
+	}
+
+	for i := range xq {
+		out[i] = float32(xq[i]) / 32768
+	}
+}
+
+// lpcAnalysisFilter filters in with the LPC analysis filter aQ12, writing
+// length samples to out. The first len(aQ12) samples of out are zero. See
+// silk_LPC_analysis_filter() (LPC_analysis_filter.c).
+func lpcAnalysisFilter(out, in []int16, aQ12 []int16, length int) {
+	dLPC := len(aQ12)
+	for i := dLPC; i < length; i++ {
+		// The sum is allowed to wrap around, it can only overflow for
+		// invalid streams
+		var predictionQ12 int32
+		for k := 0; k < dLPC; k++ {
+			predictionQ12 += int32(in[i-k-1]) * int32(aQ12[k])
+		}
+
+		out[i] = sat16(rshiftRound((int32(in[i])<<12)-predictionQ12, 12))
+	}
+
+	for i := 0; i < dLPC; i++ {
+		out[i] = 0
+	}
+}
+
+// The fixed-point helpers below match the macros of the reference
+// implementation (macros.h, SigProc_FIX.h and Inlines.h) of the same name.
+
+// smulwb multiplies a by the low 16 bits of b and shifts the result right
+// by 16
+func smulwb(a, b int32) int32 {
+	return int32((int64(a) * int64(int16(b))) >> 16)
+}
+
+// smlawb adds smulwb(b, c) to a
+func smlawb(a, b, c int32) int32 {
+	return a + smulwb(b, c)
+}
+
+// smulww multiplies a by b and shifts the result right by 16
+func smulww(a, b int32) int32 {
+	return int32((int64(a) * int64(b)) >> 16)
+}
+
+// smmul returns the upper 32 bits of the product of a and b
+func smmul(a, b int32) int32 {
+	return int32((int64(a) * int64(b)) >> 32)
+}
+
+// mul32FracQ31 multiplies a by the Q31 fraction b, rounding the result
+func mul32FracQ31(a, b int32) int32 {
+	return int32(rshiftRound64(int64(a)*int64(b), 31))
+}
+
+// rshiftRound shifts a right by shift, rounding to the nearest value
+func rshiftRound(a int32, shift int) int32 {
+	if shift == 1 {
+		return (a >> 1) + (a & 1)
+	}
+
+	return ((a >> (shift - 1)) + 1) >> 1
+}
+
+func rshiftRound64(a int64, shift int) int64 {
+	if shift == 1 {
+		return (a >> 1) + (a & 1)
+	}
+
+	return ((a >> (shift - 1)) + 1) >> 1
+}
+
+func sat16(a int32) int16 {
+	return int16(clamp(math.MinInt16, a, math.MaxInt16))
+}
+
+func sat32(a int64) int32 {
+	switch {
+	case a > math.MaxInt32:
+		return math.MaxInt32
+	case a < math.MinInt32:
+		return math.MinInt32
+	}
+
+	return int32(a)
+}
+
+func addSat32(a, b int32) int32 {
+	return sat32(int64(a) + int64(b))
+}
+
+func subSat32(a, b int32) int32 {
+	return sat32(int64(a) - int64(b))
+}
+
+// lshiftSat32 shifts a left by shift, saturating the result
+func lshiftSat32(a int32, shift int) int32 {
+	return clamp(math.MinInt32>>shift, a, math.MaxInt32>>shift) << shift
+}
+
+func absInt32(a int32) int32 {
+	if a < 0 {
+		return -a
+	}
+
+	return a
+}
+
+// inverse32VarQ approximates (1 << qRes) / b. See silk_INVERSE32_varQ().
+func inverse32VarQ(b int32, qRes int) int32 {
+	bHeadroom := bits.LeadingZeros32(uint32(absInt32(b))) - 1
+	bNormalized := b << bHeadroom
+
+	// Inverse of the 16 most significant bits, refined with the error
+	// of the approximation
+	bInverse := (math.MaxInt32 >> 2) / (bNormalized >> 16)
+	result := bInverse << 16
+	errQ32 := ((1 << 29) - smulwb(bNormalized, bInverse)) << 3
+	result += smulww(errQ32, bInverse)
+
+	lshift := 61 - bHeadroom - qRes
+	switch {
+	case lshift <= 0:
+		return lshiftSat32(result, -lshift)
+	case lshift < 32:
+		return result >> lshift
+	}
+
+	return 0
+}
+
+// div32VarQ approximates (a << qRes) / b. See silk_DIV32_varQ().
+func div32VarQ(a, b int32, qRes int) int32 {
+	aHeadroom := bits.LeadingZeros32(uint32(absInt32(a))) - 1
+	aNormalized := a << aHeadroom
+	bHeadroom := bits.LeadingZeros32(uint32(absInt32(b))) - 1
+	bNormalized := b << bHeadroom
+
+	// Divide by the inverse of the 16 most significant bits of b, then
+	// correct with the remainder
+	bInverse := (math.MaxInt32 >> 2) / (bNormalized >> 16)
+	result := smulwb(aNormalized, bInverse)
+	aNormalized -= smmul(bNormalized, result) << 3
+	result = smlawb(result, aNormalized, bInverse)
+
+	lshift := 29 + aHeadroom - bHeadroom - qRes
+	switch {
+	case lshift < 0:
+		return lshiftSat32(result, -lshift)
+	case lshift < 32:
+		return result >> lshift
+	}
+
+	return 0
+}
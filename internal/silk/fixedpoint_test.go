@@ -0,0 +1,45 @@
+package silk
+
+import (
+	"math"
+	"testing"
+)
+
+func TestFixedPointDecode(t *testing.T) {
+	floatDecoder, fixedDecoder := NewDecoder(), NewFixedPointDecoder()
+	floatOut, fixedOut := make([]float32, 320), make([]float32, 320)
+
+	for i := 0; i < 3; i++ {
+		if err := floatDecoder.Decode(testSilkFrame(), floatOut, false, nanoseconds20Ms, BandwidthWideband); err != nil {
+			t.Fatal(err)
+		}
+		if err := fixedDecoder.Decode(testSilkFrame(), fixedOut, false, nanoseconds20Ms, BandwidthWideband); err != nil {
+			t.Fatal(err)
+		}
+
+		for j := range fixedOut {
+			sample := fixedOut[j] * 32768
+			switch {
+			case sample != float32(math.Round(float64(sample))):
+				t.Fatalf("%d is not an integer sample (%f)", j, sample)
+			case math.Abs(float64(fixedOut[j]-floatOut[j])) > 1.0/32768:
+				t.Fatalf("%d (%f) != (%f)", j, fixedOut[j], floatOut[j])
+			}
+		}
+	}
+}
+
+func TestFixedPointArithmetic(t *testing.T) {
+	switch {
+	case inverse32VarQ(1<<16, 47) != 2147483646:
+		t.Fatal(inverse32VarQ(1<<16, 47))
+	case div32VarQ(1<<17, 1<<16, 16) != 131071:
+		t.Fatal(div32VarQ(1<<17, 1<<16, 16))
+	case rshiftRound(-3, 1) != -1 || rshiftRound(5, 2) != 1 || rshiftRound(6, 2) != 2:
+		t.Fatal()
+	case lshiftSat32(math.MaxInt32>>2, 4) != math.MaxInt32>>4<<4:
+		t.Fatal()
+	case sat16(40000) != math.MaxInt16 || sat16(-40000) != math.MinInt16:
+		t.Fatal()
+	}
+}
@@ -0,0 +1,140 @@
+package silk
+
+// FrameParameters are the parameters coded in a SILK frame, before they are
+// used to reconstruct its audio.
+//
+// https://datatracker.ietf.org/doc/html/rfc6716#section-4.2.7
+type FrameParameters struct {
+	// The VAD flag of the frame and the LBRR flag of the packet
+	//
+	// https://datatracker.ietf.org/doc/html/rfc6716#section-4.2.3
+	VoiceActivityDetected bool
+	LowBitRateRedundancy  bool
+
+	// The signal type is inactive without voice activity, otherwise it is
+	// unvoiced or voiced. The quantization offset type is low or high.
+	//
+	// https://datatracker.ietf.org/doc/html/rfc6716#section-4.2.7.3
+	Voiced                 bool
+	HighQuantizationOffset bool
+
+	// https://datatracker.ietf.org/doc/html/rfc6716#section-4.2.7.4
+	GainsQ16 [subframeCount]int32
+
+	// The first stage index and the stabilized NLSF coefficients, of which
+	// the first LPCOrder are used
+	//
+	// https://datatracker.ietf.org/doc/html/rfc6716#section-4.2.7.5
+	I1       uint32
+	LPCOrder int
+	NLSFQ15  [maxLPCOrder]int16
+
+	// The weight of the current frame when interpolating the NLSFs of the
+	// first half of the frame, 4 means no interpolation.
+	//
+	// https://datatracker.ietf.org/doc/html/rfc6716#section-4.2.7.5.5
+	InterpolationWeightQ2 int16
+
+	// The long-term prediction parameters are only coded for voiced frames
+	//
+	// https://datatracker.ietf.org/doc/html/rfc6716#section-4.2.7.6
+	PitchLags        [subframeCount]int
+	PeriodicityIndex uint32
+	LTPFilterIndices [subframeCount]uint32
+	LTPScaleQ14      int32
+
+	// https://datatracker.ietf.org/doc/html/rfc6716#section-4.2.7.7
+	Seed uint32
+
+	// The pulse and LSB counts of the first Shellblocks shell blocks
+	//
+	// https://datatracker.ietf.org/doc/html/rfc6716#section-4.2.7.8
+	Shellblocks int
+	PulseCounts [maxShellblocks]uint8
+	LSBCounts   [maxShellblocks]uint8
+}
+
+// DecodeParameters decodes the parameters of a SILK frame without
+// reconstructing its audio, which is much cheaper than Decode. The state
+// used to decode the parameters of the next frame is updated, the state
+// used for reconstruction and concealment isn't. A Decoder should either
+// only decode parameters, or only audio. The FrameParameters are a copy,
+// decoding the next frame doesn't change them.
+func (d *Decoder) DecodeParameters(in []byte, isStereo bool, nanoseconds int, bandwidth Bandwidth) (FrameParameters, error) {
+	if err := d.validateFrameParameters(isStereo, nanoseconds, bandwidth); err != nil {
+		return FrameParameters{}, err
+	}
+
+	voiceActivityDetected, lowBitRateRedundancy := d.decodeFrameStart(in, nanoseconds, bandwidth)
+
+	d.decodeFrame(voiceActivityDetected, nanoseconds, bandwidth, nil)
+
+	d.parameters.LowBitRateRedundancy = lowBitRateRedundancy
+	return d.parameters, nil
+}
+
+// DecodeHeader decodes only the start of a SILK frame, its VAD and LBRR
+// flags, signal type, quantization offset type and gains. The remaining
+// fields of the FrameParameters are zero. This is enough to follow the
+// activity and level of a stream at a fraction of the cost of
+// DecodeParameters, but the LBRR frames still have to be decoded to reach
+// the regular frame when they are present. The gains of the next frame
+// depend on the gains of this one, so a Decoder should only decode headers.
+func (d *Decoder) DecodeHeader(in []byte, isStereo bool, nanoseconds int, bandwidth Bandwidth) (FrameParameters, error) {
+	if err := d.validateFrameParameters(isStereo, nanoseconds, bandwidth); err != nil {
+		return FrameParameters{}, err
+	}
+
+	voiceActivityDetected, lowBitRateRedundancy := d.decodeFrameStart(in, nanoseconds, bandwidth)
+
+	signalType, quantizationOffsetType := d.determineFrameType(voiceActivityDetected)
+	d.decodeSubframeQuantizations(signalType)
+	d.haveDecoded = true
+
+	d.parameters = FrameParameters{
+		VoiceActivityDetected:  voiceActivityDetected,
+		LowBitRateRedundancy:   lowBitRateRedundancy,
+		Voiced:                 signalType == frameSignalTypeVoiced,
+		HighQuantizationOffset: quantizationOffsetType == frameQuantizationOffsetTypeHigh,
+		GainsQ16:               d.scratch.fixedGainQ16,
+	}
+	return d.parameters, nil
+}
+
+// saveFrameParameters keeps the parameters of the frame being decoded. The
+// LTP filter indices are kept as they are decoded.
+func (d *Decoder) saveFrameParameters(
+	voiceActivityDetected bool,
+	signalType frameSignalType, quantizationOffsetType frameQuantizationOffsetType,
+	I1 uint32, nlsfQ15 []int16, wQ2 int16,
+	pitchLags []int, LTPscaleQ14 float32,
+	seed uint32,
+	pulsecounts, lsbcounts []uint8,
+) {
+	p := &d.parameters
+	p.VoiceActivityDetected = voiceActivityDetected
+	p.Voiced = signalType == frameSignalTypeVoiced
+	p.HighQuantizationOffset = quantizationOffsetType == frameQuantizationOffsetTypeHigh
+	p.GainsQ16 = d.scratch.fixedGainQ16
+	p.I1 = I1
+	p.LPCOrder = copy(p.NLSFQ15[:], nlsfQ15)
+	p.InterpolationWeightQ2 = wQ2
+
+	p.PitchLags = [subframeCount]int{}
+	copy(p.PitchLags[:], pitchLags)
+	if !p.Voiced {
+		p.PeriodicityIndex = 0
+		p.LTPFilterIndices = [subframeCount]uint32{}
+	}
+	p.LTPScaleQ14 = int32(LTPscaleQ14)
+
+	p.Seed = seed
+	p.Shellblocks = copy(p.PulseCounts[:], pulsecounts)
+	copy(p.LSBCounts[:], lsbcounts)
+}
+
+// VoiceActivityDetected reports if the VAD flag of the most recently decoded
+// frame was set. LBRR frames are only coded for frames with voice activity.
+func (d *Decoder) VoiceActivityDetected() bool {
+	return d.parameters.VoiceActivityDetected
+}
@@ -0,0 +1,145 @@
+package silk
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDecodeParameters(t *testing.T) {
+	d := NewDecoder()
+	parameters, err := d.DecodeParameters(testSilkFrame(), false, nanoseconds20Ms, BandwidthWideband)
+	switch {
+	case err != nil:
+		t.Fatal(err)
+	case parameters.VoiceActivityDetected || parameters.LowBitRateRedundancy:
+		t.Fatal()
+	case parameters.Voiced || !parameters.HighQuantizationOffset:
+		t.Fatal()
+	case parameters.GainsQ16 != [subframeCount]int32{210944, 112640, 96256, 96256}:
+		t.Fatal(parameters.GainsQ16)
+	case parameters.I1 != 9 || parameters.LPCOrder != 16:
+		t.Fatal()
+	case !reflect.DeepEqual(parameters.NLSFQ15[:], testNlsfQ1()):
+		t.Fatal(parameters.NLSFQ15)
+	case parameters.InterpolationWeightQ2 != 4:
+		t.Fatal()
+	case parameters.Shellblocks != 20:
+		t.Fatal()
+	}
+
+	// The parameters match those of a Decoder that reconstructs the frames
+	decoder := NewDecoder()
+	out := make([]float32, 320)
+	previous := parameters
+	for _, frame := range [][]byte{testSilkFrame(), testVoicedSilkFrame(), testVoicedSilkFrame()} {
+		parameters, err := d.DecodeParameters(frame, false, nanoseconds20Ms, BandwidthWideband)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = decoder.Decode(frame, out, false, nanoseconds20Ms, BandwidthWideband); err != nil {
+			t.Fatal(err)
+		}
+
+		parameters.LowBitRateRedundancy = false
+		switch {
+		case parameters.VoiceActivityDetected != decoder.VoiceActivityDetected():
+			t.Fatal()
+		case parameters != decoder.parameters:
+			t.Fatalf("%+v != %+v", parameters, decoder.parameters)
+		}
+	}
+
+	// Decoding the next frames didn't change the parameters of the first
+	d = NewDecoder()
+	if first, _ := d.DecodeParameters(testSilkFrame(), false, nanoseconds20Ms, BandwidthWideband); previous != first {
+		t.Fatalf("%+v != %+v", previous, first)
+	}
+}
+
+func TestDecodeHeader(t *testing.T) {
+	headerDecoder := NewDecoder()
+	d := NewDecoder()
+	for _, frame := range [][]byte{testSilkFrame(), testVoicedSilkFrame(), testVoicedSilkFrame()} {
+		header, err := headerDecoder.DecodeHeader(frame, false, nanoseconds20Ms, BandwidthWideband)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		parameters, err := d.DecodeParameters(frame, false, nanoseconds20Ms, BandwidthWideband)
+		switch {
+		case err != nil:
+			t.Fatal(err)
+		case header.VoiceActivityDetected != parameters.VoiceActivityDetected:
+			t.Fatal()
+		case header.LowBitRateRedundancy != parameters.LowBitRateRedundancy:
+			t.Fatal()
+		case header.Voiced != parameters.Voiced:
+			t.Fatal()
+		case header.HighQuantizationOffset != parameters.HighQuantizationOffset:
+			t.Fatal()
+		case header.GainsQ16 != parameters.GainsQ16:
+			t.Fatalf("%v != %v", header.GainsQ16, parameters.GainsQ16)
+		case header.LPCOrder != 0 || header.Shellblocks != 0:
+			t.Fatal()
+		case header.VoiceActivityDetected != headerDecoder.VoiceActivityDetected():
+			t.Fatal()
+		}
+	}
+}
+
+// testLowBitRateRedundancySilkFrame is a frame with the VAD and LBRR flags
+// set, an LBRR frame precedes the regular frame
+func testLowBitRateRedundancySilkFrame() []byte {
+	frame := testVoicedSilkFrame()
+	frame[0] |= 0xc0
+	return frame
+}
+
+func TestDecodeHeaderLowBitRateRedundancy(t *testing.T) {
+	headerDecoder := NewDecoder()
+	d := NewDecoder()
+	for _, test := range []struct {
+		frame     []byte
+		bandwidth Bandwidth
+	}{
+		{testVoicedSilkFrame(), BandwidthWideband},
+		{testLowBitRateRedundancySilkFrame(), BandwidthWideband},
+		{testSilkFrame(), BandwidthNarrowband},
+		{testLowBitRateRedundancySilkFrame(), BandwidthMediumband},
+	} {
+		header, err := headerDecoder.DecodeHeader(test.frame, false, nanoseconds20Ms, test.bandwidth)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		parameters, err := d.DecodeParameters(test.frame, false, nanoseconds20Ms, test.bandwidth)
+		switch {
+		case err != nil:
+			t.Fatal(err)
+		case header.LowBitRateRedundancy != (test.frame[0]&0xc0 == 0xc0):
+			t.Fatal()
+		case header.VoiceActivityDetected != parameters.VoiceActivityDetected:
+			t.Fatal()
+		case header.LowBitRateRedundancy != parameters.LowBitRateRedundancy:
+			t.Fatal()
+		case header.Voiced != parameters.Voiced:
+			t.Fatal()
+		case header.HighQuantizationOffset != parameters.HighQuantizationOffset:
+			t.Fatal()
+		case header.GainsQ16 != parameters.GainsQ16:
+			t.Fatalf("%v != %v", header.GainsQ16, parameters.GainsQ16)
+		}
+
+		// The state is reset before the LBRR frame when the bandwidth
+		// changes, so the frame is decoded like the first of a stream
+		if test.bandwidth != BandwidthWideband {
+			first := NewDecoder()
+			expected, err := first.DecodeHeader(test.frame, false, nanoseconds20Ms, test.bandwidth)
+			if err != nil {
+				t.Fatal(err)
+			} else if header != expected {
+				t.Fatalf("%+v != %+v", header, expected)
+			}
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package silk
+
+const (
+	// Every concealed frame is attenuated by these factors, voiced frames
+	// are expected to continue for longer than unvoiced frames.
+	concealAttenuationVoiced   = 0.95
+	concealAttenuationUnvoiced = 0.8
+
+	// Chirp factor of the bandwidth expansion applied to the LPC filter of
+	// every concealed frame.
+	concealBandwidthExpansion = 0.99
+)
+
+// Conceal generates a replacement for a lost SILK frame from the state of
+// the most recently decoded frame. Packet loss concealment is not normative
+// in RFC 6716, this implementation follows the outline of the reference
+// decoder: voiced frames repeat the final pitch period of the previous
+// excitation, unvoiced frames use randomly selected samples of it. The
+// excitation is attenuated for every consecutive lost frame and passed
+// through the LPC filter of the previous frame.
+//
+// https://datatracker.ietf.org/doc/html/rfc6716#section-4.4
+func (d *Decoder) Conceal(out []float32, nanoseconds int, bandwidth Bandwidth) error {
+	if err := d.validateFrame(out, false, nanoseconds, bandwidth); err != nil {
+		return err
+	}
+
+	frameLength := d.samplesInSubframe(bandwidth) * subframeCount
+	out = out[:frameLength]
+
+	if !d.haveDecoded || len(d.concealExcitation) != frameLength {
+		for i := range out {
+			out[i] = 0
+		}
+		d.updateFinalOutValues(out)
+		return nil
+	}
+
+	d.lostFrames++
+
+	attenuation := float32(1.0)
+	for i := 0; i < d.lostFrames; i++ {
+		if d.concealPitchLag != 0 {
+			attenuation *= concealAttenuationVoiced
+		} else {
+			attenuation *= concealAttenuationUnvoiced
+		}
+	}
+
+	// The LPC filter gets less resonant with every lost frame to avoid
+	// a tonal artifact building up.
+	chirp := float32(1.0)
+	for k := range d.concealAQ12 {
+		chirp *= concealBandwidthExpansion
+		d.concealAQ12[k] *= chirp
+	}
+
+	lpcHistory := d.previousFrameLPCValues
+	dLPC := len(d.concealAQ12)
+	lpc := d.scratch.lpc[:frameLength]
+	seed := uint32(d.lostFrames)
+
+	for i := range out {
+		var excitation float32
+		if lag := d.concealPitchLag; lag != 0 && lag <= frameLength {
+			excitation = d.concealExcitation[frameLength-lag+(i%lag)]
+		} else {
+			seed = 196314165*seed + 907633515
+			excitation = d.concealExcitation[(seed>>16)%uint32(frameLength)]
+		}
+
+		lpcVal := excitation * attenuation
+		for k := 0; k < dLPC; k++ {
+			var previous float32
+			if index := i - k - 1; index >= 0 {
+				previous = lpc[index]
+			} else if index += len(lpcHistory); index >= 0 {
+				previous = lpcHistory[index]
+			}
+
+			lpcVal += previous * (d.concealAQ12[k] / 4096.0)
+		}
+
+		lpc[i] = lpcVal
+		out[i] = clampFloat(-1.0, lpcVal, 1.0)
+	}
+
+	d.previousFrameLPCValues = append(d.previousFrameLPCValues[:0], lpc[frameLength-dLPC:]...)
+	d.updateFinalOutValues(out)
+	return nil
+}
+
+// updateFinalOutValues appends the output of a frame that was not produced
+// by lpcSynthesis to the buffered output values.
+func (d *Decoder) updateFinalOutValues(out []float32) {
+	if len(out) >= len(d.finalOutValues) {
+		copy(d.finalOutValues, out[len(out)-len(d.finalOutValues):])
+		return
+	}
+
+	copy(d.finalOutValues, d.finalOutValues[len(out):])
+	copy(d.finalOutValues[len(d.finalOutValues)-len(out):], out)
+}
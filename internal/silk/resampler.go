@@ -0,0 +1,243 @@
+package silk
+
+import "math"
+
+const (
+	// The output sample rate of the resampler in kHz
+	resamplerOutputRate = 48
+
+	// The highest internal sample rate in kHz, of WB frames
+	maxResamplerInputRate = 16
+
+	// The input is processed in batches of at most 10 ms
+	resamplerMaxBatchSize = maxResamplerInputRate * 10
+
+	// The fractional FIR filter reads 8 samples of the upsampled signal
+	resamplerOrderFIR = 8
+)
+
+var (
+	// The all-pass filters of the 2x upsampler, for the even and odd
+	// output samples
+	resamplerUp2HQ0 = [3]int32{1746, 14986, 39083 - 65536}
+	resamplerUp2HQ1 = [3]int32{6854, 25769, 55542 - 65536}
+
+	// The interpolation filter for 12 fractional phases, each row holds
+	// the first half of the symmetric filter
+	resamplerFracFIR12 = [12][resamplerOrderFIR / 2]int32{
+		{189, -600, 617, 30567},
+		{117, -159, -1070, 29704},
+		{52, 221, -2392, 28276},
+		{-4, 529, -3350, 26341},
+		{-48, 758, -3956, 23973},
+		{-80, 905, -4235, 21254},
+		{-99, 972, -4222, 18278},
+		{-107, 967, -3957, 15143},
+		{-103, 896, -3487, 11950},
+		{-91, 773, -2865, 8798},
+		{-71, 611, -2143, 5784},
+		{-46, 425, -1375, 2996},
+	}
+)
+
+// resampler converts the output of the fixed-point decoder from the
+// internal sample rate to 48 kHz, exactly like silk_resampler() of libopus
+// configured for decoding (resampler.c, resampler_private_IIR_FIR.c and
+// resampler_private_up2_HQ.c). The signal is upsampled by 2 with all-pass
+// IIR filters, and interpolated to 48 kHz with a fractional FIR filter.
+type resampler struct {
+	// The internal sample rate in kHz the resampler was initialized for,
+	// zero before the first frame
+	inputRate   int
+	inputDelay  int
+	invRatioQ16 int32
+
+	iirState  [6]int32
+	firState  [resamplerOrderFIR]int16
+	delayedIn [maxResamplerInputRate]int16
+
+	// Buffers of a single call
+	upsampled [resamplerOrderFIR + 2*resamplerMaxBatchSize]int16
+	shortIn   [2 * maxResamplerInputRate]int16
+}
+
+// init resets the resampler for input at inputRate kHz, as
+// silk_resampler_init() does whenever the internal sample rate changes
+func (r *resampler) init(inputRate int) {
+	*r = resampler{inputRate: inputRate}
+
+	// The input is delayed to align the output of every internal sample
+	// rate, delay_matrix_dec of resampler.c for 48 kHz output
+	switch inputRate {
+	case 12:
+		r.inputDelay = 4
+	case 16:
+		r.inputDelay = 7
+	}
+
+	// The step through the 2x upsampled signal in Q16, rounded up so the
+	// output has exactly the length of the input at 48 kHz
+	inputHz, outputHz := int32(inputRate*1000), int32(resamplerOutputRate*1000)
+	r.invRatioQ16 = ((inputHz << 15) / outputHz) << 2
+	for smulww(r.invRatioQ16, outputHz) < inputHz<<1 {
+		r.invRatioQ16++
+	}
+}
+
+// resample writes len(in)*48/inputRate samples to out. The first
+// millisecond of the input completes the samples delayed by the previous
+// call, like silk_resampler().
+func (r *resampler) resample(in, out []int16) {
+	if len(in) < r.inputRate {
+		// silk_Decode() never passes less than 1 ms, the delay line
+		// continues through the whole input
+		buffered := append(r.shortIn[:0], r.delayedIn[:r.inputDelay]...)
+		buffered = append(buffered, in...)
+		r.iirFIR(out, buffered[:len(in)])
+		copy(r.delayedIn[:], buffered[len(in):])
+		return
+	}
+
+	n := r.inputRate - r.inputDelay
+	copy(r.delayedIn[r.inputDelay:], in[:n])
+	out = r.iirFIR(out, r.delayedIn[:r.inputRate])
+	r.iirFIR(out, in[n:len(in)-r.inputDelay])
+	copy(r.delayedIn[:], in[len(in)-r.inputDelay:])
+}
+
+// iirFIR upsamples in by 2 and interpolates the result, in batches of at
+// most 10 ms. It returns the rest of out.
+func (r *resampler) iirFIR(out, in []int16) []int16 {
+	batchSize := r.inputRate * 10
+	copy(r.upsampled[:], r.firState[:])
+
+	n := 0
+	for {
+		n = len(in)
+		if n > batchSize {
+			n = batchSize
+		}
+
+		r.up2HQ(r.upsampled[resamplerOrderFIR:], in[:n])
+		out = r.interpolate(out, int32(n)<<17)
+
+		in = in[n:]
+		if len(in) == 0 {
+			break
+		}
+		copy(r.upsampled[:], r.upsampled[n<<1:][:resamplerOrderFIR])
+	}
+
+	copy(r.firState[:], r.upsampled[n<<1:])
+	return out
+}
+
+// interpolate writes the samples of the upsampled signal at every step of
+// invRatioQ16 before maxIndexQ16, the fractional part of the position
+// selects one of 12 filter phases
+func (r *resampler) interpolate(out []int16, maxIndexQ16 int32) []int16 {
+	i := 0
+	for indexQ16 := int32(0); indexQ16 < maxIndexQ16; indexQ16 += r.invRatioQ16 {
+		table := smulwb(indexQ16&0xFFFF, 12)
+		buf := r.upsampled[indexQ16>>16:]
+
+		resQ15 := int32(buf[0]) * resamplerFracFIR12[table][0]
+		resQ15 += int32(buf[1]) * resamplerFracFIR12[table][1]
+		resQ15 += int32(buf[2]) * resamplerFracFIR12[table][2]
+		resQ15 += int32(buf[3]) * resamplerFracFIR12[table][3]
+		resQ15 += int32(buf[4]) * resamplerFracFIR12[11-table][3]
+		resQ15 += int32(buf[5]) * resamplerFracFIR12[11-table][2]
+		resQ15 += int32(buf[6]) * resamplerFracFIR12[11-table][1]
+		resQ15 += int32(buf[7]) * resamplerFracFIR12[11-table][0]
+
+		out[i] = sat16(rshiftRound(resQ15, 15))
+		i++
+	}
+
+	return out[i:]
+}
+
+// up2HQ upsamples in by 2 into out, with a cascade of three all-pass
+// sections for both the even and the odd output samples. The state is in
+// Q10.
+func (r *resampler) up2HQ(out, in []int16) {
+	s := &r.iirState
+	allPass := func(x, coefficient int32, state *int32, last bool) int32 {
+		y := x - *state
+		v := smulwb(y, coefficient)
+		if last {
+			v = smlawb(y, y, coefficient)
+		}
+
+		result := *state + v
+		*state = x + v
+		return result
+	}
+
+	for k, sample := range in {
+		in32 := int32(sample) << 10
+
+		even := allPass(in32, resamplerUp2HQ0[0], &s[0], false)
+		even = allPass(even, resamplerUp2HQ0[1], &s[1], false)
+		even = allPass(even, resamplerUp2HQ0[2], &s[2], true)
+		out[2*k] = sat16(rshiftRound(even, 10))
+
+		odd := allPass(in32, resamplerUp2HQ1[0], &s[3], false)
+		odd = allPass(odd, resamplerUp2HQ1[1], &s[4], false)
+		odd = allPass(odd, resamplerUp2HQ1[2], &s[5], true)
+		out[2*k+1] = sat16(rshiftRound(odd, 10))
+	}
+}
+
+// Resample converts samples of the fixed-point decoder at the internal
+// sample rate of bandwidth to 48 kHz, as silk_Decode() of libopus does for
+// mono output. The samples are delayed by one, and resampled by the
+// resampler of libopus, which restarts whenever the bandwidth changes. out
+// must hold 48 kHz samples for the duration of in, both hold multiples of
+// 1/32768.
+func (d *Decoder) Resample(in, out []float32, bandwidth Bandwidth) error {
+	inputRate := d.samplesInSubframe(bandwidth) / 5
+	switch {
+	case inputRate == 0:
+		return errUnsupportedSilkBandwidth
+	case len(out) < len(in)*resamplerOutputRate/inputRate:
+		return errOutBufferTooSmall
+	}
+
+	if d.resampler.inputRate != inputRate {
+		d.resampler.init(inputRate)
+	}
+
+	for len(in) > 0 {
+		n := len(in)
+		if n > maxFrameLength {
+			n = maxFrameLength
+		}
+
+		// silk_Decode() buffers the last samples of every frame in
+		// sStereo.sMid, the resampler starts with the last one of the
+		// previous frame
+		samples := d.scratch.resampleIn[:n]
+		samples[0] = d.resamplerDelayedSample
+		for i := 1; i < n; i++ {
+			samples[i] = toInt16(in[i-1])
+		}
+		d.resamplerDelayedSample = toInt16(in[n-1])
+
+		resampled := d.scratch.resampleOut[:n*resamplerOutputRate/inputRate]
+		d.resampler.resample(samples, resampled)
+		for i, sample := range resampled {
+			out[i] = float32(sample) / 32768
+		}
+
+		in, out = in[n:], out[len(resampled):]
+	}
+
+	return nil
+}
+
+// toInt16 converts a sample of the fixed-point decoder back to the 16-bit
+// value it represents
+func toInt16(sample float32) int16 {
+	return int16(clamp(math.MinInt16, int32(math.Round(float64(sample)*32768)), math.MaxInt16))
+}
@@ -0,0 +1,149 @@
+package silk
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestResamplerInit(t *testing.T) {
+	for _, test := range []struct {
+		inputRate   int
+		inputDelay  int
+		invRatioQ16 int32
+	}{
+		{8, 0, 21846},
+		{12, 4, 32768},
+		{16, 7, 43691},
+	} {
+		r := resampler{}
+		r.init(test.inputRate)
+		switch {
+		case r.inputDelay != test.inputDelay:
+			t.Fatalf("%d: %d != %d", test.inputRate, r.inputDelay, test.inputDelay)
+		case r.invRatioQ16 != test.invRatioQ16:
+			t.Fatalf("%d: %d != %d", test.inputRate, r.invRatioQ16, test.invRatioQ16)
+		}
+	}
+}
+
+func TestResamplerSine(t *testing.T) {
+	const (
+		frequency = 1000
+		amplitude = 10000
+	)
+
+	for _, inputRate := range []int{8, 12, 16} {
+		r := resampler{}
+		r.init(inputRate)
+
+		// 100 ms of a sine, resampled in 20 ms frames
+		in := make([]int16, inputRate*100)
+		for i := range in {
+			in[i] = int16(amplitude * math.Sin(2*math.Pi*frequency*float64(i)/float64(inputRate*1000)))
+		}
+		out := make([]int16, len(in)*resamplerOutputRate/inputRate)
+		for i := 0; i < len(in); i += inputRate * 20 {
+			r.resample(in[i:i+inputRate*20], out[i*resamplerOutputRate/inputRate:])
+		}
+
+		// After the first 10 ms the output is the sine at 48 kHz, delayed
+		// by the filters
+		out = out[480:]
+		var sin, cos float64
+		for i, sample := range out {
+			sin += float64(sample) * math.Sin(2*math.Pi*frequency*float64(i)/48000)
+			cos += float64(sample) * math.Cos(2*math.Pi*frequency*float64(i)/48000)
+		}
+		sin, cos = 2*sin/float64(len(out)), 2*cos/float64(len(out))
+
+		var residual float64
+		for i, sample := range out {
+			expected := sin*math.Sin(2*math.Pi*frequency*float64(i)/48000) + cos*math.Cos(2*math.Pi*frequency*float64(i)/48000)
+			residual += (float64(sample) - expected) * (float64(sample) - expected)
+		}
+
+		if gain := math.Hypot(sin, cos) / amplitude; math.Abs(gain-1) > 0.001 {
+			t.Fatalf("%d: gain %f", inputRate, gain)
+		}
+		if rms := math.Sqrt(residual / float64(len(out))); rms > 2 {
+			t.Fatalf("%d: residual %f", inputRate, rms)
+		}
+	}
+}
+
+func TestDecoderResample(t *testing.T) {
+	d := NewFixedPointDecoder()
+	frames := make([]float32, 2*320)
+	if err := d.Decode(testSilkFrame(), frames, false, nanoseconds20Ms, BandwidthWideband); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Decode(testVoicedSilkFrame(), frames[320:], false, nanoseconds20Ms, BandwidthWideband); err != nil {
+		t.Fatal(err)
+	}
+
+	out := make([]float32, 3*len(frames))
+	for i := 0; i < 2; i++ {
+		if err := d.Resample(frames[i*320:(i+1)*320], out[i*960:], BandwidthWideband); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The frames are delayed by one sample, like silk_Decode() does
+	r := resampler{}
+	r.init(16)
+	delayed := make([]int16, len(frames))
+	for i := 1; i < len(frames); i++ {
+		delayed[i] = toInt16(frames[i-1])
+	}
+	expected := make([]int16, len(out))
+	r.resample(delayed[:320], expected)
+	r.resample(delayed[320:], expected[960:])
+	for i := range expected {
+		if toInt16(out[i]) != expected[i] {
+			t.Fatalf("%d: %f != %d", i, out[i], expected[i])
+		}
+	}
+
+	// A new bandwidth restarts the resampler
+	if err := d.Resample(frames[:160], out, BandwidthNarrowband); err != nil {
+		t.Fatal(err)
+	} else if d.resampler.inputRate != 8 {
+		t.Fatal(d.resampler.inputRate)
+	}
+
+	if err := d.Resample(frames[:320], out[:959], BandwidthWideband); !errors.Is(err, errOutBufferTooSmall) {
+		t.Fatal(err)
+	}
+	if err := d.Resample(frames[:320], out, 0); !errors.Is(err, errUnsupportedSilkBandwidth) {
+		t.Fatal(err)
+	}
+}
+
+func TestResamplerShortInput(t *testing.T) {
+	// Inputs shorter than 1 ms continue the delay line. At 12 kHz every
+	// input sample produces exactly four output samples, so the output
+	// matches filtering the delayed input in one call.
+	in := make([]int16, 48)
+	for i := range in {
+		in[i] = int16(500 * i)
+	}
+
+	whole, split := resampler{}, resampler{}
+	whole.init(12)
+	split.init(12)
+
+	delayed := append(make([]int16, whole.inputDelay), in...)
+	expected := make([]int16, 4*len(in))
+	whole.iirFIR(expected, delayed[:len(in)])
+
+	out := make([]int16, 4*len(in))
+	for i := 0; i < len(in); i += 6 {
+		split.resample(in[i:i+6], out[4*i:])
+	}
+
+	if !reflect.DeepEqual(out, expected) {
+		t.Fatalf("%v != %v", out, expected)
+	}
+}
@@ -27,6 +27,17 @@ const (
 	frameQuantizationOffsetTypeHigh
 )
 
+// The largest frame is a 20 ms WB frame with 16 LPC coefficients, 20
+// shell blocks, 320 samples and pitch lags of up to 288 samples.
+const (
+	maxLPCOrder    = 16
+	maxShellblocks = 20
+	maxFrameLength = maxShellblocks * pulsecountLargestPartitionSize
+	maxPitchLag    = 288
+
+	ltpFilterTaps = 5
+)
+
 // Bandwidth constants
 const (
 	BandwidthNarrowband Bandwidth = iota + 1
@@ -48,6 +59,14 @@ func maxInt32(a, b int32) int32 {
 	return b
 }
 
+func minInt32(a, b int32) int32 {
+	if a > b {
+		return b
+	}
+
+	return a
+}
+
 func minUint(a, b uint) uint {
 	if a > b {
 		return b
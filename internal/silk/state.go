@@ -0,0 +1,267 @@
+package silk
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+)
+
+const (
+	// The version of the format written by MarshalBinary, it changes
+	// whenever the state of the Decoder does.
+	stateVersion = 2
+
+	// The number of previous output samples kept for LTP synthesis
+	finalOutValuesLength = 306
+)
+
+// Reset returns the Decoder to the state of a newly created one. Whether it
+// uses fixed-point reconstruction is kept.
+func (d *Decoder) Reset() {
+	finalOutValues := d.finalOutValues
+	if len(finalOutValues) != finalOutValuesLength {
+		finalOutValues = make([]float32, finalOutValuesLength)
+	}
+	for i := range finalOutValues {
+		finalOutValues[i] = 0
+	}
+
+	*d = Decoder{
+		finalOutValues: finalOutValues,
+		fixedPoint:     d.fixedPoint,
+	}
+}
+
+// Clone returns a deep copy of the Decoder. Decoding with either doesn't
+// affect the other.
+func (d *Decoder) Clone() Decoder {
+	clone := *d
+	clone.previousFrameLPCValues = append([]float32(nil), d.previousFrameLPCValues...)
+	clone.finalOutValues = append([]float32(nil), d.finalOutValues...)
+	clone.n0Q15 = append([]int16(nil), d.n0Q15...)
+	clone.concealExcitation = append([]float32(nil), d.concealExcitation...)
+	clone.concealAQ12 = append([]float32(nil), d.concealAQ12...)
+	clone.comfortNoiseNLSFQ15 = append([]int16(nil), d.comfortNoiseNLSFQ15...)
+	clone.comfortNoiseAQ12 = append([]float32(nil), d.comfortNoiseAQ12...)
+	clone.comfortNoiseExcitation = append([]float32(nil), d.comfortNoiseExcitation...)
+
+	return clone
+}
+
+// MarshalBinary encodes the state the Decoder carries between frames, so
+// decoding can continue from it in another Decoder. The range decoder and
+// scratch buffers only live for the duration of a frame and are not
+// included.
+func (d *Decoder) MarshalBinary() ([]byte, error) {
+	w := &stateWriter{}
+	w.write(uint8(stateVersion))
+	w.write(d.fixedPoint)
+	w.write(d.haveDecoded)
+	w.write(d.isPreviousFrameVoiced)
+	w.write(uint8(d.bandwidth))
+	w.write(d.previousLogGain)
+	w.writeFloat32s(d.previousFrameLPCValues)
+	w.writeFloat32s(d.finalOutValues)
+	w.writeInt16s(d.n0Q15)
+
+	w.writeFloat32s(d.concealExcitation)
+	w.writeFloat32s(d.concealAQ12)
+	w.write(int32(d.concealPitchLag))
+	w.write(int32(d.lostFrames))
+
+	w.write(uint8(d.comfortNoiseBandwidth))
+	w.writeInt16s(d.comfortNoiseNLSFQ15)
+	w.writeFloat32s(d.comfortNoiseAQ12)
+	w.write(d.comfortNoiseGain)
+	w.writeFloat32s(d.comfortNoiseExcitation)
+	w.write(d.comfortNoiseSeed)
+
+	w.write(d.previousGainQ16)
+	w.write(d.lpcStateQ14)
+	w.write(d.outBuffer)
+
+	w.write(uint8(d.resampler.inputRate))
+	w.write(d.resampler.iirState)
+	w.write(d.resampler.firState)
+	w.write(d.resampler.delayedIn)
+	w.write(d.resamplerDelayedSample)
+
+	return w.buf.Bytes(), nil
+}
+
+// UnmarshalBinary restores the state encoded by MarshalBinary. The state is
+// validated, so decoding from it can't fail in ways a stream couldn't
+// cause.
+func (d *Decoder) UnmarshalBinary(data []byte) error {
+	r := &stateReader{r: bytes.NewReader(data)}
+
+	var version, bandwidth, comfortNoiseBandwidth, resamplerInputRate uint8
+	var concealPitchLag, lostFrames int32
+	state := Decoder{}
+
+	if r.read(&version); r.err == nil && version != stateVersion {
+		return errUnsupportedStateVersion
+	}
+	r.read(&state.fixedPoint)
+	r.read(&state.haveDecoded)
+	r.read(&state.isPreviousFrameVoiced)
+	r.read(&bandwidth)
+	r.read(&state.previousLogGain)
+	state.previousFrameLPCValues = r.readFloat32s(maxLPCOrder)
+	state.finalOutValues = r.readFloat32s(finalOutValuesLength)
+	state.n0Q15 = r.readInt16s(maxLPCOrder)
+
+	state.concealExcitation = r.readFloat32s(maxFrameLength)
+	state.concealAQ12 = r.readFloat32s(maxLPCOrder)
+	r.read(&concealPitchLag)
+	r.read(&lostFrames)
+
+	r.read(&comfortNoiseBandwidth)
+	state.comfortNoiseNLSFQ15 = r.readInt16s(maxLPCOrder)
+	state.comfortNoiseAQ12 = r.readFloat32s(maxLPCOrder)
+	r.read(&state.comfortNoiseGain)
+	state.comfortNoiseExcitation = r.readFloat32s(maxFrameLength)
+	r.read(&state.comfortNoiseSeed)
+
+	r.read(&state.previousGainQ16)
+	r.read(&state.lpcStateQ14)
+	r.read(&state.outBuffer)
+
+	var resampler resampler
+	r.read(&resamplerInputRate)
+	r.read(&resampler.iirState)
+	r.read(&resampler.firState)
+	r.read(&resampler.delayedIn)
+	r.read(&state.resamplerDelayedSample)
+
+	switch {
+	case r.err != nil:
+		return r.err
+	case r.r.Len() != 0:
+		return errInvalidState
+	}
+
+	state.bandwidth = Bandwidth(bandwidth)
+	state.comfortNoiseBandwidth = Bandwidth(comfortNoiseBandwidth)
+	state.concealPitchLag = int(concealPitchLag)
+	state.lostFrames = int(lostFrames)
+
+	// The filter coefficients of the resampler follow from its input
+	// rate
+	switch resamplerInputRate {
+	case 0:
+	case 8, 12, 16:
+		state.resampler.init(int(resamplerInputRate))
+		state.resampler.iirState = resampler.iirState
+		state.resampler.firState = resampler.firState
+		state.resampler.delayedIn = resampler.delayedIn
+	default:
+		return errInvalidState
+	}
+
+	if !state.isValid() {
+		return errInvalidState
+	}
+
+	*d = state
+	return nil
+}
+
+// isValid checks the invariants the decoding functions rely on
+func (d *Decoder) isValid() bool {
+	isValidBandwidth := func(b Bandwidth) bool {
+		return b == 0 || d.samplesInSubframe(b) != 0
+	}
+
+	isValidNLSF := func(nlsfQ15 []int16) bool {
+		for _, n := range nlsfQ15 {
+			if n < 0 {
+				return false
+			}
+		}
+		return true
+	}
+
+	lpcOrder := 10
+	if d.bandwidth == BandwidthWideband {
+		lpcOrder = 16
+	}
+
+	switch {
+	case !isValidBandwidth(d.bandwidth), !isValidBandwidth(d.comfortNoiseBandwidth):
+		return false
+	case len(d.finalOutValues) != finalOutValuesLength:
+		return false
+	case len(d.n0Q15) != 0 && len(d.n0Q15) != lpcOrder:
+		return false
+	case !isValidNLSF(d.n0Q15), !isValidNLSF(d.comfortNoiseNLSFQ15):
+		return false
+	case d.concealPitchLag < 0 || d.concealPitchLag > maxPitchLag || d.lostFrames < 0:
+		return false
+	}
+
+	return true
+}
+
+// stateWriter writes the fields of the state in little endian. Writing to a
+// bytes.Buffer can't fail.
+type stateWriter struct {
+	buf bytes.Buffer
+}
+
+func (w *stateWriter) write(v interface{}) {
+	_ = binary.Write(&w.buf, binary.LittleEndian, v)
+}
+
+func (w *stateWriter) writeFloat32s(v []float32) {
+	w.write(uint16(len(v)))
+	w.write(v)
+}
+
+func (w *stateWriter) writeInt16s(v []int16) {
+	w.write(uint16(len(v)))
+	w.write(v)
+}
+
+// stateReader reads the fields written by stateWriter, the first error
+// stops all further reads.
+type stateReader struct {
+	r   *bytes.Reader
+	err error
+}
+
+func (r *stateReader) read(v interface{}) {
+	if r.err == nil {
+		r.err = binary.Read(r.r, binary.LittleEndian, v)
+	}
+}
+
+func (r *stateReader) readLength(maxLength int) int {
+	var length uint16
+	if r.read(&length); r.err == nil && int(length) > maxLength {
+		r.err = errInvalidState
+	}
+	if r.err != nil {
+		return 0
+	}
+
+	return int(length)
+}
+
+func (r *stateReader) readFloat32s(maxLength int) []float32 {
+	v := make([]float32, r.readLength(maxLength))
+	r.read(v)
+	for _, f := range v {
+		if math.IsNaN(float64(f)) || math.IsInf(float64(f), 0) {
+			r.err = errInvalidState
+		}
+	}
+
+	return v
+}
+
+func (r *stateReader) readInt16s(maxLength int) []int16 {
+	v := make([]int16, r.readLength(maxLength))
+	r.read(v)
+	return v
+}
@@ -0,0 +1,122 @@
+package silk
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func testVoicedSilkFrame() []byte {
+	return []byte{0xac, 0xbd, 0xa9, 0xf7, 0x26, 0x24, 0x5a, 0xa4, 0x00, 0x37, 0xbf, 0x9c, 0xde, 0xe, 0xcf, 0x94, 0x64, 0xaa, 0xf9, 0x87, 0xd0, 0x79, 0x19, 0xa8, 0x21, 0xc0}
+}
+
+// decodeTestFrames decodes a voiced and an unvoiced frame, and conceals a
+// lost one. The output of the fixed-point decoder is resampled.
+func decodeTestFrames(t *testing.T, d *Decoder) []float32 {
+	out := make([]float32, 320*3)
+	if err := d.Decode(testSilkFrame(), out, false, nanoseconds20Ms, BandwidthWideband); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Decode(testVoicedSilkFrame(), out[320:], false, nanoseconds20Ms, BandwidthWideband); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Conceal(out[640:], nanoseconds20Ms, BandwidthWideband); err != nil {
+		t.Fatal(err)
+	}
+
+	if !d.fixedPoint {
+		return out
+	}
+
+	resampled := make([]float32, 3*len(out))
+	if err := d.Resample(out, resampled, BandwidthWideband); err != nil {
+		t.Fatal(err)
+	}
+	return resampled
+}
+
+func TestDecoderState(t *testing.T) {
+	for _, newDecoder := range []func() Decoder{NewDecoder, NewFixedPointDecoder} {
+		d := newDecoder()
+		decodeTestFrames(t, &d)
+
+		clone := d.Clone()
+		state, err := d.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		restored := NewDecoder()
+		if err = restored.UnmarshalBinary(state); err != nil {
+			t.Fatal(err)
+		}
+
+		expected := decodeTestFrames(t, &d)
+		if actual := decodeTestFrames(t, &clone); !reflect.DeepEqual(expected, actual) {
+			t.Fatal("clone decoded differently")
+		}
+		if actual := decodeTestFrames(t, &restored); !reflect.DeepEqual(expected, actual) {
+			t.Fatal("restored decoder decoded differently")
+		}
+
+		d.Reset()
+		fresh := newDecoder()
+		if !reflect.DeepEqual(decodeTestFrames(t, &fresh), decodeTestFrames(t, &d)) {
+			t.Fatal("reset decoder decoded differently")
+		}
+	}
+}
+
+func TestDecoderUnmarshalBinaryErrors(t *testing.T) {
+	d := NewDecoder()
+	decodeTestFrames(t, &d)
+	state, err := d.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Version", func(t *testing.T) {
+		invalid := append([]byte{stateVersion + 1}, state[1:]...)
+		if err := d.UnmarshalBinary(invalid); !errors.Is(err, errUnsupportedStateVersion) {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("Trailing Data", func(t *testing.T) {
+		if err := d.UnmarshalBinary(append(state, 0)); !errors.Is(err, errInvalidState) {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("Truncated", func(t *testing.T) {
+		if err := d.UnmarshalBinary(state[:len(state)-1]); err == nil {
+			t.Fatal("truncated state was accepted")
+		}
+	})
+}
+
+func FuzzDecoderUnmarshalBinary(f *testing.F) {
+	d := NewDecoder()
+	out := make([]float32, 320*3)
+	_ = d.Decode(testSilkFrame(), out, false, nanoseconds20Ms, BandwidthWideband)
+	_ = d.Decode(testVoicedSilkFrame(), out, false, nanoseconds20Ms, BandwidthWideband)
+
+	state, err := d.MarshalBinary()
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(state)
+
+	f.Fuzz(func(t *testing.T, state []byte) {
+		d := NewDecoder()
+		if err := d.UnmarshalBinary(state); err != nil {
+			return
+		}
+
+		for _, bandwidth := range []Bandwidth{BandwidthWideband, BandwidthNarrowband} {
+			_ = d.Conceal(out, nanoseconds20Ms, bandwidth)
+			_ = d.ComfortNoise(out, bandwidth)
+			_ = d.Decode(testVoicedSilkFrame(), out, false, nanoseconds20Ms, bandwidth)
+		}
+	})
+}
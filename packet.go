@@ -0,0 +1,511 @@
+package opus
+
+import (
+	"time"
+)
+
+const (
+	// The maximum size of a single frame is 1275 bytes
+	//
+	// https://datatracker.ietf.org/doc/html/rfc6716#section-3.2.1
+	maxFrameSize = 1275
+
+	// The audio duration contained within a packet MUST NOT exceed 120 ms
+	//
+	// https://datatracker.ietf.org/doc/html/rfc6716#section-3.2.5
+	maxPacketDuration = 120 * time.Millisecond
+
+	// The number of frames of a code 3 packet is coded in six bits
+	//
+	// https://datatracker.ietf.org/doc/html/rfc6716#section-3.2.5
+	maxFrameCount = 0b00111111
+)
+
+// Packet is an Opus packet split into the individual frames it carries
+//
+// https://datatracker.ietf.org/doc/html/rfc6716#section-3
+type Packet struct {
+	// TOC is the table-of-contents header of the packet
+	TOC byte
+
+	// Frames are the compressed frames of the packet, a frame of length 0
+	// signals that no data is available for it (DTX or a lost frame).
+	Frames [][]byte
+
+	// Padding is the Opus padding of a code 3 packet, which can carry
+	// extensions
+	Padding []byte
+}
+
+// Configuration returns the configuration number of the packet
+func (p Packet) Configuration() Configuration {
+	return tableOfContentsHeader(p.TOC).configuration()
+}
+
+// Bandwidth returns the audio bandwidth of the packet
+func (p Packet) Bandwidth() Bandwidth {
+	return p.Configuration().bandwidth()
+}
+
+// IsStereo returns true if the packet contains stereo frames
+func (p Packet) IsStereo() bool {
+	return tableOfContentsHeader(p.TOC).isStereo()
+}
+
+// FrameDuration returns the duration of a single frame in the packet
+func (p Packet) FrameDuration() time.Duration {
+	return time.Duration(p.Configuration().frameDuration().nanoseconds())
+}
+
+// Duration returns the duration of all frames in the packet
+func (p Packet) Duration() time.Duration {
+	return p.FrameDuration() * time.Duration(len(p.Frames))
+}
+
+// IsDTX returns true if the packet carries no audio. During discontinuous
+// transmission (DTX) encoders send packets of one or two bytes, the TOC
+// header and frames no longer than a byte, and the decoder produces comfort
+// noise in their place.
+//
+// https://datatracker.ietf.org/doc/html/rfc6716#section-2.1.9
+func (p Packet) IsDTX() bool {
+	for _, frame := range p.Frames {
+		if len(frame) > maxDTXFrameSize {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ParsePacket splits an Opus packet into its frames
+//
+// https://datatracker.ietf.org/doc/html/rfc6716#section-3.2
+func ParsePacket(in []byte) (Packet, error) {
+	p, _, err := parsePacket(in, false, nil)
+	return p, err
+}
+
+// ParseSelfDelimitedPacket parses a packet using the self-delimiting
+// framing from the start of in. It returns the number of bytes the packet
+// occupied, the next packet starts at in[n:].
+//
+// To use the internal framing described in Section 3 for anything other
+// than the last Opus stream in a multistream packet requires additional
+// information to indicate where one Opus stream ends and the next one
+// begins.  The self-delimiting framing described here is designed to
+// avoid this overhead.  The only difference is that the self-delimiting
+// framing signals the size of the last frame explicitly.
+//
+// https://datatracker.ietf.org/doc/html/rfc6716#appendix-B
+func ParseSelfDelimitedPacket(in []byte) (p Packet, n int, err error) {
+	return parsePacket(in, true, nil)
+}
+
+// parsePacket parses a packet, appending its frames to frames[:0] so the
+// Decoder can reuse the slice between packets.
+func parsePacket(in []byte, isSelfDelimited bool, frames [][]byte) (p Packet, n int, err error) {
+	if len(in) < 1 {
+		return Packet{}, 0, errTooShortForTableOfContentsHeader
+	}
+
+	p.TOC = in[0]
+	offset := 1
+
+	switch tableOfContentsHeader(p.TOC).frameCode() {
+	case frameCodeOneFrame:
+		// For code 0 packets, the TOC byte is immediately followed by N-1
+		// bytes of compressed data for a single frame (where N is the size
+		// of the packet), as illustrated in Figure 2.
+		//
+		// https://datatracker.ietf.org/doc/html/rfc6716#section-3.2.2
+		frameLength := len(in) - offset
+		if isSelfDelimited {
+			if frameLength, offset, err = parseFrameLength(in, offset); err != nil {
+				return Packet{}, 0, err
+			}
+		}
+
+		if p.Frames, offset, err = sliceFrames(frames, in, offset, frameLength); err != nil {
+			return Packet{}, 0, err
+		}
+	case frameCodeTwoEqualFrames:
+		// For code 1 packets, the TOC byte is immediately followed by the
+		// (N-1)/2 bytes of compressed data for the first frame, followed by
+		// (N-1)/2 bytes of compressed data for the second frame, as
+		// illustrated in Figure 3.  The number of payload bytes available for
+		// compressed data, N-1, MUST be even for all code 1 packets [R3].
+		//
+		// https://datatracker.ietf.org/doc/html/rfc6716#section-3.2.3
+		frameLength := (len(in) - offset) / 2
+		if isSelfDelimited {
+			if frameLength, offset, err = parseFrameLength(in, offset); err != nil {
+				return Packet{}, 0, err
+			}
+		} else if (len(in)-offset)%2 != 0 {
+			return Packet{}, 0, errTwoEqualFramesOddLength
+		}
+
+		if p.Frames, offset, err = sliceFrames(frames, in, offset, frameLength, frameLength); err != nil {
+			return Packet{}, 0, err
+		}
+	case frameCodeTwoDifferentFrames:
+		// For code 2 packets, the TOC byte is followed by a one- or two-byte
+		// sequence indicating the length of the first frame (marked N1 in
+		// Figure 4), followed by N1 bytes of compressed data for the first
+		// frame.  The remaining N-N1-2 or N-N1-3 bytes are the compressed data
+		// for the second frame.
+		//
+		// https://datatracker.ietf.org/doc/html/rfc6716#section-3.2.4
+		var firstFrameLength, secondFrameLength int
+		if firstFrameLength, offset, err = parseFrameLength(in, offset); err != nil {
+			return Packet{}, 0, err
+		}
+
+		secondFrameLength = len(in) - offset - firstFrameLength
+		if isSelfDelimited {
+			if secondFrameLength, offset, err = parseFrameLength(in, offset); err != nil {
+				return Packet{}, 0, err
+			}
+		}
+
+		if p.Frames, offset, err = sliceFrames(frames, in, offset, firstFrameLength, secondFrameLength); err != nil {
+			return Packet{}, 0, err
+		}
+	case frameCodeArbitraryFrames:
+		if p.Frames, p.Padding, offset, err = parseArbitraryFrames(frames, in, offset, isSelfDelimited); err != nil {
+			return Packet{}, 0, err
+		}
+	}
+
+	if p.Duration() > maxPacketDuration {
+		return Packet{}, 0, errPacketTooLong
+	}
+
+	return p, offset, nil
+}
+
+// Code 3 packets signal the number of frames, as well as additional
+// padding, called "Opus padding" to indicate that this padding is added
+// at the Opus layer rather than at the transport layer.  Code 3 packets
+// MUST have at least 2 bytes [R6,R7].
+//
+// https://datatracker.ietf.org/doc/html/rfc6716#section-3.2.5
+func parseArbitraryFrames(frames [][]byte, in []byte, offset int, isSelfDelimited bool) (_ [][]byte, padding []byte, n int, err error) {
+	if len(in) <= offset {
+		return nil, nil, 0, errTooShortForArbitraryLengthFrames
+	}
+
+	isVBR, hasPadding, frameCount := parseFrameCountByte(in[offset])
+	offset++
+	if frameCount == 0 {
+		return nil, nil, 0, errInvalidFrameCount
+	}
+
+	// If the padding flag is set, the number of bytes of padding is
+	// encoded in the bytes following the frame count byte.  Values from 0
+	// to 254 indicate that 0 to 254 bytes of padding are included, in
+	// addition to the byte(s) used to indicate the size of the padding.
+	// If the value is 255, then the size of the additional padding is 254
+	// bytes, plus the padding value encoded in the next byte.
+	paddingLength := 0
+	for hasPadding {
+		if len(in) <= offset {
+			return nil, nil, 0, errTooShortForArbitraryLengthFrames
+		}
+
+		value := int(in[offset])
+		offset++
+
+		if value == 255 {
+			paddingLength += 254
+		} else {
+			paddingLength += value
+			hasPadding = false
+		}
+	}
+
+	var frameLengthsBuffer [maxFrameCount]int
+	frameLengths := frameLengthsBuffer[:frameCount]
+	switch {
+	case isVBR:
+		// In the VBR case, the (optional) padding length is followed by M-1
+		// frame lengths (indicated by "N1" to "N[M-1]" in Figure 7), each
+		// encoded in a one- or two-byte sequence as described above.  The
+		// packet MUST contain enough data for the M-1 lengths after removing
+		// the (optional) padding, and the sum of these lengths MUST be no
+		// larger than the number of bytes remaining in the packet after
+		// decoding them [R7].  The compressed data for all M frames follows,
+		// each frame consisting of the indicated number of bytes, with the
+		// final frame consuming any remaining bytes before the final padding.
+		lengthsCount := len(frameLengths) - 1
+		if isSelfDelimited {
+			lengthsCount++
+		}
+
+		for i := 0; i < lengthsCount; i++ {
+			if frameLengths[i], offset, err = parseFrameLength(in, offset); err != nil {
+				return nil, nil, 0, err
+			}
+		}
+
+		if !isSelfDelimited {
+			lastFrameLength := len(in) - paddingLength - offset
+			for i := 0; i < lengthsCount; i++ {
+				lastFrameLength -= frameLengths[i]
+			}
+
+			if lastFrameLength < 0 {
+				return nil, nil, 0, errTooShortForArbitraryLengthFrames
+			}
+			frameLengths[len(frameLengths)-1] = lastFrameLength
+		}
+	case isSelfDelimited:
+		// In the CBR case, the self-delimiting framing codes a single
+		// length, shared by all frames.
+		//
+		// https://datatracker.ietf.org/doc/html/rfc6716#appendix-B
+		var frameLength int
+		if frameLength, offset, err = parseFrameLength(in, offset); err != nil {
+			return nil, nil, 0, err
+		}
+
+		for i := range frameLengths {
+			frameLengths[i] = frameLength
+		}
+	default:
+		// In the CBR case, let R=N-2-P be the number of bytes remaining in the
+		// packet after subtracting the (optional) padding.  Then, the
+		// compressed length of each frame in bytes is equal to R/M.  The value
+		// R MUST be a non-negative integer multiple of M [R6].
+		remaining := len(in) - offset - paddingLength
+		if remaining < 0 || remaining%int(frameCount) != 0 {
+			return nil, nil, 0, errArbitraryLengthFramesCBRMismatch
+		}
+
+		for i := range frameLengths {
+			frameLengths[i] = remaining / int(frameCount)
+		}
+	}
+
+	if frames, offset, err = sliceFrames(frames, in, offset, frameLengths...); err != nil {
+		return nil, nil, 0, err
+	}
+
+	if len(in)-offset < paddingLength {
+		return nil, nil, 0, errTooShortForArbitraryLengthFrames
+	}
+
+	return frames, in[offset : offset+paddingLength], offset + paddingLength, nil
+}
+
+// sliceFrames slices consecutive frames of the given lengths from in,
+// starting at offset, into frames[:0]. The returned offset points past the
+// last frame.
+func sliceFrames(frames [][]byte, in []byte, offset int, frameLengths ...int) ([][]byte, int, error) {
+	frames = frames[:0]
+	for _, frameLength := range frameLengths {
+		switch {
+		case frameLength < 0 || len(in)-offset < frameLength:
+			return nil, 0, errTooShortForFrame
+		case frameLength > maxFrameSize:
+			return nil, 0, errFrameTooLarge
+		}
+
+		frames = append(frames, in[offset:offset+frameLength])
+		offset += frameLength
+	}
+
+	return frames, offset, nil
+}
+
+// When a packet contains multiple VBR frames (i.e., code 2 or 3), the
+// compressed length of one or more of these frames is indicated with a
+// one- or two-byte sequence, with the meaning of the first byte as
+// follows:
+//
+// o  0: No frame (Discontinuous Transmission (DTX) or lost packet)
+//
+// o  1...251: Length of the frame in bytes
+//
+// o  252...255: A second byte is needed.  The total length is
+// (second_byte*4)+first_byte
+//
+// https://datatracker.ietf.org/doc/html/rfc6716#section-3.2.1
+func parseFrameLength(in []byte, offset int) (frameLength int, n int, err error) {
+	if len(in) <= offset {
+		return 0, 0, errTooShortForFrameLength
+	}
+
+	frameLength = int(in[offset])
+	offset++
+
+	if frameLength >= 252 {
+		if len(in) <= offset {
+			return 0, 0, errTooShortForFrameLength
+		}
+
+		frameLength += int(in[offset]) * 4
+		offset++
+	}
+
+	return frameLength, offset, nil
+}
+
+// appendFrameLength appends a frame length in the one- or two-byte sequence
+// read by parseFrameLength
+func appendFrameLength(out []byte, frameLength int) []byte {
+	if frameLength < 252 {
+		return append(out, byte(frameLength))
+	}
+
+	first := 252 + frameLength&0b11
+	return append(out, byte(first), byte((frameLength-first)/4))
+}
+
+// frameLengthSize returns the number of bytes appendFrameLength appends
+func frameLengthSize(frameLength int) int {
+	if frameLength < 252 {
+		return 1
+	}
+
+	return 2
+}
+
+// appendPacket appends a packet of frames to out with the most compact
+// framing. Code 3 is used for more than two frames, when there is padding
+// data, or to pad the packet to targetLength if it is shorter. The Opus
+// padding holds the padding data followed by zeros.
+//
+// https://datatracker.ietf.org/doc/html/rfc6716#section-3.2
+func appendPacket(out []byte, toc byte, frames [][]byte, padding []byte, targetLength int, isSelfDelimited bool) ([]byte, error) {
+	if len(frames) == 0 || len(frames) > maxFrameCount {
+		return nil, errInvalidFrameCount
+	}
+	toc &^= 0b11
+
+	isCBR := true
+	for _, frame := range frames[1:] {
+		isCBR = isCBR && len(frame) == len(frames[0])
+	}
+
+	code := frameCode(frameCodeArbitraryFrames)
+	size := 1
+	for _, frame := range frames {
+		size += len(frame)
+	}
+
+	switch {
+	case len(padding) > 0 || len(frames) > 2:
+	case len(frames) == 1:
+		code = frameCodeOneFrame
+	case isCBR:
+		code = frameCodeTwoEqualFrames
+	default:
+		code = frameCodeTwoDifferentFrames
+		size += frameLengthSize(len(frames[0]))
+	}
+
+	// The self-delimiting framing adds the length of the last frame, which
+	// is shared by both frames of code 1
+	//
+	// https://datatracker.ietf.org/doc/html/rfc6716#appendix-B
+	if isSelfDelimited {
+		size += frameLengthSize(len(frames[len(frames)-1]))
+	}
+
+	if code != frameCodeArbitraryFrames && size >= targetLength {
+		if targetLength != 0 && size > targetLength {
+			return nil, errOutBufferTooSmall
+		}
+
+		out = append(out, toc|byte(code))
+		if code == frameCodeTwoDifferentFrames {
+			out = appendFrameLength(out, len(frames[0]))
+		}
+		if isSelfDelimited {
+			out = appendFrameLength(out, len(frames[len(frames)-1]))
+		}
+		for _, frame := range frames {
+			out = append(out, frame...)
+		}
+
+		return out, nil
+	}
+
+	// Code 3 adds the frame count byte, and the lengths of all frames but
+	// the last one for VBR
+	lengthsCount := len(frames) - 1
+	switch {
+	case isCBR && isSelfDelimited:
+		lengthsCount = 1
+	case isCBR:
+		lengthsCount = 0
+	case isSelfDelimited:
+		lengthsCount++
+	}
+
+	size = 2
+	for i, frame := range frames {
+		size += len(frame)
+		if i < lengthsCount {
+			size += frameLengthSize(len(frame))
+		}
+	}
+
+	// The padding amount includes the bytes that code the padding length
+	amount := 0
+	if len(padding) > 0 {
+		amount = len(padding) + 1
+		for paddingDataLength(amount) < len(padding) {
+			amount++
+		}
+	}
+	if targetLength-size > amount {
+		amount = targetLength - size
+	}
+	if targetLength != 0 && size+amount > targetLength {
+		return nil, errOutBufferTooSmall
+	}
+
+	countByte := byte(len(frames))
+	if !isCBR {
+		countByte |= 0b10000000
+	}
+	if amount > 0 {
+		countByte |= 0b01000000
+	}
+
+	out = append(out, toc|frameCodeArbitraryFrames, countByte)
+	if amount > 0 {
+		// A value of 255 codes 254 bytes of padding followed by another
+		// value
+		for i := 0; i < (amount-1)/255; i++ {
+			out = append(out, 255)
+		}
+		out = append(out, byte(amount-(amount-1)/255*255-1))
+	}
+	for _, frame := range frames[:lengthsCount] {
+		out = appendFrameLength(out, len(frame))
+	}
+	for _, frame := range frames {
+		out = append(out, frame...)
+	}
+
+	out = append(out, padding...)
+	for i := len(padding); i < paddingDataLength(amount); i++ {
+		out = append(out, 0)
+	}
+
+	return out, nil
+}
+
+// paddingDataLength returns the number of bytes of Opus padding that
+// follow the frames when the padding takes amount bytes in total
+func paddingDataLength(amount int) int {
+	if amount == 0 {
+		return 0
+	}
+
+	return amount - (amount-1)/255 - 1
+}
@@ -0,0 +1,169 @@
+package opus
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParsePacket(t *testing.T) {
+	t.Run("One Frame", func(t *testing.T) {
+		p, err := ParsePacket([]byte{0x48, 0x01, 0x02, 0x03})
+		switch {
+		case err != nil:
+			t.Fatal(err)
+		case !reflect.DeepEqual(p.Frames, [][]byte{{0x01, 0x02, 0x03}}):
+			t.Fatal()
+		case p.Configuration() != 9:
+			t.Fatal()
+		case p.Bandwidth() != BandwidthWideband:
+			t.Fatal()
+		case p.Duration() != 20*time.Millisecond:
+			t.Fatal()
+		}
+	})
+
+	t.Run("Two Equal Frames", func(t *testing.T) {
+		p, err := ParsePacket([]byte{0x49, 0x01, 0x02, 0x03, 0x04})
+		switch {
+		case err != nil:
+			t.Fatal(err)
+		case !reflect.DeepEqual(p.Frames, [][]byte{{0x01, 0x02}, {0x03, 0x04}}):
+			t.Fatal()
+		case p.Duration() != 40*time.Millisecond:
+			t.Fatal()
+		}
+
+		if _, err = ParsePacket([]byte{0x49, 0x01, 0x02, 0x03}); !errors.Is(err, errTwoEqualFramesOddLength) {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("Two Different Frames", func(t *testing.T) {
+		p, err := ParsePacket([]byte{0x4a, 0x01, 0x01, 0x02, 0x03})
+		switch {
+		case err != nil:
+			t.Fatal(err)
+		case !reflect.DeepEqual(p.Frames, [][]byte{{0x01}, {0x02, 0x03}}):
+			t.Fatal()
+		}
+
+		if _, err = ParsePacket([]byte{0x4a, 0x05, 0x01}); !errors.Is(err, errTooShortForFrame) {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("Two Byte Frame Length", func(t *testing.T) {
+		in := append([]byte{0x4a, 0xfc, 0x01}, make([]byte, 260)...)
+		p, err := ParsePacket(in)
+		switch {
+		case err != nil:
+			t.Fatal(err)
+		case len(p.Frames[0]) != 256:
+			t.Fatal()
+		case len(p.Frames[1]) != 4:
+			t.Fatal()
+		}
+	})
+
+	t.Run("Arbitrary CBR Frames With Padding", func(t *testing.T) {
+		p, err := ParsePacket([]byte{0x4b, 0x43, 0x02, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x00, 0x00})
+		switch {
+		case err != nil:
+			t.Fatal(err)
+		case !reflect.DeepEqual(p.Frames, [][]byte{{0x01, 0x02}, {0x03, 0x04}, {0x05, 0x06}}):
+			t.Fatal()
+		case !bytes.Equal(p.Padding, []byte{0x00, 0x00}):
+			t.Fatal()
+		}
+
+		if _, err = ParsePacket([]byte{0x4b, 0x02, 0x01, 0x02, 0x03}); !errors.Is(err, errArbitraryLengthFramesCBRMismatch) {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("Arbitrary VBR Frames", func(t *testing.T) {
+		p, err := ParsePacket([]byte{0x4b, 0x83, 0x01, 0x00, 0x01, 0x02, 0x03})
+		switch {
+		case err != nil:
+			t.Fatal(err)
+		case !reflect.DeepEqual(p.Frames, [][]byte{{0x01}, {}, {0x02, 0x03}}):
+			t.Fatal()
+		}
+	})
+
+	t.Run("Long Padding", func(t *testing.T) {
+		in := append([]byte{0x4b, 0x41, 0xff, 0x01, 0x01}, make([]byte, 255)...)
+		p, err := ParsePacket(in)
+		switch {
+		case err != nil:
+			t.Fatal(err)
+		case len(p.Padding) != 255:
+			t.Fatal()
+		case !reflect.DeepEqual(p.Frames, [][]byte{{0x01}}):
+			t.Fatal()
+		}
+	})
+
+	t.Run("Invalid Frame Count", func(t *testing.T) {
+		if _, err := ParsePacket([]byte{0x4b, 0x00}); !errors.Is(err, errInvalidFrameCount) {
+			t.Fatal(err)
+		}
+
+		// 7 frames of 20ms exceed 120ms
+		if _, err := ParsePacket([]byte{0x4b, 0x07}); !errors.Is(err, errPacketTooLong) {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		if _, err := ParsePacket([]byte{}); !errors.Is(err, errTooShortForTableOfContentsHeader) {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestParseSelfDelimitedPacket(t *testing.T) {
+	stream := []byte{
+		// Code 0, one frame of two bytes
+		0x48, 0x02, 0x01, 0x02,
+		// Code 1, two frames of one byte
+		0x49, 0x01, 0x03, 0x04,
+		// Code 2, frames of one and two bytes
+		0x4a, 0x01, 0x02, 0x05, 0x06, 0x07,
+		// Code 3 VBR with one byte of padding, frames of zero and one byte
+		0x4b, 0xc2, 0x01, 0x00, 0x01, 0x08, 0x00,
+		// Code 3 CBR, two frames of one byte
+		0x4b, 0x02, 0x01, 0x09, 0x0a,
+	}
+
+	expectedFrames := [][][]byte{
+		{{0x01, 0x02}},
+		{{0x03}, {0x04}},
+		{{0x05}, {0x06, 0x07}},
+		{{}, {0x08}},
+		{{0x09}, {0x0a}},
+	}
+
+	for i := range expectedFrames {
+		p, n, err := ParseSelfDelimitedPacket(stream)
+		switch {
+		case err != nil:
+			t.Fatal(err)
+		case !reflect.DeepEqual(p.Frames, expectedFrames[i]):
+			t.Fatalf("%d: %v", i, p.Frames)
+		}
+
+		stream = stream[n:]
+	}
+
+	if len(stream) != 0 {
+		t.Fatal("stream not fully consumed")
+	}
+
+	if _, _, err := ParseSelfDelimitedPacket([]byte{0x48, 0x05, 0x01}); !errors.Is(err, errTooShortForFrame) {
+		t.Fatal(err)
+	}
+}
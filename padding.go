@@ -0,0 +1,108 @@
+package opus
+
+// PadPacket returns packet padded to targetLength bytes. The packet is
+// rewritten to code 3 with Opus padding, the frames and extensions are
+// kept, so the decoded audio doesn't change. Padding every packet to the
+// same length hides the changes of the bitrate from the transport.
+//
+// https://datatracker.ietf.org/doc/html/rfc6716#section-3.2.5
+func PadPacket(packet []byte, targetLength int) ([]byte, error) {
+	if targetLength < len(packet) {
+		return nil, errTargetLengthTooShort
+	}
+
+	p, err := ParsePacket(packet)
+	if err != nil {
+		return nil, err
+	}
+
+	return appendRepacketized(nil, p, targetLength, false)
+}
+
+// UnpadPacket returns packet without its Opus padding, using the most
+// compact framing for its frames. Extensions in the padding are kept.
+func UnpadPacket(packet []byte) ([]byte, error) {
+	p, err := ParsePacket(packet)
+	if err != nil {
+		return nil, err
+	}
+
+	return appendRepacketized(nil, p, 0, false)
+}
+
+// PadMultistreamPacket returns a multistream packet of streamCount streams
+// padded to targetLength bytes. All streams but the last one use the
+// self-delimiting framing, the padding is added to the last one.
+//
+// https://datatracker.ietf.org/doc/html/rfc7845#section-5.1.1
+func PadMultistreamPacket(packet []byte, targetLength, streamCount int) ([]byte, error) {
+	if streamCount < 1 {
+		return nil, errInvalidStreamCount
+	}
+	if targetLength < len(packet) {
+		return nil, errTargetLengthTooShort
+	}
+
+	offset := 0
+	for i := 0; i < streamCount-1; i++ {
+		_, n, err := ParseSelfDelimitedPacket(packet[offset:])
+		if err != nil {
+			return nil, err
+		}
+		offset += n
+	}
+
+	p, err := ParsePacket(packet[offset:])
+	if err != nil {
+		return nil, err
+	}
+
+	return appendRepacketized(append([]byte{}, packet[:offset]...), p, targetLength-offset, false)
+}
+
+// UnpadMultistreamPacket returns a multistream packet of streamCount
+// streams without the Opus padding of any of its streams
+func UnpadMultistreamPacket(packet []byte, streamCount int) ([]byte, error) {
+	if streamCount < 1 {
+		return nil, errInvalidStreamCount
+	}
+
+	var out []byte
+	for i := 0; i < streamCount-1; i++ {
+		p, n, err := ParseSelfDelimitedPacket(packet)
+		if err != nil {
+			return nil, err
+		}
+		if out, err = appendRepacketized(out, p, 0, true); err != nil {
+			return nil, err
+		}
+		packet = packet[n:]
+	}
+
+	p, err := ParsePacket(packet)
+	if err != nil {
+		return nil, err
+	}
+
+	return appendRepacketized(out, p, 0, false)
+}
+
+// appendRepacketized appends the frames and extensions of p to out as a
+// packet of targetLength bytes, or of the most compact framing if
+// targetLength is 0. The contents of the padding must be ignored by
+// decoders, so padding that doesn't hold valid extensions is dropped.
+//
+// https://datatracker.ietf.org/doc/html/rfc6716#section-3.2.5
+func appendRepacketized(out []byte, p Packet, targetLength int, isSelfDelimited bool) ([]byte, error) {
+	extensions, err := p.Extensions()
+	if err != nil {
+		extensions = nil
+	}
+
+	padding, err := AppendExtensions(nil, extensions, len(p.Frames))
+	if err != nil {
+		return nil, err
+	}
+
+	return appendPacket(out, p.TOC, p.Frames, padding, targetLength, isSelfDelimited)
+}
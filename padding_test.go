@@ -0,0 +1,160 @@
+package opus
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func decodeTestPacket(t *testing.T, packet []byte) []byte {
+	out := make([]byte, 1920)
+	decoder := NewDecoder()
+	if _, _, err := decoder.Decode(packet, out); err != nil {
+		t.Fatal(err)
+	}
+
+	return out
+}
+
+func TestPadPacket(t *testing.T) {
+	packet := append([]byte{0x48}, testSilkFrame()...)
+	expected := decodeTestPacket(t, packet)
+
+	for _, length := range []int{len(packet), len(packet) + 1, 100, 300, 1000} {
+		padded, err := PadPacket(packet, length)
+		switch {
+		case err != nil:
+			t.Fatal(err)
+		case len(padded) != length:
+			t.Fatal(len(padded))
+		case !bytes.Equal(decodeTestPacket(t, padded), expected):
+			t.Fatalf("%d: padded packet decoded differently", length)
+		}
+
+		unpadded, err := UnpadPacket(padded)
+		if err != nil {
+			t.Fatal(err)
+		} else if !bytes.Equal(unpadded, packet) {
+			t.Fatal(unpadded)
+		}
+	}
+
+	if _, err := PadPacket(packet, len(packet)-1); !errors.Is(err, errTargetLengthTooShort) {
+		t.Fatal(err)
+	}
+}
+
+func TestUnpadPacket(t *testing.T) {
+	// Code 3 CBR packets of two and three frames with three bytes of padding
+	unpadded, err := UnpadPacket([]byte{0x4b, 0x42, 0x03, 0x01, 0x02, 0x00, 0x00, 0x00})
+	if err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(unpadded, []byte{0x49, 0x01, 0x02}) {
+		t.Fatal(unpadded)
+	}
+
+	unpadded, err = UnpadPacket([]byte{0x4b, 0x43, 0x03, 0x01, 0x02, 0x03, 0x00, 0x00, 0x00})
+	if err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(unpadded, []byte{0x4b, 0x03, 0x01, 0x02, 0x03}) {
+		t.Fatal(unpadded)
+	}
+
+	// Extensions are kept, the zeros after them are removed
+	unpadded, err = UnpadPacket([]byte{0x4b, 0x42, 0x04, 0x01, 0x02, 3<<1 | 1, 0xAA, 0x00, 0x00})
+	if err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(unpadded, []byte{0x4b, 0x42, 0x02, 0x01, 0x02, 3<<1 | 1, 0xAA}) {
+		t.Fatal(unpadded)
+	}
+
+	// Padding that doesn't hold valid extensions is dropped
+	unpadded, err = UnpadPacket([]byte{0x4b, 0x42, 0x02, 0x01, 0x02, 32<<1 | 1, 0x05})
+	if err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(unpadded, []byte{0x49, 0x01, 0x02}) {
+		t.Fatal(unpadded)
+	}
+	if padded, err := PadPacket([]byte{0x4b, 0x41, 0x01, 0x01, 1 << 1}, 8); err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(padded, []byte{0x4b, 0x41, 0x04, 0x01, 0x00, 0x00, 0x00, 0x00}) {
+		t.Fatal(padded)
+	}
+
+	if _, err := appendPacket(nil, 0x48, nil, nil, 0, false); !errors.Is(err, errInvalidFrameCount) {
+		t.Fatal(err)
+	}
+
+	unpadded = []byte{0x4b, 0x42, 0x02, 0x01, 0x02, 3<<1 | 1, 0xAA}
+	padded, err := PadPacket(unpadded, 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := ParsePacket(padded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	extensions, err := p.Extensions()
+	switch {
+	case err != nil:
+		t.Fatal(err)
+	case !reflect.DeepEqual(extensions, []Extension{{ID: 3, Frame: 0, Data: []byte{0xAA}}}):
+		t.Fatalf("%+v", extensions)
+	}
+}
+
+func TestPadMultistreamPacket(t *testing.T) {
+	// The first stream is self-delimited, and padded by a byte
+	first := append([]byte{0x4b, 0x41, 0x01, byte(len(testSilkFrame()))}, testSilkFrame()...)
+	first = append(first, 0x00)
+	last := append([]byte{0x48}, testSilkFrame()...)
+	packet := append(append([]byte{}, first...), last...)
+
+	decode := func(packet []byte) []byte {
+		out := make([]byte, 2*1920)
+		decoder := NewDecoder()
+		n, _, _, err := decoder.DecodeSelfDelimited(packet, out[:1920])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		decoder = NewDecoder()
+		if _, _, err = decoder.Decode(packet[n:], out[1920:]); err != nil {
+			t.Fatal(err)
+		}
+
+		return out
+	}
+	expected := decode(packet)
+
+	padded, err := PadMultistreamPacket(packet, 100, 2)
+	switch {
+	case err != nil:
+		t.Fatal(err)
+	case len(padded) != 100:
+		t.Fatal(len(padded))
+	case !bytes.Equal(padded[:len(first)], first):
+		t.Fatal("first stream changed")
+	case !bytes.Equal(decode(padded), expected):
+		t.Fatal("padded packet decoded differently")
+	}
+
+	unpadded, err := UnpadMultistreamPacket(padded, 2)
+	selfDelimited := append([]byte{0x48, byte(len(testSilkFrame()))}, testSilkFrame()...)
+	switch {
+	case err != nil:
+		t.Fatal(err)
+	case !bytes.Equal(unpadded, append(selfDelimited, last...)):
+		t.Fatal(unpadded)
+	case !bytes.Equal(decode(unpadded), expected):
+		t.Fatal("unpadded packet decoded differently")
+	}
+
+	if _, err := PadMultistreamPacket(packet, 100, 0); !errors.Is(err, errInvalidStreamCount) {
+		t.Fatal(err)
+	}
+	if _, err := UnpadMultistreamPacket(packet, 3); err == nil {
+		t.Fatal("packet with missing stream unpadded")
+	}
+}
@@ -0,0 +1,95 @@
+// Package ambisonics implements the ambisonics channel mapping families
+// of Ogg Opus and renders ambisonic channels to loudspeakers and headphones
+//
+// https://datatracker.ietf.org/doc/html/rfc8486
+package ambisonics
+
+import (
+	"math"
+
+	"github.com/pion/opus/pkg/oggreader"
+)
+
+// Order returns the ambisonic order for the given channel count, and if the
+// channels are followed by a non-diegetic stereo pair.
+//
+// Allowed numbers of channels: (1 + n)^2 + 2j for n = 0...14 and j = 0 or 1,
+// where n denotes the (highest) ambisonic order and j denotes whether or not
+// there is a separate non-diegetic stereo stream.
+//
+// https://datatracker.ietf.org/doc/html/rfc8486#section-3.1
+func Order(channels int) (order int, hasNonDiegeticStereo bool, err error) {
+	order, hasNonDiegeticStereo, ok := oggreader.AmbisonicsOrder(channels)
+	if !ok {
+		return 0, false, errInvalidChannelCount
+	}
+
+	return order, hasNonDiegeticStereo, nil
+}
+
+// Each ambisonic channel is identified by its Ambisonic Channel Number
+// (ACN), given by ACN = n * (n + 1) + m where n is the degree and m is
+// the order of the spherical harmonic.
+//
+// https://datatracker.ietf.org/doc/html/rfc8486#section-3.1
+func acnDegree(acn int) int {
+	return int(math.Sqrt(float64(acn)))
+}
+
+// sphericalHarmonics evaluates the real valued, SN3D normalized spherical
+// harmonics up to the given order for a direction given in radians.
+// Azimuth is measured counter-clockwise from the front, elevation upwards
+// from the horizontal plane. The result is indexed by ACN.
+//
+//	Y(n, m) = N(n, |m|) * P(n, |m|)(sin(elevation)) * { cos(m * azimuth)   m >= 0
+//	                                                   { sin(|m| * azimuth) m < 0
+//
+//	N(n, m) = sqrt((2 - delta(m)) * (n - m)! / (n + m)!)
+//
+// P(n, m) is the associated Legendre function without the Condon-Shortley
+// phase.
+func sphericalHarmonics(order int, azimuth, elevation float64) []float64 {
+	out := make([]float64, (order+1)*(order+1))
+	x := math.Sin(elevation)
+	cosElevation := math.Cos(elevation)
+
+	for m := 0; m <= order; m++ {
+		// P(m, m) = (2m - 1)!! * (1 - x^2)^(m/2)
+		pmm := 1.0
+		for i := 1; i <= m; i++ {
+			pmm *= float64(2*i-1) * cosElevation
+		}
+
+		pPrevious, pCurrent := 0.0, pmm
+		for n := m; n <= order; n++ {
+			switch {
+			case n == m+1:
+				pPrevious, pCurrent = pCurrent, x*float64(2*m+1)*pmm
+			case n > m+1:
+				pPrevious, pCurrent = pCurrent, (float64(2*n-1)*x*pCurrent-float64(n+m-1)*pPrevious)/float64(n-m)
+			}
+
+			normalization := math.Sqrt(factorialRatio(n-m, n+m))
+			if m != 0 {
+				normalization *= math.Sqrt2
+			}
+
+			out[n*(n+1)+m] = normalization * pCurrent * math.Cos(float64(m)*azimuth)
+			if m != 0 {
+				out[n*(n+1)-m] = normalization * pCurrent * math.Sin(float64(m)*azimuth)
+			}
+		}
+	}
+
+	return out
+}
+
+// factorialRatio computes a! / b! for a <= b
+func factorialRatio(a, b int) float64 {
+	ratio := 1.0
+	for i := a + 1; i <= b; i++ {
+		ratio /= float64(i)
+	}
+
+	return ratio
+}
@@ -0,0 +1,206 @@
+package ambisonics
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/pion/opus/pkg/oggreader"
+)
+
+const floatEqualityThreshold = 0.000001
+
+func TestOrder(t *testing.T) {
+	for _, test := range []struct {
+		channels             int
+		order                int
+		hasNonDiegeticStereo bool
+	}{
+		{1, 0, false},
+		{4, 1, false},
+		{6, 1, true},
+		{9, 2, false},
+		{11, 2, true},
+		{225, 14, false},
+		{227, 14, true},
+	} {
+		order, hasNonDiegeticStereo, err := Order(test.channels)
+		switch {
+		case err != nil:
+			t.Fatal(err)
+		case order != test.order:
+			t.Fatalf("%d: order %d != %d", test.channels, order, test.order)
+		case hasNonDiegeticStereo != test.hasNonDiegeticStereo:
+			t.Fatalf("%d: non-diegetic stereo mismatch", test.channels)
+		}
+	}
+
+	for _, channels := range []int{0, 2, 5, 12, 228} {
+		if _, _, err := Order(channels); !errors.Is(err, errInvalidChannelCount) {
+			t.Fatalf("%d: %v", channels, err)
+		}
+	}
+}
+
+func TestSphericalHarmonics(t *testing.T) {
+	azimuth, elevation := 0.3, 0.2
+	harmonics := sphericalHarmonics(2, azimuth, elevation)
+
+	// First and second order SN3D harmonics in ACN order
+	//
+	// https://en.wikipedia.org/wiki/Ambisonic_data_exchange_formats
+	x := math.Cos(azimuth) * math.Cos(elevation)
+	y := math.Sin(azimuth) * math.Cos(elevation)
+	z := math.Sin(elevation)
+	expected := []float64{
+		1,
+		y,
+		z,
+		x,
+		math.Sqrt(3) * x * y,
+		math.Sqrt(3) * y * z,
+		0.5 * (3*z*z - 1),
+		math.Sqrt(3) * x * z,
+		math.Sqrt(3) / 2 * (x*x - y*y),
+	}
+
+	for i := range expected {
+		if math.Abs(harmonics[i]-expected[i]) > floatEqualityThreshold {
+			t.Fatalf("ACN %d: %f != %f", i, harmonics[i], expected[i])
+		}
+	}
+}
+
+func TestDemixer(t *testing.T) {
+	t.Run("Ambisonics", func(t *testing.T) {
+		d, err := NewDemixer(&oggreader.OggHeader{
+			ChannelMap:     2,
+			Channels:       4,
+			StreamCount:    3,
+			CoupledCount:   1,
+			ChannelMapping: []uint8{3, 255, 0, 1},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		out := make([]float32, 8)
+		if err := d.Demix([]float32{0.1, 0.2, 0.3, 0.4, 0.5, 0.6, 0.7, 0.8}, out); err != nil {
+			t.Fatal(err)
+		}
+
+		expected := []float32{0.4, 0, 0.1, 0.2, 0.8, 0, 0.5, 0.6}
+		for i := range expected {
+			if out[i] != expected[i] {
+				t.Fatalf("%d: %f != %f", i, out[i], expected[i])
+			}
+		}
+	})
+
+	t.Run("Projection", func(t *testing.T) {
+		d, err := NewDemixer(&oggreader.OggHeader{
+			ChannelMap:   3,
+			Channels:     4,
+			StreamCount:  2,
+			CoupledCount: 2,
+			DemixingMatrix: []int16{
+				16384, 16384, 0, 0,
+				16384, -16384, 0, 0,
+				0, 0, -32768, 0,
+				0, 0, 0, 16384,
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		out := make([]float32, 4)
+		if err := d.Demix([]float32{0.5, 0.25, 0.5, 1}, out); err != nil {
+			t.Fatal(err)
+		}
+
+		expected := []float32{0.375, 0.125, -0.5, 0.5}
+		for i := range expected {
+			if math.Abs(float64(out[i]-expected[i])) > floatEqualityThreshold {
+				t.Fatalf("%d: %f != %f", i, out[i], expected[i])
+			}
+		}
+	})
+
+	t.Run("Invalid Demixing Matrix", func(t *testing.T) {
+		_, err := NewDemixer(&oggreader.OggHeader{
+			ChannelMap:     3,
+			Channels:       4,
+			StreamCount:    2,
+			CoupledCount:   2,
+			DemixingMatrix: []int16{32767},
+		})
+		if !errors.Is(err, errInvalidDemixingMatrix) {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("Unsupported Channel Mapping", func(t *testing.T) {
+		_, err := NewDemixer(&oggreader.OggHeader{ChannelMap: 1, Channels: 4})
+		if !errors.Is(err, errUnsupportedChannelMapping) {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestStereoRenderer(t *testing.T) {
+	r, err := NewStereoRenderer(6)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A first order source directly to the left, followed by a
+	// non-diegetic stereo pair.
+	out := make([]float32, 2)
+	if err := r.Render([]float32{1, 1, 0, 0, 0.25, 0.5}, out); err != nil {
+		t.Fatal(err)
+	}
+
+	if math.Abs(float64(out[0]-1.25)) > floatEqualityThreshold {
+		t.Fatalf("left %f", out[0])
+	} else if math.Abs(float64(out[1]-0.5)) > floatEqualityThreshold {
+		t.Fatalf("right %f", out[1])
+	}
+}
+
+func TestBinauralRenderer(t *testing.T) {
+	r, err := NewBinauralRenderer(4, 48000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A source directly to the left is louder in the left ear
+	in := make([]float32, 4*64)
+	in[0], in[1] = 1, 1
+
+	out := make([]float32, 2*64)
+	if err := r.Render(in, out); err != nil {
+		t.Fatal(err)
+	}
+
+	// The speakers above and below are followed by the ring starting at
+	// the front, so speaker 4 is directly to the left and reaches the
+	// right ear late.
+	if r.leftDelays[4] != 0 || r.rightDelays[4] == 0 {
+		t.Fatalf("left delay %d, right delay %d", r.leftDelays[4], r.rightDelays[4])
+	}
+
+	var leftEnergy, rightEnergy float32
+	for i := 0; i < len(out); i += 2 {
+		leftEnergy += out[i] * out[i]
+		rightEnergy += out[i+1] * out[i+1]
+	}
+
+	if leftEnergy <= rightEnergy {
+		t.Fatalf("left %f <= right %f", leftEnergy, rightEnergy)
+	}
+
+	if _, err := NewBinauralRenderer(4, 0); !errors.Is(err, errInvalidSampleRate) {
+		t.Fatal(err)
+	}
+}
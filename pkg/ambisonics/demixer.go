@@ -0,0 +1,121 @@
+package ambisonics
+
+import (
+	"github.com/pion/opus/pkg/oggreader"
+)
+
+// Demixer converts the channels decoded from the streams of an Ogg Opus
+// file using channel mapping family 2 or 3 into ambisonic channels in
+// ACN order, followed by the non-diegetic stereo pair if present.
+//
+// The decoded channels are expected in stream order, the two channels of
+// every coupled stream followed by the single channel of every uncoupled
+// stream. opus.Stream demixes the ambisonics files it decodes with it, it
+// rejects files with coupled streams.
+type Demixer struct {
+	channels       int
+	streamChannels int
+
+	// mapping is used by channel mapping family 2
+	mapping []uint8
+
+	// matrix is used by channel mapping family 3 and holds
+	// channels * streamChannels coefficients in column-major order.
+	matrix []float32
+}
+
+// NewDemixer creates a Demixer for the channel mapping described in the
+// Ogg Opus ID header
+func NewDemixer(header *oggreader.OggHeader) (*Demixer, error) {
+	if _, _, err := Order(int(header.Channels)); err != nil {
+		return nil, err
+	}
+
+	d := &Demixer{
+		channels:       int(header.Channels),
+		streamChannels: int(header.StreamCount) + int(header.CoupledCount),
+	}
+
+	switch header.ChannelMap {
+	case oggreader.ChannelMappingFamilyAmbisonics:
+		// Channel mapping family 2 maps every output channel to a
+		// decoded channel, exactly like channel mapping family 1.
+		//
+		// https://datatracker.ietf.org/doc/html/rfc8486#section-3.1
+		if len(header.ChannelMapping) != d.channels {
+			return nil, errInvalidChannelMapping
+		}
+
+		d.mapping = append([]uint8{}, header.ChannelMapping...)
+	case oggreader.ChannelMappingFamilyProjection:
+		// The demixing matrix is applied to the decoded channels to
+		// produce the ambisonic channels. Every coefficient is a Q15
+		// value in the range [-1, 1).
+		//
+		// https://datatracker.ietf.org/doc/html/rfc8486#section-3.2
+		if len(header.DemixingMatrix) != d.channels*d.streamChannels {
+			return nil, errInvalidDemixingMatrix
+		}
+
+		d.matrix = make([]float32, len(header.DemixingMatrix))
+		for i := range header.DemixingMatrix {
+			d.matrix[i] = float32(header.DemixingMatrix[i]) / 32768.0
+		}
+	default:
+		return nil, errUnsupportedChannelMapping
+	}
+
+	return d, nil
+}
+
+// Channels returns the number of ambisonic channels produced by Demix,
+// including the non-diegetic stereo pair
+func (d *Demixer) Channels() int {
+	return d.channels
+}
+
+// StreamChannels returns the number of decoded channels expected by Demix
+func (d *Demixer) StreamChannels() int {
+	return d.streamChannels
+}
+
+// Demix converts interleaved decoded channels into interleaved ambisonic
+// channels. in must contain StreamChannels samples for every sample frame
+// and out must be large enough to hold Channels samples for each of them.
+func (d *Demixer) Demix(in, out []float32) error {
+	if len(in)%d.streamChannels != 0 {
+		return errBufferLengthMismatch
+	}
+
+	sampleFrames := len(in) / d.streamChannels
+	if len(out) < sampleFrames*d.channels {
+		return errBufferLengthMismatch
+	}
+
+	for i := 0; i < sampleFrames; i++ {
+		decoded := in[i*d.streamChannels : (i+1)*d.streamChannels]
+		ambisonic := out[i*d.channels : (i+1)*d.channels]
+
+		if d.matrix == nil {
+			for c, index := range d.mapping {
+				if index == oggreader.SilentChannel || int(index) >= d.streamChannels {
+					ambisonic[c] = 0
+				} else {
+					ambisonic[c] = decoded[index]
+				}
+			}
+
+			continue
+		}
+
+		for c := range ambisonic {
+			sum := float32(0)
+			for j := range decoded {
+				sum += d.matrix[j*d.channels+c] * decoded[j]
+			}
+			ambisonic[c] = sum
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,12 @@
+package ambisonics
+
+import "errors"
+
+var (
+	errInvalidChannelCount       = errors.New("channel count is not a valid ambisonics channel count")
+	errUnsupportedChannelMapping = errors.New("channel mapping family is not an ambisonics family")
+	errInvalidDemixingMatrix     = errors.New("demixing matrix size does not match channel and stream count")
+	errInvalidChannelMapping     = errors.New("channel mapping size does not match channel count")
+	errBufferLengthMismatch      = errors.New("length of in and out buffer do not contain the same number of samples")
+	errInvalidSampleRate         = errors.New("sample rate must be positive")
+)
@@ -0,0 +1,208 @@
+package ambisonics
+
+import (
+	"math"
+)
+
+const (
+	// Approximate head radius in meters and speed of sound in meters
+	// per second used to derive the interaural time difference.
+	headRadius    = 0.0875
+	speedOfSound  = 343.0
+	minRingLength = 8
+
+	// The far ear is attenuated by up to this amount to approximate
+	// the shadowing of the head.
+	headShadow = 0.5
+)
+
+// Speaker is the direction of a virtual loudspeaker in radians. Azimuth is
+// measured counter-clockwise from the front, elevation upwards from the
+// horizontal plane.
+type Speaker struct {
+	Azimuth   float64
+	Elevation float64
+}
+
+// Renderer renders ambisonic channels to stereo by decoding them to a set
+// of virtual loudspeakers, and then mixing every loudspeaker into a left
+// and right output.
+type Renderer struct {
+	channels             int
+	acnChannels          int
+	hasNonDiegeticStereo bool
+
+	// decodingGains holds acnChannels gains for every speaker
+	decodingGains [][]float32
+
+	leftGains, rightGains   []float32
+	leftDelays, rightDelays []int
+
+	// history holds the most recent samples of every speaker,
+	// used to delay the signal arriving at the far ear.
+	history      [][]float32
+	historyIndex int
+}
+
+// NewStereoRenderer creates a Renderer that outputs a stereo pair of
+// virtual microphones pointing left and right.
+func NewStereoRenderer(channels int) (*Renderer, error) {
+	left := Speaker{Azimuth: math.Pi / 2}
+	right := Speaker{Azimuth: -math.Pi / 2}
+
+	r, err := newRenderer(channels, []Speaker{left, right})
+	if err != nil {
+		return nil, err
+	}
+
+	r.leftGains = []float32{1, 0}
+	r.rightGains = []float32{0, 1}
+	r.leftDelays = []int{0, 0}
+	r.rightDelays = []int{0, 0}
+	r.history = [][]float32{{0}, {0}}
+	return r, nil
+}
+
+// NewBinauralRenderer creates a Renderer that outputs a binaural signal for
+// headphones. The ambisonic channels are decoded to a ring of virtual
+// loudspeakers around the listener plus one above and one below, every
+// loudspeaker then reaches each ear with a simple model of the level and
+// time differences caused by the head.
+func NewBinauralRenderer(channels, sampleRate int) (*Renderer, error) {
+	if sampleRate <= 0 {
+		return nil, errInvalidSampleRate
+	}
+
+	order, _, err := Order(channels)
+	if err != nil {
+		return nil, err
+	}
+
+	ringLength := 2*order + 2
+	if ringLength < minRingLength {
+		ringLength = minRingLength
+	}
+
+	speakers := []Speaker{{Elevation: math.Pi / 2}, {Elevation: -math.Pi / 2}}
+	for i := 0; i < ringLength; i++ {
+		speakers = append(speakers, Speaker{Azimuth: 2 * math.Pi * float64(i) / float64(ringLength)})
+	}
+
+	r, err := newRenderer(channels, speakers)
+	if err != nil {
+		return nil, err
+	}
+
+	r.leftGains = make([]float32, len(speakers))
+	r.rightGains = make([]float32, len(speakers))
+	r.leftDelays = make([]int, len(speakers))
+	r.rightDelays = make([]int, len(speakers))
+	r.history = make([][]float32, len(speakers))
+
+	maxDelay := 0
+	for k, speaker := range speakers {
+		// lateral is 1 for a source directly left of the listener and -1
+		// for a source directly to the right.
+		lateral := math.Sin(speaker.Azimuth) * math.Cos(speaker.Elevation)
+		r.leftGains[k] = float32(1 - headShadow*(1-lateral)/2)
+		r.rightGains[k] = float32(1 - headShadow*(1+lateral)/2)
+
+		// Woodworth's formula for the interaural time difference
+		angle := math.Asin(math.Abs(lateral))
+		delay := int(math.Round(headRadius / speedOfSound * (angle + math.Sin(angle)) * float64(sampleRate)))
+		if lateral > 0 {
+			r.rightDelays[k] = delay
+		} else {
+			r.leftDelays[k] = delay
+		}
+
+		if delay > maxDelay {
+			maxDelay = delay
+		}
+	}
+
+	for k := range r.history {
+		r.history[k] = make([]float32, maxDelay+1)
+	}
+
+	return r, nil
+}
+
+// newRenderer computes the gains of a sampling decoder with in-phase
+// weighting for the given virtual loudspeakers. In-phase weighting avoids
+// loudspeakers opposite of a source playing it back with inverted polarity.
+func newRenderer(channels int, speakers []Speaker) (*Renderer, error) {
+	order, hasNonDiegeticStereo, err := Order(channels)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Renderer{
+		channels:             channels,
+		acnChannels:          (order + 1) * (order + 1),
+		hasNonDiegeticStereo: hasNonDiegeticStereo,
+		decodingGains:        make([][]float32, len(speakers)),
+	}
+
+	//                  N! * (N + 1)!
+	// w(n) = ---------------------------------
+	//         (N + n + 1)! * (N - n)!
+	weights := make([]float64, order+1)
+	for n := range weights {
+		weights[n] = factorial(order) * factorial(order+1) / (factorial(order+n+1) * factorial(order-n))
+	}
+
+	for k, speaker := range speakers {
+		harmonics := sphericalHarmonics(order, speaker.Azimuth, speaker.Elevation)
+
+		r.decodingGains[k] = make([]float32, r.acnChannels)
+		for acn := range harmonics {
+			n := acnDegree(acn)
+			r.decodingGains[k][acn] = float32(weights[n] * float64(2*n+1) * harmonics[acn] / float64(len(speakers)))
+		}
+	}
+
+	return r, nil
+}
+
+// Render converts interleaved ambisonic channels, as returned by
+// Demixer.Demix, into interleaved stereo. The non-diegetic stereo pair
+// is mixed into the output unchanged.
+func (r *Renderer) Render(in, out []float32) error {
+	if len(in)%r.channels != 0 || len(out) < (len(in)/r.channels)*2 {
+		return errBufferLengthMismatch
+	}
+
+	for i := 0; i < len(in)/r.channels; i++ {
+		ambisonic := in[i*r.channels : (i+1)*r.channels]
+
+		var left, right float32
+		for k, gains := range r.decodingGains {
+			speaker := float32(0)
+			for acn, gain := range gains {
+				speaker += gain * ambisonic[acn]
+			}
+
+			history := r.history[k]
+			history[r.historyIndex] = speaker
+
+			left += r.leftGains[k] * history[(r.historyIndex-r.leftDelays[k]+len(history))%len(history)]
+			right += r.rightGains[k] * history[(r.historyIndex-r.rightDelays[k]+len(history))%len(history)]
+		}
+		r.historyIndex = (r.historyIndex + 1) % len(r.history[0])
+
+		if r.hasNonDiegeticStereo {
+			left += ambisonic[r.acnChannels]
+			right += ambisonic[r.acnChannels+1]
+		}
+
+		out[i*2] = left
+		out[i*2+1] = right
+	}
+
+	return nil
+}
+
+func factorial(n int) float64 {
+	return 1 / factorialRatio(0, n)
+}
@@ -0,0 +1,158 @@
+package analysis
+
+import (
+	"math"
+
+	"github.com/pion/opus"
+	"github.com/pion/opus/internal/celt"
+	"github.com/pion/opus/internal/silk"
+)
+
+const (
+	// MinLevel is the level of silence in dBov, the lowest level RFC 6464
+	// can express
+	MinLevel = -127
+
+	gainQ16One     = 1 << 16
+	fullScaleLevel = 32768
+)
+
+// Activity is the speech activity and level of a packet, estimated from the
+// start of its frames without decoding any audio
+type Activity struct {
+	// VoiceActivity is set when any SILK frame of the packet has its VAD
+	// flag set. CELT frames have no VAD flag.
+	VoiceActivity bool
+
+	// Level is the mean level of the quantization gains of the SILK frames,
+	// or of the coarse band energies of the CELT frames, in dBov between
+	// MinLevel and 0.
+	//
+	// The gains scale the excitation before the LPC synthesis filter, the
+	// decoded audio is louder by the prediction gain of the filter. The
+	// band energies are those of the MDCT of the frame. Both levels are
+	// therefore not the level of the decoded audio and differ from each
+	// other, but rise and fall with it, which is enough to rank speakers
+	// using the same mode.
+	Level float64
+}
+
+// ActivityParser estimates the speech activity and level of the packets of
+// a single stream, e.g. to find the active speakers among many streams
+// without decoding them. Only the VAD flags, signal type and gains of the
+// SILK frames, or the header flags and coarse band energies of the CELT
+// frames are decoded.
+//
+// Only 20 ms mono SILK frames are supported. The SILK layer of Hybrid
+// packets is used.
+type ActivityParser struct {
+	silkDecoder silk.Decoder
+	celtDecoder celt.Decoder
+}
+
+// NewActivityParser creates a new ActivityParser
+func NewActivityParser() ActivityParser {
+	return ActivityParser{
+		silkDecoder: silk.NewDecoder(),
+		celtDecoder: celt.NewDecoder(),
+	}
+}
+
+// Parse estimates the activity of the packet in. The gains and energies are
+// coded relative to those of the previous frame, so every packet of the
+// stream must be passed in order.
+func (a *ActivityParser) Parse(in []byte) (Activity, error) {
+	packet, err := opus.ParsePacket(in)
+	if err != nil {
+		return Activity{}, err
+	}
+
+	var (
+		activity Activity
+		power    float64
+		count    int
+	)
+	switch cfg := packet.Configuration(); {
+	case cfg <= maxSilkOnlyConfiguration:
+		activity.VoiceActivity, power, count, err = a.parseSILK(packet, silk.Bandwidth(packet.Bandwidth()))
+	case cfg <= maxHybridConfiguration:
+		activity.VoiceActivity, power, count, err = a.parseSILK(packet, silk.BandwidthWideband)
+	default:
+		power, count, err = a.parseCELT(packet)
+	}
+	if err != nil {
+		return Activity{}, err
+	}
+
+	activity.Level = MinLevel
+	if count != 0 {
+		activity.Level = math.Max(MinLevel, math.Min(0, 10*math.Log10(power/float64(count))))
+	}
+
+	return activity, nil
+}
+
+// parseSILK returns if any frame has voice activity, and the total power of
+// the gains of the frames relative to full scale
+func (a *ActivityParser) parseSILK(packet opus.Packet, bandwidth silk.Bandwidth) (voiceActivity bool, power float64, gains int, err error) {
+	if err := validateSILK(packet); err != nil {
+		return false, 0, 0, err
+	}
+
+	for _, frame := range packet.Frames {
+		// Frames during DTX carry no header
+		if len(frame) <= 1 {
+			continue
+		}
+
+		header, err := a.silkDecoder.DecodeHeader(frame, packet.IsStereo(), int(packet.FrameDuration().Nanoseconds()), bandwidth)
+		if err != nil {
+			return false, 0, 0, err
+		}
+
+		voiceActivity = voiceActivity || header.VoiceActivityDetected
+		for _, gainQ16 := range header.GainsQ16 {
+			gain := float64(gainQ16) / gainQ16One / fullScaleLevel
+			power += gain * gain
+			gains++
+		}
+	}
+
+	return voiceActivity, power, gains, nil
+}
+
+// parseCELT returns the total power of the frames relative to full scale,
+// the power of a frame is the sum of the power of its bands
+func (a *ActivityParser) parseCELT(packet opus.Packet) (power float64, frames int, err error) {
+	channels := 1
+	if packet.IsStereo() {
+		channels = 2
+	}
+
+	for _, frame := range packet.Frames {
+		// Like SILK frames, frames of at most one byte are lost or DTX
+		// frames
+		if len(frame) <= 1 {
+			continue
+		}
+
+		header, err := a.celtDecoder.DecodeHeader(frame, packet.IsStereo(), int(packet.FrameDuration().Nanoseconds()), celt.Bandwidth(packet.Bandwidth()))
+		if err != nil {
+			return 0, 0, err
+		}
+
+		frames++
+		if header.Silence {
+			continue
+		}
+
+		for channel := 0; channel < channels; channel++ {
+			for band := 0; band < header.Bands; band++ {
+				amplitude := math.Exp2(float64(header.CoarseEnergies[channel][band])) / fullScaleLevel
+				power += amplitude * amplitude / float64(channels)
+			}
+		}
+	}
+
+	return power, frames, nil
+}
@@ -0,0 +1,69 @@
+package analysis
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestActivityParser(t *testing.T) {
+	parser := NewActivityParser()
+	packets := testSilkPackets()
+
+	inactive, err := parser.Parse(packets[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	voiced, err := parser.Parse(packets[1])
+	switch {
+	case err != nil:
+		t.Fatal(err)
+	case inactive.VoiceActivity || !voiced.VoiceActivity:
+		t.Fatal()
+	case inactive.Level <= MinLevel || voiced.Level >= 0:
+		t.Fatal(inactive.Level, voiced.Level)
+	case voiced.Level <= inactive.Level+20:
+		t.Fatal(inactive.Level, voiced.Level)
+	}
+
+	dtx, err := parser.Parse([]byte{0x48})
+	switch {
+	case err != nil:
+		t.Fatal(err)
+	case dtx.VoiceActivity || dtx.Level != MinLevel:
+		t.Fatal()
+	}
+
+}
+
+func TestActivityParserCELT(t *testing.T) {
+	parser := NewActivityParser()
+
+	// A 20 ms FB CELT-only frame with the silence flag set
+	silence, err := parser.Parse([]byte{0xF8, 0xFF, 0xFE})
+	switch {
+	case err != nil:
+		t.Fatal(err)
+	case silence.VoiceActivity || silence.Level != MinLevel:
+		t.Fatal(silence)
+	}
+
+	// A frame of zeros codes the mean energy of every band
+	active, err := parser.Parse(append([]byte{0xF8}, make([]byte, 64)...))
+	switch {
+	case err != nil:
+		t.Fatal(err)
+	case active.VoiceActivity:
+		t.Fatal()
+	case active.Level <= MinLevel || active.Level >= 0:
+		t.Fatal(active.Level)
+	}
+}
+
+func TestActivityParserUnsupported(t *testing.T) {
+	// A 10 ms Hybrid packet
+	parser := NewActivityParser()
+	if _, err := parser.Parse([]byte{0x60, 0x01, 0x02}); !errors.Is(err, errUnsupportedSILKFrame) {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,321 @@
+// Package analysis decodes the parameters coded in an Opus stream without
+// reconstructing its audio, e.g. to plot how an encoder behaves over time.
+//
+// The SILK decoder only supports 20 ms mono frames, and only the start of
+// CELT frames is decoded, their header flags and coarse band energies.
+// Analyzing SILK frames of other durations, stereo SILK frames or Hybrid
+// packets returns an error. ActivityParser only decodes enough of a packet
+// to estimate its speech activity and level.
+package analysis
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pion/opus"
+	"github.com/pion/opus/internal/celt"
+	"github.com/pion/opus/internal/silk"
+)
+
+// The highest configuration numbers of the SILK-only and the Hybrid mode.
+// The SILK layer of Hybrid packets always runs at the WB internal sample
+// rate.
+//
+// https://datatracker.ietf.org/doc/html/rfc6716#section-3.1
+const (
+	maxSilkOnlyConfiguration = 11
+	maxHybridConfiguration   = 15
+)
+
+// SignalType is the signal type of a SILK frame
+//
+// https://datatracker.ietf.org/doc/html/rfc6716#section-4.2.7.3
+type SignalType byte
+
+// SignalType constants
+const (
+	SignalTypeInactive SignalType = iota + 1
+	SignalTypeUnvoiced
+	SignalTypeVoiced
+)
+
+func (s SignalType) String() string {
+	switch s {
+	case SignalTypeInactive:
+		return "Inactive"
+	case SignalTypeUnvoiced:
+		return "Unvoiced"
+	case SignalTypeVoiced:
+		return "Voiced"
+	}
+
+	return "Invalid"
+}
+
+// QuantizationOffsetType selects the offset applied to the excitation of a
+// SILK frame
+//
+// https://datatracker.ietf.org/doc/html/rfc6716#section-4.2.7.3
+type QuantizationOffsetType byte
+
+// QuantizationOffsetType constants
+const (
+	QuantizationOffsetTypeLow QuantizationOffsetType = iota + 1
+	QuantizationOffsetTypeHigh
+)
+
+func (q QuantizationOffsetType) String() string {
+	switch q {
+	case QuantizationOffsetTypeLow:
+		return "Low"
+	case QuantizationOffsetTypeHigh:
+		return "High"
+	}
+
+	return "Invalid"
+}
+
+// Packet contains the parameters of every frame of an Opus packet. SILK-only
+// packets have SILKFrames, CELT-only packets CELTFrames.
+type Packet struct {
+	Configuration opus.Configuration
+	Bandwidth     opus.Bandwidth
+	IsStereo      bool
+	FrameDuration time.Duration
+
+	SILKFrames []SILKFrame
+	CELTFrames []CELTFrame
+}
+
+// SILKFrame contains the parameters coded in a SILK frame. The names follow
+// RFC 6716, the suffix of a value is its Q format.
+//
+// https://datatracker.ietf.org/doc/html/rfc6716#section-4.2.7
+type SILKFrame struct {
+	// A frame of at most one byte carries no parameters, the encoder is
+	// in a period of discontinuous transmission (DTX).
+	IsDTX bool
+
+	// https://datatracker.ietf.org/doc/html/rfc6716#section-4.2.3
+	VoiceActivityDetected bool
+	LowBitRateRedundancy  bool
+
+	// https://datatracker.ietf.org/doc/html/rfc6716#section-4.2.7.3
+	SignalType             SignalType
+	QuantizationOffsetType QuantizationOffsetType
+
+	// The quantization gain of each subframe
+	//
+	// https://datatracker.ietf.org/doc/html/rfc6716#section-4.2.7.4
+	GainsQ16 []int32
+
+	// The first stage index and the stabilized normalized LSF vector
+	//
+	// https://datatracker.ietf.org/doc/html/rfc6716#section-4.2.7.5
+	I1      uint32
+	NLSFQ15 []int16
+
+	// The weight of the current frame when interpolating the NLSFs of the
+	// first half of the frame, 4 means no interpolation.
+	//
+	// https://datatracker.ietf.org/doc/html/rfc6716#section-4.2.7.5.5
+	InterpolationWeightQ2 int16
+
+	// The pitch lag and LTP filter index of each subframe, only voiced
+	// frames have them.
+	//
+	// https://datatracker.ietf.org/doc/html/rfc6716#section-4.2.7.6
+	PitchLags        []int
+	PeriodicityIndex uint32
+	LTPFilterIndices []uint32
+	LTPScaleQ14      int32
+
+	// https://datatracker.ietf.org/doc/html/rfc6716#section-4.2.7.7
+	Seed uint32
+
+	// The pulse and LSB counts of each shell block
+	//
+	// https://datatracker.ietf.org/doc/html/rfc6716#section-4.2.7.8
+	PulseCounts []uint8
+	LSBCounts   []uint8
+}
+
+// CELTFrame contains the start of a CELT frame, its header flags and coarse
+// band energies. The fine energies, bit allocation and band shapes that
+// follow aren't decoded.
+//
+// https://datatracker.ietf.org/doc/html/rfc6716#section-4.3
+type CELTFrame struct {
+	// Frames of at most one byte are treated as lost by decoders
+	IsDTX bool
+
+	// https://datatracker.ietf.org/doc/html/rfc6716#section-4.3
+	Silence bool
+
+	// The pitch post-filter, the period is in samples at 48 kHz
+	//
+	// https://datatracker.ietf.org/doc/html/rfc6716#section-4.3.7.1
+	PostFilter       bool
+	PostFilterPeriod int
+	PostFilterGain   float32
+	PostFilterTapset int
+
+	Transient bool
+	Intra     bool
+
+	// The coarse energy of each coded band of each channel, as the base-2
+	// logarithm of the amplitude of the band. They are predicted from the
+	// previous frame including its fine energies, which aren't decoded, so
+	// they are estimates accurate to a few dB.
+	//
+	// https://datatracker.ietf.org/doc/html/rfc6716#section-4.3.2.1
+	CoarseEnergies [][]float32
+}
+
+// Analyzer decodes the parameters of the packets of a single stream. Like a
+// decoder, some parameters are coded relative to the previous frame, so
+// every packet of the stream must be passed in order.
+type Analyzer struct {
+	silkDecoder silk.Decoder
+	celtDecoder celt.Decoder
+}
+
+// NewAnalyzer creates a new Analyzer
+func NewAnalyzer() Analyzer {
+	return Analyzer{
+		silkDecoder: silk.NewDecoder(),
+		celtDecoder: celt.NewDecoder(),
+	}
+}
+
+// Analyze decodes the parameters of every frame of the packet in. Every
+// call returns new slices, they aren't changed by later calls.
+func (a *Analyzer) Analyze(in []byte) (Packet, error) {
+	packet, err := opus.ParsePacket(in)
+	if err != nil {
+		return Packet{}, err
+	}
+
+	p := Packet{
+		Configuration: packet.Configuration(),
+		Bandwidth:     packet.Bandwidth(),
+		IsStereo:      packet.IsStereo(),
+		FrameDuration: packet.FrameDuration(),
+	}
+
+	switch cfg := p.Configuration; {
+	case cfg <= maxSilkOnlyConfiguration:
+		p.SILKFrames, err = a.analyzeSILK(packet)
+	case cfg <= maxHybridConfiguration:
+		err = fmt.Errorf("%w: configuration %d", errUnsupportedConfigurationMode, cfg)
+	default:
+		p.CELTFrames, err = a.analyzeCELT(packet)
+	}
+	if err != nil {
+		return Packet{}, err
+	}
+
+	return p, nil
+}
+
+func (a *Analyzer) analyzeSILK(packet opus.Packet) ([]SILKFrame, error) {
+	if err := validateSILK(packet); err != nil {
+		return nil, err
+	}
+
+	frames := make([]SILKFrame, len(packet.Frames))
+	for i, frame := range packet.Frames {
+		if len(frame) <= 1 {
+			frames[i].IsDTX = true
+			continue
+		}
+
+		parameters, err := a.silkDecoder.DecodeParameters(frame, packet.IsStereo(), int(packet.FrameDuration().Nanoseconds()), silk.Bandwidth(packet.Bandwidth()))
+		if err != nil {
+			return nil, err
+		}
+
+		frames[i] = newSILKFrame(parameters)
+	}
+
+	return frames, nil
+}
+
+func (a *Analyzer) analyzeCELT(packet opus.Packet) ([]CELTFrame, error) {
+	channels := 1
+	if packet.IsStereo() {
+		channels = 2
+	}
+
+	frames := make([]CELTFrame, len(packet.Frames))
+	for i, frame := range packet.Frames {
+		if len(frame) <= 1 {
+			frames[i].IsDTX = true
+			continue
+		}
+
+		header, err := a.celtDecoder.DecodeHeader(frame, packet.IsStereo(), int(packet.FrameDuration().Nanoseconds()), celt.Bandwidth(packet.Bandwidth()))
+		if err != nil {
+			return nil, err
+		}
+
+		frames[i] = CELTFrame{
+			Silence:          header.Silence,
+			PostFilter:       header.PostFilter,
+			PostFilterPeriod: header.PostFilterPeriod,
+			PostFilterGain:   header.PostFilterGain,
+			PostFilterTapset: header.PostFilterTapset,
+			Transient:        header.Transient,
+			Intra:            header.Intra,
+			CoarseEnergies:   make([][]float32, channels),
+		}
+		for channel := range frames[i].CoarseEnergies {
+			frames[i].CoarseEnergies[channel] = append([]float32(nil), header.CoarseEnergies[channel][:header.Bands]...)
+		}
+	}
+
+	return frames, nil
+}
+
+// validateSILK returns an error for SILK frames the SILK decoder doesn't
+// support yet
+func validateSILK(packet opus.Packet) error {
+	if packet.IsStereo() || packet.FrameDuration() != 20*time.Millisecond {
+		return fmt.Errorf("%w: %v stereo=%t", errUnsupportedSILKFrame, packet.FrameDuration(), packet.IsStereo())
+	}
+
+	return nil
+}
+
+func newSILKFrame(p silk.FrameParameters) SILKFrame {
+	frame := SILKFrame{
+		VoiceActivityDetected:  p.VoiceActivityDetected,
+		LowBitRateRedundancy:   p.LowBitRateRedundancy,
+		SignalType:             SignalTypeInactive,
+		QuantizationOffsetType: QuantizationOffsetTypeLow,
+		GainsQ16:               append([]int32(nil), p.GainsQ16[:]...),
+		I1:                     p.I1,
+		NLSFQ15:                append([]int16(nil), p.NLSFQ15[:p.LPCOrder]...),
+		InterpolationWeightQ2:  p.InterpolationWeightQ2,
+		LTPScaleQ14:            p.LTPScaleQ14,
+		Seed:                   p.Seed,
+		PulseCounts:            append([]uint8(nil), p.PulseCounts[:p.Shellblocks]...),
+		LSBCounts:              append([]uint8(nil), p.LSBCounts[:p.Shellblocks]...),
+	}
+
+	switch {
+	case p.Voiced:
+		frame.SignalType = SignalTypeVoiced
+		frame.PitchLags = append([]int(nil), p.PitchLags[:]...)
+		frame.PeriodicityIndex = p.PeriodicityIndex
+		frame.LTPFilterIndices = append([]uint32(nil), p.LTPFilterIndices[:]...)
+	case p.VoiceActivityDetected:
+		frame.SignalType = SignalTypeUnvoiced
+	}
+
+	if p.HighQuantizationOffset {
+		frame.QuantizationOffsetType = QuantizationOffsetTypeHigh
+	}
+
+	return frame
+}
@@ -0,0 +1,158 @@
+package analysis
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/pion/opus"
+)
+
+// 20ms wideband SILK-only packets, the second is voiced
+func testSilkPackets() [][]byte {
+	return [][]byte{
+		{0x48, 0x0B, 0xE4, 0xC1, 0x36, 0xEC, 0xC5, 0x80},
+		{0x48, 0xac, 0xbd, 0xa9, 0xf7, 0x26, 0x24, 0x5a, 0xa4, 0x00, 0x37, 0xbf, 0x9c, 0xde, 0xe, 0xcf, 0x94, 0x64, 0xaa, 0xf9, 0x87, 0xd0, 0x79, 0x19, 0xa8, 0x21, 0xc0},
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	analyzer := NewAnalyzer()
+	packets := testSilkPackets()
+
+	packet, err := analyzer.Analyze(packets[0])
+	switch {
+	case err != nil:
+		t.Fatal(err)
+	case packet.Bandwidth != opus.BandwidthWideband:
+		t.Fatal()
+	case len(packet.SILKFrames) != 1:
+		t.Fatal()
+	}
+
+	frame := packet.SILKFrames[0]
+	switch {
+	case frame.IsDTX || frame.VoiceActivityDetected:
+		t.Fatal()
+	case frame.SignalType != SignalTypeInactive:
+		t.Fatal(frame.SignalType)
+	case frame.QuantizationOffsetType != QuantizationOffsetTypeHigh:
+		t.Fatal(frame.QuantizationOffsetType)
+	case !reflect.DeepEqual(frame.GainsQ16, []int32{210944, 112640, 96256, 96256}):
+		t.Fatal(frame.GainsQ16)
+	case frame.I1 != 9 || len(frame.NLSFQ15) != 16:
+		t.Fatal()
+	case frame.PitchLags != nil || frame.LTPFilterIndices != nil:
+		t.Fatal()
+	case len(frame.PulseCounts) != 20:
+		t.Fatal()
+	}
+
+	if packet, err = analyzer.Analyze(packets[1]); err != nil {
+		t.Fatal(err)
+	}
+
+	frame = packet.SILKFrames[0]
+	switch {
+	case frame.SignalType != SignalTypeVoiced:
+		t.Fatal(frame.SignalType)
+	case !reflect.DeepEqual(frame.PitchLags, []int{205, 205, 206, 206}):
+		t.Fatal(frame.PitchLags)
+	case frame.PeriodicityIndex != 2:
+		t.Fatal()
+	case !reflect.DeepEqual(frame.LTPFilterIndices, []uint32{7, 18, 0, 7}):
+		t.Fatal(frame.LTPFilterIndices)
+	case frame.InterpolationWeightQ2 != 1:
+		t.Fatal()
+	}
+}
+
+func TestAnalyzeDTX(t *testing.T) {
+	analyzer := NewAnalyzer()
+	packet, err := analyzer.Analyze([]byte{0x48})
+	switch {
+	case err != nil:
+		t.Fatal(err)
+	case len(packet.SILKFrames) != 1:
+		t.Fatal()
+	case !packet.SILKFrames[0].IsDTX:
+		t.Fatal()
+	}
+}
+
+func TestAnalyzeCELT(t *testing.T) {
+	analyzer := NewAnalyzer()
+
+	// A 20 ms FB CELT-only frame with the silence flag set, and a stereo
+	// frame of zeros coding the mean energy of every band
+	packet, err := analyzer.Analyze([]byte{0xF8, 0xFF, 0xFE})
+	switch {
+	case err != nil:
+		t.Fatal(err)
+	case packet.SILKFrames != nil || len(packet.CELTFrames) != 1:
+		t.Fatal()
+	case !packet.CELTFrames[0].Silence || len(packet.CELTFrames[0].CoarseEnergies) != 1:
+		t.Fatalf("%+v", packet.CELTFrames[0])
+	}
+
+	if packet, err = analyzer.Analyze(append([]byte{0xFC}, make([]byte, 64)...)); err != nil {
+		t.Fatal(err)
+	}
+
+	frame := packet.CELTFrames[0]
+	switch {
+	case frame.IsDTX || frame.Silence || frame.PostFilter || frame.Transient || frame.Intra:
+		t.Fatalf("%+v", frame)
+	case len(frame.CoarseEnergies) != 2 || len(frame.CoarseEnergies[1]) != 21:
+		t.Fatal(frame.CoarseEnergies)
+	case frame.CoarseEnergies[0][0] != frame.CoarseEnergies[1][0]:
+		t.Fatal(frame.CoarseEnergies)
+	}
+
+	if packet, err = analyzer.Analyze([]byte{0xF8}); err != nil {
+		t.Fatal(err)
+	} else if !packet.CELTFrames[0].IsDTX {
+		t.Fatal()
+	}
+}
+
+func TestAnalyzeCopies(t *testing.T) {
+	analyzer := NewAnalyzer()
+	packets := testSilkPackets()
+
+	first, err := analyzer.Analyze(packets[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The frames of a packet aren't changed by analyzing the next packets
+	snapshot := fmt.Sprintf("%+v", first)
+	for i := 0; i < 3; i++ {
+		if _, err = analyzer.Analyze(packets[i%2]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if after := fmt.Sprintf("%+v", first); after != snapshot {
+		t.Fatal(after)
+	}
+}
+
+func TestAnalyzeUnsupported(t *testing.T) {
+	analyzer := NewAnalyzer()
+
+	// Hybrid packets, 10 ms and 60 ms SILK frames and stereo SILK frames
+	for _, test := range []struct {
+		packet []byte
+		err    error
+	}{
+		{[]byte{0x78, 0x01}, errUnsupportedConfigurationMode},
+		{[]byte{0x40, 0x01}, errUnsupportedSILKFrame},
+		{[]byte{0x58, 0x01}, errUnsupportedSILKFrame},
+		{[]byte{0x4C, 0x01}, errUnsupportedSILKFrame},
+	} {
+		if _, err := analyzer.Analyze(test.packet); !errors.Is(err, test.err) {
+			t.Fatal(err)
+		}
+	}
+}
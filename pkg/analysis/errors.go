@@ -0,0 +1,8 @@
+package analysis
+
+import "errors"
+
+var (
+	errUnsupportedConfigurationMode = errors.New("the CELT layer of Hybrid packets is not implemented")
+	errUnsupportedSILKFrame         = errors.New("only 20ms mono SILK frames are supported")
+)
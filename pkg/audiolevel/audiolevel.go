@@ -0,0 +1,110 @@
+// Package audiolevel computes the audio levels carried by the RTP header
+// extension for client-to-mixer audio level indication, and smooths them to
+// rank speakers.
+//
+// https://datatracker.ietf.org/doc/html/rfc6464
+package audiolevel
+
+import (
+	"encoding/binary"
+	"math"
+
+	"github.com/pion/opus/pkg/analysis"
+)
+
+const (
+	// Silence is the level of digital silence, and of any audio quieter
+	// than -127 dBov
+	Silence = 127
+
+	voiceActivityMask = 0b10000000
+	levelMask         = 0b01111111
+
+	bytesPerSample = 2
+	fullScale      = 32768
+)
+
+// Extension is the data of the audio level header extension. It consists
+// of a voice activity flag "V" and the level of the audio in the packet.
+//
+//	 0                   1
+//	 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5
+//	+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+//	|  ID   | len=0 |V| level       |
+//	+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+//
+// The audio level is expressed in -dBov, with values from 0 to 127
+// representing 0 to -127 dBov.
+//
+// https://datatracker.ietf.org/doc/html/rfc6464#section-3
+type Extension struct {
+	VoiceActivity bool
+	Level         uint8
+}
+
+// Marshal returns the byte following the ID and length of the extension
+func (e Extension) Marshal() byte {
+	b := e.Level
+	if b > Silence {
+		b = Silence
+	}
+	if e.VoiceActivity {
+		b |= voiceActivityMask
+	}
+
+	return b
+}
+
+// ParseExtension parses the byte following the ID and length of the
+// extension
+func ParseExtension(b byte) Extension {
+	return Extension{
+		VoiceActivity: b&voiceActivityMask != 0,
+		Level:         b & levelMask,
+	}
+}
+
+// Level computes the audio level of the 16-bit little-endian PCM of a
+// packet, as produced by opus.Decoder. The level is the root mean square
+// of all samples of the packet, in -dBov. dBov is the level relative to the
+// overload point of the system, so a full-scale square wave is 0 dBov and
+// a full-scale sine wave -3 dBov.
+//
+// https://datatracker.ietf.org/doc/html/rfc6464#section-4
+func Level(pcm []byte) uint8 {
+	sampleCount := len(pcm) / bytesPerSample
+	if sampleCount == 0 {
+		return Silence
+	}
+
+	var power float64
+	for i := 0; i < sampleCount; i++ {
+		sample := float64(int16(binary.LittleEndian.Uint16(pcm[i*bytesPerSample:]))) / fullScale
+		power += sample * sample
+	}
+
+	return levelFromDBov(10 * math.Log10(power/float64(sampleCount)))
+}
+
+// FromActivity creates the Extension of a packet estimated by an
+// analysis.ActivityParser, without decoding the packet. The level is lower
+// than the level of the decoded audio, see analysis.Activity.
+func FromActivity(activity analysis.Activity) Extension {
+	return Extension{
+		VoiceActivity: activity.VoiceActivity,
+		Level:         levelFromDBov(activity.Level),
+	}
+}
+
+// levelFromDBov rounds a level in dBov to the nearest level of the
+// extension, levels outside of the range are clamped
+func levelFromDBov(dBov float64) uint8 {
+	switch {
+	case math.IsNaN(dBov) || dBov <= -Silence:
+		return Silence
+	case dBov >= 0:
+		return 0
+	}
+
+	return uint8(math.Round(-dBov))
+}
@@ -0,0 +1,132 @@
+package audiolevel
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/pion/opus"
+	"github.com/pion/opus/pkg/analysis"
+)
+
+func sinePCM(amplitude float64, sampleCount int) []byte {
+	pcm := make([]byte, sampleCount*bytesPerSample)
+	for i := 0; i < sampleCount; i++ {
+		sample := int16(math.Round(amplitude * math.Sin(2*math.Pi*float64(i)/48)))
+		binary.LittleEndian.PutUint16(pcm[i*bytesPerSample:], uint16(sample))
+	}
+
+	return pcm
+}
+
+func TestLevel(t *testing.T) {
+	squareWave := make([]byte, 960*bytesPerSample)
+	for i := 0; i < len(squareWave); i += 4 {
+		binary.LittleEndian.PutUint16(squareWave[i:], uint16(32767))
+		binary.LittleEndian.PutUint16(squareWave[i+2:], 0x8000)
+	}
+
+	for _, test := range []struct {
+		Name     string
+		PCM      []byte
+		Expected uint8
+	}{
+		{"Empty", nil, Silence},
+		{"Digital Silence", make([]byte, 1920), Silence},
+		{"Full-Scale Square Wave", squareWave, 0},
+		{"Full-Scale Sine Wave", sinePCM(32767, 960), 3},
+		{"Quiet Sine Wave", sinePCM(327.67, 960), 43},
+	} {
+		t.Run(test.Name, func(t *testing.T) {
+			if level := Level(test.PCM); level != test.Expected {
+				t.Fatalf("%d != %d", level, test.Expected)
+			}
+		})
+	}
+}
+
+func TestExtension(t *testing.T) {
+	for _, test := range []struct {
+		Extension Extension
+		Byte      byte
+	}{
+		{Extension{VoiceActivity: true, Level: 30}, 0x9E},
+		{Extension{VoiceActivity: false, Level: Silence}, 0x7F},
+		{Extension{VoiceActivity: true, Level: 0}, 0x80},
+	} {
+		if b := test.Extension.Marshal(); b != test.Byte {
+			t.Fatalf("%x != %x", b, test.Byte)
+		}
+		if extension := ParseExtension(test.Byte); extension != test.Extension {
+			t.Fatalf("%v != %v", extension, test.Extension)
+		}
+	}
+
+	// Levels can't exceed 7 bits
+	if b := (Extension{Level: 200}).Marshal(); b != Silence {
+		t.Fatal(b)
+	}
+}
+
+func TestFromActivity(t *testing.T) {
+	switch {
+	case FromActivity(analysis.Activity{VoiceActivity: true, Level: -40.4}) != Extension{VoiceActivity: true, Level: 40}:
+		t.Fatal()
+	case FromActivity(analysis.Activity{Level: analysis.MinLevel}) != Extension{Level: Silence}:
+		t.Fatal()
+	case FromActivity(analysis.Activity{Level: -200}) != Extension{Level: Silence}:
+		t.Fatal()
+	}
+}
+
+func TestDecodedLevel(t *testing.T) {
+	decoder := opus.NewDecoder()
+	out := make([]byte, 1920)
+	packet := []byte{0x48, 0xac, 0xbd, 0xa9, 0xf7, 0x26, 0x24, 0x5a, 0xa4, 0x00, 0x37, 0xbf, 0x9c, 0xde, 0xe, 0xcf, 0x94, 0x64, 0xaa, 0xf9, 0x87, 0xd0, 0x79, 0x19, 0xa8, 0x21, 0xc0}
+
+	if _, _, err := decoder.Decode(packet, out); err != nil {
+		t.Fatal(err)
+	}
+
+	extension := Extension{VoiceActivity: decoder.VoiceActivity(), Level: Level(out)}
+	switch {
+	case !extension.VoiceActivity:
+		t.Fatal()
+	case extension.Level == 0 || extension.Level == Silence:
+		t.Fatal(extension.Level)
+	}
+}
+
+func TestSmoother(t *testing.T) {
+	smoother := NewSmoother()
+	if smoother.Level() != -Silence {
+		t.Fatal()
+	}
+
+	// The level rises to a speaker within a few packets
+	for i := 0; i < 10; i++ {
+		smoother.Update(Extension{VoiceActivity: true, Level: 20}, 20*time.Millisecond)
+	}
+	if level := smoother.Level(); level < -25 || level > -20 {
+		t.Fatal(level)
+	}
+
+	// A short pause keeps most of the level, a long one falls to silence
+	smoother.Update(Extension{Level: 20}, 100*time.Millisecond)
+	if level := smoother.Level(); level < -50 {
+		t.Fatal(level)
+	}
+	smoother.Update(Extension{Level: Silence}, 5*time.Second)
+	if level := smoother.Level(); level > -126 {
+		t.Fatal(level)
+	}
+
+	// Without voice activity signaling the level of every packet counts
+	smoother.IgnoreVoiceActivity = true
+	smoother.AttackTime = 0
+	smoother.Update(Extension{Level: 30}, 20*time.Millisecond)
+	if smoother.Level() != -30 {
+		t.Fatal(smoother.Level())
+	}
+}
@@ -0,0 +1,66 @@
+package audiolevel
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	defaultAttackTime  = 50 * time.Millisecond
+	defaultReleaseTime = 500 * time.Millisecond
+)
+
+// Smoother follows the audio level of a single stream, to rank the streams
+// of a conference by how actively they are speaking. The level rises
+// quickly when a speaker starts and falls slowly, so the short pauses
+// between words don't change the ranking.
+type Smoother struct {
+	// The time constants of the exponential smoothing when the level
+	// rises and falls
+	AttackTime  time.Duration
+	ReleaseTime time.Duration
+
+	// Packets without voice activity count as silence, unless
+	// IgnoreVoiceActivity is set. Set it when the sender doesn't signal
+	// voice activity.
+	IgnoreVoiceActivity bool
+
+	level float64
+}
+
+// NewSmoother creates a Smoother starting at silence
+func NewSmoother() *Smoother {
+	return &Smoother{
+		AttackTime:  defaultAttackTime,
+		ReleaseTime: defaultReleaseTime,
+		level:       -Silence,
+	}
+}
+
+// Update adds the level of a packet with the given duration. Lost packets
+// and periods of DTX should be added as silence.
+func (s *Smoother) Update(extension Extension, duration time.Duration) {
+	level := -float64(extension.Level)
+	if extension.Level > Silence || (!extension.VoiceActivity && !s.IgnoreVoiceActivity) {
+		level = -Silence
+	}
+
+	timeConstant := s.ReleaseTime
+	if level > s.level {
+		timeConstant = s.AttackTime
+	}
+
+	// The level converges on the level of the packet, by a factor that
+	// depends on how much of the time constant the packet covers
+	weight := 1.0
+	if timeConstant > 0 {
+		weight = 1 - math.Exp(-float64(duration)/float64(timeConstant))
+	}
+	s.level += (level - s.level) * weight
+}
+
+// Level returns the smoothed level in dBov, between -127 and 0. Louder
+// streams have higher levels.
+func (s *Smoother) Level() float64 {
+	return s.level
+}
@@ -0,0 +1,59 @@
+package conformance
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+const (
+	bitstreamHeaderSize = 8
+
+	// The largest packet the test tool writes, 48 frames of the maximum
+	// frame size
+	maxBitstreamPacketSize = 1500 * 48
+)
+
+// Reader reads the packets of a .bit file. These are written by the
+// opus_demo test tool of libopus, and the official test vectors are
+// distributed in this format. Each packet is prefixed by its length and the
+// final range of the encoder, both 32-bit big-endian integers.
+//
+// https://datatracker.ietf.org/doc/html/rfc6716#appendix-A.4
+type Reader struct {
+	r      io.Reader
+	header [bitstreamHeaderSize]byte
+}
+
+// NewReader creates a Reader that reads packets from r
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: r}
+}
+
+// ReadPacket returns the next packet and the final range the encoder reported
+// for it. A packet of length zero signals a lost packet. io.EOF is returned
+// when there are no more packets.
+func (r *Reader) ReadPacket() (packet []byte, finalRange uint32, err error) {
+	if _, err = io.ReadFull(r.r, r.header[:]); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, 0, errTruncatedPacket
+		}
+		return nil, 0, err
+	}
+
+	length := binary.BigEndian.Uint32(r.header[0:4])
+	finalRange = binary.BigEndian.Uint32(r.header[4:8])
+	if length > maxBitstreamPacketSize {
+		return nil, 0, errPacketTooLarge
+	}
+
+	packet = make([]byte, length)
+	if _, err = io.ReadFull(r.r, packet); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, 0, errTruncatedPacket
+		}
+		return nil, 0, err
+	}
+
+	return packet, finalRange, nil
+}
@@ -0,0 +1,280 @@
+package conformance
+
+import (
+	"fmt"
+	"math"
+)
+
+const (
+	compareBandCount      = 21
+	compareFrequencyCount = 240
+	compareWindowSize     = 480
+	compareWindowStep     = 120
+)
+
+// Edges of the bands, in units of 100 Hz, the spectral energy is compared in
+var compareBands = [compareBandCount + 1]int{
+	0, 2, 4, 6, 8, 10, 12, 14, 16, 20, 24, 28, 32, 40, 48, 56, 68, 80, 96, 120, 156, 200,
+}
+
+// Result is the outcome of comparing decoded audio with the reference
+type Result struct {
+	// Quality is the Opus quality metric in percent. A decoder passes the
+	// test vector when it is at least 0.
+	Quality float64
+
+	// Error is the internal weighted error the quality is derived from
+	Error float64
+}
+
+// Passed returns true if the decoded audio is close enough to the reference
+func (r Result) Passed() bool {
+	return r.Quality >= 0
+}
+
+func (r Result) String() string {
+	if !r.Passed() {
+		return fmt.Sprintf("FAILS (internal weighted error is %f)", r.Error)
+	}
+
+	return fmt.Sprintf("PASSES, quality metric %.1f %% (internal weighted error is %f)", r.Quality, r.Error)
+}
+
+// Compare implements the perceptual comparison of the opus_compare tool of
+// libopus. reference is the interleaved 48 kHz output of the reference
+// decoder with referenceChannels channels, a stereo reference is mixed down
+// when channels is 1. decoded is the output under test, with the given
+// number of channels and sample rate.
+//
+// The spectra of both signals are compared in bands on a Bark-like scale,
+// after applying a simple model of frequency and temporal masking. Because
+// the output of the decoder is not required to be bit-exact, this accepts
+// the differences a conforming decoder may produce.
+//
+// https://datatracker.ietf.org/doc/html/rfc6716#section-6
+func Compare(reference []int16, referenceChannels int, decoded []int16, channels, sampleRate int) (Result, error) {
+	switch {
+	case channels != 1 && channels != 2:
+		return Result{}, errUnsupportedChannels
+	case referenceChannels != 1 && referenceChannels != 2:
+		return Result{}, errUnsupportedChannels
+	case referenceChannels < channels:
+		return Result{}, errMonoReference
+	}
+
+	ybands := compareBandCount
+	switch sampleRate {
+	case 8000:
+		ybands = 13
+	case 12000:
+		ybands = 15
+	case 16000:
+		ybands = 17
+	case 24000:
+		ybands = 19
+	case 48000:
+	default:
+		return Result{}, errUnsupportedSampleRate
+	}
+
+	downsample := 48000 / sampleRate
+	yfreqs := compareFrequencyCount / downsample
+
+	x := make([]float32, len(reference))
+	for i := range reference {
+		x[i] = float32(reference[i])
+	}
+	xlength := len(x) / referenceChannels
+	if referenceChannels == 2 && channels == 1 {
+		for i := 0; i < xlength; i++ {
+			x[i] = .5 * (x[2*i] + x[2*i+1])
+		}
+	}
+
+	y := make([]float32, len(decoded))
+	for i := range decoded {
+		y[i] = float32(decoded[i])
+	}
+	ylength := len(y) / channels
+
+	switch {
+	case xlength != ylength*downsample:
+		return Result{}, fmt.Errorf("%w (%d != %d)", errSampleCountMismatch, xlength, ylength*downsample)
+	case xlength < compareWindowSize:
+		return Result{}, fmt.Errorf("%w (%d < %d)", errInsufficientSampleData, xlength, compareWindowSize)
+	}
+
+	frames := (xlength - compareWindowSize + compareWindowStep) / compareWindowStep
+	xb := make([]float32, frames*compareBandCount*channels)
+	X := make([]float32, frames*compareFrequencyCount*channels)
+	Y := make([]float32, frames*yfreqs*channels)
+
+	// Compute the per-band spectral energy of the original signal and the error
+	bandEnergy(xb, X, compareBands[:], compareBandCount, x, channels, frames, compareWindowSize, compareWindowStep, 1)
+	bandEnergy(nil, Y, compareBands[:], ybands, y, channels, frames, compareWindowSize/downsample, compareWindowStep/downsample, downsample)
+
+	for xi := 0; xi < frames; xi++ {
+		// Frequency masking (low to high): 10 dB/Bark slope
+		for bi := 1; bi < compareBandCount; bi++ {
+			for ci := 0; ci < channels; ci++ {
+				xb[(xi*compareBandCount+bi)*channels+ci] += 0.1 * xb[(xi*compareBandCount+bi-1)*channels+ci]
+			}
+		}
+
+		// Frequency masking (high to low): 15 dB/Bark slope
+		for bi := compareBandCount - 2; bi >= 0; bi-- {
+			for ci := 0; ci < channels; ci++ {
+				xb[(xi*compareBandCount+bi)*channels+ci] += 0.03 * xb[(xi*compareBandCount+bi+1)*channels+ci]
+			}
+		}
+
+		// Temporal masking: -3 dB/2.5ms slope
+		if xi > 0 {
+			for bi := 0; bi < compareBandCount; bi++ {
+				for ci := 0; ci < channels; ci++ {
+					xb[(xi*compareBandCount+bi)*channels+ci] += 0.5 * xb[((xi-1)*compareBandCount+bi)*channels+ci]
+				}
+			}
+		}
+
+		// Allowing some cross-talk
+		if channels == 2 {
+			for bi := 0; bi < compareBandCount; bi++ {
+				l := xb[(xi*compareBandCount+bi)*channels+0]
+				r := xb[(xi*compareBandCount+bi)*channels+1]
+				xb[(xi*compareBandCount+bi)*channels+0] += .01 * r
+				xb[(xi*compareBandCount+bi)*channels+1] += .01 * l
+			}
+		}
+
+		// Apply masking
+		for bi := 0; bi < ybands; bi++ {
+			for xj := compareBands[bi]; xj < compareBands[bi+1]; xj++ {
+				for ci := 0; ci < channels; ci++ {
+					X[(xi*compareFrequencyCount+xj)*channels+ci] += 0.1 * xb[(xi*compareBandCount+bi)*channels+ci]
+					Y[(xi*yfreqs+xj)*channels+ci] += 0.1 * xb[(xi*compareBandCount+bi)*channels+ci]
+				}
+			}
+		}
+	}
+
+	// Average of consecutive frames to make comparison slightly less sensitive
+	for bi := 0; bi < ybands; bi++ {
+		for xj := compareBands[bi]; xj < compareBands[bi+1]; xj++ {
+			for ci := 0; ci < channels; ci++ {
+				xtmp := X[xj*channels+ci]
+				ytmp := Y[xj*channels+ci]
+				for xi := 1; xi < frames; xi++ {
+					xtmp2 := X[(xi*compareFrequencyCount+xj)*channels+ci]
+					ytmp2 := Y[(xi*yfreqs+xj)*channels+ci]
+					X[(xi*compareFrequencyCount+xj)*channels+ci] += xtmp
+					Y[(xi*yfreqs+xj)*channels+ci] += ytmp
+					xtmp = xtmp2
+					ytmp = ytmp2
+				}
+			}
+		}
+	}
+
+	// If working at a lower sampling rate, don't take into account the last
+	// 300 Hz to allow for different transition bands. For 12 kHz, we don't
+	// skip anything, because the last band already skips 400 Hz.
+	maxCompare := compareBands[ybands] - 3
+	switch sampleRate {
+	case 48000:
+		maxCompare = compareBands[compareBandCount]
+	case 12000:
+		maxCompare = compareBands[ybands]
+	}
+
+	var err float64
+	for xi := 0; xi < frames; xi++ {
+		var Ef float64
+		for bi := 0; bi < ybands; bi++ {
+			var Eb float64
+			for xj := compareBands[bi]; xj < compareBands[bi+1] && xj < maxCompare; xj++ {
+				for ci := 0; ci < channels; ci++ {
+					re := Y[(xi*yfreqs+xj)*channels+ci] / X[(xi*compareFrequencyCount+xj)*channels+ci]
+					im := re - float32(math.Log(float64(re))) - 1
+
+					// Make comparison less sensitive around the SILK/CELT
+					// cross-over to allow for mode freedom in the filters.
+					if xj >= 79 && xj <= 81 {
+						im *= 0.1
+					}
+					if xj == 80 {
+						im *= 0.1
+					}
+					Eb += float64(im)
+				}
+			}
+			Eb /= float64((compareBands[bi+1] - compareBands[bi]) * channels)
+			Ef += Eb * Eb
+		}
+
+		// Using a fixed normalization value means we're willing to accept
+		// slightly lower quality for lower sampling rates.
+		Ef /= compareBandCount
+		Ef *= Ef
+		err += Ef * Ef
+	}
+
+	err = math.Pow(err/float64(frames), 1.0/16)
+	return Result{
+		Quality: 100 * (1 - 0.5*math.Log(1+err)/math.Log(1.13)),
+		Error:   err,
+	}, nil
+}
+
+// bandEnergy computes the power spectrum of every window of in, and the
+// average power in each band when out isn't nil.
+func bandEnergy(out, ps []float32, bands []int, bandCount int, in []float32, channels, frames, windowSize, step, downsample int) {
+	window := make([]float32, windowSize)
+	c := make([]float32, windowSize)
+	s := make([]float32, windowSize)
+	x := make([]float32, channels*windowSize)
+	psSize := windowSize / 2
+
+	for xj := 0; xj < windowSize; xj++ {
+		window[xj] = float32(0.5 - 0.5*math.Cos((2*math.Pi/float64(windowSize-1))*float64(xj)))
+		c[xj] = float32(math.Cos((2 * math.Pi / float64(windowSize)) * float64(xj)))
+		s[xj] = float32(math.Sin((2 * math.Pi / float64(windowSize)) * float64(xj)))
+	}
+
+	for xi := 0; xi < frames; xi++ {
+		for ci := 0; ci < channels; ci++ {
+			for xk := 0; xk < windowSize; xk++ {
+				x[ci*windowSize+xk] = window[xk] * in[(xi*step+xk)*channels+ci]
+			}
+		}
+
+		for bi, xj := 0, 0; bi < bandCount; bi++ {
+			var p [2]float32
+			for ; xj < bands[bi+1]; xj++ {
+				for ci := 0; ci < channels; ci++ {
+					var re, im float32
+					ti := 0
+					for xk := 0; xk < windowSize; xk++ {
+						re += c[ti] * x[ci*windowSize+xk]
+						im -= s[ti] * x[ci*windowSize+xk]
+						ti += xj
+						if ti >= windowSize {
+							ti -= windowSize
+						}
+					}
+					re *= float32(downsample)
+					im *= float32(downsample)
+
+					ps[(xi*psSize+xj)*channels+ci] = re*re + im*im + 100000
+					p[ci] += ps[(xi*psSize+xj)*channels+ci]
+				}
+			}
+
+			if out != nil {
+				for ci := 0; ci < channels; ci++ {
+					out[(xi*bandCount+bi)*channels+ci] = p[ci] / float32(bands[bi+1]-bands[bi])
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,197 @@
+// Package conformance checks the decoder against the official Opus test
+// vectors, the bitstreams and reference decoder outputs distributed with
+// RFC 6716 and updated by RFC 8251.
+//
+// https://datatracker.ietf.org/doc/html/rfc6716#appendix-A.4
+// https://datatracker.ietf.org/doc/html/rfc8251#section-11
+package conformance
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pion/opus"
+)
+
+const (
+	// The decoder outputs 48 kHz, 16-bit mono PCM
+	sampleRate     = 48000
+	bytesPerSample = 2
+
+	// The largest amount of audio a single packet can contain
+	maxPacketSamples = sampleRate * 120 / 1000
+)
+
+// Vector is a single test vector
+type Vector struct {
+	Name string
+
+	// Bitstream is the path of the .bit file to decode
+	Bitstream string
+
+	// References are the paths of the outputs of the reference decoder the
+	// result is compared with. RFC 8251 added a second, mono reference
+	// output for every vector, matching any of them is sufficient.
+	References []string
+}
+
+// referenceChannels returns the channel count of a reference output, the
+// .dec files are stereo and the m.dec files are mono
+func referenceChannels(path string) int {
+	if strings.HasSuffix(path, "m.dec") {
+		return 1
+	}
+
+	return 2
+}
+
+// FindVectors returns the test vectors in dir, every .bit file with at least
+// one matching .dec file. The official vectors are named testvector01.bit,
+// testvector01.dec and testvector01m.dec.
+func FindVectors(dir string) ([]Vector, error) {
+	bitstreams, err := filepath.Glob(filepath.Join(dir, "*.bit"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(bitstreams)
+
+	vectors := []Vector{}
+	for _, bitstream := range bitstreams {
+		base := strings.TrimSuffix(bitstream, ".bit")
+		vector := Vector{Name: filepath.Base(base), Bitstream: bitstream}
+
+		for _, reference := range []string{base + ".dec", base + "m.dec"} {
+			if _, err := os.Stat(reference); err == nil {
+				vector.References = append(vector.References, reference)
+			}
+		}
+
+		if len(vector.References) != 0 {
+			vectors = append(vectors, vector)
+		}
+	}
+
+	return vectors, nil
+}
+
+// Run decodes the bitstream of the vector and compares the output with the
+// reference outputs. It returns the best result, and an error if the
+// bitstream couldn't be decoded.
+func (v Vector) Run() (Result, error) {
+	f, err := os.Open(v.Bitstream)
+	if err != nil {
+		return Result{}, err
+	}
+	defer f.Close()
+
+	decoded, err := Decode(f)
+	if err != nil {
+		return Result{}, err
+	}
+
+	var best Result
+	for i, path := range v.References {
+		reference, err := readPCM(path)
+		if err != nil {
+			return Result{}, err
+		}
+
+		result, err := Compare(reference, referenceChannels(path), decoded, 1, sampleRate)
+		if err != nil {
+			return Result{}, fmt.Errorf("%s: %w", filepath.Base(path), err)
+		}
+
+		if i == 0 || result.Quality > best.Quality {
+			best = result
+		}
+	}
+
+	return best, nil
+}
+
+// Decode decodes all packets of a .bit file into 48 kHz mono samples. Like
+// the opus_demo tool, lost packets are concealed for the duration of the
+// previous packet, and the final range of the decoder has to match the one
+// reported by the encoder.
+func Decode(r io.Reader) ([]int16, error) {
+	return DecodeWithOptions(r, opus.Options{})
+}
+
+// DecodeWithOptions is Decode with a decoder configured by options, e.g. to
+// compare the fixed-point decoder with the output of libopus built with
+// --enable-fixed-point.
+func DecodeWithOptions(r io.Reader, options opus.Options) ([]int16, error) {
+	reader := NewReader(r)
+	decoder := opus.NewDecoderWithOptions(options)
+	out := make([]byte, maxPacketSamples*bytesPerSample)
+	samples := []int16{}
+
+	var lastPacketDuration, lastFrameDuration time.Duration
+	for i := 0; ; i++ {
+		packet, finalRange, err := reader.ReadPacket()
+		switch {
+		case errors.Is(err, io.EOF):
+			return samples, nil
+		case err != nil:
+			return nil, fmt.Errorf("packet %d: %w", i, err)
+		}
+
+		// Conceal the lost packet with frames of the previous packet
+		if len(packet) == 0 {
+			for concealed := time.Duration(0); concealed < lastPacketDuration; concealed += lastFrameDuration {
+				if _, _, err := decoder.Conceal(out); err != nil {
+					return nil, fmt.Errorf("packet %d: %w", i, err)
+				}
+				samples = appendPCM(samples, out[:durationToBytes(lastFrameDuration)])
+			}
+			continue
+		}
+
+		parsed, err := opus.ParsePacket(packet)
+		if err != nil {
+			return nil, fmt.Errorf("packet %d: %w", i, err)
+		}
+
+		if _, _, err := decoder.Decode(packet, out); err != nil {
+			return nil, fmt.Errorf("packet %d: %w", i, err)
+		}
+
+		if decoder.FinalRange() != finalRange {
+			return nil, fmt.Errorf("packet %d: %w (0x%08x != 0x%08x)", i, errFinalRangeMismatch, decoder.FinalRange(), finalRange)
+		}
+
+		lastPacketDuration = parsed.Duration()
+		lastFrameDuration = parsed.FrameDuration()
+		samples = appendPCM(samples, out[:durationToBytes(lastPacketDuration)])
+	}
+}
+
+func durationToBytes(d time.Duration) int {
+	return int(d*sampleRate/time.Second) * bytesPerSample
+}
+
+func appendPCM(samples []int16, pcm []byte) []int16 {
+	for i := 0; i+1 < len(pcm); i += bytesPerSample {
+		samples = append(samples, int16(binary.LittleEndian.Uint16(pcm[i:])))
+	}
+
+	return samples
+}
+
+// readPCM reads a file of 16-bit little-endian samples, the format of the
+// .dec reference outputs
+func readPCM(path string) ([]int16, error) {
+	pcm, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return appendPCM(make([]int16, 0, len(pcm)/bytesPerSample), pcm), nil
+}
@@ -0,0 +1,232 @@
+package conformance
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pion/opus"
+)
+
+// testVectorsEnv points to a directory with the official test vectors,
+// available at https://opus-codec.org/testvectors/
+const testVectorsEnv = "OPUS_TESTVECTORS"
+
+// fixedPointReferenceEnv points to a directory of .bit files, each with a
+// .pcm file decoded by libopus built with --enable-fixed-point, e.g. with
+// opus_demo -d 48000 1 testvector02.bit testvector02.pcm
+const fixedPointReferenceEnv = "OPUS_FIXED_POINT_REFERENCE"
+
+// A 20ms wideband SILK-only packet
+func testPacket() []byte {
+	return []byte{0x48, 0x0B, 0xE4, 0xC1, 0x36, 0xEC, 0xC5, 0x80}
+}
+
+func buildBitstream(packets [][]byte, finalRanges []uint32) []byte {
+	out := []byte{}
+	for i := range packets {
+		header := make([]byte, bitstreamHeaderSize)
+		binary.BigEndian.PutUint32(header[0:4], uint32(len(packets[i])))
+		binary.BigEndian.PutUint32(header[4:8], finalRanges[i])
+		out = append(append(out, header...), packets[i]...)
+	}
+
+	return out
+}
+
+func TestReader(t *testing.T) {
+	bitstream := buildBitstream([][]byte{testPacket(), {}}, []uint32{0x01020304, 0})
+	r := NewReader(bytes.NewReader(bitstream))
+
+	packet, finalRange, err := r.ReadPacket()
+	switch {
+	case err != nil:
+		t.Fatal(err)
+	case !bytes.Equal(packet, testPacket()):
+		t.Fatal("packet mismatch")
+	case finalRange != 0x01020304:
+		t.Fatal("final range mismatch")
+	}
+
+	if packet, _, err = r.ReadPacket(); err != nil {
+		t.Fatal(err)
+	} else if len(packet) != 0 {
+		t.Fatal("lost packet isn't empty")
+	}
+
+	if _, _, err = r.ReadPacket(); !errors.Is(err, io.EOF) {
+		t.Fatal(err)
+	}
+
+	for _, truncated := range [][]byte{bitstream[:4], bitstream[:10]} {
+		if _, _, err = NewReader(bytes.NewReader(truncated)).ReadPacket(); !errors.Is(err, errTruncatedPacket) {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	const frames = 4800
+
+	reference := make([]int16, frames*2)
+	decoded := make([]int16, frames)
+	noise := make([]int16, frames)
+	random := rand.New(rand.NewSource(0))
+
+	for i := 0; i < frames; i++ {
+		sample := int16(8000 * math.Sin(2*math.Pi*440*float64(i)/48000))
+		reference[2*i] = sample
+		reference[2*i+1] = sample
+		decoded[i] = sample
+		noise[i] = int16(random.Intn(16000) - 8000)
+	}
+
+	result, err := Compare(reference, 2, decoded, 1, 48000)
+	if err != nil {
+		t.Fatal(err)
+	} else if !result.Passed() || result.Quality < 99 {
+		t.Fatalf("identical signals %s", result)
+	}
+
+	if result, err = Compare(reference, 2, noise, 1, 48000); err != nil {
+		t.Fatal(err)
+	} else if result.Passed() {
+		t.Fatalf("noise %s", result)
+	}
+
+	if _, err = Compare(reference, 2, decoded[:frames-1], 1, 48000); !errors.Is(err, errSampleCountMismatch) {
+		t.Fatal(err)
+	}
+
+	if _, err = Compare(reference[:100], 2, decoded[:50], 1, 48000); !errors.Is(err, errInsufficientSampleData) {
+		t.Fatal(err)
+	}
+
+	// A mono reference is compared without mixing it down
+	if result, err = Compare(decoded, 1, decoded, 1, 48000); err != nil {
+		t.Fatal(err)
+	} else if !result.Passed() || result.Quality < 99 {
+		t.Fatalf("identical mono signals %s", result)
+	}
+	if result, err = Compare(decoded, 1, noise, 1, 48000); err != nil {
+		t.Fatal(err)
+	} else if result.Passed() {
+		t.Fatalf("noise against mono reference %s", result)
+	}
+	if _, err = Compare(decoded, 1, reference, 2, 48000); !errors.Is(err, errMonoReference) {
+		t.Fatal(err)
+	}
+
+	if _, err = Compare(reference, 2, decoded, 1, 44100); !errors.Is(err, errUnsupportedSampleRate) {
+		t.Fatal(err)
+	}
+}
+
+func TestDecode(t *testing.T) {
+	for _, options := range []opus.Options{{}, {FixedPoint: true}} {
+		decoder := opus.NewDecoderWithOptions(options)
+		out := make([]byte, 1920)
+		if _, _, err := decoder.Decode(testPacket(), out); err != nil {
+			t.Fatal(err)
+		}
+		finalRange := decoder.FinalRange()
+
+		bitstream := buildBitstream([][]byte{testPacket(), {}}, []uint32{finalRange, 0})
+		samples, err := DecodeWithOptions(bytes.NewReader(bitstream), options)
+		switch {
+		case err != nil:
+			t.Fatal(err)
+		case len(samples) != 1920:
+			t.Fatalf("decoded %d samples, expected 1920", len(samples))
+		case !reflect.DeepEqual(samples[:960], appendPCM(nil, out)):
+			t.Fatal("samples don't match the decoder")
+		}
+
+		bitstream = buildBitstream([][]byte{testPacket()}, []uint32{finalRange + 1})
+		if _, err = DecodeWithOptions(bytes.NewReader(bitstream), options); !errors.Is(err, errFinalRangeMismatch) {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestFixedPointReference(t *testing.T) {
+	dir := os.Getenv(fixedPointReferenceEnv)
+	if dir == "" {
+		t.Skipf("%s is not set", fixedPointReferenceEnv)
+	}
+
+	bitstreams, err := filepath.Glob(filepath.Join(dir, "*.bit"))
+	if err != nil {
+		t.Fatal(err)
+	} else if len(bitstreams) == 0 {
+		t.Skipf("no bitstreams found in %s", dir)
+	}
+
+	for _, bitstream := range bitstreams {
+		bitstream := bitstream
+		t.Run(filepath.Base(bitstream), func(t *testing.T) {
+			reference, err := readPCM(strings.TrimSuffix(bitstream, ".bit") + ".pcm")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			f, err := os.Open(bitstream)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+
+			decoded, err := DecodeWithOptions(f, opus.Options{FixedPoint: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// The output has to be bit-exact
+			if len(decoded) != len(reference) {
+				t.Fatalf("decoded %d samples, expected %d", len(decoded), len(reference))
+			}
+			for i := range decoded {
+				if decoded[i] != reference[i] {
+					t.Fatalf("sample %d (%.3f s): %d != %d", i, float64(i)/sampleRate, decoded[i], reference[i])
+				}
+			}
+		})
+	}
+}
+
+func TestVectors(t *testing.T) {
+	dir := os.Getenv(testVectorsEnv)
+	if dir == "" {
+		t.Skipf("%s is not set", testVectorsEnv)
+	}
+
+	vectors, err := FindVectors(dir)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(vectors) == 0 {
+		t.Skipf("no test vectors found in %s", dir)
+	}
+
+	for _, vector := range vectors {
+		vector := vector
+		t.Run(vector.Name, func(t *testing.T) {
+			result, err := vector.Run()
+			switch {
+			case err != nil:
+				t.Fatal(err)
+			case !result.Passed():
+				t.Fatalf("Test vector %s", result)
+			}
+
+			t.Logf("Test vector %s", result)
+		})
+	}
+}
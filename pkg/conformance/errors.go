@@ -0,0 +1,14 @@
+package conformance
+
+import "errors"
+
+var (
+	errTruncatedPacket        = errors.New("bitstream ends in the middle of a packet")
+	errPacketTooLarge         = errors.New("bitstream packet is larger than the maximum packet size")
+	errUnsupportedSampleRate  = errors.New("sample rate must be 8000, 12000, 16000, 24000 or 48000")
+	errUnsupportedChannels    = errors.New("channels must be 1 or 2")
+	errMonoReference          = errors.New("stereo output can't be compared with a mono reference")
+	errSampleCountMismatch    = errors.New("sample counts do not match")
+	errInsufficientSampleData = errors.New("insufficient sample data")
+	errFinalRangeMismatch     = errors.New("range coder state mismatch between encoder and decoder")
+)
@@ -0,0 +1,8 @@
+package jitterbuffer
+
+import "errors"
+
+var (
+	errOutBufferTooSmall = errors.New("out isn't large enough")
+	errInvalidDelay      = errors.New("MinDelay must not be larger than MaxDelay")
+)
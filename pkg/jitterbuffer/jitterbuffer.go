@@ -0,0 +1,446 @@
+// Package jitterbuffer reorders timestamped Opus packets and drives a
+// decoder to produce a steady stream of PCM frames
+package jitterbuffer
+
+import (
+	"time"
+
+	"github.com/pion/opus"
+	"github.com/pion/opus/pkg/rtpopus"
+)
+
+const (
+	bytesPerSample = 2
+
+	// Duration of output produced before the first packet is known
+	defaultFrameDuration = rtpopus.ClockRate / 50
+
+	// Largest amount of audio a single Opus packet can contain
+	maxPacketDuration = rtpopus.ClockRate * 120 / 1000
+
+	// MaxFrameSize is the largest number of bytes a single call to Read
+	// produces. Packets contain at most 120 ms of audio.
+	MaxFrameSize = maxPacketDuration * bytesPerSample
+
+	// The jitter estimate is updated with a gain of 1/16
+	//
+	// https://datatracker.ietf.org/doc/html/rfc3550#section-6.4.1
+	jitterGain = 16
+
+	// The target delay covers this many times the measured jitter
+	jitterMultiplier = 3
+
+	defaultMinDelay = 20 * time.Millisecond
+	defaultMaxDelay = 200 * time.Millisecond
+)
+
+// Decoder is the Opus decoder driven by the JitterBuffer. It is satisfied by
+// *opus.Decoder. Every method writes 16-bit little-endian samples at 48 kHz.
+type Decoder interface {
+	Decode(in []byte, out []byte) (opus.Bandwidth, bool, error)
+	DecodeFEC(in []byte, out []byte) (opus.Bandwidth, bool, error)
+	Conceal(out []byte) (opus.Bandwidth, bool, error)
+}
+
+// Config controls how much audio the JitterBuffer holds back
+type Config struct {
+	// MinDelay and MaxDelay bound the target delay. The target delay adapts
+	// to the measured jitter between these limits. A zero value selects a
+	// default of 20 ms and 200 ms respectively.
+	MinDelay time.Duration
+	MaxDelay time.Duration
+}
+
+// Stats describes the state of a JitterBuffer
+type Stats struct {
+	// LatePackets is the number of packets that arrived after their audio
+	// should have been played out
+	LatePackets uint64
+
+	// DroppedPackets is the number of packets discarded to reduce the delay
+	DroppedPackets uint64
+
+	// FECRecovered is the number of lost frames recovered with the forward
+	// error correction data of the following packet
+	FECRecovered uint64
+
+	// ConcealedDuration is the total duration of audio generated by packet
+	// loss concealment to replace lost packets and packets that failed to
+	// decode. The comfort noise of DTX and pauses of the sender, which are
+	// found when the next packet arrives, aren't counted.
+	ConcealedDuration time.Duration
+
+	// Depth is the duration of audio currently held in the buffer
+	Depth time.Duration
+
+	// TargetDelay is the depth the buffer is adapting towards
+	TargetDelay time.Duration
+
+	// Jitter is the interarrival jitter estimate
+	//
+	// https://datatracker.ietf.org/doc/html/rfc3550#section-6.4.1
+	Jitter time.Duration
+}
+
+type packet struct {
+	timestamp uint32
+	duration  uint32
+
+	// Duration of the first frame, the frame FEC data in the following
+	// packet replaces
+	frameDuration uint32
+	isDTX         bool
+	payload       []byte
+}
+
+// JitterBuffer accepts out-of-order timestamped Opus packets and produces a
+// steady stream of PCM frames. For every frame it chooses between decoding
+// the packet, recovering the frame from the FEC data of the following
+// packet, and concealing the loss.
+type JitterBuffer struct {
+	decoder  Decoder
+	minDelay uint32
+	maxDelay uint32
+
+	// Packets ordered by timestamp, all later than playout
+	packets []packet
+
+	// Timestamp of the next sample to be played out
+	playout uint32
+	playing bool
+
+	// Duration of the most recently played frame, used for concealment
+	frameDuration uint32
+
+	// The most recently played packet was a DTX packet, the frames until
+	// the next packet continue its comfort noise
+	inDTX bool
+
+	// Duration concealed since the most recently played packet, which
+	// isn't a loss when the sender turns out to have paused
+	concealedSincePacket uint32
+
+	// Transit times are relative to the first packet
+	haveTransit    bool
+	firstArrival   time.Time
+	firstTimestamp uint32
+	lastTransit    int64
+	jitter         float64
+	targetDelay    uint32
+
+	stats Stats
+}
+
+// New creates a JitterBuffer that decodes packets with decoder
+func New(decoder Decoder, config Config) (*JitterBuffer, error) {
+	if config.MinDelay == 0 {
+		config.MinDelay = defaultMinDelay
+	}
+	if config.MaxDelay == 0 {
+		config.MaxDelay = defaultMaxDelay
+	}
+	if config.MinDelay > config.MaxDelay {
+		return nil, errInvalidDelay
+	}
+
+	j := &JitterBuffer{
+		decoder:       decoder,
+		minDelay:      durationToTimestamp(config.MinDelay),
+		maxDelay:      durationToTimestamp(config.MaxDelay),
+		frameDuration: defaultFrameDuration,
+	}
+	j.targetDelay = j.minDelay
+
+	return j, nil
+}
+
+// Push adds a packet with the RTP timestamp of its first sample. arrival is
+// the time the packet was received, it is used to measure the jitter.
+// Packets arriving after their audio has been played out are counted in
+// Stats.LatePackets and discarded.
+func (j *JitterBuffer) Push(timestamp uint32, payload []byte, arrival time.Time) error {
+	duration, err := rtpopus.PacketDuration(payload)
+	if err != nil {
+		return err
+	}
+
+	parsed, err := opus.ParsePacket(payload)
+	if err != nil {
+		return err
+	}
+
+	j.updateJitter(timestamp, arrival, duration)
+
+	if j.playing && isBefore(timestamp, j.playout) {
+		j.stats.LatePackets++
+		return nil
+	}
+
+	// Packets are kept ordered by timestamp, they mostly arrive in order
+	i := len(j.packets)
+	for i > 0 && isBefore(timestamp, j.packets[i-1].timestamp) {
+		i--
+	}
+	if i > 0 && j.packets[i-1].timestamp == timestamp {
+		return nil
+	}
+
+	j.packets = append(j.packets, packet{})
+	copy(j.packets[i+1:], j.packets[i:])
+	j.packets[i] = packet{
+		timestamp:     timestamp,
+		duration:      duration,
+		frameDuration: durationToTimestamp(parsed.FrameDuration()),
+		isDTX:         parsed.IsDTX(),
+		payload:       append([]byte(nil), payload...),
+	}
+
+	return nil
+}
+
+// Read produces the next frame of 16-bit little-endian mono samples at 48 kHz
+// and returns the number of bytes written. Every call advances the playout
+// by the duration of the written frame, which is the duration of a packet,
+// at most MaxFrameSize bytes. Silence is produced until the buffer has filled
+// up to the target delay.
+func (j *JitterBuffer) Read(out []byte) (n int, err error) {
+	if !j.playing {
+		if len(j.packets) == 0 || j.depth() < j.targetDelay {
+			return j.silence(out, j.frameDuration)
+		}
+
+		j.playing = true
+		j.playout = j.packets[0].timestamp
+	}
+
+	j.discardPlayedPackets()
+	j.reduceDelay()
+
+	if len(j.packets) == 0 {
+		return j.conceal(out)
+	}
+
+	next := j.packets[0]
+
+	// The sender skipped ahead, e.g. after a silence period. Continue from
+	// the next packet instead of concealing the whole gap. The audio
+	// concealed while waiting for the packet covered the pause, no packet
+	// was lost.
+	if next.timestamp-j.playout > j.maxDelay+maxPacketDuration {
+		j.playout = next.timestamp
+		j.stats.ConcealedDuration -= timestampToDuration(j.concealedSincePacket)
+		j.concealedSincePacket = 0
+	}
+
+	switch {
+	case next.timestamp == j.playout:
+		j.packets = j.packets[1:]
+		return j.decode(out, next)
+	case next.timestamp-next.frameDuration == j.playout:
+		if n, err := j.decodeFEC(out, next); err == nil {
+			return n, nil
+		}
+	}
+
+	return j.conceal(out)
+}
+
+// Stats returns the current statistics of the JitterBuffer
+func (j *JitterBuffer) Stats() Stats {
+	stats := j.stats
+	stats.Depth = timestampToDuration(j.depth())
+	stats.TargetDelay = timestampToDuration(j.targetDelay)
+	stats.Jitter = timestampToDuration(uint32(j.jitter))
+	return stats
+}
+
+func (j *JitterBuffer) decode(out []byte, p packet) (int, error) {
+	n := int(p.duration) * bytesPerSample
+	if len(out) < n {
+		return 0, errOutBufferTooSmall
+	}
+
+	if _, _, err := j.decoder.Decode(p.payload, out); err != nil {
+		return j.concealPacket(out, p)
+	}
+
+	j.playout += p.duration
+	j.frameDuration = p.frameDuration
+	j.inDTX = p.isDTX
+	j.concealedSincePacket = 0
+	return n, nil
+}
+
+// concealPacket replaces all of a packet that failed to decode, so the
+// playout continues after it like after a decoded packet
+func (j *JitterBuffer) concealPacket(out []byte, p packet) (int, error) {
+	n := int(p.duration) * bytesPerSample
+	frameSize := int(j.frameDuration) * bytesPerSample
+
+	concealed := 0
+	for ; concealed+frameSize <= n; concealed += frameSize {
+		if _, _, err := j.decoder.Conceal(out[concealed:]); err != nil {
+			break
+		}
+	}
+
+	// Nothing has been decoded yet, or the packet doesn't end on a frame
+	// boundary of the concealed frames
+	for i := range out[concealed:n] {
+		out[concealed+i] = 0
+	}
+
+	j.stats.ConcealedDuration += timestampToDuration(p.duration)
+	j.playout += p.duration
+	j.concealedSincePacket = 0
+	return n, nil
+}
+
+func (j *JitterBuffer) decodeFEC(out []byte, p packet) (int, error) {
+	n := int(p.frameDuration) * bytesPerSample
+	if len(out) < n {
+		return 0, errOutBufferTooSmall
+	}
+
+	if _, _, err := j.decoder.DecodeFEC(p.payload, out); err != nil {
+		return 0, err
+	}
+
+	j.stats.FECRecovered++
+	j.playout += p.frameDuration
+	j.frameDuration = p.frameDuration
+	j.inDTX = false
+	j.concealedSincePacket = 0
+	return n, nil
+}
+
+func (j *JitterBuffer) conceal(out []byte) (int, error) {
+	n := int(j.frameDuration) * bytesPerSample
+	if len(out) < n {
+		return 0, errOutBufferTooSmall
+	}
+
+	// Nothing has been decoded yet, so there is nothing to continue
+	if _, _, err := j.decoder.Conceal(out); err != nil {
+		for i := range out[:n] {
+			out[i] = 0
+		}
+	}
+
+	// The decoder continues the comfort noise of DTX, no packet was lost
+	if !j.inDTX {
+		j.stats.ConcealedDuration += timestampToDuration(j.frameDuration)
+		j.concealedSincePacket += j.frameDuration
+	}
+
+	j.playout += j.frameDuration
+	return n, nil
+}
+
+func (j *JitterBuffer) silence(out []byte, duration uint32) (int, error) {
+	n := int(duration) * bytesPerSample
+	if len(out) < n {
+		return 0, errOutBufferTooSmall
+	}
+
+	for i := range out[:n] {
+		out[i] = 0
+	}
+
+	return n, nil
+}
+
+// Packets overtaken by the playout, e.g. after concealing a frame that
+// didn't align with the following packet, can't be played anymore
+func (j *JitterBuffer) discardPlayedPackets() {
+	for len(j.packets) != 0 && isBefore(j.packets[0].timestamp, j.playout) {
+		j.packets = j.packets[1:]
+		j.stats.LatePackets++
+	}
+}
+
+// When the jitter has decreased the buffer holds more audio than needed,
+// the delay is reduced by dropping the oldest packet. Only a whole packet
+// that starts at the playout and is directly followed by the next packet is
+// dropped, so the playout moves from one packet boundary to the next, and
+// only if the buffer stays above the target delay without it.
+func (j *JitterBuffer) reduceDelay() {
+	if len(j.packets) < 2 {
+		return
+	}
+
+	first, next := j.packets[0], j.packets[1]
+	last := j.packets[len(j.packets)-1]
+	switch {
+	case first.timestamp != j.playout || next.timestamp != first.timestamp+first.duration:
+		return
+	case last.timestamp+last.duration-next.timestamp <= j.targetDelay+j.frameDuration:
+		return
+	}
+
+	j.packets = j.packets[1:]
+	j.playout = next.timestamp
+	j.stats.DroppedPackets++
+}
+
+// depth is the duration of audio between the playout and the end of the
+// latest packet
+func (j *JitterBuffer) depth() uint32 {
+	if len(j.packets) == 0 {
+		return 0
+	}
+
+	start := j.packets[0].timestamp
+	if j.playing {
+		start = j.playout
+	}
+
+	last := j.packets[len(j.packets)-1]
+	return last.timestamp + last.duration - start
+}
+
+// The interarrival jitter is the mean deviation of the difference in packet
+// spacing at the receiver compared to the sender.
+//
+// https://datatracker.ietf.org/doc/html/rfc3550#section-6.4.1
+func (j *JitterBuffer) updateJitter(timestamp uint32, arrival time.Time, duration uint32) {
+	if !j.haveTransit {
+		j.firstArrival = arrival
+		j.firstTimestamp = timestamp
+	}
+
+	elapsed := int64(arrival.Sub(j.firstArrival)) * rtpopus.ClockRate / int64(time.Second)
+	transit := elapsed - int64(int32(timestamp-j.firstTimestamp))
+	if j.haveTransit {
+		d := transit - j.lastTransit
+		if d < 0 {
+			d = -d
+		}
+		j.jitter += (float64(d) - j.jitter) / jitterGain
+	}
+	j.lastTransit = transit
+	j.haveTransit = true
+
+	target := uint32(j.jitter*jitterMultiplier) + duration
+	switch {
+	case target < j.minDelay:
+		target = j.minDelay
+	case target > j.maxDelay:
+		target = j.maxDelay
+	}
+	j.targetDelay = target
+}
+
+// isBefore compares timestamps in serial number arithmetic, the timestamp
+// wraps around
+func isBefore(a, b uint32) bool {
+	return int32(a-b) < 0
+}
+
+func durationToTimestamp(d time.Duration) uint32 {
+	return uint32(d * rtpopus.ClockRate / time.Second)
+}
+
+func timestampToDuration(t uint32) time.Duration {
+	return time.Duration(t) * time.Second / rtpopus.ClockRate
+}
@@ -0,0 +1,374 @@
+package jitterbuffer
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pion/opus"
+)
+
+var (
+	errNoFEC        = errors.New("no FEC")
+	errDecodeFailed = errors.New("decode failed")
+)
+
+// fakeDecoder records which method produced every frame
+type fakeDecoder struct {
+	calls   []string
+	haveFEC bool
+
+	// Decoding the packet with this name fails
+	failing string
+}
+
+func (f *fakeDecoder) Decode(in []byte, out []byte) (opus.Bandwidth, bool, error) {
+	name := strings.TrimSpace(string(in[1:]))
+	if f.failing != "" && name == f.failing {
+		return 0, false, errDecodeFailed
+	}
+	f.calls = append(f.calls, "decode "+name)
+	return opus.BandwidthNarrowband, false, nil
+}
+
+func (f *fakeDecoder) DecodeFEC(in []byte, out []byte) (opus.Bandwidth, bool, error) {
+	if !f.haveFEC {
+		return 0, false, errNoFEC
+	}
+	f.calls = append(f.calls, "fec "+strings.TrimSpace(string(in[1:])))
+	return opus.BandwidthNarrowband, false, nil
+}
+
+func (f *fakeDecoder) Conceal(out []byte) (opus.Bandwidth, bool, error) {
+	f.calls = append(f.calls, "conceal")
+	return opus.BandwidthNarrowband, false, nil
+}
+
+// A NB 20 ms SILK packet, the payload identifies it in fakeDecoder.calls.
+// The name is followed by a space, frames of at most a byte are DTX frames.
+func testPacket(name string) []byte {
+	return append(append([]byte{0x08}, name...), ' ')
+}
+
+func readFrames(t *testing.T, j *JitterBuffer, count int) {
+	t.Helper()
+
+	out := make([]byte, MaxFrameSize)
+	for i := 0; i < count; i++ {
+		n, err := j.Read(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != 1920 {
+			t.Fatalf("Read returned %d bytes, expected 1920", n)
+		}
+	}
+}
+
+func newTestJitterBuffer(t *testing.T, decoder Decoder) *JitterBuffer {
+	t.Helper()
+
+	j, err := New(decoder, Config{MinDelay: 60 * time.Millisecond, MaxDelay: 200 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return j
+}
+
+func TestJitterBuffer(t *testing.T) {
+	start := time.Unix(0, 0)
+
+	t.Run("Buffering", func(t *testing.T) {
+		decoder := &fakeDecoder{}
+		j := newTestJitterBuffer(t, decoder)
+
+		if err := j.Push(0, testPacket("a"), start); err != nil {
+			t.Fatal(err)
+		}
+		readFrames(t, j, 2)
+
+		if len(decoder.calls) != 0 {
+			t.Fatalf("decoded %v before reaching the target delay", decoder.calls)
+		}
+	})
+
+	t.Run("Reorder", func(t *testing.T) {
+		decoder := &fakeDecoder{}
+		j := newTestJitterBuffer(t, decoder)
+
+		for _, p := range []struct {
+			timestamp uint32
+			name      string
+		}{{960, "b"}, {0, "a"}, {1920, "c"}} {
+			if err := j.Push(p.timestamp, testPacket(p.name), start); err != nil {
+				t.Fatal(err)
+			}
+		}
+		readFrames(t, j, 4)
+
+		expected := []string{"decode a", "decode b", "decode c", "conceal"}
+		if !reflect.DeepEqual(decoder.calls, expected) {
+			t.Fatalf("%v != %v", decoder.calls, expected)
+		}
+		if j.Stats().ConcealedDuration != 20*time.Millisecond {
+			t.Fatalf("concealed %v", j.Stats().ConcealedDuration)
+		}
+	})
+
+	t.Run("FEC", func(t *testing.T) {
+		decoder := &fakeDecoder{haveFEC: true}
+		j := newTestJitterBuffer(t, decoder)
+
+		for timestamp, name := range map[uint32]string{0: "a", 1920: "c", 2880: "d"} {
+			if err := j.Push(timestamp, testPacket(name), start); err != nil {
+				t.Fatal(err)
+			}
+		}
+		readFrames(t, j, 4)
+
+		expected := []string{"decode a", "fec c", "decode c", "decode d"}
+		if !reflect.DeepEqual(decoder.calls, expected) {
+			t.Fatalf("%v != %v", decoder.calls, expected)
+		}
+		if j.Stats().FECRecovered != 1 {
+			t.Fatal("FEC recovery was not counted")
+		}
+	})
+
+	t.Run("Conceal", func(t *testing.T) {
+		decoder := &fakeDecoder{}
+		j := newTestJitterBuffer(t, decoder)
+
+		for timestamp, name := range map[uint32]string{0: "a", 2880: "d"} {
+			if err := j.Push(timestamp, testPacket(name), start); err != nil {
+				t.Fatal(err)
+			}
+		}
+		readFrames(t, j, 4)
+
+		expected := []string{"decode a", "conceal", "conceal", "decode d"}
+		if !reflect.DeepEqual(decoder.calls, expected) {
+			t.Fatalf("%v != %v", decoder.calls, expected)
+		}
+		if j.Stats().ConcealedDuration != 40*time.Millisecond {
+			t.Fatalf("concealed %v", j.Stats().ConcealedDuration)
+		}
+	})
+
+	t.Run("Late", func(t *testing.T) {
+		decoder := &fakeDecoder{}
+		j := newTestJitterBuffer(t, decoder)
+
+		for timestamp, name := range map[uint32]string{0: "a", 1920: "c"} {
+			if err := j.Push(timestamp, testPacket(name), start); err != nil {
+				t.Fatal(err)
+			}
+		}
+		readFrames(t, j, 2)
+
+		if err := j.Push(960, testPacket("b"), start.Add(time.Second)); err != nil {
+			t.Fatal(err)
+		}
+		readFrames(t, j, 1)
+
+		expected := []string{"decode a", "conceal", "decode c"}
+		if !reflect.DeepEqual(decoder.calls, expected) {
+			t.Fatalf("%v != %v", decoder.calls, expected)
+		}
+		if j.Stats().LatePackets != 1 {
+			t.Fatal("late packet was not counted")
+		}
+	})
+
+	t.Run("Adapt", func(t *testing.T) {
+		j := newTestJitterBuffer(t, &fakeDecoder{})
+
+		for i := uint32(0); i < 50; i++ {
+			arrival := start.Add(time.Duration(i) * 20 * time.Millisecond)
+			if i%2 == 1 {
+				arrival = arrival.Add(30 * time.Millisecond)
+			}
+			if err := j.Push(i*960, testPacket("a"), arrival); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		stats := j.Stats()
+		if stats.Jitter < 20*time.Millisecond {
+			t.Fatalf("jitter %v is too low", stats.Jitter)
+		}
+		if stats.TargetDelay <= 60*time.Millisecond || stats.TargetDelay > 200*time.Millisecond {
+			t.Fatalf("target delay %v didn't adapt", stats.TargetDelay)
+		}
+		if stats.Depth != time.Second {
+			t.Fatalf("depth %v != 1s", stats.Depth)
+		}
+	})
+
+	t.Run("Decode failure", func(t *testing.T) {
+		decoder := &fakeDecoder{failing: "xx"}
+		j := newTestJitterBuffer(t, decoder)
+
+		// A 40 ms packet of two frames that fails to decode
+		for timestamp, payload := range map[uint32][]byte{0: testPacket("a"), 960: append([]byte{0x09}, "xx"...), 2880: testPacket("c")} {
+			if err := j.Push(timestamp, payload, start); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		out := make([]byte, MaxFrameSize)
+		for _, expected := range []int{1920, 3840, 1920} {
+			if n, err := j.Read(out); err != nil {
+				t.Fatal(err)
+			} else if n != expected {
+				t.Fatalf("Read returned %d bytes, expected %d", n, expected)
+			}
+		}
+
+		expected := []string{"decode a", "conceal", "conceal", "decode c"}
+		if !reflect.DeepEqual(decoder.calls, expected) {
+			t.Fatalf("%v != %v", decoder.calls, expected)
+		}
+		if j.Stats().ConcealedDuration != 40*time.Millisecond {
+			t.Fatalf("concealed %v", j.Stats().ConcealedDuration)
+		}
+	})
+
+	t.Run("Silence gap", func(t *testing.T) {
+		decoder := &fakeDecoder{}
+		j := newTestJitterBuffer(t, decoder)
+
+		if err := j.Push(0, testPacket("a"), start); err != nil {
+			t.Fatal(err)
+		}
+		j.targetDelay = 0
+		readFrames(t, j, 3)
+		if j.Stats().ConcealedDuration != 40*time.Millisecond {
+			t.Fatalf("concealed %v", j.Stats().ConcealedDuration)
+		}
+
+		// The sender paused for a second, nothing was lost
+		if err := j.Push(48000, testPacket("b"), start.Add(time.Second)); err != nil {
+			t.Fatal(err)
+		}
+		readFrames(t, j, 1)
+
+		expected := []string{"decode a", "conceal", "conceal", "decode b"}
+		if !reflect.DeepEqual(decoder.calls, expected) {
+			t.Fatalf("%v != %v", decoder.calls, expected)
+		}
+		if j.Stats().ConcealedDuration != 0 {
+			t.Fatalf("concealed %v", j.Stats().ConcealedDuration)
+		}
+	})
+
+	t.Run("DTX", func(t *testing.T) {
+		decoder := &fakeDecoder{}
+		j := newTestJitterBuffer(t, decoder)
+
+		// The TOC byte alone is a DTX packet, the decoder continues its
+		// comfort noise until the next packet
+		for timestamp, payload := range map[uint32][]byte{0: testPacket("a"), 960: {0x08}} {
+			if err := j.Push(timestamp, payload, start); err != nil {
+				t.Fatal(err)
+			}
+		}
+		j.targetDelay = 0
+		readFrames(t, j, 4)
+
+		expected := []string{"decode a", "decode ", "conceal", "conceal"}
+		if !reflect.DeepEqual(decoder.calls, expected) {
+			t.Fatalf("%v != %v", decoder.calls, expected)
+		}
+		if j.Stats().ConcealedDuration != 0 {
+			t.Fatalf("concealed %v", j.Stats().ConcealedDuration)
+		}
+	})
+
+	t.Run("Reduce delay", func(t *testing.T) {
+		// Packets arriving on time keep the target delay at its minimum
+		push := func(t *testing.T, j *JitterBuffer, packets map[uint32][]byte) {
+			t.Helper()
+			for timestamp, payload := range packets {
+				arrival := start.Add(time.Duration(timestamp) * time.Second / 48000)
+				if err := j.Push(timestamp, payload, arrival); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+
+		decoder := &fakeDecoder{}
+		j := newTestJitterBuffer(t, decoder)
+		push(t, j, map[uint32][]byte{
+			0: testPacket("a"), 960: testPacket("b"), 1920: testPacket("c"), 2880: testPacket("d"),
+			3840: testPacket("e"), 4800: testPacket("f"), 5760: testPacket("g"),
+		})
+		readFrames(t, j, 2)
+
+		expected := []string{"decode b", "decode c"}
+		switch {
+		case !reflect.DeepEqual(decoder.calls, expected):
+			t.Fatalf("%v != %v", decoder.calls, expected)
+		case j.Stats().DroppedPackets != 1:
+			t.Fatal(j.Stats().DroppedPackets)
+		}
+
+		// A packet followed by a gap isn't dropped, the playout would skip
+		// the gap and the frame FEC could recover
+		decoder = &fakeDecoder{haveFEC: true}
+		j = newTestJitterBuffer(t, decoder)
+		push(t, j, map[uint32][]byte{
+			0: testPacket("a"), 1920: testPacket("c"), 2880: testPacket("d"),
+			3840: testPacket("e"), 4800: testPacket("f"), 5760: testPacket("g"),
+		})
+		readFrames(t, j, 3)
+
+		expected = []string{"decode a", "fec c", "decode c"}
+		switch {
+		case !reflect.DeepEqual(decoder.calls, expected):
+			t.Fatalf("%v != %v", decoder.calls, expected)
+		case j.Stats().DroppedPackets != 0:
+			t.Fatal(j.Stats().DroppedPackets)
+		}
+
+		// Dropping the 120 ms packet would leave less than the target delay
+		decoder = &fakeDecoder{}
+		j = newTestJitterBuffer(t, decoder)
+		push(t, j, map[uint32][]byte{0: append([]byte{0x19}, "bb"...), 5760: testPacket("c"), 6720: testPacket("d")})
+
+		if n, err := j.Read(make([]byte, MaxFrameSize)); err != nil {
+			t.Fatal(err)
+		} else if n != MaxFrameSize {
+			t.Fatal(n)
+		}
+
+		expected = []string{"decode bb"}
+		switch {
+		case !reflect.DeepEqual(decoder.calls, expected):
+			t.Fatalf("%v != %v", decoder.calls, expected)
+		case j.Stats().DroppedPackets != 0:
+			t.Fatal(j.Stats().DroppedPackets)
+		}
+	})
+
+	t.Run("Out buffer too small", func(t *testing.T) {
+		j := newTestJitterBuffer(t, &fakeDecoder{})
+
+		if _, err := j.Read(make([]byte, 10)); !errors.Is(err, errOutBufferTooSmall) {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New(&fakeDecoder{}, Config{MinDelay: time.Second, MaxDelay: time.Millisecond}); !errors.Is(err, errInvalidDelay) {
+		t.Fatal(err)
+	}
+
+	decoder := opus.NewDecoder()
+	if _, err := New(&decoder, Config{}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,254 @@
+package mp4reader
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+)
+
+const (
+	// A box begins with its 32-bit size and type. A size of 1 is followed
+	// by a 64-bit size, a size of 0 extends the box to the end of the file.
+	//
+	// https://www.iso.org/standard/83102.html, section 4.2
+	boxHeaderSize      = 8
+	largeBoxHeaderSize = 16
+	largeSize          = 1
+	sizeToEnd          = 0
+
+	// The size of boxes of unknown size, the last box of the file
+	unknownSize = -1
+
+	// Full boxes begin with a version and 24 bits of flags
+	fullBoxHeaderSize = 4
+
+	// Boxes read into memory are limited to this size
+	maxBoxSize = 64 << 20
+)
+
+// box is the header of a box. Its position is the one of its header.
+type box struct {
+	boxType    string
+	position   int64
+	headerSize int64
+	size       int64
+}
+
+// end returns the position after the box, or -1 when it extends to the
+// end of the file
+func (b box) end() int64 {
+	if b.size == unknownSize {
+		return unknownSize
+	}
+
+	return b.position + b.size
+}
+
+// source reads from a stream, and counts the bytes read to know the
+// position of every box and sample. Streams that don't implement
+// io.Seeker can only move forward.
+type source struct {
+	in       io.Reader
+	buffered *bufio.Reader
+	position int64
+}
+
+func newSource(in io.Reader) (*source, error) {
+	s := &source{in: in, buffered: bufio.NewReader(in)}
+
+	// Offsets in the file are absolute
+	if seeker, ok := in.(io.Seeker); ok {
+		position, err := seeker.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil, err
+		}
+		s.position = position
+	}
+
+	return s, nil
+}
+
+// seekTo continues reading at position. Without io.Seeker, the bytes
+// before a later position are discarded.
+func (s *source) seekTo(position int64) error {
+	if position == s.position {
+		return nil
+	}
+
+	if seeker, ok := s.in.(io.Seeker); ok {
+		if _, err := seeker.Seek(position, io.SeekStart); err != nil {
+			return err
+		}
+		s.buffered.Reset(s.in)
+		s.position = position
+		return nil
+	}
+
+	if position < s.position {
+		return errNotSeekable
+	}
+
+	n, err := io.CopyN(io.Discard, s.buffered, position-s.position)
+	s.position += n
+	if err != nil {
+		return io.ErrUnexpectedEOF
+	}
+
+	return nil
+}
+
+func (s *source) read(p []byte) error {
+	n, err := io.ReadFull(s.buffered, p)
+	s.position += int64(n)
+
+	return err
+}
+
+// readBox reads the header of the next box. It returns io.EOF when there
+// are no more boxes.
+func (s *source) readBox() (box, error) {
+	b := box{position: s.position, headerSize: boxHeaderSize}
+
+	header := make([]byte, boxHeaderSize)
+	if err := s.read(header); err != nil {
+		return box{}, err
+	}
+	b.boxType = string(header[4:8])
+
+	switch size := binary.BigEndian.Uint32(header); size {
+	case largeSize:
+		if err := s.read(header); err != nil {
+			return box{}, io.ErrUnexpectedEOF
+		}
+		b.headerSize = largeBoxHeaderSize
+		b.size = int64(binary.BigEndian.Uint64(header))
+	case sizeToEnd:
+		b.size = unknownSize
+		return b, nil
+	default:
+		b.size = int64(size)
+	}
+
+	if b.size < b.headerSize {
+		return box{}, errShortBox
+	}
+
+	return b, nil
+}
+
+// readBoxData reads the payload of a box into memory
+func (s *source) readBoxData(b box) ([]byte, error) {
+	size := b.size - b.headerSize
+	if b.size == unknownSize || size > maxBoxSize {
+		return nil, errBoxTooLarge
+	}
+
+	data := make([]byte, size)
+	if err := s.read(data); err != nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	return data, nil
+}
+
+// children calls fn for every box in data, the payload of a container box
+func children(data []byte, fn func(boxType string, data []byte) error) error {
+	for len(data) > 0 {
+		if len(data) < boxHeaderSize {
+			return errShortBox
+		}
+
+		size := uint64(binary.BigEndian.Uint32(data))
+		boxType := string(data[4:8])
+		headerSize := uint64(boxHeaderSize)
+
+		switch size {
+		case largeSize:
+			if len(data) < largeBoxHeaderSize {
+				return errShortBox
+			}
+			size = binary.BigEndian.Uint64(data[8:])
+			headerSize = largeBoxHeaderSize
+		case sizeToEnd:
+			size = uint64(len(data))
+		}
+
+		if size < headerSize || size > uint64(len(data)) {
+			return errShortBox
+		}
+
+		if err := fn(boxType, data[headerSize:size]); err != nil {
+			return err
+		}
+		data = data[size:]
+	}
+
+	return nil
+}
+
+// child returns the payload of the first box of a type in data, or nil
+func child(data []byte, boxType string) []byte {
+	var found []byte
+	_ = children(data, func(t string, data []byte) error {
+		if t == boxType && found == nil {
+			found = data
+		}
+		return nil
+	})
+
+	return found
+}
+
+// fullBox splits the payload of a full box into its version, flags and
+// the data that follows
+func fullBox(data []byte) (version uint8, flags uint32, rest []byte, err error) {
+	if len(data) < fullBoxHeaderSize {
+		return 0, 0, nil, errShortBox
+	}
+
+	return data[0], binary.BigEndian.Uint32(data) & 0xFFFFFF, data[fullBoxHeaderSize:], nil
+}
+
+// reader reads big-endian fields from the payload of a box. Reads past the
+// end return 0 and set err.
+type reader struct {
+	data []byte
+	err  error
+}
+
+func (r *reader) bytes(n int) []byte {
+	if r.err != nil || len(r.data) < n {
+		r.err = errShortBox
+		return make([]byte, n)
+	}
+
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *reader) uint8() uint8 {
+	return r.bytes(1)[0]
+}
+
+func (r *reader) uint16() uint16 {
+	return binary.BigEndian.Uint16(r.bytes(2))
+}
+
+func (r *reader) uint32() uint32 {
+	return binary.BigEndian.Uint32(r.bytes(4))
+}
+
+func (r *reader) uint64() uint64 {
+	return binary.BigEndian.Uint64(r.bytes(8))
+}
+
+// uintN reads a 64-bit field for version 1 of a full box, and a 32-bit one
+// otherwise
+func (r *reader) uintN(version uint8) uint64 {
+	if version == 1 {
+		return r.uint64()
+	}
+
+	return uint64(r.uint32())
+}
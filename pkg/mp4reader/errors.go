@@ -0,0 +1,15 @@
+package mp4reader
+
+import "errors"
+
+var (
+	errNilStream      = errors.New("stream is nil")
+	errMissingMoov    = errors.New("stream has no moov box")
+	errNoOpusTrack    = errors.New("stream has no track with an Opus sample entry")
+	errMissingDOps    = errors.New("Opus sample entry has no dOps box")
+	errBadDOpsVersion = errors.New("dOps box version must be 0")
+	errShortBox       = errors.New("box is too short")
+	errBoxTooLarge    = errors.New("box is too large to be read into memory")
+	errBadSampleTable = errors.New("sample table is inconsistent")
+	errNotSeekable    = errors.New("sample data precedes the read position of a stream that does not implement io.Seeker")
+)
@@ -0,0 +1,161 @@
+package mp4reader
+
+import "io"
+
+// readFragment reads the top level boxes after the moov box until a moof
+// box has added samples of the track. The box that holds them, usually the
+// mdat box that follows, is entered before returning, so a stream that
+// doesn't implement io.Seeker only moves forward.
+//
+// https://www.iso.org/standard/83102.html, section 8.8
+func (r *MP4Reader) readFragment() error {
+	for {
+		if len(r.samples) > 0 && (r.nextBox == unknownSize || r.samples[0].offset < r.nextBox) {
+			return nil
+		}
+		if r.nextBox == unknownSize {
+			return io.EOF
+		}
+
+		if err := r.source.seekTo(r.nextBox); err != nil {
+			return err
+		}
+		b, err := r.source.readBox()
+		if err != nil {
+			return err
+		}
+		r.nextBox = b.end()
+
+		if b.boxType != "moof" {
+			continue
+		}
+
+		data, err := r.source.readBoxData(b)
+		if err != nil {
+			return err
+		}
+		if err := r.parseMoof(data, b.position); err != nil {
+			return err
+		}
+	}
+}
+
+// parseMoof adds the samples of the track fragments of the track. The data
+// of a fragment without a base data offset follows the one of the previous
+// fragment, or the moof box for the first one.
+func (r *MP4Reader) parseMoof(data []byte, position int64) error {
+	dataEnd := position
+	return children(data, func(boxType string, data []byte) error {
+		if boxType != "traf" {
+			return nil
+		}
+
+		end, err := r.parseTraf(data, position, dataEnd)
+		dataEnd = end
+		return err
+	})
+}
+
+// parseTraf parses a track fragment, and returns the end of its data
+func (r *MP4Reader) parseTraf(data []byte, moofPosition, dataEnd int64) (int64, error) {
+	_, flags, rest, err := fullBox(child(data, "tfhd"))
+	if err != nil {
+		return 0, err
+	}
+
+	tfhd := reader{data: rest}
+	trackID := tfhd.uint32()
+	defaults := r.defaults[trackID]
+
+	base := dataEnd
+	if flags&tfhdBaseDataOffset != 0 {
+		base = int64(tfhd.uint64())
+	} else if flags&tfhdDefaultBaseIsMoof != 0 {
+		base = moofPosition
+	}
+	if flags&tfhdSampleDescriptionIndex != 0 {
+		tfhd.uint32()
+	}
+	if flags&tfhdDefaultSampleDuration != 0 {
+		defaults.duration = tfhd.uint32()
+	}
+	if flags&tfhdDefaultSampleSize != 0 {
+		defaults.size = tfhd.uint32()
+	}
+	if flags&tfhdDefaultSampleFlags != 0 {
+		tfhd.uint32()
+	}
+	if tfhd.err != nil {
+		return 0, tfhd.err
+	}
+
+	isTrack := trackID == r.trackID
+	t := r.nextTime
+	if tfdt := child(data, "tfdt"); tfdt != nil {
+		version, _, rest, err := fullBox(tfdt)
+		if err != nil {
+			return 0, err
+		}
+		fields := reader{data: rest}
+		t = fields.uintN(version)
+		if fields.err != nil {
+			return 0, fields.err
+		}
+	}
+
+	offset := base
+	if err := children(data, func(boxType string, data []byte) error {
+		if boxType != "trun" {
+			return nil
+		}
+
+		_, flags, rest, err := fullBox(data)
+		if err != nil {
+			return err
+		}
+
+		trun := reader{data: rest}
+		count := trun.uint32()
+		if flags&trunDataOffset != 0 {
+			offset = base + int64(int32(trun.uint32()))
+		}
+		if flags&trunFirstSampleFlags != 0 {
+			trun.uint32()
+		}
+		if count > maxSampleCount {
+			return errBadSampleTable
+		}
+
+		for i := uint32(0); i < count && trun.err == nil; i++ {
+			s := sample{offset: offset, size: defaults.size, time: t, duration: defaults.duration}
+			if flags&trunSampleDuration != 0 {
+				s.duration = trun.uint32()
+			}
+			if flags&trunSampleSize != 0 {
+				s.size = trun.uint32()
+			}
+			if flags&trunSampleFlags != 0 {
+				trun.uint32()
+			}
+			if flags&trunSampleCompositionTimeOffset != 0 {
+				trun.uint32()
+			}
+
+			if isTrack {
+				r.samples = append(r.samples, s)
+			}
+			offset += int64(s.size)
+			t += uint64(s.duration)
+		}
+
+		return trun.err
+	}); err != nil {
+		return 0, err
+	}
+
+	if isTrack {
+		r.nextTime = t
+	}
+
+	return offset, nil
+}
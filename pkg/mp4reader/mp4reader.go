@@ -0,0 +1,573 @@
+// Package mp4reader implements an MP4 demuxer for Opus audio tracks, in
+// plain and fragmented ISO Base Media Files
+//
+// https://opus-codec.org/docs/opus_in_isobmff.html
+package mp4reader
+
+import (
+	"errors"
+	"io"
+	"time"
+
+	"github.com/pion/opus/pkg/oggreader"
+)
+
+const (
+	// Opus timestamps and the pre-skip are in 48 kHz samples
+	sampleRate = 48000
+
+	handlerTypeSound = "soun"
+	sampleEntryOpus  = "Opus"
+
+	// The fields of an AudioSampleEntry before its child boxes
+	//
+	// https://www.iso.org/standard/83102.html, section 12.2.3
+	audioSampleEntrySize = 28
+
+	// The fields of a dOps box before the channel mapping table
+	//
+	// https://opus-codec.org/docs/opus_in_isobmff.html#4.3.2
+	dOpsSize = 11
+
+	// An edit with a media time of -1 is an empty edit, which inserts
+	// silence
+	emptyEditMediaTime = -1
+
+	// Sample tables and runs are limited to this many samples, more than 3
+	// days of 20 ms packets
+	maxSampleCount = 1 << 24
+)
+
+// Flags of the tfhd and trun boxes
+//
+// https://www.iso.org/standard/83102.html, sections 8.8.7 and 8.8.8
+const (
+	tfhdBaseDataOffset         = 0x000001
+	tfhdSampleDescriptionIndex = 0x000002
+	tfhdDefaultSampleDuration  = 0x000008
+	tfhdDefaultSampleSize      = 0x000010
+	tfhdDefaultSampleFlags     = 0x000020
+	tfhdDefaultBaseIsMoof      = 0x020000
+
+	trunDataOffset                  = 0x000001
+	trunFirstSampleFlags            = 0x000004
+	trunSampleDuration              = 0x000100
+	trunSampleSize                  = 0x000200
+	trunSampleFlags                 = 0x000400
+	trunSampleCompositionTimeOffset = 0x000800
+)
+
+// Packet is an Opus packet of a sample, which can be passed to
+// opus.Decoder.Decode
+type Packet struct {
+	Data []byte
+
+	// Timestamp of the packet in the media of the track, which includes
+	// the pre-skip
+	Timestamp time.Duration
+
+	// Duration of the audio of the packet
+	Duration time.Duration
+
+	// DiscardPadding is the duration to discard from the end of the decoded
+	// audio of the packet, where the edit list ends the track
+	DiscardPadding time.Duration
+}
+
+type sample struct {
+	offset   int64
+	size     uint32
+	time     uint64
+	duration uint32
+}
+
+// trackDefaults are the defaults of the samples of fragments, given by the
+// trex box of a track
+type trackDefaults struct {
+	duration uint32
+	size     uint32
+}
+
+// MP4Reader reads the packets of the first Opus track of an MP4 file
+type MP4Reader struct {
+	source *source
+	header *oggreader.OggHeader
+
+	trackID        uint32
+	timescale      uint32
+	movieTimescale uint32
+
+	// The media time where the edit list ends the track, 0 if it doesn't
+	editEnd uint64
+
+	// Fragmented files continue after the moov box with moof boxes
+	fragmented bool
+	defaults   map[uint32]trackDefaults
+	nextBox    int64
+	nextTime   uint64
+
+	samples []sample
+}
+
+// NewWith reads the boxes of in up to the moov box, and returns an
+// MP4Reader for its first track with an Opus sample entry together with the
+// ID header of the track. The sample data is read at its offsets in the
+// file, files that store it before the moov box can only be read when in
+// implements io.Seeker.
+func NewWith(in io.Reader) (*MP4Reader, *oggreader.OggHeader, error) {
+	if in == nil {
+		return nil, nil, errNilStream
+	}
+
+	source, err := newSource(in)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	r := &MP4Reader{source: source, defaults: map[uint32]trackDefaults{}}
+	for {
+		b, err := source.readBox()
+		switch {
+		case errors.Is(err, io.EOF):
+			return nil, nil, errMissingMoov
+		case err != nil:
+			return nil, nil, err
+		}
+
+		if b.boxType == "moov" {
+			data, err := source.readBoxData(b)
+			if err != nil {
+				return nil, nil, err
+			}
+			if err := r.parseMoov(data); err != nil {
+				return nil, nil, err
+			}
+
+			r.nextBox = b.end()
+			return r, r.header, nil
+		}
+
+		if b.end() == unknownSize {
+			return nil, nil, errMissingMoov
+		}
+		if err := source.seekTo(b.end()); err != nil {
+			return nil, nil, err
+		}
+	}
+}
+
+// ReadPacket returns the next packet of the Opus track. Packets after the
+// end of the edit list are dropped. It returns io.EOF at the end of the
+// track.
+func (r *MP4Reader) ReadPacket() (Packet, error) {
+	for {
+		if len(r.samples) == 0 {
+			if !r.fragmented {
+				return Packet{}, io.EOF
+			}
+			if err := r.readFragment(); err != nil {
+				return Packet{}, err
+			}
+			continue
+		}
+
+		s := r.samples[0]
+		r.samples = r.samples[1:]
+		if r.editEnd != 0 && s.time >= r.editEnd {
+			continue
+		}
+
+		if err := r.source.seekTo(s.offset); err != nil {
+			return Packet{}, err
+		}
+		data := make([]byte, s.size)
+		if err := r.source.read(data); err != nil {
+			return Packet{}, io.ErrUnexpectedEOF
+		}
+
+		packet := Packet{
+			Data:      data,
+			Timestamp: r.duration(s.time),
+			Duration:  r.duration(uint64(s.duration)),
+		}
+		if end := s.time + uint64(s.duration); r.editEnd != 0 && end > r.editEnd {
+			packet.DiscardPadding = r.duration(end - r.editEnd)
+		}
+
+		return packet, nil
+	}
+}
+
+// duration converts a time in the timescale of the track
+func (r *MP4Reader) duration(t uint64) time.Duration {
+	timescale := uint64(r.timescale)
+	return time.Duration(t/timescale)*time.Second + time.Duration(t%timescale)*time.Second/time.Duration(timescale)
+}
+
+func (r *MP4Reader) parseMoov(data []byte) error {
+	if mvhd := child(data, "mvhd"); mvhd != nil {
+		version, _, rest, err := fullBox(mvhd)
+		if err != nil {
+			return err
+		}
+
+		fields := reader{data: rest}
+		fields.uintN(version) // creation_time
+		fields.uintN(version) // modification_time
+		r.movieTimescale = fields.uint32()
+		if fields.err != nil {
+			return fields.err
+		}
+	}
+
+	if err := children(data, func(boxType string, data []byte) error {
+		if boxType != "trak" || r.header != nil {
+			return nil
+		}
+		return r.parseTrak(data)
+	}); err != nil {
+		return err
+	}
+	if r.header == nil {
+		return errNoOpusTrack
+	}
+
+	if mvex := child(data, "mvex"); mvex != nil {
+		r.fragmented = true
+		return children(mvex, func(boxType string, data []byte) error {
+			if boxType != "trex" {
+				return nil
+			}
+
+			_, _, rest, err := fullBox(data)
+			if err != nil {
+				return err
+			}
+
+			fields := reader{data: rest}
+			trackID := fields.uint32()
+			fields.uint32() // default_sample_description_index
+			defaults := trackDefaults{duration: fields.uint32(), size: fields.uint32()}
+			r.defaults[trackID] = defaults
+
+			return fields.err
+		})
+	}
+
+	return nil
+}
+
+// parseTrak reads a track if it's an audio track with an Opus sample entry
+func (r *MP4Reader) parseTrak(data []byte) error {
+	mdia := child(data, "mdia")
+	stbl := child(child(mdia, "minf"), "stbl")
+	if handlerType(child(mdia, "hdlr")) != handlerTypeSound {
+		return nil
+	}
+
+	header, err := parseStsd(child(stbl, "stsd"))
+	if err != nil || header == nil {
+		return err
+	}
+
+	version, _, rest, err := fullBox(child(data, "tkhd"))
+	if err != nil {
+		return err
+	}
+	fields := reader{data: rest}
+	fields.uintN(version) // creation_time
+	fields.uintN(version) // modification_time
+	trackID := fields.uint32()
+
+	version, _, rest, err = fullBox(child(mdia, "mdhd"))
+	if err != nil {
+		return err
+	}
+	mdhd := reader{data: rest}
+	mdhd.uintN(version) // creation_time
+	mdhd.uintN(version) // modification_time
+	timescale := mdhd.uint32()
+
+	switch {
+	case fields.err != nil:
+		return fields.err
+	case mdhd.err != nil:
+		return mdhd.err
+	case timescale == 0:
+		return errBadSampleTable
+	}
+
+	r.header, r.trackID, r.timescale = header, trackID, timescale
+	if err := r.parseEditList(child(child(data, "edts"), "elst")); err != nil {
+		return err
+	}
+
+	return r.parseSampleTable(stbl)
+}
+
+func handlerType(hdlr []byte) string {
+	_, _, rest, err := fullBox(hdlr)
+	if err != nil || len(rest) < 8 {
+		return ""
+	}
+
+	// The handler type follows pre_defined
+	return string(rest[4:8])
+}
+
+// parseStsd returns the ID header of the first Opus sample entry, or nil
+// if there is none
+func parseStsd(stsd []byte) (*oggreader.OggHeader, error) {
+	_, _, rest, err := fullBox(stsd)
+	if err != nil || len(rest) < 4 {
+		return nil, err
+	}
+
+	var header *oggreader.OggHeader
+	err = children(rest[4:], func(boxType string, data []byte) (err error) {
+		if boxType != sampleEntryOpus || header != nil {
+			return nil
+		}
+		if len(data) < audioSampleEntrySize {
+			return errShortBox
+		}
+
+		dOps := child(data[audioSampleEntrySize:], "dOps")
+		if dOps == nil {
+			return errMissingDOps
+		}
+
+		header, err = parseDOps(dOps)
+		return err
+	})
+
+	return header, err
+}
+
+// parseDOps converts the big-endian dOps box to the little-endian Ogg ID
+// header, they carry the same fields
+//
+// https://opus-codec.org/docs/opus_in_isobmff.html#4.3.2
+func parseDOps(data []byte) (*oggreader.OggHeader, error) {
+	if len(data) < dOpsSize {
+		return nil, errShortBox
+	}
+	if data[0] != 0 {
+		return nil, errBadDOpsVersion
+	}
+
+	head := []byte("OpusHead")
+	head = append(head, 1, data[1])
+	head = append(head, data[3], data[2])
+	head = append(head, data[7], data[6], data[5], data[4])
+	head = append(head, data[9], data[8])
+	head = append(head, data[10:]...)
+
+	return oggreader.ParseIDHeader(head)
+}
+
+// parseEditList applies the first edit that isn't empty. Its media time is
+// the pre-skip, and its duration ends the track.
+//
+// https://opus-codec.org/docs/opus_in_isobmff.html#4.4
+func (r *MP4Reader) parseEditList(elst []byte) error {
+	if elst == nil {
+		return nil
+	}
+
+	version, _, rest, err := fullBox(elst)
+	if err != nil {
+		return err
+	}
+
+	fields := reader{data: rest}
+	for count := fields.uint32(); count > 0 && fields.err == nil; count-- {
+		segmentDuration := fields.uintN(version)
+		mediaTime := int64(int32(fields.uint32()))
+		if version == 1 {
+			mediaTime = int64(fields.uint64())
+		}
+		fields.uint32() // media_rate
+
+		if mediaTime == emptyEditMediaTime || fields.err != nil {
+			continue
+		}
+
+		r.header.PreSkip = uint16(uint64(mediaTime) * sampleRate / uint64(r.timescale))
+
+		// The duration is in the timescale of the movie, fragmented files
+		// may leave it at 0 for the whole track
+		if segmentDuration != 0 && r.movieTimescale != 0 {
+			r.editEnd = uint64(mediaTime) + segmentDuration*uint64(r.timescale)/uint64(r.movieTimescale)
+		}
+		break
+	}
+
+	return fields.err
+}
+
+// parseSampleTable resolves the offset, size and time of every sample of
+// the sample table
+//
+// https://www.iso.org/standard/83102.html, section 8.7
+func (r *MP4Reader) parseSampleTable(stbl []byte) error {
+	sizes, err := parseStsz(child(stbl, "stsz"))
+	if err != nil || len(sizes) == 0 {
+		return err
+	}
+
+	offsets, err := parseChunkOffsets(stbl)
+	if err != nil {
+		return err
+	}
+
+	entries, err := parseStsc(child(stbl, "stsc"))
+	if err != nil {
+		return err
+	}
+
+	// The samples per chunk of an entry apply from its first chunk until
+	// the first chunk of the next entry
+	r.samples = make([]sample, 0, len(sizes))
+	entry := 0
+	for i, offset := range offsets {
+		chunk := uint32(i + 1)
+		for entry+1 < len(entries) && entries[entry+1].firstChunk <= chunk {
+			entry++
+		}
+		if len(entries) == 0 || entries[entry].firstChunk > chunk {
+			return errBadSampleTable
+		}
+
+		for j := uint32(0); j < entries[entry].samplesPerChunk && len(r.samples) < len(sizes); j++ {
+			size := sizes[len(r.samples)]
+			r.samples = append(r.samples, sample{offset: offset, size: size})
+			offset += int64(size)
+		}
+	}
+	if len(r.samples) != len(sizes) {
+		return errBadSampleTable
+	}
+
+	return r.parseTimeToSample(child(stbl, "stts"))
+}
+
+type stscEntry struct {
+	firstChunk      uint32
+	samplesPerChunk uint32
+}
+
+// parseStsc returns the entries of the sample to chunk box
+func parseStsc(stsc []byte) ([]stscEntry, error) {
+	_, _, rest, err := fullBox(stsc)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := reader{data: rest}
+	count := uint64(fields.uint32())
+	if uint64(len(fields.data)) < count*12 {
+		return nil, errBadSampleTable
+	}
+
+	entries := make([]stscEntry, count)
+	for i := range entries {
+		entries[i] = stscEntry{firstChunk: fields.uint32(), samplesPerChunk: fields.uint32()}
+		fields.uint32() // sample_description_index
+	}
+
+	return entries, fields.err
+}
+
+// parseStsz returns the size of every sample
+func parseStsz(stsz []byte) ([]uint32, error) {
+	if stsz == nil {
+		return nil, nil
+	}
+
+	_, _, rest, err := fullBox(stsz)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := reader{data: rest}
+	sampleSize, count := fields.uint32(), fields.uint32()
+	if count > maxSampleCount || (sampleSize == 0 && uint64(len(fields.data)) < 4*uint64(count)) {
+		return nil, errBadSampleTable
+	}
+
+	sizes := make([]uint32, count)
+	for i := range sizes {
+		if sampleSize != 0 {
+			sizes[i] = sampleSize
+		} else {
+			sizes[i] = fields.uint32()
+		}
+	}
+
+	return sizes, fields.err
+}
+
+// parseChunkOffsets returns the offsets of the chunks of the stco or co64
+// box
+func parseChunkOffsets(stbl []byte) ([]int64, error) {
+	box, large := child(stbl, "stco"), false
+	if box == nil {
+		box, large = child(stbl, "co64"), true
+	}
+
+	_, _, rest, err := fullBox(box)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := reader{data: rest}
+	count := uint64(fields.uint32())
+	entrySize := uint64(4)
+	if large {
+		entrySize = 8
+	}
+	if uint64(len(fields.data)) < count*entrySize {
+		return nil, errBadSampleTable
+	}
+
+	offsets := make([]int64, count)
+	for i := range offsets {
+		if large {
+			offsets[i] = int64(fields.uint64())
+		} else {
+			offsets[i] = int64(fields.uint32())
+		}
+	}
+
+	return offsets, fields.err
+}
+
+// parseTimeToSample sets the decode time and duration of the samples
+func (r *MP4Reader) parseTimeToSample(stts []byte) error {
+	_, _, rest, err := fullBox(stts)
+	if err != nil {
+		return err
+	}
+
+	fields := reader{data: rest}
+	var i int
+	var t uint64
+	for count := fields.uint32(); count > 0 && fields.err == nil && i < len(r.samples); count-- {
+		sampleCount, delta := fields.uint32(), fields.uint32()
+		for j := uint32(0); j < sampleCount && i < len(r.samples); j++ {
+			r.samples[i].time, r.samples[i].duration = t, delta
+			t += uint64(delta)
+			i++
+		}
+	}
+	if fields.err != nil {
+		return fields.err
+	}
+	if i != len(r.samples) {
+		return errBadSampleTable
+	}
+	r.nextTime = t
+
+	return nil
+}
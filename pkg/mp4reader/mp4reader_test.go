@@ -0,0 +1,241 @@
+package mp4reader
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pion/opus/pkg/oggreader"
+)
+
+func mp4Box(boxType string, payload ...[]byte) []byte {
+	b := make([]byte, 8)
+	copy(b[4:], boxType)
+	for _, p := range payload {
+		b = append(b, p...)
+	}
+	binary.BigEndian.PutUint32(b, uint32(len(b)))
+
+	return b
+}
+
+// fields encodes 32-bit fields, the first one usually being the version
+// and flags of a full box
+func fields(values ...uint32) []byte {
+	b := make([]byte, 4*len(values))
+	for i, v := range values {
+		binary.BigEndian.PutUint32(b[i*4:], v)
+	}
+
+	return b
+}
+
+func testPacket(i int) []byte {
+	return []byte{0x48, byte(i), 0xE4, 0xC1}
+}
+
+func testHeader() *oggreader.OggHeader {
+	return &oggreader.OggHeader{Version: 1, Channels: 2, PreSkip: 312, SampleRate: 16000}
+}
+
+func opusSampleEntry() []byte {
+	entry := make([]byte, audioSampleEntrySize)
+	entry[7] = 1   // data_reference_index
+	entry[17] = 2  // channelcount
+	entry[19] = 16 // samplesize
+	binary.BigEndian.PutUint32(entry[24:], 48000<<16)
+
+	dOps := []byte{0, 2, 0x01, 0x38, 0, 0, 0x3E, 0x80, 0, 0, 0}
+
+	return mp4Box("stsd", fields(0, 1), mp4Box(sampleEntryOpus, entry, mp4Box("dOps", dOps)))
+}
+
+func audioTrak(trackID uint32, edts []byte, stbl ...[]byte) []byte {
+	return mp4Box("trak",
+		mp4Box("tkhd", fields(0, 0, 0, trackID)),
+		edts,
+		mp4Box("mdia",
+			mp4Box("mdhd", fields(0, 0, 0, 48000, 0)),
+			mp4Box("hdlr", fields(0, 0), []byte(handlerTypeSound), fields(0, 0, 0), []byte{0}),
+			mp4Box("minf", mp4Box("stbl", append([][]byte{opusSampleEntry()}, stbl...)...)),
+		),
+	)
+}
+
+// testMP4 builds a file with a video track and an Opus track of three
+// packets in two chunks, and an edit list that trims the last packet
+func testMP4(moovFirst bool) []byte {
+	ftyp := mp4Box("ftyp", []byte("isom"), fields(0), []byte("isomopus"))
+	mdat := mp4Box("mdat", testPacket(0), testPacket(1), []byte{0xFF, 0xFF, 0xFF}, testPacket(2))
+
+	moov := func(dataStart uint32) []byte {
+		return mp4Box("moov",
+			mp4Box("mvhd", fields(0, 0, 0, 1000, 0)),
+			mp4Box("trak",
+				mp4Box("tkhd", fields(0, 0, 0, 1)),
+				mp4Box("mdia",
+					mp4Box("mdhd", fields(0, 0, 0, 90000, 0)),
+					mp4Box("hdlr", fields(0, 0), []byte("vide"), fields(0, 0, 0), []byte{0}),
+				),
+			),
+			audioTrak(2,
+				// 312 samples of pre-skip, followed by 45 ms
+				mp4Box("edts", mp4Box("elst", fields(0, 1, 45, 312, 0x00010000))),
+				mp4Box("stts", fields(0, 1, 3, 960)),
+				mp4Box("stsc", fields(0, 1, 1, 2, 1)),
+				mp4Box("stsz", fields(0, 0, 3, 4, 4, 4)),
+				mp4Box("stco", fields(0, 2, dataStart, dataStart+2*4+3)),
+			),
+		)
+	}
+
+	if moovFirst {
+		dataStart := uint32(len(ftyp) + len(moov(0)) + 8)
+		return append(append(ftyp, moov(dataStart)...), mdat...)
+	}
+
+	dataStart := uint32(len(ftyp) + 8)
+	return append(append(ftyp, mdat...), moov(dataStart)...)
+}
+
+// testFragmentedMP4 builds a file with two fragments of two packets, the
+// second one continues the time of the first one
+func testFragmentedMP4() []byte {
+	file := mp4Box("ftyp", []byte("iso6"), fields(0), []byte("iso6opus"))
+	file = append(file, mp4Box("moov",
+		mp4Box("mvhd", fields(0, 0, 0, 1000, 0)),
+		audioTrak(1, nil,
+			mp4Box("stts", fields(0, 0)),
+			mp4Box("stsc", fields(0, 0)),
+			mp4Box("stsz", fields(0, 0, 0)),
+			mp4Box("stco", fields(0, 0)),
+		),
+		mp4Box("mvex", mp4Box("trex", fields(0, 1, 1, 960, 0, 0))),
+	)...)
+
+	moof := func(dataOffset uint32) []byte {
+		return mp4Box("moof",
+			mp4Box("mfhd", fields(0, 1)),
+			mp4Box("traf",
+				mp4Box("tfhd", fields(tfhdDefaultBaseIsMoof, 1)),
+				mp4Box("tfdt", fields(1<<24, 0, 0)),
+				mp4Box("trun", fields(trunDataOffset|trunSampleSize, 2, dataOffset, 4, 4)),
+			),
+		)
+	}
+	file = append(file, moof(uint32(len(moof(0))+8))...)
+	file = append(file, mp4Box("mdat", testPacket(0), testPacket(1))...)
+
+	moof = func(dataOffset uint32) []byte {
+		return mp4Box("moof",
+			mp4Box("mfhd", fields(0, 2)),
+			mp4Box("traf",
+				mp4Box("tfhd", fields(tfhdDefaultBaseIsMoof|tfhdDefaultSampleSize, 1, 4)),
+				mp4Box("trun", fields(trunDataOffset, 2, dataOffset)),
+			),
+		)
+	}
+	file = append(file, moof(uint32(len(moof(0))+8))...)
+
+	return append(file, mp4Box("mdat", testPacket(2), testPacket(3))...)
+}
+
+func readPackets(t *testing.T, in io.Reader) []Packet {
+	reader, header, err := NewWith(in)
+	switch {
+	case err != nil:
+		t.Fatal(err)
+	case !reflect.DeepEqual(header, testHeader()):
+		t.Fatalf("%+v", header)
+	}
+
+	var packets []Packet
+	for {
+		packet, err := reader.ReadPacket()
+		if errors.Is(err, io.EOF) {
+			return packets
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		packets = append(packets, packet)
+	}
+}
+
+func TestMP4Reader(t *testing.T) {
+	expected := []Packet{
+		{Data: testPacket(0), Timestamp: 0, Duration: 20 * time.Millisecond},
+		{Data: testPacket(1), Timestamp: 20 * time.Millisecond, Duration: 20 * time.Millisecond},
+		// The edit ends at 2472 samples, 408 samples before the end
+		{Data: testPacket(2), Timestamp: 40 * time.Millisecond, Duration: 20 * time.Millisecond, DiscardPadding: 8500 * time.Microsecond},
+	}
+
+	for _, in := range []io.Reader{
+		bytes.NewReader(testMP4(false)),
+		bytes.NewReader(testMP4(true)),
+		io.MultiReader(bytes.NewReader(testMP4(true))),
+	} {
+		if packets := readPackets(t, in); !reflect.DeepEqual(packets, expected) {
+			t.Fatalf("%+v", packets)
+		}
+	}
+
+	// The samples before the moov box can't be read without io.Seeker
+	reader, _, err := NewWith(io.MultiReader(bytes.NewReader(testMP4(false))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reader.ReadPacket(); !errors.Is(err, errNotSeekable) {
+		t.Fatal(err)
+	}
+}
+
+func TestMP4ReaderFragmented(t *testing.T) {
+	var expected []Packet
+	for i := 0; i < 4; i++ {
+		expected = append(expected, Packet{Data: testPacket(i), Timestamp: time.Duration(i) * 20 * time.Millisecond, Duration: 20 * time.Millisecond})
+	}
+
+	for _, in := range []io.Reader{
+		bytes.NewReader(testFragmentedMP4()),
+		io.MultiReader(bytes.NewReader(testFragmentedMP4())),
+	} {
+		if packets := readPackets(t, in); !reflect.DeepEqual(packets, expected) {
+			t.Fatalf("%+v", packets)
+		}
+	}
+}
+
+func TestMP4ReaderErrors(t *testing.T) {
+	if _, _, err := NewWith(nil); !errors.Is(err, errNilStream) {
+		t.Fatal(err)
+	}
+
+	ftyp := mp4Box("ftyp", []byte("isom"), fields(0))
+	if _, _, err := NewWith(bytes.NewReader(ftyp)); !errors.Is(err, errMissingMoov) {
+		t.Fatal(err)
+	}
+
+	moov := mp4Box("moov", mp4Box("mvhd", fields(0, 0, 0, 1000, 0)))
+	if _, _, err := NewWith(bytes.NewReader(append(ftyp, moov...))); !errors.Is(err, errNoOpusTrack) {
+		t.Fatal(err)
+	}
+
+	if _, err := parseDOps([]byte{1, 2, 0, 0, 0, 0, 0, 0, 0, 0, 0}); !errors.Is(err, errBadDOpsVersion) {
+		t.Fatal(err)
+	}
+
+	// The sample table has more samples than its chunks hold
+	moov = mp4Box("moov", audioTrak(1, nil,
+		mp4Box("stts", fields(0, 1, 3, 960)),
+		mp4Box("stsc", fields(0, 1, 1, 2, 1)),
+		mp4Box("stsz", fields(0, 4, 3)),
+		mp4Box("stco", fields(0, 1, 0)),
+	))
+	if _, _, err := NewWith(bytes.NewReader(moov)); !errors.Is(err, errBadSampleTable) {
+		t.Fatal(err)
+	}
+}
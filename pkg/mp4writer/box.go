@@ -0,0 +1,51 @@
+package mp4writer
+
+import "encoding/binary"
+
+// box encodes a box of a type with its payload
+//
+// https://www.iso.org/standard/83102.html, section 4.2
+func box(boxType string, payload ...[]byte) []byte {
+	b := make([]byte, 8)
+	copy(b[4:], boxType)
+	for _, p := range payload {
+		b = append(b, p...)
+	}
+	binary.BigEndian.PutUint32(b, uint32(len(b)))
+
+	return b
+}
+
+// fullBox encodes a box whose payload begins with a version and 24 bits of
+// flags
+func fullBox(boxType string, version uint8, flags uint32, payload ...[]byte) []byte {
+	return box(boxType, append([][]byte{uint32Field(uint32(version)<<24 | flags)}, payload...)...)
+}
+
+func uint16Field(v uint16) []byte {
+	b := make([]byte, 2)
+	binary.BigEndian.PutUint16(b, v)
+	return b
+}
+
+func uint32Field(v uint32) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, v)
+	return b
+}
+
+func uint64Field(v uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, v)
+	return b
+}
+
+// uint32Fields encodes a sequence of 32-bit fields
+func uint32Fields(values ...uint32) []byte {
+	b := make([]byte, 0, 4*len(values))
+	for _, v := range values {
+		b = append(b, uint32Field(v)...)
+	}
+
+	return b
+}
@@ -0,0 +1,9 @@
+package mp4writer
+
+import "errors"
+
+var (
+	errNilHeader           = errors.New("header is nil")
+	errInvalidChannelCount = errors.New("channel count must not be 0")
+	errWriterClosed        = errors.New("writer is closed")
+)
@@ -0,0 +1,257 @@
+// Package mp4writer implements a fragmented MP4 muxer for Opus audio, which
+// writes the CMAF init and media segments that HLS and DASH players load
+//
+// https://opus-codec.org/docs/opus_in_isobmff.html
+package mp4writer
+
+import (
+	"io"
+	"time"
+
+	"github.com/pion/opus"
+	"github.com/pion/opus/pkg/oggreader"
+)
+
+const (
+	trackID = 1
+
+	// The media timescale is the 48 kHz sample rate of Opus, so every
+	// packet duration is a whole number of ticks
+	timescale = 48000
+
+	defaultFragmentDuration = 2 * time.Second
+
+	// Flags of the tkhd, tfhd and trun boxes
+	//
+	// https://www.iso.org/standard/83102.html, sections 8.3.2, 8.8.7 and
+	// 8.8.8
+	tkhdTrackEnabled      = 0x000001
+	tkhdTrackInMovie      = 0x000002
+	tfhdDefaultBaseIsMoof = 0x020000
+	trunDataOffset        = 0x000001
+	trunSampleDuration    = 0x000100
+	trunSampleSize        = 0x000200
+
+	// A dref entry with this flag refers to the file itself
+	drefSelfContained = 0x000001
+
+	// The packed ISO 639-2 code of an undetermined language
+	languageUndetermined = 0x55C4
+
+	// 16.16 fixed point values of 1
+	fixedOne16 = 0x00010000
+	fixedOne8  = 0x0100
+)
+
+// The unity matrix of the mvhd and tkhd boxes
+var unityMatrix = uint32Fields(fixedOne16, 0, 0, 0, fixedOne16, 0, 0, 0, 0x40000000)
+
+// Options configure an MP4Writer
+type Options struct {
+	// FragmentDuration is the duration after which the packets written so
+	// far are written as a fragment. It defaults to 2 seconds.
+	FragmentDuration time.Duration
+}
+
+// MP4Writer writes Opus packets to a fragmented MP4 file with a single audio
+// track. The init segment and every fragment are written with a single
+// call of Write of the output, so they can be stored as separate segments.
+type MP4Writer struct {
+	out              io.Writer
+	fragmentDuration uint64
+
+	// The samples of the fragment being collected, and the decode time of
+	// its first sample
+	data      []byte
+	sizes     []uint32
+	durations []uint32
+	duration  uint64
+	startTime uint64
+
+	sequenceNumber uint32
+	closed         bool
+}
+
+// NewWith writes the init segment of a track described by header to out,
+// and returns an MP4Writer for its fragments. The PreSkip of the header is
+// applied by an edit list.
+func NewWith(out io.Writer, header *oggreader.OggHeader, options Options) (*MP4Writer, error) {
+	if header == nil {
+		return nil, errNilHeader
+	}
+	if header.Channels == 0 {
+		return nil, errInvalidChannelCount
+	}
+
+	if options.FragmentDuration <= 0 {
+		options.FragmentDuration = defaultFragmentDuration
+	}
+
+	w := &MP4Writer{
+		out:              out,
+		fragmentDuration: uint64(options.FragmentDuration * timescale / time.Second),
+	}
+	if _, err := out.Write(initSegment(header)); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// WritePacket adds an Opus packet to the current fragment, and writes the
+// fragment once it reaches the fragment duration. The duration of the
+// sample is the one given by the TOC of the packet.
+func (w *MP4Writer) WritePacket(packet []byte) error {
+	if w.closed {
+		return errWriterClosed
+	}
+
+	parsed, err := opus.ParsePacket(packet)
+	if err != nil {
+		return err
+	}
+	duration := uint32(parsed.Duration() * timescale / time.Second)
+
+	w.data = append(w.data, packet...)
+	w.sizes = append(w.sizes, uint32(len(packet)))
+	w.durations = append(w.durations, duration)
+	w.duration += uint64(duration)
+
+	if w.duration >= w.fragmentDuration {
+		return w.Flush()
+	}
+
+	return nil
+}
+
+// Flush writes the packets added since the last fragment as a fragment
+func (w *MP4Writer) Flush() error {
+	if len(w.sizes) == 0 {
+		return nil
+	}
+
+	w.sequenceNumber++
+	fragment := w.fragment()
+
+	w.startTime += w.duration
+	w.data, w.sizes, w.durations, w.duration = w.data[:0], w.sizes[:0], w.durations[:0], 0
+
+	_, err := w.out.Write(fragment)
+	return err
+}
+
+// Close writes the last fragment. It doesn't close the output.
+func (w *MP4Writer) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	return w.Flush()
+}
+
+// fragment returns the moof and mdat boxes of the collected samples
+//
+// https://www.iso.org/standard/83102.html, section 8.8
+func (w *MP4Writer) fragment() []byte {
+	moof := func(dataOffset uint32) []byte {
+		samples := make([]uint32, 0, 2*len(w.sizes))
+		for i, size := range w.sizes {
+			samples = append(samples, w.durations[i], size)
+		}
+
+		return box("moof",
+			fullBox("mfhd", 0, 0, uint32Field(w.sequenceNumber)),
+			box("traf",
+				fullBox("tfhd", 0, tfhdDefaultBaseIsMoof, uint32Field(trackID)),
+				fullBox("tfdt", 1, 0, uint64Field(w.startTime)),
+				fullBox("trun", 0, trunDataOffset|trunSampleDuration|trunSampleSize,
+					uint32Field(uint32(len(w.sizes))),
+					uint32Field(dataOffset),
+					uint32Fields(samples...),
+				),
+			),
+		)
+	}
+
+	// The data offset is relative to the moof box, the samples follow the
+	// header of the mdat box
+	fragment := moof(uint32(len(moof(0)) + 8))
+	return append(fragment, box("mdat", w.data)...)
+}
+
+// initSegment returns the ftyp and moov boxes
+func initSegment(header *oggreader.OggHeader) []byte {
+	ftyp := box("ftyp", []byte("iso6"), uint32Field(0), []byte("iso6cmfcmp41"))
+
+	mvhd := fullBox("mvhd", 0, 0,
+		uint32Fields(0, 0, timescale, 0),
+		uint32Field(fixedOne16),
+		uint16Field(fixedOne8),
+		make([]byte, 10),
+		unityMatrix,
+		make([]byte, 24),
+		uint32Field(trackID+1),
+	)
+
+	tkhd := fullBox("tkhd", 0, tkhdTrackEnabled|tkhdTrackInMovie,
+		uint32Fields(0, 0, trackID, 0, 0, 0, 0),
+		uint16Field(0), // layer
+		uint16Field(0), // alternate_group
+		uint16Field(fixedOne8),
+		uint16Field(0),
+		unityMatrix,
+		uint32Fields(0, 0),
+	)
+
+	// The edit skips the pre-skip, its duration of 0 spans every fragment
+	//
+	// https://opus-codec.org/docs/opus_in_isobmff.html#4.4
+	edts := box("edts", fullBox("elst", 0, 0, uint32Fields(1, 0, uint32(header.PreSkip), fixedOne16)))
+
+	mdia := box("mdia",
+		fullBox("mdhd", 0, 0, uint32Fields(0, 0, timescale, 0), uint16Field(languageUndetermined), uint16Field(0)),
+		fullBox("hdlr", 0, 0, uint32Field(0), []byte("soun"), uint32Fields(0, 0, 0), []byte("SoundHandler\x00")),
+		box("minf",
+			fullBox("smhd", 0, 0, uint32Field(0)),
+			box("dinf", fullBox("dref", 0, 0, uint32Field(1), fullBox("url ", 0, drefSelfContained))),
+			box("stbl",
+				fullBox("stsd", 0, 0, uint32Field(1), opusSampleEntry(header)),
+				fullBox("stts", 0, 0, uint32Field(0)),
+				fullBox("stsc", 0, 0, uint32Field(0)),
+				fullBox("stsz", 0, 0, uint32Fields(0, 0)),
+				fullBox("stco", 0, 0, uint32Field(0)),
+			),
+		),
+	)
+
+	mvex := box("mvex", fullBox("trex", 0, 0, uint32Fields(trackID, 1, 0, 0, 0)))
+
+	return append(ftyp, box("moov", mvhd, box("trak", tkhd, edts, mdia), mvex)...)
+}
+
+// opusSampleEntry returns the Opus sample entry with its dOps box, which
+// holds the fields of the Ogg ID header in big-endian order
+//
+// https://opus-codec.org/docs/opus_in_isobmff.html#4.3
+func opusSampleEntry(header *oggreader.OggHeader) []byte {
+	head := oggreader.MarshalIDHeader(header)
+	dOps := []byte{0, header.Channels}
+	dOps = append(dOps, uint16Field(header.PreSkip)...)
+	dOps = append(dOps, uint32Field(header.SampleRate)...)
+	dOps = append(dOps, uint16Field(header.OutputGain)...)
+
+	// The channel mapping family and table are the same
+	dOps = append(dOps, head[18:]...)
+
+	return box("Opus",
+		make([]byte, 6),
+		uint16Field(1), // data_reference_index
+		make([]byte, 8),
+		uint16Field(uint16(header.Channels)),
+		uint16Field(16), // samplesize
+		make([]byte, 4),
+		uint32Field(timescale<<16),
+		box("dOps", dOps),
+	)
+}
@@ -0,0 +1,109 @@
+package mp4writer
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pion/opus/pkg/mp4reader"
+	"github.com/pion/opus/pkg/oggreader"
+)
+
+// A 20 ms and a 60 ms wideband SILK-only packet
+var (
+	testPacket   = []byte{0x48, 0x0B, 0xE4, 0xC1, 0x36, 0xEC, 0xC5, 0x80}
+	testPacket60 = []byte{0x58, 0x0B, 0xE4, 0xC1, 0x36, 0xEC, 0xC5, 0x80}
+)
+
+// segments records every call of Write as a segment
+type segments [][]byte
+
+func (s *segments) Write(p []byte) (int, error) {
+	*s = append(*s, append([]byte{}, p...))
+	return len(p), nil
+}
+
+func TestMP4Writer(t *testing.T) {
+	header := &oggreader.OggHeader{
+		Version: 1, Channels: 6, PreSkip: 312, SampleRate: 48000, OutputGain: 0x0100,
+		ChannelMap: 1, StreamCount: 4, CoupledCount: 2, ChannelMapping: []uint8{0, 4, 1, 2, 3, 5},
+	}
+
+	var out segments
+	writer, err := NewWith(&out, header, Options{FragmentDuration: 100 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var packets [][]byte
+	for i := 0; i < 10; i++ {
+		packets = append(packets, testPacket)
+	}
+	packets = append(packets, testPacket60, testPacket)
+	for _, packet := range packets {
+		if err := writer.WritePacket(packet); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.WritePacket(testPacket); !errors.Is(err, errWriterClosed) {
+		t.Fatal(err)
+	}
+
+	// The init segment is followed by fragments of 100 ms, and the last
+	// one of 80 ms
+	if len(out) != 4 {
+		t.Fatal(len(out))
+	}
+
+	reader, readHeader, err := mp4reader.NewWith(bytes.NewReader(bytes.Join(out, nil)))
+	switch {
+	case err != nil:
+		t.Fatal(err)
+	case !reflect.DeepEqual(readHeader, header):
+		t.Fatalf("%+v", readHeader)
+	}
+
+	var timestamp time.Duration
+	for i, expected := range packets {
+		packet, err := reader.ReadPacket()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		duration := 20 * time.Millisecond
+		if i == 10 {
+			duration = 60 * time.Millisecond
+		}
+		if !reflect.DeepEqual(packet, mp4reader.Packet{Data: expected, Timestamp: timestamp, Duration: duration}) {
+			t.Fatalf("%d: %+v", i, packet)
+		}
+		timestamp += duration
+	}
+
+	if _, err := reader.ReadPacket(); !errors.Is(err, io.EOF) {
+		t.Fatal(err)
+	}
+}
+
+func TestMP4WriterErrors(t *testing.T) {
+	if _, err := NewWith(io.Discard, nil, Options{}); !errors.Is(err, errNilHeader) {
+		t.Fatal(err)
+	}
+	if _, err := NewWith(io.Discard, &oggreader.OggHeader{}, Options{}); !errors.Is(err, errInvalidChannelCount) {
+		t.Fatal(err)
+	}
+
+	writer, err := NewWith(io.Discard, &oggreader.OggHeader{Version: 1, Channels: 1}, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.WritePacket(nil); err == nil {
+		t.Fatal("empty packet accepted")
+	}
+}
@@ -8,23 +8,56 @@ import (
 )
 
 const (
+	pageHeaderTypeContinuedPacket   = 0x01
 	pageHeaderTypeBeginningOfStream = 0x02
+	pageHeaderTypeEndOfStream       = 0x04
 	pageHeaderSignature             = "OggS"
 
 	idPageSignature = "OpusHead"
 
 	pageHeaderLen       = 27
+	maxSegmentCount     = 255
 	idPagePayloadLength = 19
+
+	// The channel mapping table is present for every family other than 0,
+	// it begins with the stream count and coupled stream count.
+	channelMappingTableHeaderLength = 2
+
+	maxAmbisonicsOrder            = 14
+	nonDiegeticStereoChannelCount = 2
+)
+
+// The channel mapping families of the ID header
+//
+// https://datatracker.ietf.org/doc/html/rfc7845#section-5.1.1
+// https://datatracker.ietf.org/doc/html/rfc8486#section-3
+const (
+	ChannelMappingFamilyRTP        = 0
+	ChannelMappingFamilyVorbis     = 1
+	ChannelMappingFamilyAmbisonics = 2
+	ChannelMappingFamilyProjection = 3
+	ChannelMappingFamilyUndefined  = 255
+
+	// SilentChannel in the channel mapping marks a channel that is silent
+	SilentChannel = 255
 )
 
 var (
 	errNilStream                 = errors.New("stream is nil")
 	errBadIDPageSignature        = errors.New("bad header signature")
 	errBadIDPageType             = errors.New("wrong header, expected beginning of stream")
-	errBadIDPageLength           = errors.New("payload for id page must be 19 bytes")
+	errBadIDPageLength           = errors.New("payload for id page must be at least 19 bytes")
 	errBadIDPagePayloadSignature = errors.New("bad payload signature")
+	errBadChannelMappingTable    = errors.New("channel mapping table is too short")
+	errBadChannelCount           = errors.New("channel count is invalid for channel mapping family")
+	errBadStreamCount            = errors.New("stream count is invalid for channel count")
+	errBadChannelMapping         = errors.New("channel mapping refers to a stream that doesn't exist")
 	errShortPageHeader           = errors.New("not enough data for payload header")
-	errChecksumMismatch          = errors.New("expected and actual checksum do not match")
+
+	// ErrChecksumMismatch is returned by ParseNextPage when the CRC of a
+	// page doesn't match its contents. The page has been consumed, reading
+	// can continue with the next page.
+	ErrChecksumMismatch = errors.New("expected and actual checksum do not match")
 )
 
 // OggReader is used to read Ogg files and return page payloads
@@ -33,6 +66,14 @@ type OggReader struct {
 	bytesReadSuccesfully int64
 	checksumTable        *[256]uint32
 	doChecksum           bool
+
+	// Buffers reused for every page, so reading a stream doesn't
+	// allocate once they have grown to the largest page.
+	header     [pageHeaderLen]byte
+	sizeBuffer [maxSegmentCount]byte
+	payload    []byte
+	segments   [][]byte
+	pageHeader OggPageHeader
 }
 
 // OggHeader is the metadata from the first two pages
@@ -46,6 +87,26 @@ type OggHeader struct {
 	PreSkip    uint16
 	SampleRate uint32
 	Version    uint8
+
+	// StreamCount and CoupledCount are only present when ChannelMap
+	// is not 0. Every coupled stream decodes to two channels, every
+	// other stream decodes to one.
+	//
+	// https://datatracker.ietf.org/doc/html/rfc7845#section-5.1.1
+	StreamCount  uint8
+	CoupledCount uint8
+
+	// ChannelMapping maps every output channel to a decoded channel,
+	// 255 marks a silent channel. It is set for every family other
+	// than 0 and 3.
+	ChannelMapping []uint8
+
+	// DemixingMatrix is only set for channel mapping family 3. It holds
+	// Channels rows and (StreamCount + CoupledCount) columns of Q15
+	// coefficients in column-major order.
+	//
+	// https://datatracker.ietf.org/doc/html/rfc8486#section-3.2
+	DemixingMatrix []int16
 }
 
 // OggPageHeader is the metadata for a Page
@@ -63,6 +124,35 @@ type OggPageHeader struct {
 	segmentsCount uint8
 }
 
+// Serial returns the serial number of the logical bitstream of the page
+func (p *OggPageHeader) Serial() uint32 {
+	return p.serial
+}
+
+// PageSequence returns the sequence number of the page, it increases by one
+// for every page of a logical bitstream.
+func (p *OggPageHeader) PageSequence() uint32 {
+	return p.index
+}
+
+// IsContinuation reports if the first segment of the page continues a
+// packet of the previous page
+func (p *OggPageHeader) IsContinuation() bool {
+	return p.headerType&pageHeaderTypeContinuedPacket != 0
+}
+
+// IsBeginningOfStream reports if the page is the first page of its logical
+// bitstream
+func (p *OggPageHeader) IsBeginningOfStream() bool {
+	return p.headerType&pageHeaderTypeBeginningOfStream != 0
+}
+
+// IsEndOfStream reports if the page is the last page of its logical
+// bitstream
+func (p *OggPageHeader) IsEndOfStream() bool {
+	return p.headerType&pageHeaderTypeEndOfStream != 0
+}
+
 // NewWith returns a new Ogg reader and Ogg header
 // with an io.Reader input
 func NewWith(in io.Reader) (*OggReader, *OggHeader, error) {
@@ -94,7 +184,6 @@ func (o *OggReader) readHeaders() (*OggHeader, error) {
 		return nil, err
 	}
 
-	header := &OggHeader{}
 	if string(pageHeader.sig[:]) != pageHeaderSignature {
 		return nil, errBadIDPageSignature
 	}
@@ -103,28 +192,195 @@ func (o *OggReader) readHeaders() (*OggHeader, error) {
 		return nil, errBadIDPageType
 	}
 
-	if len(segments[0]) != idPagePayloadLength {
+	if len(segments) == 0 {
+		return nil, errBadIDPageLength
+	}
+
+	return ParseIDHeader(segments[0])
+}
+
+// ParseIDHeader parses the Opus ID header, the payload of the first page
+// of an Ogg Opus stream. Other containers carry the same header, like the
+// CodecPrivate data of Matroska and WebM.
+//
+// https://datatracker.ietf.org/doc/html/rfc7845#section-5.1
+func ParseIDHeader(payload []byte) (*OggHeader, error) {
+	if len(payload) < idPagePayloadLength {
 		return nil, errBadIDPageLength
 	}
 
-	if s := string(segments[0][:8]); s != idPageSignature {
+	if s := string(payload[:8]); s != idPageSignature {
 		return nil, errBadIDPagePayloadSignature
 	}
 
-	header.Version = segments[0][8]
-	header.Channels = segments[0][9]
-	header.PreSkip = binary.LittleEndian.Uint16(segments[0][10:12])
-	header.SampleRate = binary.LittleEndian.Uint32(segments[0][12:16])
-	header.OutputGain = binary.LittleEndian.Uint16(segments[0][16:18])
-	header.ChannelMap = segments[0][18]
+	header := &OggHeader{}
+	header.Version = payload[8]
+	header.Channels = payload[9]
+	header.PreSkip = binary.LittleEndian.Uint16(payload[10:12])
+	header.SampleRate = binary.LittleEndian.Uint32(payload[12:16])
+	header.OutputGain = binary.LittleEndian.Uint16(payload[16:18])
+	header.ChannelMap = payload[18]
+
+	if header.ChannelMap != ChannelMappingFamilyRTP {
+		if err := parseChannelMappingTable(header, payload[idPagePayloadLength:]); err != nil {
+			return nil, err
+		}
+	}
 
 	return header, nil
 }
 
+// MarshalIDHeader encodes an Opus ID header, the reverse of ParseIDHeader.
+// The channel mapping table is only written for families other than 0.
+func MarshalIDHeader(header *OggHeader) []byte {
+	payload := make([]byte, idPagePayloadLength)
+	copy(payload, idPageSignature)
+	payload[8] = header.Version
+	payload[9] = header.Channels
+	binary.LittleEndian.PutUint16(payload[10:12], header.PreSkip)
+	binary.LittleEndian.PutUint32(payload[12:16], header.SampleRate)
+	binary.LittleEndian.PutUint16(payload[16:18], header.OutputGain)
+	payload[18] = header.ChannelMap
+
+	if header.ChannelMap == ChannelMappingFamilyRTP {
+		return payload
+	}
+
+	payload = append(payload, header.StreamCount, header.CoupledCount)
+	if header.ChannelMap != ChannelMappingFamilyProjection {
+		return append(payload, header.ChannelMapping...)
+	}
+
+	for _, coefficient := range header.DemixingMatrix {
+		payload = append(payload, byte(coefficient), byte(uint16(coefficient)>>8))
+	}
+
+	return payload
+}
+
+// parseChannelMappingTable reads the optional channel mapping table that
+// follows the ID header for every channel mapping family other than 0.
+//
+//	 0                   1                   2                   3
+//	 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1
+//	+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+//	|  Stream Count | Coupled Count |              Channel         :
+//	+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+                              :
+//	|                        Mapping...                            |
+//	+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+//
+// Channel mapping family 3 replaces the channel mapping with a demixing
+// matrix of 2*C*(N+M) bytes.
+//
+// https://datatracker.ietf.org/doc/html/rfc7845#section-5.1.1
+// https://datatracker.ietf.org/doc/html/rfc8486#section-3
+func parseChannelMappingTable(header *OggHeader, table []byte) error {
+	if len(table) < channelMappingTableHeaderLength {
+		return errBadChannelMappingTable
+	}
+
+	header.StreamCount = table[0]
+	header.CoupledCount = table[1]
+	table = table[channelMappingTableHeaderLength:]
+
+	if header.StreamCount == 0 || header.CoupledCount > header.StreamCount ||
+		int(header.StreamCount)+int(header.CoupledCount) > 255 {
+		return errBadStreamCount
+	}
+
+	switch header.ChannelMap {
+	case ChannelMappingFamilyAmbisonics, ChannelMappingFamilyProjection:
+		if _, _, ok := AmbisonicsOrder(int(header.Channels)); !ok {
+			return errBadChannelCount
+		}
+	case ChannelMappingFamilyVorbis:
+		if header.Channels == 0 || header.Channels > 8 {
+			return errBadChannelCount
+		}
+	}
+
+	if header.ChannelMap == ChannelMappingFamilyProjection {
+		columns := int(header.StreamCount) + int(header.CoupledCount)
+		matrixSize := int(header.Channels) * columns
+		if len(table) < matrixSize*2 {
+			return errBadChannelMappingTable
+		}
+
+		header.DemixingMatrix = make([]int16, matrixSize)
+		for i := range header.DemixingMatrix {
+			header.DemixingMatrix[i] = int16(binary.LittleEndian.Uint16(table[i*2:]))
+		}
+
+		return nil
+	}
+
+	if len(table) < int(header.Channels) {
+		return errBadChannelMappingTable
+	}
+
+	// Each entry indexes the N+M decoded channels of the streams, or is 255
+	// for a silent channel
+	for _, index := range table[:header.Channels] {
+		if index != SilentChannel &&
+			int(index) >= int(header.StreamCount)+int(header.CoupledCount) {
+			return errBadChannelMapping
+		}
+	}
+
+	header.ChannelMapping = append([]uint8{}, table[:header.Channels]...)
+	return nil
+}
+
+// AmbisonicsOrder returns the ambisonic order of the channels of channel
+// mapping families 2 and 3, and if they are followed by a non-diegetic
+// stereo pair. Ambisonics streams carry (1 + n)^2 channels for an order n
+// between 0 and 14, optionally followed by the stereo pair. ok is false for
+// every other channel count.
+//
+// https://datatracker.ietf.org/doc/html/rfc8486#section-3.1
+func AmbisonicsOrder(channels int) (order int, hasNonDiegeticStereo bool, ok bool) {
+	for order = 0; order <= maxAmbisonicsOrder; order++ {
+		switch channels {
+		case (order + 1) * (order + 1):
+			return order, false, true
+		case (order+1)*(order+1) + nonDiegeticStereoChannelCount:
+			return order, true, true
+		}
+	}
+
+	return 0, false, false
+}
+
 // ParseNextPage reads from stream and returns Ogg page segments, header,
 // and an error if there is incomplete page data.
 func (o *OggReader) ParseNextPage() ([][]byte, *OggPageHeader, error) {
-	h := make([]byte, pageHeaderLen)
+	segments, pageHeader, err := o.ParseNextPageNoCopy()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	payloadSize := 0
+	for _, segment := range segments {
+		payloadSize += len(segment)
+	}
+
+	payload := make([]byte, 0, payloadSize)
+	copied := make([][]byte, len(segments))
+	for i, segment := range segments {
+		payload = append(payload, segment...)
+		copied[i] = payload[len(payload)-len(segment) : len(payload) : len(payload)]
+	}
+
+	headerCopy := *pageHeader
+	return copied, &headerCopy, nil
+}
+
+// ParseNextPageNoCopy is like ParseNextPage, but returns segments and a
+// header that are reused by the next call, so reading a stream doesn't
+// allocate once the buffers have grown to the largest page. Copy them to
+// keep them around for longer.
+func (o *OggReader) ParseNextPageNoCopy() ([][]byte, *OggPageHeader, error) {
+	h := o.header[:]
 
 	n, err := io.ReadFull(o.stream, h)
 	if err != nil {
@@ -133,9 +389,8 @@ func (o *OggReader) ParseNextPage() ([][]byte, *OggPageHeader, error) {
 		return nil, nil, errShortPageHeader
 	}
 
-	pageHeader := &OggPageHeader{
-		sig: [4]byte{h[0], h[1], h[2], h[3]},
-	}
+	pageHeader := &o.pageHeader
+	pageHeader.sig = [4]byte{h[0], h[1], h[2], h[3]}
 
 	pageHeader.version = h[4]
 	pageHeader.headerType = h[5]
@@ -144,21 +399,30 @@ func (o *OggReader) ParseNextPage() ([][]byte, *OggPageHeader, error) {
 	pageHeader.index = binary.LittleEndian.Uint32(h[18 : 18+4])
 	pageHeader.segmentsCount = h[26]
 
-	sizeBuffer := make([]byte, pageHeader.segmentsCount)
+	sizeBuffer := o.sizeBuffer[:pageHeader.segmentsCount]
 	if _, err = io.ReadFull(o.stream, sizeBuffer); err != nil {
 		return nil, nil, err
 	}
 
-	segments := [][]byte{}
-
+	payloadSize := 0
 	for _, s := range sizeBuffer {
-		segment := make([]byte, int(s))
-		if _, err = io.ReadFull(o.stream, segment); err != nil {
-			return nil, nil, err
-		}
+		payloadSize += int(s)
+	}
 
-		segments = append(segments, segment)
+	if cap(o.payload) < payloadSize {
+		o.payload = make([]byte, payloadSize)
+	}
+	payload := o.payload[:payloadSize]
+	if _, err = io.ReadFull(o.stream, payload); err != nil {
+		return nil, nil, err
+	}
+
+	segments := o.segments[:0]
+	for _, s := range sizeBuffer {
+		segments = append(segments, payload[:s:s])
+		payload = payload[s:]
 	}
+	o.segments = segments
 
 	if o.doChecksum {
 		var checksum uint32
@@ -186,7 +450,7 @@ func (o *OggReader) ParseNextPage() ([][]byte, *OggPageHeader, error) {
 		}
 
 		if binary.LittleEndian.Uint32(h[22:22+4]) != checksum {
-			return nil, nil, errChecksumMismatch
+			return nil, nil, ErrChecksumMismatch
 		}
 	}
 
@@ -47,6 +47,125 @@ func TestOggReader_ParseValidHeader(t *testing.T) {
 	}
 }
 
+// buildOggContainerWithIDPayload generates an Ogg page containing only
+// the provided ID header payload. The checksum is not set.
+func buildOggContainerWithIDPayload(payload []byte) []byte {
+	page := []byte{
+		0x4f, 0x67, 0x67, 0x53, 0x00, 0x02, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x8e, 0x9b, 0x20, 0xaa, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01, byte(len(payload)),
+	}
+
+	return append(page, payload...)
+}
+
+func TestOggReader_ParseChannelMappingTable(t *testing.T) {
+	idPayload := func(channels, channelMap byte, table ...byte) []byte {
+		return append([]byte{
+			0x4f, 0x70, 0x75, 0x73, 0x48, 0x65, 0x61, 0x64, 0x01, channels,
+			0x38, 0x01, 0x80, 0xbb, 0x00, 0x00, 0x00, 0x00, channelMap,
+		}, table...)
+	}
+
+	t.Run("Ambisonics", func(t *testing.T) {
+		ogg := buildOggContainerWithIDPayload(idPayload(6, 2, 4, 2, 0, 1, 2, 3, 4, 5))
+
+		_, header, err := newWith(bytes.NewReader(ogg), false)
+		switch {
+		case err != nil:
+			t.Fatal(err)
+		case header.StreamCount != 4:
+			t.Fatal()
+		case header.CoupledCount != 2:
+			t.Fatal()
+		case !reflect.DeepEqual(header.ChannelMapping, []uint8{0, 1, 2, 3, 4, 5}):
+			t.Fatal()
+		case header.DemixingMatrix != nil:
+			t.Fatal()
+		}
+	})
+
+	t.Run("Projection", func(t *testing.T) {
+		ogg := buildOggContainerWithIDPayload(idPayload(4, 3, 2, 2,
+			0xff, 0x7f, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0xff, 0x7f, 0x00, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00, 0xff, 0x7f, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x80,
+		))
+
+		_, header, err := newWith(bytes.NewReader(ogg), false)
+		switch {
+		case err != nil:
+			t.Fatal(err)
+		case header.ChannelMapping != nil:
+			t.Fatal()
+		case !reflect.DeepEqual(header.DemixingMatrix, []int16{
+			32767, 0, 0, 0,
+			0, 32767, 0, 0,
+			0, 0, 32767, 0,
+			0, 0, 0, -32768,
+		}):
+			t.Fatal()
+		}
+	})
+
+	t.Run("Invalid Ambisonics Channel Count", func(t *testing.T) {
+		ogg := buildOggContainerWithIDPayload(idPayload(5, 2, 5, 0, 0, 1, 2, 3, 4))
+
+		_, _, err := newWith(bytes.NewReader(ogg), false)
+		if !errors.Is(err, errBadChannelCount) {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("Truncated Demixing Matrix", func(t *testing.T) {
+		ogg := buildOggContainerWithIDPayload(idPayload(4, 3, 2, 2, 0xff, 0x7f))
+
+		_, _, err := newWith(bytes.NewReader(ogg), false)
+		if !errors.Is(err, errBadChannelMappingTable) {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("Invalid Stream Count", func(t *testing.T) {
+		ogg := buildOggContainerWithIDPayload(idPayload(4, 2, 1, 2, 0, 1, 2, 3))
+
+		_, _, err := newWith(bytes.NewReader(ogg), false)
+		if !errors.Is(err, errBadStreamCount) {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("Invalid Channel Mapping", func(t *testing.T) {
+		ogg := buildOggContainerWithIDPayload(idPayload(3, 1, 2, 0, 0, 255, 2))
+
+		_, _, err := newWith(bytes.NewReader(ogg), false)
+		if !errors.Is(err, errBadChannelMapping) {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestMarshalIDHeader(t *testing.T) {
+	for _, header := range []*OggHeader{
+		{Version: 1, Channels: 2, PreSkip: 312, SampleRate: 48000, OutputGain: 0x0605},
+		{Version: 1, Channels: 6, ChannelMap: 1, StreamCount: 4, CoupledCount: 2, ChannelMapping: []uint8{0, 4, 1, 2, 3, 5}},
+		{Version: 1, Channels: 4, ChannelMap: 3, StreamCount: 2, CoupledCount: 2, DemixingMatrix: []int16{
+			32767, 0, 0, 0,
+			0, 32767, 0, 0,
+			0, 0, 32767, 0,
+			0, 0, 0, -32768,
+		}},
+	} {
+		parsed, err := ParseIDHeader(MarshalIDHeader(header))
+		if err != nil {
+			t.Fatal(err)
+		} else if !reflect.DeepEqual(parsed, header) {
+			t.Fatalf("%+v != %+v", parsed, header)
+		}
+	}
+}
+
 func TestOggReader_ParseNextPage(t *testing.T) {
 	ogg := bytes.NewReader(buildOggContainer())
 	reader, _, err := NewWith(ogg)
@@ -57,11 +176,17 @@ func TestOggReader_ParseNextPage(t *testing.T) {
 		t.Fatal()
 	}
 
-	payload, _, err := reader.ParseNextPage()
+	payload, pageHeader, err := reader.ParseNextPage()
 	switch {
 	case err != nil:
 		t.Fatal()
-	case !reflect.DeepEqual([]byte{0x98, 0x36, 0xbe, 0x88, 0x9e}, payload):
+	case !reflect.DeepEqual([][]byte{{0x98, 0x36, 0xbe, 0x88, 0x9e}}, payload):
+		t.Fatal()
+	case pageHeader.Serial() != 0xaa209b8e:
+		t.Fatal()
+	case pageHeader.PageSequence() != 2:
+		t.Fatal()
+	case pageHeader.IsContinuation() || pageHeader.IsBeginningOfStream() || pageHeader.IsEndOfStream():
 		t.Fatal()
 	}
 
@@ -71,6 +196,64 @@ func TestOggReader_ParseNextPage(t *testing.T) {
 	}
 }
 
+// loopingReader repeats data forever
+type loopingReader struct {
+	data   []byte
+	offset int
+}
+
+func (l *loopingReader) Read(p []byte) (int, error) {
+	n := copy(p, l.data[l.offset:])
+	l.offset = (l.offset + n) % len(l.data)
+	return n, nil
+}
+
+func TestOggReader_ParseNextPageCopies(t *testing.T) {
+	ogg := buildOggContainer()
+	reader, _, err := NewWith(io.MultiReader(bytes.NewReader(ogg), &loopingReader{data: ogg[47:]}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	segments, pageHeader, err := reader.ParseNextPage()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The results of ParseNextPage don't share the buffers the next call
+	// reads into
+	shared, sharedHeader, err := reader.ParseNextPageNoCopy()
+	switch {
+	case err != nil:
+		t.Fatal(err)
+	case &segments[0][0] == &shared[0][0] || pageHeader == sharedHeader:
+		t.Fatal("page shares the buffers of the reader")
+	case !reflect.DeepEqual(segments, shared) || *pageHeader != *sharedHeader:
+		t.Fatal("page was copied incorrectly")
+	}
+}
+
+func TestOggReader_ParseNextPageNoCopyAllocs(t *testing.T) {
+	ogg := buildOggContainer()
+	reader, _, err := NewWith(io.MultiReader(bytes.NewReader(ogg), &loopingReader{data: ogg[47:]}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parse := func() {
+		if _, _, err := reader.ParseNextPageNoCopy(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The first page sizes the buffers of the reader
+	parse()
+
+	if allocs := testing.AllocsPerRun(100, parse); allocs != 0 {
+		t.Fatalf("ParseNextPageNoCopy allocated %f times per run", allocs)
+	}
+}
+
 func TestOggReader_ParseErrors(t *testing.T) {
 	t.Run("Assert that Reader isn't nil", func(t *testing.T) {
 		_, _, err := NewWith(nil)
@@ -124,8 +307,30 @@ func TestOggReader_ParseErrors(t *testing.T) {
 		ogg[22] = 0
 
 		_, _, err := NewWith(bytes.NewReader(ogg))
-		if !errors.Is(err, errChecksumMismatch) {
+		if !errors.Is(err, ErrChecksumMismatch) {
 			t.Fatal()
 		}
 	})
 }
+
+func FuzzOggReader(f *testing.F) {
+	f.Add(buildOggContainer(), true)
+	f.Add(buildOggContainerWithIDPayload([]byte{
+		0x4f, 0x70, 0x75, 0x73, 0x48, 0x65, 0x61, 0x64, 0x01, 0x04, 0x00, 0x0f,
+		0x80, 0xbb, 0x00, 0x00, 0x00, 0x00, 0x03, 0x02, 0x02,
+	}), false)
+	f.Add([]byte("OggS"), false)
+
+	f.Fuzz(func(t *testing.T, in []byte, doChecksum bool) {
+		reader, _, err := newWith(bytes.NewReader(in), doChecksum)
+		if err != nil {
+			return
+		}
+
+		for {
+			if _, _, err := reader.ParseNextPage(); err != nil {
+				return
+			}
+		}
+	})
+}
@@ -0,0 +1,85 @@
+package oggreader
+
+const (
+	// NoGranulePosition is the granule position of pages on which no
+	// packet ends
+	//
+	// https://datatracker.ietf.org/doc/html/rfc3533#section-6
+	NoGranulePosition = ^uint64(0)
+
+	// CommentHeaderSignature begins the comment header, the first packet
+	// after the ID header
+	//
+	// https://datatracker.ietf.org/doc/html/rfc7845#section-5.2
+	CommentHeaderSignature = "OpusTags"
+
+	// A packet continues in the next segment when the lacing value of its
+	// segment is 255
+	//
+	// https://datatracker.ietf.org/doc/html/rfc3533#section-6
+	maxSegmentSize = 255
+)
+
+// IsCommentHeader reports if a packet begins with the signature of the
+// comment header
+func IsCommentHeader(packet []byte) bool {
+	return len(packet) >= len(CommentHeaderSignature) && string(packet[:len(CommentHeaderSignature)]) == CommentHeaderSignature
+}
+
+// PacketAssembler reassembles the packets of a logical bitstream from the
+// segments of its pages. A packet may span several pages, a page that is
+// lost discards the packet it continues.
+type PacketAssembler struct {
+	packet       []byte
+	skipFragment bool
+}
+
+// AddPage appends the segments of a page, and calls handle for every packet
+// that ends on it. The packet is only valid during the call. Packets that
+// continue on the page after a page was dropped are skipped, as are the
+// incomplete packets of a previous page when the page doesn't continue them.
+func (a *PacketAssembler) AddPage(segments [][]byte, pageHeader *OggPageHeader, handle func(packet []byte) error) error {
+	if !pageHeader.IsContinuation() {
+		a.Reset()
+	}
+
+	for _, segment := range segments {
+		a.packet = append(a.packet, segment...)
+		if len(segment) == maxSegmentSize {
+			continue
+		}
+
+		packet, skip := a.packet, a.skipFragment
+		a.packet = a.packet[:0]
+		a.skipFragment = false
+		if skip {
+			continue
+		}
+
+		if err := handle(packet); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DropPage discards the packet that continues on a page that was lost,
+// like a page that failed its checksum
+func (a *PacketAssembler) DropPage() {
+	a.packet = a.packet[:0]
+	a.skipFragment = true
+}
+
+// Reset discards the packet being reassembled, like at the beginning of the
+// next link of a chained stream
+func (a *PacketAssembler) Reset() {
+	a.packet = a.packet[:0]
+	a.skipFragment = false
+}
+
+// Incomplete reports if a packet is waiting for its continuation on the
+// next page
+func (a *PacketAssembler) Incomplete() bool {
+	return len(a.packet) != 0
+}
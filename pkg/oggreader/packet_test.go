@@ -0,0 +1,75 @@
+package oggreader
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestPacketAssembler(t *testing.T) {
+	var (
+		assembler PacketAssembler
+		packets   [][]byte
+	)
+	handle := func(packet []byte) error {
+		packets = append(packets, append([]byte{}, packet...))
+		return nil
+	}
+
+	first := &OggPageHeader{}
+	continued := &OggPageHeader{headerType: pageHeaderTypeContinuedPacket}
+	long := bytes.Repeat([]byte{1}, maxSegmentSize)
+
+	// A packet spanning two pages, and a packet ending on the second
+	if err := assembler.AddPage([][]byte{{0}, long}, first, handle); err != nil {
+		t.Fatal(err)
+	} else if !assembler.Incomplete() {
+		t.Fatal()
+	}
+	if err := assembler.AddPage([][]byte{{2}, {3}}, continued, handle); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(packets, [][]byte{{0}, append(append([]byte{}, long...), 2), {3}}) || assembler.Incomplete() {
+		t.Fatal(packets)
+	}
+
+	// The continuation of a packet on a dropped page is skipped
+	packets = nil
+	if err := assembler.AddPage([][]byte{long}, first, handle); err != nil {
+		t.Fatal(err)
+	}
+	assembler.DropPage()
+	if err := assembler.AddPage([][]byte{{4}, {5}}, continued, handle); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(packets, [][]byte{{5}}) {
+		t.Fatal(packets)
+	}
+
+	// A page that doesn't continue discards the incomplete packet
+	packets = nil
+	if err := assembler.AddPage([][]byte{long}, first, handle); err != nil {
+		t.Fatal(err)
+	}
+	if err := assembler.AddPage([][]byte{{6}}, first, handle); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(packets, [][]byte{{6}}) {
+		t.Fatal(packets)
+	}
+
+	errHandle := errors.New("handle failed")
+	if err := assembler.AddPage([][]byte{{7}}, first, func([]byte) error { return errHandle }); !errors.Is(err, errHandle) {
+		t.Fatal(err)
+	}
+}
+
+func TestIsCommentHeader(t *testing.T) {
+	switch {
+	case !IsCommentHeader([]byte("OpusTags\x00\x00\x00\x00")):
+		t.Fatal()
+	case IsCommentHeader([]byte("OpusTag")), IsCommentHeader([]byte{0x48, 0x0B}):
+		t.Fatal()
+	}
+}
@@ -0,0 +1,12 @@
+package rtpopus
+
+import "errors"
+
+var (
+	errShortRTPPacket        = errors.New("RTP packet is too short")
+	errUnsupportedRTPVersion = errors.New("unsupported RTP version")
+	errEmptyPayload          = errors.New("payload must contain an Opus packet")
+	errOldPacket             = errors.New("packet is older than the last depacketized packet")
+	errMalformedParameter    = errors.New("malformed fmtp parameter")
+	errInvalidParameterValue = errors.New("invalid fmtp parameter value")
+)
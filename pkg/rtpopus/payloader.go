@@ -0,0 +1,82 @@
+package rtpopus
+
+// Payloader builds RTP payloads from Opus packets of a single stream,
+// assigning consecutive sequence numbers and timestamps derived from the
+// packet durations.
+type Payloader struct {
+	PayloadType    uint8
+	SSRC           uint32
+	SequenceNumber uint16
+	Timestamp      uint32
+
+	// The first packet of a stream and the first packet after a call to
+	// Skip are marked as the start of a talkspurt.
+	inSilence bool
+}
+
+// NewPayloader creates a Payloader starting at the given sequence number
+// and timestamp. Both SHOULD be random.
+//
+// https://datatracker.ietf.org/doc/html/rfc3550#section-5.1
+func NewPayloader(payloadType uint8, ssrc uint32, sequenceNumber uint16, timestamp uint32) *Payloader {
+	return &Payloader{
+		PayloadType:    payloadType,
+		SSRC:           ssrc,
+		SequenceNumber: sequenceNumber,
+		Timestamp:      timestamp,
+		inSilence:      true,
+	}
+}
+
+// Payload assigns the next sequence number and timestamp to an Opus packet.
+// The Opus packet is used as the RTP payload unchanged.
+//
+// https://datatracker.ietf.org/doc/html/rfc7587#section-4.2
+func (p *Payloader) Payload(opusPacket []byte) (Packet, error) {
+	if len(opusPacket) == 0 {
+		return Packet{}, errEmptyPayload
+	}
+
+	duration, err := PacketDuration(opusPacket)
+	if err != nil {
+		return Packet{}, err
+	}
+
+	packet := Packet{
+		SequenceNumber: p.SequenceNumber,
+		Timestamp:      p.Timestamp,
+		Marker:         p.inSilence,
+		Payload:        opusPacket,
+		Duration:       duration,
+	}
+
+	p.inSilence = false
+	p.SequenceNumber++
+	p.Timestamp += duration
+	return packet, nil
+}
+
+// PayloadRTP builds a complete RTP packet for an Opus packet and appends
+// it to buf
+func (p *Payloader) PayloadRTP(buf []byte, opusPacket []byte) ([]byte, error) {
+	packet, err := p.Payload(opusPacket)
+	if err != nil {
+		return buf, err
+	}
+
+	return MarshalRTP(buf, Header{
+		Marker:         packet.Marker,
+		PayloadType:    p.PayloadType,
+		SequenceNumber: packet.SequenceNumber,
+		Timestamp:      packet.Timestamp,
+		SSRC:           p.SSRC,
+	}, packet.Payload), nil
+}
+
+// Skip advances the timestamp without sending a packet, for example while
+// DTX suppresses transmission during silence. The next packet is marked
+// as the start of a talkspurt.
+func (p *Payloader) Skip(duration uint32) {
+	p.Timestamp += duration
+	p.inSilence = true
+}
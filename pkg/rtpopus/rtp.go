@@ -0,0 +1,98 @@
+package rtpopus
+
+import (
+	"encoding/binary"
+)
+
+const (
+	rtpVersion       = 2
+	rtpHeaderLength  = 12
+	csrcLength       = 4
+	extensionHeader  = 4
+	versionShift     = 6
+	paddingMask      = 0x20
+	extensionMask    = 0x10
+	csrcCountMask    = 0x0f
+	markerMask       = 0x80
+	payloadTypeMask  = 0x7f
+	extensionUnitLen = 4
+)
+
+// Header is the fixed RTP header, CSRCs and header extensions are skipped
+// when reading and never written.
+//
+//	 0                   1                   2                   3
+//	 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1
+//	+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+//	|V=2|P|X|  CC   |M|     PT      |       sequence number         |
+//	+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+//	|                           timestamp                           |
+//	+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+//	|           synchronization source (SSRC) identifier            |
+//	+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+
+//
+// https://datatracker.ietf.org/doc/html/rfc3550#section-5.1
+type Header struct {
+	Marker         bool
+	PayloadType    uint8
+	SequenceNumber uint16
+	Timestamp      uint32
+	SSRC           uint32
+}
+
+// ParseRTP splits an RTP packet into its header and payload
+func ParseRTP(in []byte) (Header, []byte, error) {
+	if len(in) < rtpHeaderLength {
+		return Header{}, nil, errShortRTPPacket
+	}
+
+	if in[0]>>versionShift != rtpVersion {
+		return Header{}, nil, errUnsupportedRTPVersion
+	}
+
+	h := Header{
+		Marker:         in[1]&markerMask != 0,
+		PayloadType:    in[1] & payloadTypeMask,
+		SequenceNumber: binary.BigEndian.Uint16(in[2:4]),
+		Timestamp:      binary.BigEndian.Uint32(in[4:8]),
+		SSRC:           binary.BigEndian.Uint32(in[8:12]),
+	}
+
+	offset := rtpHeaderLength + int(in[0]&csrcCountMask)*csrcLength
+	if in[0]&extensionMask != 0 {
+		if len(in) < offset+extensionHeader {
+			return Header{}, nil, errShortRTPPacket
+		}
+
+		offset += extensionHeader + int(binary.BigEndian.Uint16(in[offset+2:offset+4]))*extensionUnitLen
+	}
+
+	// The last octet of the padding contains a count of how many
+	// padding octets should be ignored, including itself.
+	end := len(in)
+	if in[0]&paddingMask != 0 {
+		end -= int(in[end-1])
+	}
+
+	if offset > end {
+		return Header{}, nil, errShortRTPPacket
+	}
+
+	return h, in[offset:end], nil
+}
+
+// MarshalRTP appends the header followed by the payload to buf
+func MarshalRTP(buf []byte, h Header, payload []byte) []byte {
+	var header [rtpHeaderLength]byte
+	header[0] = rtpVersion << versionShift
+	header[1] = h.PayloadType & payloadTypeMask
+	if h.Marker {
+		header[1] |= markerMask
+	}
+
+	binary.BigEndian.PutUint16(header[2:4], h.SequenceNumber)
+	binary.BigEndian.PutUint32(header[4:8], h.Timestamp)
+	binary.BigEndian.PutUint32(header[8:12], h.SSRC)
+
+	return append(append(buf, header[:]...), payload...)
+}
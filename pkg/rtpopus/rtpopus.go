@@ -0,0 +1,135 @@
+// Package rtpopus implements the RTP payload format for Opus
+//
+// https://datatracker.ietf.org/doc/html/rfc7587
+package rtpopus
+
+import (
+	"time"
+
+	"github.com/pion/opus"
+)
+
+// ClockRate is the RTP timestamp clock rate of Opus. The RTP timestamp is
+// incremented with a 48000 Hz clock rate for all modes of Opus and all
+// sampling rates.
+//
+// https://datatracker.ietf.org/doc/html/rfc7587#section-4.1
+const ClockRate = 48000
+
+// Packet is an Opus packet carried in the payload of an RTP packet
+type Packet struct {
+	SequenceNumber uint16
+	Timestamp      uint32
+
+	// Marker is set on the first packet after a silence period
+	Marker bool
+
+	// Payload is the Opus packet. One or more Opus frames MUST be placed into
+	// one RTP packet, packing frames from multiple Opus packets is not allowed.
+	//
+	// https://datatracker.ietf.org/doc/html/rfc7587#section-4.2
+	Payload []byte
+
+	// Duration is the audio duration of Payload in ClockRate ticks,
+	// computed from the TOC header.
+	Duration uint32
+
+	// LostPackets is the number of packets missing before this one,
+	// detected from a gap in the sequence numbers.
+	LostPackets uint16
+
+	// Gap is the number of ClockRate ticks between the end of the previous
+	// packet and the start of this one. If LostPackets is 0 the sender
+	// stopped transmitting during silence (DTX), otherwise the audio was
+	// lost and should be concealed or recovered with FEC.
+	Gap uint32
+}
+
+// IsLoss reports if audio was lost before this packet
+func (p Packet) IsLoss() bool {
+	return p.LostPackets != 0
+}
+
+// Depacketizer turns RTP payloads of a single stream into Opus packets, and
+// tracks the sequence numbers and timestamps to detect lost packets and
+// silence periods.
+type Depacketizer struct {
+	haveDepacketized       bool
+	nextSequenceNumber     uint16
+	nextTimestamp          uint32
+	maxSequenceNumberSkips uint16
+}
+
+// NewDepacketizer creates a new Depacketizer
+func NewDepacketizer() *Depacketizer {
+	return &Depacketizer{
+		// Sequence number jumps beyond this are treated as a restart of the
+		// stream instead of a loss.
+		maxSequenceNumberSkips: 3000,
+	}
+}
+
+// DepacketizeRTP parses a complete RTP packet and depacketizes its payload
+func (d *Depacketizer) DepacketizeRTP(in []byte) (Packet, error) {
+	h, payload, err := ParseRTP(in)
+	if err != nil {
+		return Packet{}, err
+	}
+
+	p, err := d.Depacketize(h.SequenceNumber, h.Timestamp, payload)
+	p.Marker = h.Marker
+	return p, err
+}
+
+// Depacketize converts the payload of an RTP packet into an Opus packet.
+// Packets are expected in order, packets older than the most recent one
+// return an error and don't change the state of the Depacketizer.
+func (d *Depacketizer) Depacketize(sequenceNumber uint16, timestamp uint32, payload []byte) (Packet, error) {
+	if len(payload) == 0 {
+		return Packet{}, errEmptyPayload
+	}
+
+	duration, err := PacketDuration(payload)
+	if err != nil {
+		return Packet{}, err
+	}
+
+	p := Packet{
+		SequenceNumber: sequenceNumber,
+		Timestamp:      timestamp,
+		Payload:        payload,
+		Duration:       duration,
+	}
+
+	if d.haveDepacketized {
+		sequenceNumberDelta := sequenceNumber - d.nextSequenceNumber
+		timestampDelta := timestamp - d.nextTimestamp
+
+		switch {
+		case sequenceNumberDelta >= 0x8000:
+			return Packet{}, errOldPacket
+		case sequenceNumberDelta > d.maxSequenceNumberSkips:
+			// The stream was restarted, don't report a loss
+		default:
+			p.LostPackets = sequenceNumberDelta
+			if timestampDelta < 0x80000000 {
+				p.Gap = timestampDelta
+			}
+		}
+	}
+
+	d.haveDepacketized = true
+	d.nextSequenceNumber = sequenceNumber + 1
+	d.nextTimestamp = timestamp + duration
+	return p, nil
+}
+
+// PacketDuration returns the duration of an Opus packet in ClockRate ticks
+func PacketDuration(payload []byte) (uint32, error) {
+	packet, err := opus.ParsePacket(payload)
+	if err != nil {
+		return 0, err
+	}
+
+	return uint32(packet.Duration() * ClockRate / time.Second), nil
+}
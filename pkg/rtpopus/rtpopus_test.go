@@ -0,0 +1,160 @@
+package rtpopus
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// 20ms wideband SILK packet
+var testOpusPacket = []byte{0x48, 0x0B, 0xE4, 0xC1, 0x36, 0xEC, 0xC5, 0x80}
+
+func TestDepacketizer(t *testing.T) {
+	d := NewDepacketizer()
+
+	p, err := d.Depacketize(65535, 1000, testOpusPacket)
+	switch {
+	case err != nil:
+		t.Fatal(err)
+	case p.Duration != 960:
+		t.Fatal()
+	case p.IsLoss() || p.Gap != 0:
+		t.Fatal()
+	case !bytes.Equal(p.Payload, testOpusPacket):
+		t.Fatal()
+	}
+
+	t.Run("Consecutive", func(t *testing.T) {
+		p, err := d.Depacketize(0, 1960, testOpusPacket)
+		if err != nil {
+			t.Fatal(err)
+		} else if p.IsLoss() || p.Gap != 0 {
+			t.Fatal()
+		}
+	})
+
+	t.Run("DTX", func(t *testing.T) {
+		p, err := d.Depacketize(1, 1960+960*5, testOpusPacket)
+		if err != nil {
+			t.Fatal(err)
+		} else if p.IsLoss() || p.Gap != 960*4 {
+			t.Fatal()
+		}
+	})
+
+	t.Run("Loss", func(t *testing.T) {
+		p, err := d.Depacketize(4, 1960+960*8, testOpusPacket)
+		if err != nil {
+			t.Fatal(err)
+		} else if p.LostPackets != 2 || p.Gap != 960*2 {
+			t.Fatal()
+		}
+	})
+
+	t.Run("Old Packet", func(t *testing.T) {
+		if _, err := d.Depacketize(3, 1960+960*7, testOpusPacket); !errors.Is(err, errOldPacket) {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("Empty Payload", func(t *testing.T) {
+		if _, err := d.Depacketize(5, 0, nil); !errors.Is(err, errEmptyPayload) {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestPayloader(t *testing.T) {
+	p := NewPayloader(111, 0xdeadbeef, 65535, 0xffffffff)
+
+	first, err := p.Payload(testOpusPacket)
+	switch {
+	case err != nil:
+		t.Fatal(err)
+	case !first.Marker:
+		t.Fatal()
+	case first.SequenceNumber != 65535 || first.Timestamp != 0xffffffff:
+		t.Fatal()
+	}
+
+	p.Skip(960)
+
+	rtpPacket, err := p.PayloadRTP(nil, testOpusPacket)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h, payload, err := ParseRTP(rtpPacket)
+	switch {
+	case err != nil:
+		t.Fatal(err)
+	case !reflect.DeepEqual(h, Header{Marker: true, PayloadType: 111, SequenceNumber: 0, Timestamp: 1919, SSRC: 0xdeadbeef}):
+		t.Fatalf("%+v", h)
+	case !bytes.Equal(payload, testOpusPacket):
+		t.Fatal()
+	}
+
+	third, err := p.Payload(testOpusPacket)
+	if err != nil {
+		t.Fatal(err)
+	} else if third.Marker || third.SequenceNumber != 1 || third.Timestamp != 2879 {
+		t.Fatal()
+	}
+}
+
+func TestParseRTP(t *testing.T) {
+	rtpPacket := []byte{
+		// Padding, extension and one CSRC
+		0xb1, 0xef, 0x00, 0x01, 0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x03,
+		0x00, 0x00, 0x00, 0x04,
+		0xbe, 0xde, 0x00, 0x01, 0x10, 0xff, 0x00, 0x00,
+		0x48, 0x01,
+		0x00, 0x02,
+	}
+
+	h, payload, err := ParseRTP(rtpPacket)
+	switch {
+	case err != nil:
+		t.Fatal(err)
+	case !reflect.DeepEqual(h, Header{Marker: true, PayloadType: 111, SequenceNumber: 1, Timestamp: 2, SSRC: 3}):
+		t.Fatalf("%+v", h)
+	case !bytes.Equal(payload, []byte{0x48, 0x01}):
+		t.Fatal(payload)
+	}
+
+	if _, _, err := ParseRTP(rtpPacket[:11]); !errors.Is(err, errShortRTPPacket) {
+		t.Fatal(err)
+	}
+}
+
+func TestParseParameters(t *testing.T) {
+	p, err := ParseParameters("a=fmtp:111 maxplaybackrate=16000; sprop-stereo=1;useinbandfec=1;minptime=10;maxaveragebitrate=20000")
+	switch {
+	case err != nil:
+		t.Fatal(err)
+	case !reflect.DeepEqual(p, Parameters{
+		MaxPlaybackRate:     16000,
+		SpropMaxCaptureRate: 48000,
+		MinPacketTime:       10,
+		MaxAverageBitrate:   20000,
+		SpropStereo:         true,
+		UseInbandFEC:        true,
+	}):
+		t.Fatalf("%+v", p)
+	case p.String() != "maxplaybackrate=16000;minptime=10;maxaveragebitrate=20000;sprop-stereo=1;useinbandfec=1":
+		t.Fatal(p.String())
+	}
+
+	if _, err := ParseParameters("stereo=2"); !errors.Is(err, errInvalidParameterValue) {
+		t.Fatal(err)
+	}
+
+	if _, err := ParseParameters("maxaveragebitrate=100"); !errors.Is(err, errInvalidParameterValue) {
+		t.Fatal(err)
+	}
+
+	if _, err := ParseParameters("usedtx"); !errors.Is(err, errMalformedParameter) {
+		t.Fatal(err)
+	}
+}
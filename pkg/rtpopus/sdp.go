@@ -0,0 +1,181 @@
+package rtpopus
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultMaxPlaybackRate   = 48000
+	defaultMaxCaptureRate    = 48000
+	minMaxAverageBitrate     = 6000
+	maxMaxAverageBitrate     = 510000
+	fmtpAttributePrefix      = "a=fmtp:"
+	fmtpParameterSeparator   = ";"
+	fmtpKeyValueSeparator    = "="
+	fmtpPayloadTypeSeparator = " "
+)
+
+// Parameters are the Opus specific media type parameters carried in the
+// SDP "a=fmtp" attribute
+//
+// https://datatracker.ietf.org/doc/html/rfc7587#section-6.1
+type Parameters struct {
+	// MaxPlaybackRate is a hint about the maximum output sampling rate
+	// that the receiver is capable of rendering in Hz.
+	MaxPlaybackRate uint32
+
+	// SpropMaxCaptureRate is a hint about the maximum input sampling rate
+	// that the sender is likely to produce.
+	SpropMaxCaptureRate uint32
+
+	// MaxPacketTime, PacketTime and MinPacketTime are packet durations
+	// in milliseconds, 0 when not present.
+	MaxPacketTime uint32
+	PacketTime    uint32
+	MinPacketTime uint32
+
+	// MaxAverageBitrate is the maximum average receive bitrate of a session
+	// in bits per second, 0 when not present.
+	MaxAverageBitrate uint32
+
+	// Stereo specifies whether the decoder prefers receiving stereo or mono
+	// signals, SpropStereo whether the sender is likely to produce stereo.
+	Stereo      bool
+	SpropStereo bool
+
+	// CBR specifies if the decoder prefers the use of a constant bitrate.
+	CBR bool
+
+	// UseInbandFEC specifies that the decoder has the capability to take
+	// advantage of the Opus in-band FEC.
+	UseInbandFEC bool
+
+	// UseDTX specifies if the decoder prefers the use of DTX.
+	UseDTX bool
+}
+
+// DefaultParameters returns the values used for parameters that are
+// not present
+func DefaultParameters() Parameters {
+	return Parameters{
+		MaxPlaybackRate:     defaultMaxPlaybackRate,
+		SpropMaxCaptureRate: defaultMaxCaptureRate,
+	}
+}
+
+// ParseParameters parses the parameters of an "a=fmtp" attribute. Both
+// the complete attribute "a=fmtp:111 minptime=10;useinbandfec=1" and only
+// the parameter list "minptime=10;useinbandfec=1" are accepted. Unknown
+// parameters are ignored.
+func ParseParameters(fmtp string) (Parameters, error) {
+	p := DefaultParameters()
+
+	fmtp = strings.TrimSpace(fmtp)
+	if strings.HasPrefix(fmtp, fmtpAttributePrefix) {
+		index := strings.Index(fmtp, fmtpPayloadTypeSeparator)
+		if index == -1 {
+			return p, nil
+		}
+		fmtp = fmtp[index+1:]
+	}
+
+	for _, parameter := range strings.Split(fmtp, fmtpParameterSeparator) {
+		parameter = strings.TrimSpace(parameter)
+		if parameter == "" {
+			continue
+		}
+
+		key, value, found := strings.Cut(parameter, fmtpKeyValueSeparator)
+		if !found {
+			return Parameters{}, fmt.Errorf("%w: %s", errMalformedParameter, parameter)
+		}
+
+		var err error
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "maxplaybackrate":
+			p.MaxPlaybackRate, err = parseUint(value)
+		case "sprop-maxcapturerate":
+			p.SpropMaxCaptureRate, err = parseUint(value)
+		case "maxptime":
+			p.MaxPacketTime, err = parseUint(value)
+		case "ptime":
+			p.PacketTime, err = parseUint(value)
+		case "minptime":
+			p.MinPacketTime, err = parseUint(value)
+		case "maxaveragebitrate":
+			if p.MaxAverageBitrate, err = parseUint(value); err == nil &&
+				(p.MaxAverageBitrate < minMaxAverageBitrate || p.MaxAverageBitrate > maxMaxAverageBitrate) {
+				err = errInvalidParameterValue
+			}
+		case "stereo":
+			p.Stereo, err = parseBool(value)
+		case "sprop-stereo":
+			p.SpropStereo, err = parseBool(value)
+		case "cbr":
+			p.CBR, err = parseBool(value)
+		case "useinbandfec":
+			p.UseInbandFEC, err = parseBool(value)
+		case "usedtx":
+			p.UseDTX, err = parseBool(value)
+		}
+
+		if err != nil {
+			return Parameters{}, fmt.Errorf("%w: %s", err, parameter)
+		}
+	}
+
+	return p, nil
+}
+
+// String returns the parameters in the format of the "a=fmtp" attribute.
+// Parameters that match their default value are omitted.
+func (p Parameters) String() string {
+	parameters := []string{}
+	appendUint := func(key string, value, defaultValue uint32) {
+		if value != defaultValue {
+			parameters = append(parameters, key+fmtpKeyValueSeparator+strconv.FormatUint(uint64(value), 10))
+		}
+	}
+	appendBool := func(key string, value bool) {
+		if value {
+			parameters = append(parameters, key+fmtpKeyValueSeparator+"1")
+		}
+	}
+
+	appendUint("maxplaybackrate", p.MaxPlaybackRate, defaultMaxPlaybackRate)
+	appendUint("sprop-maxcapturerate", p.SpropMaxCaptureRate, defaultMaxCaptureRate)
+	appendUint("maxptime", p.MaxPacketTime, 0)
+	appendUint("ptime", p.PacketTime, 0)
+	appendUint("minptime", p.MinPacketTime, 0)
+	appendUint("maxaveragebitrate", p.MaxAverageBitrate, 0)
+	appendBool("stereo", p.Stereo)
+	appendBool("sprop-stereo", p.SpropStereo)
+	appendBool("cbr", p.CBR)
+	appendBool("useinbandfec", p.UseInbandFEC)
+	appendBool("usedtx", p.UseDTX)
+
+	return strings.Join(parameters, fmtpParameterSeparator)
+}
+
+func parseUint(value string) (uint32, error) {
+	v, err := strconv.ParseUint(strings.TrimSpace(value), 10, 32)
+	if err != nil {
+		return 0, errInvalidParameterValue
+	}
+
+	return uint32(v), nil
+}
+
+// Boolean parameters are 0 or 1
+func parseBool(value string) (bool, error) {
+	switch strings.TrimSpace(value) {
+	case "0":
+		return false, nil
+	case "1":
+		return true, nil
+	}
+
+	return false, errInvalidParameterValue
+}
@@ -0,0 +1,19 @@
+package wav
+
+import "errors"
+
+var (
+	errUnsupportedSampleFormat = errors.New("unsupported sample format")
+	errInvalidChannelCount     = errors.New("channel count must be between 1 and 65535")
+	errInvalidSampleRate       = errors.New("sample rate is out of range")
+	errWriterClosed            = errors.New("writer is closed")
+
+	errShortHeader        = errors.New("file is too short to contain a RIFF header")
+	errBadSignature       = errors.New("file is not a RIFF/WAVE or RF64 file")
+	errMissingFormatChunk = errors.New("data chunk precedes the fmt chunk")
+	errMissingDataChunk   = errors.New("file ends before the data chunk")
+	errShortChunk         = errors.New("chunk is too short")
+	errChunkTooLarge      = errors.New("chunk is too large")
+	errBadDS64Chunk       = errors.New("ds64 chunk is invalid")
+	errBadBlockAlign      = errors.New("block align does not match the sample format")
+)
@@ -0,0 +1,236 @@
+package wav
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Reader reads the samples of a WAV or RF64 file
+type Reader struct {
+	in     io.Reader
+	format Format
+
+	// The bytes left in the data chunk, or -1 when its size is unknown and
+	// it continues until the end of the file
+	remaining int64
+	dataSize  int64
+
+	buffer []byte
+}
+
+// NewReader reads the header of a WAV file up to the start of its samples.
+// Chunks other than the fmt and data chunks are skipped.
+func NewReader(in io.Reader) (*Reader, error) {
+	r := &Reader{in: in}
+
+	var header [12]byte
+	if _, err := io.ReadFull(in, header[:]); err != nil {
+		return nil, errShortHeader
+	}
+
+	rf64 := false
+	switch {
+	case string(header[8:12]) != "WAVE":
+		return nil, errBadSignature
+	case string(header[:4]) == "RF64":
+		rf64 = true
+	case string(header[:4]) != "RIFF":
+		return nil, errBadSignature
+	}
+
+	haveFormat := false
+	ds64DataSize := int64(-1)
+	for {
+		var chunkHeader [chunkHeaderSize]byte
+		if _, err := io.ReadFull(in, chunkHeader[:]); err != nil {
+			return nil, errMissingDataChunk
+		}
+		id := string(chunkHeader[:4])
+		size := int64(binary.LittleEndian.Uint32(chunkHeader[4:]))
+
+		switch {
+		case id == "data":
+			if !haveFormat {
+				return nil, errMissingFormatChunk
+			}
+
+			// The size of the data chunk of RF64 files is in the ds64
+			// chunk, in other files the largest size marks a stream of
+			// unknown length
+			r.dataSize = size
+			switch {
+			case rf64 && size == unknownSize && ds64DataSize >= 0:
+				r.dataSize = ds64DataSize
+			case size == unknownSize:
+				r.dataSize = -1
+			}
+			r.remaining = r.dataSize
+
+			return r, nil
+		case id == "fmt ":
+			chunk, err := readChunk(in, size)
+			if err != nil {
+				return nil, err
+			}
+			if r.format, err = parseFormatChunk(chunk); err != nil {
+				return nil, err
+			}
+			haveFormat = true
+		case id == "ds64" && rf64:
+			chunk, err := readChunk(in, size)
+			if err != nil {
+				return nil, err
+			}
+			if len(chunk) < 16 || binary.LittleEndian.Uint64(chunk[8:]) > math.MaxInt64 {
+				return nil, errBadDS64Chunk
+			}
+			ds64DataSize = int64(binary.LittleEndian.Uint64(chunk[8:]))
+		default:
+			// Chunks are padded to an even size
+			if _, err := io.CopyN(io.Discard, in, size+size%2); err != nil {
+				return nil, errMissingDataChunk
+			}
+		}
+	}
+}
+
+// readChunk reads a small chunk, and the padding after it
+func readChunk(in io.Reader, size int64) ([]byte, error) {
+	if size > maxReadChunkSize {
+		return nil, errChunkTooLarge
+	}
+
+	chunk := make([]byte, size+size%2)
+	if _, err := io.ReadFull(in, chunk); err != nil {
+		return nil, errShortChunk
+	}
+
+	return chunk[:size], nil
+}
+
+// parseFormatChunk reads the format of the samples. WAVE_FORMAT_EXTENSIBLE
+// carries the format tag in the first two bytes of its sub format GUID.
+func parseFormatChunk(chunk []byte) (Format, error) {
+	if len(chunk) < formatChunkSize {
+		return Format{}, errShortChunk
+	}
+
+	formatTag := binary.LittleEndian.Uint16(chunk)
+	format := Format{
+		Channels:   int(binary.LittleEndian.Uint16(chunk[2:])),
+		SampleRate: int(binary.LittleEndian.Uint32(chunk[4:])),
+	}
+	blockAlign := int(binary.LittleEndian.Uint16(chunk[12:]))
+	bitsPerSample := binary.LittleEndian.Uint16(chunk[14:])
+
+	if formatTag == formatExtensible {
+		if len(chunk) < extensibleFormatChunkSize {
+			return Format{}, errShortChunk
+		}
+		format.ChannelMask = binary.LittleEndian.Uint32(chunk[20:])
+		formatTag = binary.LittleEndian.Uint16(chunk[24:])
+	}
+
+	switch {
+	case formatTag == formatPCM && bitsPerSample == 16:
+		format.SampleFormat = Int16
+	case formatTag == formatPCM && bitsPerSample == 24:
+		format.SampleFormat = Int24
+	case formatTag == formatPCM && bitsPerSample == 32:
+		format.SampleFormat = Int32
+	case formatTag == formatIEEEFloat && bitsPerSample == 32:
+		format.SampleFormat = Float32
+	default:
+		return Format{}, fmt.Errorf("%w: format %#x with %d bits per sample", errUnsupportedSampleFormat, formatTag, bitsPerSample)
+	}
+
+	if err := format.validate(); err != nil {
+		return Format{}, err
+	}
+	if blockAlign != format.Channels*format.SampleFormat.BytesPerSample() {
+		return Format{}, errBadBlockAlign
+	}
+
+	return format, nil
+}
+
+// Format returns the format of the samples
+func (r *Reader) Format() Format {
+	return r.format
+}
+
+// DataSize returns the size of the samples in bytes, or -1 when the file
+// is a stream of unknown length
+func (r *Reader) DataSize() int64 {
+	return r.dataSize
+}
+
+// Read reads samples encoded in the sample format of the file. It returns
+// io.EOF at the end of the data chunk.
+func (r *Reader) Read(p []byte) (int, error) {
+	if r.remaining == 0 {
+		return 0, io.EOF
+	}
+	if r.remaining > 0 && int64(len(p)) > r.remaining {
+		p = p[:r.remaining]
+	}
+
+	n, err := r.in.Read(p)
+	if r.remaining > 0 {
+		r.remaining -= int64(n)
+		if errors.Is(err, io.EOF) && r.remaining > 0 {
+			err = io.ErrUnexpectedEOF
+		}
+	}
+
+	return n, err
+}
+
+// ReadFloat32 reads interleaved samples, integer samples are scaled to
+// values between -1 and 1. It returns the number of samples read, and
+// io.EOF once no samples are left.
+func (r *Reader) ReadFloat32(samples []float32) (int, error) {
+	in, err := r.readSamples(len(samples))
+	bytesPerSample := r.format.SampleFormat.BytesPerSample()
+	for i := range samples[:len(in)/bytesPerSample] {
+		samples[i] = decodeSample(r.format.SampleFormat, in[i*bytesPerSample:])
+	}
+
+	return len(in) / bytesPerSample, err
+}
+
+// ReadInt16 reads interleaved samples, converted to 16 bits. It returns the
+// number of samples read, and io.EOF once no samples are left.
+func (r *Reader) ReadInt16(samples []int16) (int, error) {
+	in, err := r.readSamples(len(samples))
+	bytesPerSample := r.format.SampleFormat.BytesPerSample()
+	for i := range samples[:len(in)/bytesPerSample] {
+		if r.format.SampleFormat == Int16 {
+			samples[i] = int16(binary.LittleEndian.Uint16(in[i*bytesPerSample:]))
+		} else {
+			samples[i] = int16(quantize(decodeSample(r.format.SampleFormat, in[i*bytesPerSample:]), math.MaxInt16))
+		}
+	}
+
+	return len(in) / bytesPerSample, err
+}
+
+// readSamples reads up to count samples. The samples before the end of the
+// file are returned without an error, a sample cut off by it is reported as
+// io.ErrUnexpectedEOF.
+func (r *Reader) readSamples(count int) ([]byte, error) {
+	bytesPerSample := r.format.SampleFormat.BytesPerSample()
+	if cap(r.buffer) < count*bytesPerSample {
+		r.buffer = make([]byte, count*bytesPerSample)
+	}
+
+	n, err := io.ReadFull(r, r.buffer[:count*bytesPerSample])
+	if errors.Is(err, io.ErrUnexpectedEOF) && n > 0 && n%bytesPerSample == 0 {
+		err = nil
+	}
+
+	return r.buffer[:n], err
+}
@@ -0,0 +1,175 @@
+// Package wav reads and writes PCM audio in RIFF/WAVE files, and in RF64
+// files for recordings larger than 4 GiB
+//
+// https://learn.microsoft.com/en-us/windows/win32/api/mmreg/ns-mmreg-waveformatextensible
+// https://tech.ebu.ch/docs/tech/tech3306v1_1.pdf
+package wav
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// SampleFormat is the encoding of the samples in a file
+type SampleFormat int
+
+// Supported sample formats, every sample is little-endian
+const (
+	Int16 SampleFormat = iota + 1
+	Int24
+	Int32
+	Float32
+)
+
+// BytesPerSample returns the size of a single sample of one channel
+func (f SampleFormat) BytesPerSample() int {
+	switch f {
+	case Int16:
+		return 2
+	case Int24:
+		return 3
+	case Int32, Float32:
+		return 4
+	default:
+		return 0
+	}
+}
+
+func (f SampleFormat) String() string {
+	switch f {
+	case Int16:
+		return "int16"
+	case Int24:
+		return "int24"
+	case Int32:
+		return "int32"
+	case Float32:
+		return "float32"
+	default:
+		return "unknown"
+	}
+}
+
+// Speaker positions of the channel mask of WAVE_FORMAT_EXTENSIBLE. The
+// channels of a file are in the order of the bits set in its mask.
+const (
+	SpeakerFrontLeft uint32 = 1 << iota
+	SpeakerFrontRight
+	SpeakerFrontCenter
+	SpeakerLowFrequency
+	SpeakerBackLeft
+	SpeakerBackRight
+	SpeakerFrontLeftOfCenter
+	SpeakerFrontRightOfCenter
+	SpeakerBackCenter
+	SpeakerSideLeft
+	SpeakerSideRight
+	SpeakerTopCenter
+	SpeakerTopFrontLeft
+	SpeakerTopFrontCenter
+	SpeakerTopFrontRight
+	SpeakerTopBackLeft
+	SpeakerTopBackCenter
+	SpeakerTopBackRight
+)
+
+// Format describes the samples of a file. Samples of all channels are
+// interleaved.
+type Format struct {
+	SampleFormat SampleFormat
+	Channels     int
+	SampleRate   int
+
+	// ChannelMask assigns the channels to speaker positions. It's zero
+	// when the channels have no positions.
+	ChannelMask uint32
+}
+
+func (f Format) validate() error {
+	switch {
+	case f.SampleFormat.BytesPerSample() == 0:
+		return errUnsupportedSampleFormat
+	case f.Channels <= 0 || f.Channels > math.MaxUint16:
+		return errInvalidChannelCount
+	case f.SampleRate <= 0 || int64(f.SampleRate) > math.MaxUint32/int64(f.Channels*f.SampleFormat.BytesPerSample()):
+		return errInvalidSampleRate
+	}
+
+	return nil
+}
+
+// isExtensible reports if the format must be described by
+// WAVE_FORMAT_EXTENSIBLE. It's required for integer samples of more than
+// 16 bits, more than two channels, and to assign speaker positions.
+func (f Format) isExtensible() bool {
+	return f.Channels > 2 || f.ChannelMask != 0 || f.SampleFormat == Int24 || f.SampleFormat == Int32
+}
+
+const (
+	formatPCM        = 1
+	formatIEEEFloat  = 3
+	formatExtensible = 0xFFFE
+
+	chunkHeaderSize = 8
+
+	// The fmt chunk of WAVE_FORMAT_PCM ends after the bits per sample, other
+	// formats add the size of an extension, which is 22 for
+	// WAVE_FORMAT_EXTENSIBLE
+	formatChunkSize           = 16
+	formatChunkExtensionSize  = 2
+	extensibleFormatChunkSize = 40
+	extensibleExtensionSize   = 22
+
+	// The ds64 chunk of RF64 files holds the 64-bit sizes of the RIFF and
+	// data chunks, the sample count and the length of an empty table
+	ds64ChunkSize = 28
+
+	// The fmt and ds64 chunks are read into memory, larger chunks of these
+	// types are rejected
+	maxReadChunkSize = 4096
+
+	// Size fields of streams of unknown length, and of RF64 files whose
+	// sizes are in the ds64 chunk
+	unknownSize = math.MaxUint32
+)
+
+// The sub format GUID of WAVE_FORMAT_EXTENSIBLE starts with the format tag,
+// followed by these bytes
+var subFormatGUIDSuffix = [...]byte{0x00, 0x00, 0x00, 0x00, 0x10, 0x00, 0x80, 0x00, 0x00, 0xAA, 0x00, 0x38, 0x9B, 0x71}
+
+// encodeSample writes a sample between -1 and 1 in the sample format,
+// clipping it to the range of the integer formats
+func encodeSample(f SampleFormat, out []byte, sample float32) {
+	switch f {
+	case Int16:
+		binary.LittleEndian.PutUint16(out, uint16(quantize(sample, math.MaxInt16)))
+	case Int24:
+		v := quantize(sample, 1<<23-1)
+		out[0], out[1], out[2] = byte(v), byte(v>>8), byte(v>>16)
+	case Int32:
+		binary.LittleEndian.PutUint32(out, uint32(quantize(sample, math.MaxInt32)))
+	case Float32:
+		binary.LittleEndian.PutUint32(out, math.Float32bits(sample))
+	}
+}
+
+// decodeSample reads a sample of the sample format, integer samples are
+// scaled to values between -1 and 1
+func decodeSample(f SampleFormat, in []byte) float32 {
+	switch f {
+	case Int16:
+		return float32(int16(binary.LittleEndian.Uint16(in))) / (math.MaxInt16 + 1)
+	case Int24:
+		return float32(int32(uint32(in[0])<<8|uint32(in[1])<<16|uint32(in[2])<<24)>>8) / (1 << 23)
+	case Int32:
+		return float32(float64(int32(binary.LittleEndian.Uint32(in))) / (math.MaxInt32 + 1))
+	case Float32:
+		return math.Float32frombits(binary.LittleEndian.Uint32(in))
+	default:
+		return 0
+	}
+}
+
+func quantize(sample float32, max int64) int64 {
+	return int64(math.Max(float64(-max-1), math.Min(math.Round(float64(sample)*float64(max+1)), float64(max))))
+}
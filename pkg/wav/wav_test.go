@@ -0,0 +1,198 @@
+package wav
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// testSamples are exactly representable in every sample format
+func testSamples(count int) []float32 {
+	samples := make([]float32, count)
+	for i := range samples {
+		samples[i] = float32(i*1237%65536-32768) / 32768
+	}
+
+	return samples
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, format := range []Format{
+		{SampleFormat: Int16, Channels: 1, SampleRate: 48000},
+		{SampleFormat: Int24, Channels: 1, SampleRate: 44100},
+		{SampleFormat: Int32, Channels: 2, SampleRate: 16000},
+		{SampleFormat: Float32, Channels: 2, SampleRate: 48000},
+		{SampleFormat: Int16, Channels: 6, SampleRate: 48000, ChannelMask: 0x3F},
+	} {
+		path := filepath.Join(t.TempDir(), "test.wav")
+		file, err := os.Create(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		samples := testSamples(format.Channels * 99)
+		w, err := NewWriter(file, format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := w.WriteFloat32(samples); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if err := file.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The sizes are updated on Close, and odd sizes are padded
+		dataSize := len(samples) * format.SampleFormat.BytesPerSample()
+		if len(data) != w.headerSize+dataSize+dataSize%2 || binary.LittleEndian.Uint32(data[4:]) != uint32(len(data)-8) {
+			t.Fatalf("%v: %d bytes", format, len(data))
+		}
+
+		r, err := NewReader(bytes.NewReader(data))
+		switch {
+		case err != nil:
+			t.Fatal(err)
+		case r.Format() != format:
+			t.Fatalf("%+v != %+v", r.Format(), format)
+		case r.DataSize() != int64(dataSize):
+			t.Fatal(r.DataSize())
+		}
+
+		decoded := make([]float32, len(samples)+1)
+		if n, err := r.ReadFloat32(decoded); err != nil || n != len(samples) {
+			t.Fatal(n, err)
+		} else if !reflect.DeepEqual(decoded[:n], samples) {
+			t.Fatalf("%v: samples mismatch", format)
+		}
+		if _, err := r.ReadFloat32(decoded); !errors.Is(err, io.EOF) {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestStream(t *testing.T) {
+	var out bytes.Buffer
+	w, err := NewWriter(&out, Format{SampleFormat: Int24, Channels: 1, SampleRate: 48000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteInt16([]int16{-32768, 1, 32767}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Streams have no JUNK chunk, and their sizes are unknown
+	if out.Len() != 68+9 || binary.LittleEndian.Uint32(out.Bytes()[4:]) != unknownSize {
+		t.Fatal(out.Bytes())
+	}
+
+	r, err := NewReader(&out)
+	if err != nil {
+		t.Fatal(err)
+	} else if r.DataSize() != -1 {
+		t.Fatal(r.DataSize())
+	}
+
+	samples := make([]int16, 2)
+	if n, err := r.ReadInt16(samples); err != nil || n != 2 || samples[0] != -32768 || samples[1] != 1 {
+		t.Fatal(n, err, samples)
+	}
+	if n, err := r.ReadInt16(samples); err != nil || n != 1 || samples[0] != 32767 {
+		t.Fatal(n, err, samples)
+	}
+	if _, err := r.ReadInt16(samples); !errors.Is(err, io.EOF) {
+		t.Fatal(err)
+	}
+}
+
+func TestRF64(t *testing.T) {
+	format := Format{SampleFormat: Int16, Channels: 2, SampleRate: 48000}
+	w := &Writer{format: format, seekable: true}
+	w.headerSize = len(w.header(false))
+	w.dataSize = 5 << 30
+	w.closed = true
+
+	header := w.header(true)
+	if len(header) != w.headerSize || string(header[:4]) != "RF64" || string(header[12:16]) != "ds64" {
+		t.Fatal(header)
+	}
+
+	r, err := NewReader(bytes.NewReader(header))
+	switch {
+	case err != nil:
+		t.Fatal(err)
+	case r.DataSize() != 5<<30 || r.Format() != format:
+		t.Fatal(r.DataSize(), r.Format())
+	}
+
+	// A file truncated before the end of its data is reported
+	if _, err := r.ReadInt16(make([]int16, 1)); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatal(err)
+	}
+}
+
+func TestReaderChunks(t *testing.T) {
+	var out bytes.Buffer
+	w, err := NewWriter(&out, Format{SampleFormat: Int16, Channels: 1, SampleRate: 8000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := out.Bytes()
+	formatChunk := header[12 : len(header)-chunkHeaderSize]
+	if w.headerSize != 44 {
+		t.Fatal(w.headerSize)
+	}
+
+	file := func(chunks ...[]byte) []byte {
+		b := []byte("RIFF\x00\x00\x00\x00WAVE")
+		for _, chunk := range chunks {
+			b = append(b, chunk...)
+		}
+
+		return b
+	}
+	data := []byte("data\x04\x00\x00\x00\x01\x00\x02\x00")
+
+	// Unknown chunks are skipped, including their padding
+	r, err := NewReader(bytes.NewReader(file([]byte("LIST\x03\x00\x00\x00abc\x00"), formatChunk, data, []byte("LIST"))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, err := io.ReadAll(r); err != nil || !bytes.Equal(b, data[8:]) {
+		t.Fatal(b, err)
+	}
+
+	unsupported := append([]byte{}, formatChunk...)
+	binary.LittleEndian.PutUint16(unsupported[22:], 8)
+
+	for _, test := range []struct {
+		File     []byte
+		Expected error
+	}{
+		{[]byte("RIFF"), errShortHeader},
+		{[]byte("RIFX\x00\x00\x00\x00WAVE"), errBadSignature},
+		{file(data, formatChunk), errMissingFormatChunk},
+		{file(formatChunk), errMissingDataChunk},
+		{file(unsupported, data), errUnsupportedSampleFormat},
+		{file([]byte("fmt \x04\x00\x00\x00\x01\x00\x01\x00"), data), errShortChunk},
+	} {
+		if _, err := NewReader(bytes.NewReader(test.File)); !errors.Is(err, test.Expected) {
+			t.Fatalf("%q: %v != %v", test.File, err, test.Expected)
+		}
+	}
+}
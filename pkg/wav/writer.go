@@ -0,0 +1,248 @@
+package wav
+
+import (
+	"io"
+	"math"
+)
+
+// Writer writes samples to a WAV file. The sizes of the file are only known
+// once it's closed, until then the header marks the file as being of
+// unknown length. When the output is an io.WriteSeeker, Close updates the
+// header, and files of 4 GiB and more are converted to RF64.
+type Writer struct {
+	out    io.Writer
+	format Format
+
+	// Space for a ds64 chunk is reserved with a JUNK chunk when the
+	// output is seekable, the header is rewritten at start
+	seekable bool
+	start    int64
+
+	headerSize int
+	dataSize   uint64
+	buffer     []byte
+	closed     bool
+}
+
+// NewWriter writes the header of a WAV file with the given format to out,
+// and returns a Writer for its samples
+func NewWriter(out io.Writer, format Format) (*Writer, error) {
+	if err := format.validate(); err != nil {
+		return nil, err
+	}
+
+	w := &Writer{out: out, format: format}
+	if seeker, ok := out.(io.WriteSeeker); ok {
+		start, err := seeker.Seek(0, io.SeekCurrent)
+		w.seekable, w.start = err == nil, start
+	}
+
+	header := w.header(false)
+	w.headerSize = len(header)
+	if _, err := out.Write(header); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// Format returns the format of the samples
+func (w *Writer) Format() Format {
+	return w.format
+}
+
+// Write writes interleaved samples that are already encoded in the sample
+// format of the file, like the 16-bit little-endian output of
+// opus.Decoder.Decode for Int16 files
+func (w *Writer) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, errWriterClosed
+	}
+
+	n, err := w.out.Write(p)
+	w.dataSize += uint64(n)
+	return n, err
+}
+
+// WriteFloat32 writes interleaved samples between -1 and 1, like the output
+// of opus.Decoder.DecodeFloat32. Samples outside of this range are clipped
+// for the integer formats.
+func (w *Writer) WriteFloat32(samples []float32) error {
+	bytesPerSample := w.format.SampleFormat.BytesPerSample()
+	out := w.grow(len(samples) * bytesPerSample)
+	for i, sample := range samples {
+		encodeSample(w.format.SampleFormat, out[i*bytesPerSample:], sample)
+	}
+
+	_, err := w.Write(out)
+	return err
+}
+
+// WriteInt16 writes interleaved 16-bit samples
+func (w *Writer) WriteInt16(samples []int16) error {
+	bytesPerSample := w.format.SampleFormat.BytesPerSample()
+	out := w.grow(len(samples) * bytesPerSample)
+	for i, sample := range samples {
+		encodeSample(w.format.SampleFormat, out[i*bytesPerSample:], float32(sample)/(math.MaxInt16+1))
+	}
+
+	_, err := w.Write(out)
+	return err
+}
+
+// Close pads the data chunk to an even size and updates the sizes in the
+// header when the output is seekable. It doesn't close the output.
+func (w *Writer) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	// Streams of unknown length are read until their end, a padding byte
+	// would be read as part of the samples
+	if !w.seekable {
+		return nil
+	}
+
+	if w.dataSize%2 != 0 {
+		if _, err := w.out.Write([]byte{0}); err != nil {
+			return err
+		}
+	}
+
+	seeker, _ := w.out.(io.WriteSeeker)
+	end, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+
+	if _, err := seeker.Seek(w.start, io.SeekStart); err != nil {
+		return err
+	}
+
+	rf64 := w.riffSize() > unknownSize || w.dataSize >= unknownSize
+	if _, err := seeker.Write(w.header(rf64)); err != nil {
+		return err
+	}
+
+	_, err = seeker.Seek(end, io.SeekStart)
+	return err
+}
+
+func (w *Writer) grow(n int) []byte {
+	if cap(w.buffer) < n {
+		w.buffer = make([]byte, n)
+	}
+
+	return w.buffer[:n]
+}
+
+// riffSize is the size of the RIFF chunk, it counts everything after its
+// size field
+func (w *Writer) riffSize() uint64 {
+	return uint64(w.headerSize) - chunkHeaderSize + w.dataSize + w.dataSize%2
+}
+
+// header returns everything before the samples: the RIFF header, the JUNK
+// or ds64 chunk, the fmt chunk and the header of the data chunk. The sizes
+// are unknown until Close.
+//
+//	RIFF <size> WAVE
+//	JUNK|ds64 <28> <RIFF size> <data size> <sample count> <table length>
+//	fmt  <size> <format>
+//	data <size>
+func (w *Writer) header(rf64 bool) []byte {
+	riffSize, dataSize := uint32(unknownSize), uint32(unknownSize)
+	if w.closed && !rf64 {
+		riffSize, dataSize = uint32(w.riffSize()), uint32(w.dataSize)
+	}
+
+	b := make([]byte, 0, 3*chunkHeaderSize+4+ds64ChunkSize+extensibleFormatChunkSize)
+	if rf64 {
+		b = append(b, "RF64"...)
+	} else {
+		b = append(b, "RIFF"...)
+	}
+	b = appendUint32(b, riffSize)
+	b = append(b, "WAVE"...)
+
+	if w.seekable {
+		if rf64 {
+			b = append(b, "ds64"...)
+		} else {
+			b = append(b, "JUNK"...)
+		}
+		b = appendUint32(b, ds64ChunkSize)
+
+		var riffSize64, dataSize64, sampleCount uint64
+		if rf64 {
+			riffSize64, dataSize64 = w.riffSize(), w.dataSize
+			sampleCount = w.dataSize / uint64(w.format.Channels*w.format.SampleFormat.BytesPerSample())
+		}
+		b = appendUint64(b, riffSize64)
+		b = appendUint64(b, dataSize64)
+		b = appendUint64(b, sampleCount)
+		b = appendUint32(b, 0)
+	}
+
+	b = w.appendFormatChunk(b)
+	b = append(b, "data"...)
+	return appendUint32(b, dataSize)
+}
+
+func (w *Writer) appendFormatChunk(b []byte) []byte {
+	f := w.format
+	formatTag := uint16(formatPCM)
+	if f.SampleFormat == Float32 {
+		formatTag = formatIEEEFloat
+	}
+
+	size := formatChunkSize
+	switch {
+	case f.isExtensible():
+		size = extensibleFormatChunkSize
+	case formatTag != formatPCM:
+		size = formatChunkSize + formatChunkExtensionSize
+	}
+
+	blockAlign := f.Channels * f.SampleFormat.BytesPerSample()
+
+	b = append(b, "fmt "...)
+	b = appendUint32(b, uint32(size))
+	if f.isExtensible() {
+		b = appendUint16(b, formatExtensible)
+	} else {
+		b = appendUint16(b, formatTag)
+	}
+	b = appendUint16(b, uint16(f.Channels))
+	b = appendUint32(b, uint32(f.SampleRate))
+	b = appendUint32(b, uint32(f.SampleRate*blockAlign))
+	b = appendUint16(b, uint16(blockAlign))
+	b = appendUint16(b, uint16(8*f.SampleFormat.BytesPerSample()))
+
+	switch size {
+	case extensibleFormatChunkSize:
+		// The valid bits per sample, the channel mask and the sub format
+		b = appendUint16(b, extensibleExtensionSize)
+		b = appendUint16(b, uint16(8*f.SampleFormat.BytesPerSample()))
+		b = appendUint32(b, f.ChannelMask)
+		b = appendUint16(b, formatTag)
+		b = append(b, subFormatGUIDSuffix[:]...)
+	case formatChunkSize + formatChunkExtensionSize:
+		b = appendUint16(b, 0)
+	}
+
+	return b
+}
+
+func appendUint16(b []byte, v uint16) []byte {
+	return append(b, byte(v), byte(v>>8))
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	return appendUint32(appendUint32(b, uint32(v)), uint32(v>>32))
+}
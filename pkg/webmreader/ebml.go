@@ -0,0 +1,275 @@
+package webmreader
+
+import (
+	"bufio"
+	"errors"
+	"io"
+)
+
+// EBML element IDs, including their length marker
+//
+// https://www.matroska.org/technical/elements.html
+const (
+	idEBML    = 0x1A45DFA3
+	idDocType = 0x4282
+
+	idSegment = 0x18538067
+
+	idSeekHead     = 0x114D9B74
+	idSeek         = 0x4DBB
+	idSeekID       = 0x53AB
+	idSeekPosition = 0x53AC
+
+	idInfo          = 0x1549A966
+	idTimecodeScale = 0x2AD7B1
+
+	idTracks       = 0x1654AE6B
+	idTrackEntry   = 0xAE
+	idTrackNumber  = 0xD7
+	idTrackType    = 0x83
+	idCodecID      = 0x86
+	idCodecPrivate = 0x63A2
+	idCodecDelay   = 0x56AA
+	idSeekPreRoll  = 0x56BB
+
+	idCluster        = 0x1F43B675
+	idTimecode       = 0xE7
+	idSimpleBlock    = 0xA3
+	idBlockGroup     = 0xA0
+	idBlock          = 0xA1
+	idDiscardPadding = 0x75A2
+
+	idCues               = 0x1C53BB6B
+	idCuePoint           = 0xBB
+	idCueTime            = 0xB3
+	idCueTrackPositions  = 0xB7
+	idCueTrack           = 0xF7
+	idCueClusterPosition = 0xF1
+)
+
+const (
+	// IDs are at most 4 bytes long, sizes at most 8 bytes
+	maxIDLength   = 4
+	maxSizeLength = 8
+
+	// A size with all of its value bits set marks an element of unknown
+	// size, which continues until an element that can't be its child
+	unknownSize = -1
+
+	// Elements read into memory are limited to this size
+	maxElementSize = 16 << 20
+)
+
+// element is the header of an EBML element
+type element struct {
+	id   uint32
+	size int64
+}
+
+// ebmlReader reads EBML elements, and counts the bytes read to know the
+// position of every element
+type ebmlReader struct {
+	in       io.Reader
+	buffered *bufio.Reader
+	position int64
+}
+
+func newEBMLReader(in io.Reader) *ebmlReader {
+	return &ebmlReader{in: in, buffered: bufio.NewReader(in)}
+}
+
+func (e *ebmlReader) readByte() (byte, error) {
+	b, err := e.buffered.ReadByte()
+	if err == nil {
+		e.position++
+	}
+
+	return b, err
+}
+
+// readVint reads a variable size integer. The number of leading zero bits
+// of the first byte gives the number of bytes that follow it.
+//
+// https://datatracker.ietf.org/doc/html/rfc8794#section-4
+func (e *ebmlReader) readVint(maxLength int) (value uint64, length int, err error) {
+	first, err := e.readByte()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for length = 1; length <= maxLength; length++ {
+		if first&(0x80>>(length-1)) != 0 {
+			break
+		}
+	}
+	if length > maxLength {
+		return 0, 0, errInvalidVint
+	}
+
+	value = uint64(first)
+	for i := 1; i < length; i++ {
+		b, err := e.readByte()
+		if err != nil {
+			return 0, 0, io.ErrUnexpectedEOF
+		}
+		value = value<<8 | uint64(b)
+	}
+
+	return value, length, nil
+}
+
+// readElement reads the ID and size of the next element. It returns io.EOF
+// when there are no more elements.
+func (e *ebmlReader) readElement() (element, error) {
+	id, _, err := e.readVint(maxIDLength)
+	if err != nil {
+		return element{}, err
+	}
+
+	size, length, err := e.readVint(maxSizeLength)
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return element{}, err
+	}
+
+	// Remove the length marker, the value has at most 56 bits
+	size &^= 1 << (7 * length)
+	if size == 1<<(7*length)-1 {
+		return element{id: uint32(id), size: unknownSize}, nil
+	}
+
+	return element{id: uint32(id), size: int64(size)}, nil
+}
+
+// readData reads the data of an element into memory
+func (e *ebmlReader) readData(el element) ([]byte, error) {
+	if el.size == unknownSize {
+		return nil, errUnknownSize
+	}
+	if el.size > maxElementSize {
+		return nil, errElementTooLarge
+	}
+
+	data := make([]byte, el.size)
+	n, err := io.ReadFull(e.buffered, data)
+	e.position += int64(n)
+	if err != nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	return data, nil
+}
+
+// skip discards the data of an element
+func (e *ebmlReader) skip(el element) error {
+	if el.size == unknownSize {
+		return errUnknownSize
+	}
+
+	n, err := io.CopyN(io.Discard, e.buffered, el.size)
+	e.position += n
+	if err != nil {
+		return io.ErrUnexpectedEOF
+	}
+
+	return nil
+}
+
+// seek continues reading at an absolute position of a seekable input
+func (e *ebmlReader) seek(position int64) error {
+	seeker, ok := e.in.(io.Seeker)
+	if !ok {
+		return errNotSeekable
+	}
+
+	if _, err := seeker.Seek(position, io.SeekStart); err != nil {
+		return err
+	}
+	e.buffered.Reset(e.in)
+	e.position = position
+
+	return nil
+}
+
+// children calls fn for every child element in the data of a master
+// element
+func children(data []byte, fn func(id uint32, data []byte) error) error {
+	for len(data) > 0 {
+		id, n, err := parseVint(data, maxIDLength)
+		if err != nil {
+			return err
+		}
+		data = data[n:]
+
+		size, n, err := parseVint(data, maxSizeLength)
+		if err != nil {
+			return err
+		}
+		data = data[n:]
+
+		size &^= 1 << (7 * n)
+		if size > uint64(len(data)) {
+			return errShortElement
+		}
+
+		if err := fn(uint32(id), data[:size]); err != nil {
+			return err
+		}
+		data = data[size:]
+	}
+
+	return nil
+}
+
+// parseVint parses a variable size integer including its length marker
+func parseVint(data []byte, maxLength int) (value uint64, length int, err error) {
+	if len(data) == 0 {
+		return 0, 0, errShortElement
+	}
+
+	for length = 1; length <= maxLength; length++ {
+		if data[0]&(0x80>>(length-1)) != 0 {
+			break
+		}
+	}
+	switch {
+	case length > maxLength:
+		return 0, 0, errInvalidVint
+	case length > len(data):
+		return 0, 0, errShortElement
+	}
+
+	for _, b := range data[:length] {
+		value = value<<8 | uint64(b)
+	}
+
+	return value, length, nil
+}
+
+// parseUint parses an unsigned integer element of up to 8 bytes
+func parseUint(data []byte) (uint64, error) {
+	if len(data) > 8 {
+		return 0, errInvalidInteger
+	}
+
+	var value uint64
+	for _, b := range data {
+		value = value<<8 | uint64(b)
+	}
+
+	return value, nil
+}
+
+// parseInt parses a signed integer element of up to 8 bytes
+func parseInt(data []byte) (int64, error) {
+	value, err := parseUint(data)
+	if err != nil || len(data) == 0 {
+		return 0, err
+	}
+
+	// Sign extend the value
+	shift := 64 - 8*len(data)
+	return int64(value<<shift) >> shift, nil
+}
@@ -0,0 +1,19 @@
+package webmreader
+
+import "errors"
+
+var (
+	errNilStream       = errors.New("stream is nil")
+	errBadEBMLHeader   = errors.New("stream does not begin with an EBML header")
+	errBadDocType      = errors.New("document type is neither webm nor matroska")
+	errMissingSegment  = errors.New("stream has no Segment")
+	errNoOpusTrack     = errors.New("stream has no A_OPUS track before the first Cluster")
+	errInvalidVint     = errors.New("variable size integer is longer than allowed")
+	errInvalidInteger  = errors.New("integer element is longer than 8 bytes")
+	errShortElement    = errors.New("element is larger than its parent")
+	errElementTooLarge = errors.New("element is too large to be read into memory")
+	errUnknownSize     = errors.New("element of unknown size can't be read into memory")
+	errShortBlock      = errors.New("block is too short")
+	errBadLacing       = errors.New("lace sizes exceed the block")
+	errNotSeekable     = errors.New("stream does not implement io.Seeker")
+)
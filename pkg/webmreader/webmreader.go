@@ -0,0 +1,624 @@
+// Package webmreader implements a WebM and Matroska demuxer for Opus audio
+// tracks
+//
+// https://www.matroska.org/technical/codec_specs.html
+// https://www.webmproject.org/docs/container/
+package webmreader
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/pion/opus"
+	"github.com/pion/opus/pkg/oggreader"
+)
+
+const (
+	codecIDOpus    = "A_OPUS"
+	trackTypeAudio = 2
+
+	// TimecodeScale is the duration of a timecode tick in nanoseconds
+	defaultTimecodeScale = 1000000
+
+	// Opus timestamps and the pre-skip are in 48 kHz samples
+	sampleRate = 48000
+
+	// The lacing bits of the block flags
+	//
+	// https://www.matroska.org/technical/notes.html#block-lacing
+	lacingMask  = 0x06
+	lacingNone  = 0x00
+	lacingXiph  = 0x02
+	lacingFixed = 0x04
+	lacingEBML  = 0x06
+
+	// A Xiph lace size continues in the next byte after a byte of 255
+	maxXiphLaceByte = 255
+)
+
+// Packet is an Opus packet of a block, which can be passed to
+// opus.Decoder.Decode
+type Packet struct {
+	Data []byte
+
+	// Timestamp of the packet from the start of the Segment, which includes
+	// the CodecDelay
+	Timestamp time.Duration
+
+	// Duration of the audio of the packet
+	Duration time.Duration
+
+	// DiscardPadding is the duration to discard from the end of the decoded
+	// audio of the packet, the last packet of a stream uses it to trim the
+	// stream to its exact length
+	DiscardPadding time.Duration
+}
+
+type cuePoint struct {
+	time     uint64
+	position int64
+}
+
+// WebMReader reads the packets of the first Opus track of a WebM or Matroska
+// file
+type WebMReader struct {
+	reader *ebmlReader
+	header *oggreader.OggHeader
+
+	trackNumber   uint64
+	timecodeScale uint64
+	codecDelay    time.Duration
+	seekPreRoll   time.Duration
+
+	// Positions of the data of the Segment, its end, and of the first
+	// Cluster. The end is -1 when the size of the Segment is unknown.
+	segmentStart int64
+	segmentEnd   int64
+	firstCluster int64
+
+	// The Cues, or the position a SeekHead gives for them, -1 if unknown
+	cues         []cuePoint
+	haveCues     bool
+	cuesPosition int64
+
+	clusterTimecode uint64
+	packets         []Packet
+
+	// After seeking, packets that end before this time are dropped
+	seekTarget time.Duration
+}
+
+// NewWith reads the EBML header and the Segment up to its first Cluster,
+// and returns a WebMReader for its first A_OPUS track together with the ID
+// header of the track
+func NewWith(in io.Reader) (*WebMReader, *oggreader.OggHeader, error) {
+	if in == nil {
+		return nil, nil, errNilStream
+	}
+
+	w := &WebMReader{
+		reader:        newEBMLReader(in),
+		timecodeScale: defaultTimecodeScale,
+		segmentEnd:    -1,
+		cuesPosition:  -1,
+	}
+
+	// Positions are absolute, so Seek works on a file that doesn't begin
+	// with the stream
+	if seeker, ok := in.(io.Seeker); ok {
+		position, err := seeker.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil, nil, err
+		}
+		w.reader.position = position
+	}
+
+	if err := w.readEBMLHeader(); err != nil {
+		return nil, nil, err
+	}
+	if err := w.readSegmentHeader(); err != nil {
+		return nil, nil, err
+	}
+
+	return w, w.header, nil
+}
+
+// CodecDelay returns the audio the decoder outputs before the first sample
+// of the stream, which is discarded like the pre-skip of an Ogg stream.
+// The PreSkip of the header returned by NewWith is set from it.
+func (w *WebMReader) CodecDelay() time.Duration {
+	return w.codecDelay
+}
+
+// SeekPreRoll returns how long the decoder has to run after seeking before
+// its output is valid
+func (w *WebMReader) SeekPreRoll() time.Duration {
+	return w.seekPreRoll
+}
+
+// ReadPacket returns the next packet of the Opus track. It returns io.EOF
+// at the end of the Segment.
+func (w *WebMReader) ReadPacket() (Packet, error) {
+	for {
+		for len(w.packets) > 0 {
+			packet := w.packets[0]
+			w.packets = w.packets[1:]
+			if packet.Timestamp+packet.Duration <= w.seekTarget {
+				continue
+			}
+
+			w.seekTarget = 0
+			return packet, nil
+		}
+
+		if err := w.readBlocks(); err != nil {
+			return Packet{}, err
+		}
+	}
+}
+
+// Seek positions the reader at the Cluster that contains the time
+// SeekPreRoll before t, using the Cues of the file, and drops the packets
+// before that time. The decoder should be reset, and its output before t
+// discarded. Without Cues the reader starts over at the first Cluster.
+// The stream must implement io.Seeker.
+func (w *WebMReader) Seek(t time.Duration) error {
+	if _, ok := w.reader.in.(io.Seeker); !ok {
+		return errNotSeekable
+	}
+
+	if !w.haveCues && w.cuesPosition >= 0 {
+		if err := w.loadCues(); err != nil {
+			return err
+		}
+	}
+
+	target := t - w.seekPreRoll
+	if target < 0 {
+		target = 0
+	}
+
+	position := w.firstCluster
+	for _, cue := range w.cues {
+		if time.Duration(cue.time*w.timecodeScale) > target {
+			break
+		}
+		position = cue.position
+	}
+
+	if err := w.reader.seek(position); err != nil {
+		return err
+	}
+	w.packets = w.packets[:0]
+	w.clusterTimecode = 0
+	w.seekTarget = target
+
+	return nil
+}
+
+// readEBMLHeader checks the DocType of the EBML header
+//
+// https://datatracker.ietf.org/doc/html/rfc8794#section-11.2
+func (w *WebMReader) readEBMLHeader() error {
+	el, err := w.reader.readElement()
+	if err != nil || el.id != idEBML {
+		return errBadEBMLHeader
+	}
+
+	data, err := w.reader.readData(el)
+	if err != nil {
+		return err
+	}
+
+	// The DocType defaults to matroska
+	docType := "matroska"
+	if err := children(data, func(id uint32, data []byte) error {
+		if id == idDocType {
+			docType = string(bytes.TrimRight(data, "\x00"))
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	if docType != "webm" && docType != "matroska" {
+		return errBadDocType
+	}
+
+	return nil
+}
+
+// readSegmentHeader reads the top level elements of the Segment until its
+// first Cluster
+func (w *WebMReader) readSegmentHeader() error {
+	for {
+		el, err := w.reader.readElement()
+		if err != nil {
+			return errMissingSegment
+		}
+
+		if el.id == idSegment {
+			w.segmentStart = w.reader.position
+			if el.size != unknownSize {
+				w.segmentEnd = w.segmentStart + el.size
+			}
+			break
+		}
+
+		if err := w.reader.skip(el); err != nil {
+			return err
+		}
+	}
+
+	for {
+		position := w.reader.position
+		el, err := w.reader.readElement()
+		if err != nil {
+			return errNoOpusTrack
+		}
+
+		switch el.id {
+		case idCluster:
+			if w.header == nil {
+				return errNoOpusTrack
+			}
+			w.firstCluster = position
+			return nil
+		case idInfo:
+			err = w.parseElement(el, w.parseInfo)
+		case idTracks:
+			err = w.parseElement(el, w.parseTracks)
+		case idSeekHead:
+			err = w.parseElement(el, w.parseSeekHead)
+		case idCues:
+			err = w.parseElement(el, w.parseCues)
+		default:
+			err = w.reader.skip(el)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// readBlocks reads elements of the Clusters until a block of the Opus track
+// has been read
+func (w *WebMReader) readBlocks() error {
+	for len(w.packets) == 0 {
+		if w.segmentEnd >= 0 && w.reader.position >= w.segmentEnd {
+			return io.EOF
+		}
+
+		el, err := w.reader.readElement()
+		if err != nil {
+			return err
+		}
+
+		switch el.id {
+		case idCluster:
+			// The children of the Cluster follow
+		case idTimecode:
+			err = w.parseElement(el, func(data []byte) (err error) {
+				w.clusterTimecode, err = parseUint(data)
+				return err
+			})
+		case idSimpleBlock:
+			err = w.parseElement(el, func(data []byte) error {
+				return w.parseBlock(data, 0)
+			})
+		case idBlockGroup:
+			err = w.parseElement(el, w.parseBlockGroup)
+		case idCues:
+			err = w.parseElement(el, w.parseCues)
+		default:
+			err = w.reader.skip(el)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// parseElement reads the data of an element, and passes it to parse
+func (w *WebMReader) parseElement(el element, parse func(data []byte) error) error {
+	data, err := w.reader.readData(el)
+	if err != nil {
+		return err
+	}
+
+	return parse(data)
+}
+
+func (w *WebMReader) parseInfo(data []byte) error {
+	return children(data, func(id uint32, data []byte) (err error) {
+		if id == idTimecodeScale {
+			w.timecodeScale, err = parseUint(data)
+		}
+		return err
+	})
+}
+
+// parseTracks finds the first A_OPUS track, its CodecPrivate holds the
+// same ID header as an Ogg Opus stream
+//
+// https://wiki.xiph.org/MatroskaOpus
+func (w *WebMReader) parseTracks(data []byte) error {
+	return children(data, func(id uint32, data []byte) error {
+		if id != idTrackEntry || w.header != nil {
+			return nil
+		}
+
+		var (
+			trackNumber, trackType  uint64
+			codecDelay, seekPreRoll uint64
+			codecID                 string
+			codecPrivate            []byte
+		)
+		if err := children(data, func(id uint32, data []byte) (err error) {
+			switch id {
+			case idTrackNumber:
+				trackNumber, err = parseUint(data)
+			case idTrackType:
+				trackType, err = parseUint(data)
+			case idCodecID:
+				codecID = string(bytes.TrimRight(data, "\x00"))
+			case idCodecPrivate:
+				codecPrivate = data
+			case idCodecDelay:
+				codecDelay, err = parseUint(data)
+			case idSeekPreRoll:
+				seekPreRoll, err = parseUint(data)
+			}
+			return err
+		}); err != nil {
+			return err
+		}
+
+		if codecID != codecIDOpus || (trackType != 0 && trackType != trackTypeAudio) {
+			return nil
+		}
+
+		header, err := oggreader.ParseIDHeader(codecPrivate)
+		if err != nil {
+			return err
+		}
+
+		w.trackNumber = trackNumber
+		w.codecDelay = time.Duration(codecDelay)
+		w.seekPreRoll = time.Duration(seekPreRoll)
+		if codecDelay != 0 {
+			header.PreSkip = uint16(w.codecDelay * sampleRate / time.Second)
+		}
+		w.header = header
+
+		return nil
+	})
+}
+
+// parseSeekHead finds the position of the Cues, which usually follow the
+// Clusters
+func (w *WebMReader) parseSeekHead(data []byte) error {
+	return children(data, func(id uint32, data []byte) error {
+		if id != idSeek {
+			return nil
+		}
+
+		var seekID, seekPosition uint64
+		if err := children(data, func(id uint32, data []byte) (err error) {
+			switch id {
+			case idSeekID:
+				seekID, err = parseUint(data)
+			case idSeekPosition:
+				seekPosition, err = parseUint(data)
+			}
+			return err
+		}); err != nil {
+			return err
+		}
+
+		if seekID == idCues {
+			w.cuesPosition = w.segmentStart + int64(seekPosition)
+		}
+
+		return nil
+	})
+}
+
+func (w *WebMReader) parseCues(data []byte) error {
+	w.cues = w.cues[:0]
+	if err := children(data, func(id uint32, data []byte) error {
+		if id != idCuePoint {
+			return nil
+		}
+
+		var cueTime uint64
+		return children(data, func(id uint32, data []byte) (err error) {
+			switch id {
+			case idCueTime:
+				cueTime, err = parseUint(data)
+			case idCueTrackPositions:
+				var track, position uint64
+				if err := children(data, func(id uint32, data []byte) (err error) {
+					switch id {
+					case idCueTrack:
+						track, err = parseUint(data)
+					case idCueClusterPosition:
+						position, err = parseUint(data)
+					}
+					return err
+				}); err != nil {
+					return err
+				}
+
+				if track == w.trackNumber {
+					w.cues = append(w.cues, cuePoint{time: cueTime, position: w.segmentStart + int64(position)})
+				}
+			}
+			return err
+		})
+	}); err != nil {
+		return err
+	}
+
+	sort.Slice(w.cues, func(i, j int) bool {
+		return w.cues[i].time < w.cues[j].time
+	})
+	w.haveCues = true
+
+	return nil
+}
+
+// loadCues reads the Cues at the position given by the SeekHead
+func (w *WebMReader) loadCues() error {
+	if err := w.reader.seek(w.cuesPosition); err != nil {
+		return err
+	}
+
+	el, err := w.reader.readElement()
+	if err != nil || el.id != idCues {
+		// The SeekHead is wrong, seek without Cues
+		w.haveCues = true
+		return nil
+	}
+
+	return w.parseElement(el, w.parseCues)
+}
+
+func (w *WebMReader) parseBlockGroup(data []byte) error {
+	var (
+		block          []byte
+		discardPadding int64
+	)
+	if err := children(data, func(id uint32, data []byte) (err error) {
+		switch id {
+		case idBlock:
+			block = data
+		case idDiscardPadding:
+			discardPadding, err = parseInt(data)
+		}
+		return err
+	}); err != nil {
+		return err
+	}
+
+	if block == nil {
+		return nil
+	}
+
+	return w.parseBlock(block, time.Duration(discardPadding))
+}
+
+// parseBlock splits a SimpleBlock or Block of the Opus track into its
+// laced packets. The DiscardPadding applies to the last one.
+//
+// https://www.matroska.org/technical/basics.html#block-structure
+func (w *WebMReader) parseBlock(data []byte, discardPadding time.Duration) error {
+	trackNumber, n, err := parseVint(data, maxSizeLength)
+	if err != nil {
+		return err
+	}
+	if trackNumber&^(1<<(7*n)) != w.trackNumber {
+		return nil
+	}
+
+	data = data[n:]
+	if len(data) < 3 {
+		return errShortBlock
+	}
+
+	// The timecode of the block is relative to the Cluster
+	timecode := int64(w.clusterTimecode) + int64(int16(binary.BigEndian.Uint16(data)))
+	timestamp := time.Duration(timecode * int64(w.timecodeScale))
+
+	frames, err := splitLaces(data[2]&lacingMask, data[3:])
+	if err != nil {
+		return err
+	}
+
+	for i, frame := range frames {
+		parsed, err := opus.ParsePacket(frame)
+		if err != nil {
+			return err
+		}
+
+		packet := Packet{Data: frame, Timestamp: timestamp, Duration: parsed.Duration()}
+		if i == len(frames)-1 {
+			packet.DiscardPadding = discardPadding
+		}
+		timestamp += packet.Duration
+		w.packets = append(w.packets, packet)
+	}
+
+	return nil
+}
+
+// splitLaces splits the frames of a block by its lacing
+func splitLaces(lacing byte, data []byte) ([][]byte, error) {
+	if lacing == lacingNone {
+		return [][]byte{data}, nil
+	}
+
+	if len(data) == 0 {
+		return nil, errShortBlock
+	}
+	count := int(data[0]) + 1
+	data = data[1:]
+
+	sizes := make([]int64, count-1)
+	switch lacing {
+	case lacingXiph:
+		for i := range sizes {
+			for {
+				if len(data) == 0 {
+					return nil, errShortBlock
+				}
+				b := data[0]
+				data = data[1:]
+				sizes[i] += int64(b)
+				if b != maxXiphLaceByte {
+					break
+				}
+			}
+		}
+	case lacingEBML:
+		// The first size is unsigned, every other one is the signed
+		// difference to the previous size
+		for i := range sizes {
+			value, n, err := parseVint(data, maxSizeLength)
+			if err != nil {
+				return nil, err
+			}
+			data = data[n:]
+
+			value &^= 1 << (7 * n)
+			if i == 0 {
+				sizes[i] = int64(value)
+			} else {
+				sizes[i] = sizes[i-1] + int64(value) - (1<<(7*n-1) - 1)
+			}
+		}
+	case lacingFixed:
+		if len(data)%count != 0 {
+			return nil, errBadLacing
+		}
+		for i := range sizes {
+			sizes[i] = int64(len(data) / count)
+		}
+	}
+
+	frames := make([][]byte, 0, count)
+	for _, size := range sizes {
+		if size < 0 || size > int64(len(data)) {
+			return nil, errBadLacing
+		}
+		frames = append(frames, data[:size])
+		data = data[size:]
+	}
+
+	return append(frames, data), nil
+}
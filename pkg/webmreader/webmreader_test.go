@@ -0,0 +1,318 @@
+package webmreader
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pion/opus"
+)
+
+const (
+	testVideoTrack = 1
+	testAudioTrack = 2
+)
+
+// A 20 ms wideband SILK-only packet
+var testPacket = []byte{0x48, 0x0B, 0xE4, 0xC1, 0x36, 0xEC, 0xC5, 0x80}
+
+// ebmlElement encodes an element with an 8 byte size
+func ebmlElement(id uint32, data ...[]byte) []byte {
+	out := ebmlID(id)
+	out = append(out, 0x01, 0, 0, 0, 0, 0, 0, 0)
+	size := len(out)
+	for _, d := range data {
+		out = append(out, d...)
+	}
+	binary.BigEndian.PutUint64(out[size-8:], uint64(len(out)-size))
+	out[size-8] = 0x01
+
+	return out
+}
+
+// ebmlUnknownSize encodes the header of an element of unknown size
+func ebmlUnknownSize(id uint32) []byte {
+	return append(ebmlID(id), 0x01, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF)
+}
+
+func ebmlID(id uint32) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, id)
+	for len(b) > 1 && b[0] == 0 {
+		b = b[1:]
+	}
+	return b
+}
+
+func ebmlUint(v uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, v)
+	return b
+}
+
+func block(track byte, timecode int16, flags byte, frames ...byte) []byte {
+	b := []byte{0x80 | track, byte(uint16(timecode) >> 8), byte(timecode), flags}
+	return append(b, frames...)
+}
+
+func opusHead() []byte {
+	head := []byte("OpusHead")
+	head = append(head, 1, 1)
+
+	// No pre-skip, 16 kHz input and no output gain
+	head = append(head, 0, 0)
+	head = append(head, 0x80, 0x3E, 0, 0)
+	return append(head, 0, 0, 0)
+}
+
+// testWebM builds a file with a video and an Opus track, and two Clusters
+// with Cues after them
+func testWebM(cues bool) []byte {
+	tracks := ebmlElement(idTracks,
+		ebmlElement(idTrackEntry,
+			ebmlElement(idTrackNumber, ebmlUint(testVideoTrack)),
+			ebmlElement(idCodecID, []byte("V_VP8")),
+		),
+		ebmlElement(idTrackEntry,
+			ebmlElement(idTrackNumber, ebmlUint(testAudioTrack)),
+			ebmlElement(idTrackType, ebmlUint(trackTypeAudio)),
+			ebmlElement(idCodecID, []byte(codecIDOpus)),
+			ebmlElement(idCodecPrivate, opusHead()),
+			ebmlElement(idCodecDelay, ebmlUint(6500000)),
+			ebmlElement(idSeekPreRoll, ebmlUint(80000000)),
+		),
+	)
+	info := ebmlElement(idInfo, ebmlElement(idTimecodeScale, ebmlUint(defaultTimecodeScale)))
+
+	// The second and third packet are Xiph laced
+	laced := append([]byte{1, byte(len(testPacket))}, testPacket...)
+	laced = append(laced, testPacket...)
+	firstCluster := ebmlElement(idCluster,
+		ebmlElement(idTimecode, ebmlUint(0)),
+		ebmlElement(idSimpleBlock, block(testAudioTrack, 0, 0x80, testPacket...)),
+		ebmlElement(idSimpleBlock, block(testVideoTrack, 0, 0x80, 1, 2, 3)),
+		ebmlElement(idSimpleBlock, block(testAudioTrack, 20, 0x80|lacingXiph, laced...)),
+	)
+	secondCluster := ebmlElement(idCluster,
+		ebmlElement(idTimecode, ebmlUint(1000)),
+		ebmlElement(idBlockGroup,
+			ebmlElement(idBlock, block(testAudioTrack, 0, 0, testPacket...)),
+			ebmlElement(idDiscardPadding, ebmlUint(5000000)),
+		),
+	)
+
+	seekHead := func(position uint64) []byte {
+		return ebmlElement(idSeekHead, ebmlElement(idSeek,
+			ebmlElement(idSeekID, ebmlID(idCues)),
+			ebmlElement(idSeekPosition, ebmlUint(position)),
+		))
+	}
+	firstPosition := len(seekHead(0)) + len(info) + len(tracks)
+	secondPosition := firstPosition + len(firstCluster)
+
+	segment := append(seekHead(uint64(secondPosition+len(secondCluster))), info...)
+	segment = append(segment, tracks...)
+	segment = append(segment, firstCluster...)
+	segment = append(segment, secondCluster...)
+	if cues {
+		segment = append(segment, ebmlElement(idCues,
+			ebmlElement(idCuePoint,
+				ebmlElement(idCueTime, ebmlUint(0)),
+				ebmlElement(idCueTrackPositions,
+					ebmlElement(idCueTrack, ebmlUint(testAudioTrack)),
+					ebmlElement(idCueClusterPosition, ebmlUint(uint64(firstPosition))),
+				),
+			),
+			ebmlElement(idCuePoint,
+				ebmlElement(idCueTime, ebmlUint(1000)),
+				ebmlElement(idCueTrackPositions,
+					ebmlElement(idCueTrack, ebmlUint(testAudioTrack)),
+					ebmlElement(idCueClusterPosition, ebmlUint(uint64(secondPosition))),
+				),
+			),
+		)...)
+	}
+
+	file := ebmlElement(idEBML, ebmlElement(idDocType, []byte("webm")))
+	return append(file, ebmlElement(idSegment, segment)...)
+}
+
+func readTimestamps(t *testing.T, reader *WebMReader) []time.Duration {
+	var timestamps []time.Duration
+	for {
+		packet, err := reader.ReadPacket()
+		if errors.Is(err, io.EOF) {
+			return timestamps
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		timestamps = append(timestamps, packet.Timestamp)
+	}
+}
+
+func TestWebMReader(t *testing.T) {
+	reader, header, err := NewWith(bytes.NewReader(testWebM(true)))
+	switch {
+	case err != nil:
+		t.Fatal(err)
+	case header.Channels != 1 || header.SampleRate != 16000:
+		t.Fatalf("%+v", header)
+	case header.PreSkip != 312:
+		t.Fatal(header.PreSkip)
+	case reader.CodecDelay() != 6500*time.Microsecond:
+		t.Fatal(reader.CodecDelay())
+	case reader.SeekPreRoll() != 80*time.Millisecond:
+		t.Fatal(reader.SeekPreRoll())
+	}
+
+	decoder := opus.NewDecoder()
+	out := make([]byte, 1920)
+	var packets []Packet
+	for {
+		packet, err := reader.ReadPacket()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, _, err := decoder.Decode(packet.Data, out); err != nil {
+			t.Fatal(err)
+		}
+		packets = append(packets, packet)
+	}
+
+	expected := []Packet{
+		{Data: testPacket, Timestamp: 0, Duration: 20 * time.Millisecond},
+		{Data: testPacket, Timestamp: 20 * time.Millisecond, Duration: 20 * time.Millisecond},
+		{Data: testPacket, Timestamp: 40 * time.Millisecond, Duration: 20 * time.Millisecond},
+		{Data: testPacket, Timestamp: time.Second, Duration: 20 * time.Millisecond, DiscardPadding: 5 * time.Millisecond},
+	}
+	if !reflect.DeepEqual(packets, expected) {
+		t.Fatalf("%+v", packets)
+	}
+}
+
+func TestWebMReaderSeek(t *testing.T) {
+	for _, cues := range []bool{true, false} {
+		reader, _, err := NewWith(bytes.NewReader(testWebM(cues)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Seeking starts at the Cluster before the pre-roll, and drops the
+		// packets that end before it
+		for _, test := range []struct {
+			seek       time.Duration
+			timestamps []time.Duration
+		}{
+			{1090 * time.Millisecond, []time.Duration{time.Second}},
+			{100 * time.Millisecond, []time.Duration{20 * time.Millisecond, 40 * time.Millisecond, time.Second}},
+			{0, []time.Duration{0, 20 * time.Millisecond, 40 * time.Millisecond, time.Second}},
+		} {
+			if err := reader.Seek(test.seek); err != nil {
+				t.Fatal(err)
+			}
+			if timestamps := readTimestamps(t, reader); !reflect.DeepEqual(timestamps, test.timestamps) {
+				t.Fatalf("%v: %v", test.seek, timestamps)
+			}
+		}
+	}
+
+	reader, _, err := NewWith(io.MultiReader(bytes.NewReader(testWebM(true))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := reader.Seek(0); !errors.Is(err, errNotSeekable) {
+		t.Fatal(err)
+	}
+}
+
+func TestWebMReaderUnknownSize(t *testing.T) {
+	// A live recording doesn't know the size of the Segment and Clusters
+	file := ebmlElement(idEBML)
+	file = append(file, ebmlUnknownSize(idSegment)...)
+	file = append(file, ebmlElement(idTracks, ebmlElement(idTrackEntry,
+		ebmlElement(idTrackNumber, ebmlUint(1)),
+		ebmlElement(idCodecID, []byte(codecIDOpus)),
+		ebmlElement(idCodecPrivate, opusHead()),
+	))...)
+	for i := 0; i < 2; i++ {
+		file = append(file, ebmlUnknownSize(idCluster)...)
+		file = append(file, ebmlElement(idTimecode, ebmlUint(uint64(i*40)))...)
+		file = append(file, ebmlElement(idSimpleBlock, block(1, 0, 0x80, testPacket...))...)
+		file = append(file, ebmlElement(idSimpleBlock, block(1, 20, 0x80, testPacket...))...)
+	}
+
+	reader, header, err := NewWith(bytes.NewReader(file))
+	switch {
+	case err != nil:
+		t.Fatal(err)
+	case header.PreSkip != 0 || reader.CodecDelay() != 0:
+		t.Fatal(header.PreSkip, reader.CodecDelay())
+	}
+
+	expected := []time.Duration{0, 20 * time.Millisecond, 40 * time.Millisecond, 60 * time.Millisecond}
+	if timestamps := readTimestamps(t, reader); !reflect.DeepEqual(timestamps, expected) {
+		t.Fatal(timestamps)
+	}
+}
+
+func TestWebMReaderErrors(t *testing.T) {
+	if _, _, err := NewWith(nil); !errors.Is(err, errNilStream) {
+		t.Fatal(err)
+	}
+
+	if _, _, err := NewWith(bytes.NewReader([]byte("OggS"))); !errors.Is(err, errBadEBMLHeader) {
+		t.Fatal(err)
+	}
+
+	file := ebmlElement(idEBML, ebmlElement(idDocType, []byte("mkv3d")))
+	if _, _, err := NewWith(bytes.NewReader(file)); !errors.Is(err, errBadDocType) {
+		t.Fatal(err)
+	}
+
+	file = ebmlElement(idEBML, ebmlElement(idDocType, []byte("webm")))
+	file = append(file, ebmlElement(idSegment,
+		ebmlElement(idTracks, ebmlElement(idTrackEntry, ebmlElement(idCodecID, []byte("A_VORBIS")))),
+		ebmlElement(idCluster),
+	)...)
+	if _, _, err := NewWith(bytes.NewReader(file)); !errors.Is(err, errNoOpusTrack) {
+		t.Fatal(err)
+	}
+}
+
+func TestSplitLaces(t *testing.T) {
+	a, b, c := bytes.Repeat([]byte{1}, 300), []byte{2, 2}, []byte{3, 3, 3}
+
+	for _, test := range []struct {
+		name   string
+		lacing byte
+		data   []byte
+		frames [][]byte
+		err    error
+	}{
+		{"None", lacingNone, c, [][]byte{c}, nil},
+		{"Xiph", lacingXiph, append([]byte{2, 255, 45, 2}, append(append(a, b...), c...)...), [][]byte{a, b, c}, nil},
+		// 300, then 2 as the difference -298 with a bias of 8191 as 7893
+		{"EBML", lacingEBML, append([]byte{2, 0x41, 0x2C, 0x5E, 0xD5}, append(append(a, b...), c...)...), [][]byte{a, b, c}, nil},
+		{"Fixed", lacingFixed, []byte{2, 3, 3, 3, 3, 3, 3, 3, 3, 3}, [][]byte{c, c, c}, nil},
+		{"FixedUneven", lacingFixed, []byte{1, 3, 3, 3}, nil, errBadLacing},
+		{"XiphTooLong", lacingXiph, []byte{1, 4, 1, 1}, nil, errBadLacing},
+		{"Empty", lacingXiph, nil, nil, errShortBlock},
+	} {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			frames, err := splitLaces(test.lacing, test.data)
+			if !errors.Is(err, test.err) {
+				t.Fatal(err)
+			} else if !reflect.DeepEqual(frames, test.frames) {
+				t.Fatal(frames)
+			}
+		})
+	}
+}
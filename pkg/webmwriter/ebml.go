@@ -0,0 +1,167 @@
+package webmwriter
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// EBML element IDs, including their length marker
+//
+// https://www.matroska.org/technical/elements.html
+const (
+	idEBML               = 0x1A45DFA3
+	idEBMLVersion        = 0x4286
+	idEBMLReadVersion    = 0x42F7
+	idEBMLMaxIDLength    = 0x42F2
+	idEBMLMaxSizeLength  = 0x42F3
+	idDocType            = 0x4282
+	idDocTypeVersion     = 0x4287
+	idDocTypeReadVersion = 0x4285
+
+	idSegment = 0x18538067
+
+	idSeekHead     = 0x114D9B74
+	idSeek         = 0x4DBB
+	idSeekID       = 0x53AB
+	idSeekPosition = 0x53AC
+
+	idInfo          = 0x1549A966
+	idTimecodeScale = 0x2AD7B1
+	idDuration      = 0x4489
+	idMuxingApp     = 0x4D80
+	idWritingApp    = 0x5741
+
+	idTracks            = 0x1654AE6B
+	idTrackEntry        = 0xAE
+	idTrackNumber       = 0xD7
+	idTrackUID          = 0x73C5
+	idTrackType         = 0x83
+	idCodecID           = 0x86
+	idCodecPrivate      = 0x63A2
+	idCodecDelay        = 0x56AA
+	idSeekPreRoll       = 0x56BB
+	idAudio             = 0xE1
+	idSamplingFrequency = 0xB5
+	idChannels          = 0x9F
+
+	idCluster        = 0x1F43B675
+	idTimecode       = 0xE7
+	idSimpleBlock    = 0xA3
+	idBlockGroup     = 0xA0
+	idBlock          = 0xA1
+	idDiscardPadding = 0x75A2
+
+	idCues               = 0x1C53BB6B
+	idCuePoint           = 0xBB
+	idCueTime            = 0xB3
+	idCueTrackPositions  = 0xB7
+	idCueTrack           = 0xF7
+	idCueClusterPosition = 0xF1
+)
+
+const (
+	// Sizes that are only known once the file is closed are written with
+	// 8 bytes, so they can be updated in place
+	fixedSizeLength = 8
+
+	// An 8 byte size with every value bit set marks an element of unknown
+	// size
+	unknownSize = 1<<56 - 1
+)
+
+// appendID appends an element ID, which already contains its length
+// marker
+func appendID(b []byte, id uint32) []byte {
+	switch {
+	case id > 0xFFFFFF:
+		return append(b, byte(id>>24), byte(id>>16), byte(id>>8), byte(id))
+	case id > 0xFFFF:
+		return append(b, byte(id>>16), byte(id>>8), byte(id))
+	case id > 0xFF:
+		return append(b, byte(id>>8), byte(id))
+	default:
+		return append(b, byte(id))
+	}
+}
+
+// appendSize appends the shortest variable size integer for size. A value
+// with every bit set is reserved for unknown sizes, so it uses a byte more.
+//
+// https://datatracker.ietf.org/doc/html/rfc8794#section-4
+func appendSize(b []byte, size uint64) []byte {
+	length := 1
+	for length < fixedSizeLength && size >= 1<<(7*length)-1 {
+		length++
+	}
+
+	return appendVint(b, size, length)
+}
+
+// appendVint appends a variable size integer of length bytes
+func appendVint(b []byte, value uint64, length int) []byte {
+	value |= 1 << (7 * length)
+	for i := length - 1; i >= 0; i-- {
+		b = append(b, byte(value>>(8*i)))
+	}
+
+	return b
+}
+
+func appendElement(b []byte, id uint32, data []byte) []byte {
+	b = appendID(b, id)
+	b = appendSize(b, uint64(len(data)))
+	return append(b, data...)
+}
+
+// appendUintElement appends an unsigned integer element with the fewest
+// bytes
+func appendUintElement(b []byte, id uint32, value uint64) []byte {
+	length := 1
+	for length < 8 && value >= 1<<(8*length) {
+		length++
+	}
+
+	b = appendID(b, id)
+	b = appendSize(b, uint64(length))
+	for i := length - 1; i >= 0; i-- {
+		b = append(b, byte(value>>(8*i)))
+	}
+
+	return b
+}
+
+// appendFixedUintElement appends an unsigned integer element of 8 bytes,
+// which can be updated once the value is known
+func appendFixedUintElement(b []byte, id uint32, value uint64) []byte {
+	b = appendID(b, id)
+	b = appendSize(b, 8)
+	return append(b, putUint64(value)...)
+}
+
+// appendIntElement appends a signed integer element with the fewest bytes
+func appendIntElement(b []byte, id uint32, value int64) []byte {
+	length := 1
+	for length < 8 && (value < -1<<(8*length-1) || value >= 1<<(8*length-1)) {
+		length++
+	}
+
+	b = appendID(b, id)
+	b = appendSize(b, uint64(length))
+	for i := length - 1; i >= 0; i-- {
+		b = append(b, byte(value>>(8*i)))
+	}
+
+	return b
+}
+
+func appendFloatElement(b []byte, id uint32, value float64) []byte {
+	b = appendID(b, id)
+	b = appendSize(b, 8)
+	return append(b, putUint64(math.Float64bits(value))...)
+}
+
+func putUint64(value uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, value)
+	return b
+}
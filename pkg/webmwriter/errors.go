@@ -0,0 +1,10 @@
+package webmwriter
+
+import "errors"
+
+var (
+	errNilHeader           = errors.New("header is nil")
+	errInvalidChannelCount = errors.New("channel count must not be 0")
+	errTimestampDecreased  = errors.New("timestamp is before the one of the previous packet")
+	errWriterClosed        = errors.New("writer is closed")
+)
@@ -0,0 +1,381 @@
+// Package webmwriter implements a WebM muxer for Opus audio, which writes
+// files that browsers can play
+//
+// https://www.matroska.org/technical/codec_specs.html
+// https://www.webmproject.org/docs/container/
+package webmwriter
+
+import (
+	"io"
+	"math"
+	"time"
+
+	"github.com/pion/opus"
+	"github.com/pion/opus/pkg/oggreader"
+)
+
+const (
+	codecIDOpus    = "A_OPUS"
+	trackTypeAudio = 2
+	trackNumber    = 1
+	appName        = "pion/opus"
+
+	// Timecodes are in milliseconds
+	timecodeScale = time.Millisecond
+
+	// Opus timestamps and the pre-skip are in 48 kHz samples
+	sampleRate = 48000
+
+	// The decoder converges within 80 ms after seeking
+	//
+	// https://datatracker.ietf.org/doc/html/rfc7845#section-4.6
+	seekPreRoll = 80 * time.Millisecond
+
+	// A new Cluster is started after this duration, well below the 16-bit
+	// timecodes of blocks relative to their Cluster
+	maxClusterDuration = 5 * time.Second
+
+	// Every block is a keyframe
+	simpleBlockFlagKeyframe = 0x80
+)
+
+type cuePoint struct {
+	time     uint64
+	position int64
+}
+
+// WebMWriter writes Opus packets to a WebM file with a single audio track.
+// When the output is an io.WriteSeeker, Close updates the sizes and the
+// duration of the file, and adds Cues for seeking. Otherwise the Segment
+// and Clusters are written with unknown sizes, like a live recording.
+type WebMWriter struct {
+	out      io.Writer
+	seekable bool
+	start    int64
+	position int64
+
+	// Positions relative to start of values that Close updates
+	segmentStart        int64
+	segmentSizePosition int64
+	cuesSeekPosition    int64
+	durationPosition    int64
+
+	clusterOpen         bool
+	clusterTimecode     time.Duration
+	clusterSizePosition int64
+	cues                []cuePoint
+
+	// The last packet is held back until the next one, so Close can add
+	// the DiscardPadding to it
+	last          []byte
+	lastTimestamp time.Duration
+	lastDuration  time.Duration
+	haveLast      bool
+	end           time.Duration
+	trimEnd       bool
+
+	buffer []byte
+	closed bool
+}
+
+// NewWith writes the EBML header, and the Info and Tracks of a Segment with
+// an A_OPUS track described by header to out. The PreSkip of the header
+// sets the CodecDelay of the track.
+func NewWith(out io.Writer, header *oggreader.OggHeader) (*WebMWriter, error) {
+	if header == nil {
+		return nil, errNilHeader
+	}
+	if header.Channels == 0 {
+		return nil, errInvalidChannelCount
+	}
+
+	w := &WebMWriter{out: out}
+	if seeker, ok := out.(io.WriteSeeker); ok {
+		start, err := seeker.Seek(0, io.SeekCurrent)
+		w.seekable, w.start = err == nil, start
+	}
+
+	b := appendElement(nil, idEBML, w.ebmlHeader())
+
+	b = appendID(b, idSegment)
+	w.segmentSizePosition = int64(len(b))
+	b = appendVint(b, unknownSize, fixedSizeLength)
+	w.segmentStart = int64(len(b))
+
+	info, tracks := w.info(), tracks(header)
+	if w.seekable {
+		// The SeekHead has a fixed size, the position of the Cues is
+		// updated by Close
+		infoPosition := uint64(len(seekHead(0, 0, 0)))
+		tracksPosition := infoPosition + uint64(len(info))
+
+		b = append(b, seekHead(infoPosition, tracksPosition, 0)...)
+		w.cuesSeekPosition = int64(len(b)) - 8
+
+		// The Duration is the last element of the Info
+		w.durationPosition = int64(len(b)+len(info)) - 8
+	}
+	b = append(b, info...)
+	b = append(b, tracks...)
+
+	if err := w.write(b); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// WritePacket writes an Opus packet with its timestamp from the start of
+// the stream, which includes the CodecDelay. The first packet usually has
+// the timestamp 0. Timestamps must not decrease, and gaps between them
+// are kept.
+func (w *WebMWriter) WritePacket(packet []byte, timestamp time.Duration) error {
+	if w.closed {
+		return errWriterClosed
+	}
+	if w.haveLast && timestamp < w.lastTimestamp {
+		return errTimestampDecreased
+	}
+
+	parsed, err := opus.ParsePacket(packet)
+	if err != nil {
+		return err
+	}
+
+	if w.haveLast {
+		if err := w.writeBlock(w.last, w.lastTimestamp, 0); err != nil {
+			return err
+		}
+	}
+
+	w.last = append(w.last[:0], packet...)
+	w.lastTimestamp = timestamp
+	w.lastDuration = parsed.Duration()
+	w.haveLast = true
+
+	return nil
+}
+
+// TrimEnd sets the end of the stream. The audio of the last packet after
+// it is discarded by the DiscardPadding of its block.
+func (w *WebMWriter) TrimEnd(end time.Duration) {
+	w.end, w.trimEnd = end, true
+}
+
+// Close writes the last packet, and when the output is seekable the Cues,
+// the sizes of the Segment and Clusters and the Duration. It doesn't close
+// the output.
+func (w *WebMWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	end := w.lastTimestamp + w.lastDuration
+	if w.haveLast {
+		var discardPadding time.Duration
+		if w.trimEnd && w.end < end {
+			discardPadding = end - w.end
+			if discardPadding > w.lastDuration {
+				discardPadding = w.lastDuration
+			}
+			end -= discardPadding
+		}
+
+		if err := w.writeBlock(w.last, w.lastTimestamp, discardPadding); err != nil {
+			return err
+		}
+	}
+
+	if !w.seekable {
+		return nil
+	}
+
+	if err := w.closeCluster(); err != nil {
+		return err
+	}
+
+	cuesPosition := w.position - w.segmentStart
+	if err := w.write(w.cuesElement()); err != nil {
+		return err
+	}
+	segmentEnd := w.position
+
+	if err := w.patch(w.segmentSizePosition, appendVint(nil, uint64(segmentEnd-w.segmentStart), fixedSizeLength)); err != nil {
+		return err
+	}
+	if err := w.patch(w.cuesSeekPosition, putUint64(uint64(cuesPosition))); err != nil {
+		return err
+	}
+
+	return w.patch(w.durationPosition, putUint64(math.Float64bits(float64(end)/float64(timecodeScale))))
+}
+
+// writeBlock writes a packet to the current Cluster, or starts a new one.
+// Packets with DiscardPadding are written in a BlockGroup, every other one
+// in a SimpleBlock.
+//
+// https://www.matroska.org/technical/basics.html#block-structure
+func (w *WebMWriter) writeBlock(packet []byte, timestamp, discardPadding time.Duration) error {
+	if !w.clusterOpen || timestamp-w.clusterTimecode >= maxClusterDuration {
+		if err := w.startCluster(timestamp); err != nil {
+			return err
+		}
+	}
+
+	flags := byte(simpleBlockFlagKeyframe)
+	if discardPadding != 0 {
+		flags = 0
+	}
+
+	relative := int16((timestamp - w.clusterTimecode) / timecodeScale)
+	block := append(w.buffer[:0], 0x80|trackNumber, byte(uint16(relative)>>8), byte(relative), flags)
+	block = append(block, packet...)
+
+	var b []byte
+	if discardPadding == 0 {
+		b = appendElement(nil, idSimpleBlock, block)
+	} else {
+		group := appendElement(nil, idBlock, block)
+		group = appendIntElement(group, idDiscardPadding, int64(discardPadding))
+		b = appendElement(nil, idBlockGroup, group)
+	}
+	w.buffer = block
+
+	return w.write(b)
+}
+
+// startCluster closes the current Cluster, and starts one at timestamp
+func (w *WebMWriter) startCluster(timestamp time.Duration) error {
+	if err := w.closeCluster(); err != nil {
+		return err
+	}
+
+	// The Cluster starts at a whole timecode
+	w.clusterTimecode = timestamp / timecodeScale * timecodeScale
+	w.cues = append(w.cues, cuePoint{
+		time:     uint64(w.clusterTimecode / timecodeScale),
+		position: w.position - w.segmentStart,
+	})
+
+	b := appendID(nil, idCluster)
+	w.clusterSizePosition = w.position + int64(len(b))
+	b = appendVint(b, unknownSize, fixedSizeLength)
+	b = appendUintElement(b, idTimecode, uint64(w.clusterTimecode/timecodeScale))
+	w.clusterOpen = true
+
+	return w.write(b)
+}
+
+// closeCluster updates the size of the current Cluster on a seekable output
+func (w *WebMWriter) closeCluster() error {
+	if !w.clusterOpen || !w.seekable {
+		return nil
+	}
+	w.clusterOpen = false
+
+	size := w.position - w.clusterSizePosition - fixedSizeLength
+	return w.patch(w.clusterSizePosition, appendVint(nil, uint64(size), fixedSizeLength))
+}
+
+func (w *WebMWriter) write(b []byte) error {
+	n, err := w.out.Write(b)
+	w.position += int64(n)
+	return err
+}
+
+// patch overwrites the bytes at a position of a seekable output, and
+// continues at the end
+func (w *WebMWriter) patch(position int64, b []byte) error {
+	seeker, _ := w.out.(io.WriteSeeker)
+	if _, err := seeker.Seek(w.start+position, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := seeker.Write(b); err != nil {
+		return err
+	}
+
+	_, err := seeker.Seek(w.start+w.position, io.SeekStart)
+	return err
+}
+
+// ebmlHeader returns the children of the EBML header of a WebM file
+//
+// https://www.webmproject.org/docs/container/#EBML
+func (w *WebMWriter) ebmlHeader() []byte {
+	b := appendUintElement(nil, idEBMLVersion, 1)
+	b = appendUintElement(b, idEBMLReadVersion, 1)
+	b = appendUintElement(b, idEBMLMaxIDLength, 4)
+	b = appendUintElement(b, idEBMLMaxSizeLength, 8)
+	b = appendElement(b, idDocType, []byte("webm"))
+	b = appendUintElement(b, idDocTypeVersion, 4)
+	return appendUintElement(b, idDocTypeReadVersion, 2)
+}
+
+// info returns the Info element. The Duration is only known once a
+// seekable output is closed.
+func (w *WebMWriter) info() []byte {
+	b := appendUintElement(nil, idTimecodeScale, uint64(timecodeScale))
+	b = appendElement(b, idMuxingApp, []byte(appName))
+	b = appendElement(b, idWritingApp, []byte(appName))
+	if w.seekable {
+		b = appendFloatElement(b, idDuration, 0)
+	}
+
+	return appendElement(nil, idInfo, b)
+}
+
+// tracks returns the Tracks element with the Opus track. Its CodecPrivate
+// holds the same ID header as an Ogg Opus stream.
+//
+// https://wiki.xiph.org/MatroskaOpus
+func tracks(header *oggreader.OggHeader) []byte {
+	audio := appendFloatElement(nil, idSamplingFrequency, sampleRate)
+	audio = appendUintElement(audio, idChannels, uint64(header.Channels))
+
+	entry := appendUintElement(nil, idTrackNumber, trackNumber)
+	entry = appendUintElement(entry, idTrackUID, trackNumber)
+	entry = appendUintElement(entry, idTrackType, trackTypeAudio)
+	entry = appendElement(entry, idCodecID, []byte(codecIDOpus))
+	entry = appendElement(entry, idCodecPrivate, oggreader.MarshalIDHeader(header))
+	entry = appendUintElement(entry, idCodecDelay, uint64(time.Duration(header.PreSkip)*time.Second/sampleRate))
+	entry = appendUintElement(entry, idSeekPreRoll, uint64(seekPreRoll))
+	entry = appendElement(entry, idAudio, audio)
+
+	return appendElement(nil, idTracks, appendElement(nil, idTrackEntry, entry))
+}
+
+// seekHead returns a SeekHead with the positions of the Info, Tracks and
+// Cues relative to the data of the Segment. The Cues position is last.
+func seekHead(info, tracks, cues uint64) []byte {
+	var b []byte
+	for _, seek := range []struct {
+		id       uint32
+		position uint64
+	}{
+		{idInfo, info},
+		{idTracks, tracks},
+		{idCues, cues},
+	} {
+		entry := appendElement(nil, idSeekID, appendID(nil, seek.id))
+		entry = appendFixedUintElement(entry, idSeekPosition, seek.position)
+		b = appendElement(b, idSeek, entry)
+	}
+
+	return appendElement(nil, idSeekHead, b)
+}
+
+// cuesElement returns Cues with a CuePoint for every Cluster
+func (w *WebMWriter) cuesElement() []byte {
+	var b []byte
+	for _, cue := range w.cues {
+		positions := appendUintElement(nil, idCueTrack, trackNumber)
+		positions = appendUintElement(positions, idCueClusterPosition, uint64(cue.position))
+
+		point := appendUintElement(nil, idCueTime, cue.time)
+		point = appendElement(point, idCueTrackPositions, positions)
+		b = appendElement(b, idCuePoint, point)
+	}
+
+	return appendElement(nil, idCues, b)
+}
@@ -0,0 +1,166 @@
+package webmwriter
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pion/opus/pkg/oggreader"
+	"github.com/pion/opus/pkg/webmreader"
+)
+
+// A 20 ms wideband SILK-only packet
+var testPacket = []byte{0x48, 0x0B, 0xE4, 0xC1, 0x36, 0xEC, 0xC5, 0x80}
+
+// 6 s of packets span two Clusters
+const testPacketCount = 300
+
+func testHeader() *oggreader.OggHeader {
+	return &oggreader.OggHeader{Version: 1, Channels: 2, PreSkip: 312, SampleRate: 16000}
+}
+
+func writeTestStream(t *testing.T, out io.Writer) {
+	writer, err := NewWith(out, testHeader())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < testPacketCount; i++ {
+		if err := writer.WritePacket(testPacket, time.Duration(i)*20*time.Millisecond); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The last packet is trimmed to 5 ms
+	writer.TrimEnd((testPacketCount-1)*20*time.Millisecond + 5*time.Millisecond)
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writer.WritePacket(testPacket, 0); !errors.Is(err, errWriterClosed) {
+		t.Fatal(err)
+	}
+}
+
+func readTestStream(t *testing.T, reader *webmreader.WebMReader) []webmreader.Packet {
+	var packets []webmreader.Packet
+	for {
+		packet, err := reader.ReadPacket()
+		if errors.Is(err, io.EOF) {
+			return packets
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		packets = append(packets, packet)
+	}
+}
+
+func checkTestStream(t *testing.T, in io.Reader) *webmreader.WebMReader {
+	reader, header, err := webmreader.NewWith(in)
+	switch {
+	case err != nil:
+		t.Fatal(err)
+	case !reflect.DeepEqual(header, testHeader()):
+		t.Fatalf("%+v", header)
+	case reader.CodecDelay() != 6500*time.Microsecond:
+		t.Fatal(reader.CodecDelay())
+	case reader.SeekPreRoll() != seekPreRoll:
+		t.Fatal(reader.SeekPreRoll())
+	}
+
+	packets := readTestStream(t, reader)
+	if len(packets) != testPacketCount {
+		t.Fatal(len(packets))
+	}
+	for i, packet := range packets {
+		expected := webmreader.Packet{Data: testPacket, Timestamp: time.Duration(i) * 20 * time.Millisecond, Duration: 20 * time.Millisecond}
+		if i == testPacketCount-1 {
+			expected.DiscardPadding = 15 * time.Millisecond
+		}
+		if !reflect.DeepEqual(packet, expected) {
+			t.Fatalf("%d: %+v", i, packet)
+		}
+	}
+
+	return reader
+}
+
+func TestWebMWriter(t *testing.T) {
+	// A file is seekable, its sizes are updated and Cues are added
+	path := filepath.Join(t.TempDir(), "test.webm")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	writeTestStream(t, file)
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+
+	reader := checkTestStream(t, file)
+
+	// The second Cluster starts at 5 s, seeking to 5.5 s starts there after
+	// the pre-roll
+	if err := reader.Seek(5500 * time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if packets := readTestStream(t, reader); len(packets) != 29 || packets[0].Timestamp != 5420*time.Millisecond {
+		t.Fatal(len(packets), packets[0].Timestamp)
+	}
+
+	// A stream is written with unknown sizes
+	var stream bytes.Buffer
+	writeTestStream(t, &stream)
+	checkTestStream(t, &stream)
+}
+
+func TestWebMWriterErrors(t *testing.T) {
+	if _, err := NewWith(io.Discard, nil); !errors.Is(err, errNilHeader) {
+		t.Fatal(err)
+	}
+	if _, err := NewWith(io.Discard, &oggreader.OggHeader{}); !errors.Is(err, errInvalidChannelCount) {
+		t.Fatal(err)
+	}
+
+	writer, err := NewWith(io.Discard, testHeader())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.WritePacket(testPacket, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.WritePacket(testPacket, 0); !errors.Is(err, errTimestampDecreased) {
+		t.Fatal(err)
+	}
+	if err := writer.WritePacket(nil, time.Second); err == nil {
+		t.Fatal("empty packet accepted")
+	}
+}
+
+func TestAppendSize(t *testing.T) {
+	for _, test := range []struct {
+		size     uint64
+		expected []byte
+	}{
+		{0, []byte{0x80}},
+		{126, []byte{0xFE}},
+		// 127 would mark an unknown size
+		{127, []byte{0x40, 0x7F}},
+		{300, []byte{0x41, 0x2C}},
+	} {
+		if b := appendSize(nil, test.size); !bytes.Equal(b, test.expected) {
+			t.Fatalf("%d: %x", test.size, b)
+		}
+	}
+
+	if b := appendIntElement(nil, idDiscardPadding, -129); !bytes.Equal(b, []byte{0x75, 0xA2, 0x82, 0xFF, 0x7F}) {
+		t.Fatalf("%x", b)
+	}
+}
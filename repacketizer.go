@@ -0,0 +1,114 @@
+package opus
+
+import "time"
+
+// Repacketizer merges the frames of several packets into one packet, or
+// splits a packet into several, without decoding them. Packets are added
+// with Cat, and packets of any contiguous range of the collected frames are
+// produced with OutRange. The zero value is ready to use.
+//
+// The Repacketizer keeps references to the added packets, they must not be
+// modified until Reset is called.
+//
+// https://opus-codec.org/docs/opus_api-1.3.1/group__opus__repacketizer.html
+type Repacketizer struct {
+	toc        byte
+	frames     [][]byte
+	extensions []Extension
+}
+
+// Reset removes the collected frames
+func (r *Repacketizer) Reset() {
+	r.frames = r.frames[:0]
+	r.extensions = r.extensions[:0]
+}
+
+// Cat adds the frames of packet. All packets must share the configuration
+// and stereo flag of the first one, and the collected frames can't exceed
+// 120 ms. The extensions in the padding of the packet are kept with the
+// frames they belong to, padding that doesn't hold valid extensions is
+// ignored like decoders do.
+func (r *Repacketizer) Cat(packet []byte) error {
+	p, err := ParsePacket(packet)
+	if err != nil {
+		return err
+	}
+
+	if len(r.frames) == 0 {
+		r.toc = p.TOC
+	} else if p.TOC&^0b11 != r.toc&^0b11 {
+		return errRepacketizerConfigurationMismatch
+	}
+
+	if p.FrameDuration()*time.Duration(len(r.frames)+len(p.Frames)) > maxPacketDuration {
+		return errPacketTooLong
+	}
+
+	extensions, _ := p.Extensions()
+	for _, extension := range extensions {
+		extension.Frame += len(r.frames)
+		r.extensions = append(r.extensions, extension)
+	}
+
+	r.frames = append(r.frames, p.Frames...)
+	return nil
+}
+
+// FrameCount returns the number of collected frames
+func (r *Repacketizer) FrameCount() int {
+	return len(r.frames)
+}
+
+// Out writes a packet of all collected frames to out, and returns its
+// length
+func (r *Repacketizer) Out(out []byte) (int, error) {
+	return r.OutRange(0, len(r.frames), out)
+}
+
+// OutRange writes a packet of the collected frames from begin up to end to
+// out with the most compact framing, and returns its length. Writing every
+// frame to a packet of its own splits the added packets.
+func (r *Repacketizer) OutRange(begin, end int, out []byte) (int, error) {
+	return r.outRange(begin, end, out, false)
+}
+
+// OutRangePadded is like OutRange, but adds Opus padding so the packet fills
+// all of out
+func (r *Repacketizer) OutRangePadded(begin, end int, out []byte) (int, error) {
+	return r.outRange(begin, end, out, true)
+}
+
+func (r *Repacketizer) outRange(begin, end int, out []byte, pad bool) (int, error) {
+	if begin < 0 || begin >= end || end > len(r.frames) {
+		return 0, errInvalidFrameRange
+	}
+
+	var extensions []Extension
+	for _, extension := range r.extensions {
+		if extension.Frame >= begin && extension.Frame < end {
+			extension.Frame -= begin
+			extensions = append(extensions, extension)
+		}
+	}
+
+	padding, err := AppendExtensions(nil, extensions, end-begin)
+	if err != nil {
+		return 0, err
+	}
+
+	targetLength := 0
+	if pad {
+		targetLength = len(out)
+	}
+
+	// Limiting the capacity keeps append from writing past the length of
+	// out, a longer packet is allocated and rejected instead
+	packet, err := appendPacket(out[:0:len(out)], r.toc, r.frames[begin:end], padding, targetLength, false)
+	if err != nil {
+		return 0, err
+	} else if len(packet) > len(out) {
+		return 0, errOutBufferTooSmall
+	}
+
+	return len(packet), nil
+}
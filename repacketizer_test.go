@@ -0,0 +1,203 @@
+package opus
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestRepacketizer(t *testing.T) {
+	packet := append([]byte{0x48}, testSilkFrame()...)
+
+	expected := make([]byte, 3*1920)
+	decoder := NewDecoder()
+	for i := 0; i < 3; i++ {
+		if _, _, err := decoder.Decode(packet, expected[i*1920:(i+1)*1920]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var r Repacketizer
+	for i := 0; i < 3; i++ {
+		if err := r.Cat(packet); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	out := make([]byte, 100)
+	n, err := r.Out(out)
+	switch {
+	case err != nil:
+		t.Fatal(err)
+	case !bytes.Equal(out[:n], append([]byte{0x4b, 0x03}, bytes.Repeat(testSilkFrame(), 3)...)):
+		t.Fatal(out[:n])
+	}
+	merged := append([]byte{}, out[:n]...)
+
+	decoded := make([]byte, 3*1920)
+	decoder = NewDecoder()
+	if _, _, err := decoder.Decode(merged, decoded); err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(decoded, expected) {
+		t.Fatal("merged packet decoded differently")
+	}
+
+	// Split the merged packet back into single frames
+	r.Reset()
+	if err := r.Cat(merged); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < r.FrameCount(); i++ {
+		if n, err = r.OutRange(i, i+1, out); err != nil {
+			t.Fatal(err)
+		} else if !bytes.Equal(out[:n], packet) {
+			t.Fatal(out[:n])
+		}
+	}
+
+	// Two frames of the same length use code 1
+	n, err = r.OutRange(1, 3, out)
+	switch {
+	case err != nil:
+		t.Fatal(err)
+	case !bytes.Equal(out[:n], append([]byte{0x49}, bytes.Repeat(testSilkFrame(), 2)...)):
+		t.Fatal(out[:n])
+	}
+
+	r.Reset()
+	if err := r.Cat([]byte{0x48, 0x01}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Cat([]byte{0x48, 0x02, 0x03}); err != nil {
+		t.Fatal(err)
+	}
+	if n, err = r.Out(out); err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(out[:n], []byte{0x4a, 0x01, 0x01, 0x02, 0x03}) {
+		t.Fatal(out[:n])
+	}
+}
+
+func TestRepacketizerPadded(t *testing.T) {
+	packet := append([]byte{0x48}, testSilkFrame()...)
+
+	var r Repacketizer
+	if err := r.Cat(packet); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, length := range []int{len(packet), len(packet) + 2, 300, 600} {
+		out := make([]byte, length)
+		n, err := r.OutRangePadded(0, 1, out)
+		if err != nil {
+			t.Fatal(err)
+		} else if n != length {
+			t.Fatal(n)
+		}
+
+		p, err := ParsePacket(out)
+		switch {
+		case err != nil:
+			t.Fatal(err)
+		case !reflect.DeepEqual(p.Frames, [][]byte{testSilkFrame()}):
+			t.Fatal(p.Frames)
+		}
+	}
+
+	if _, err := r.OutRangePadded(0, 1, make([]byte, len(packet)+1)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.OutRange(0, 1, make([]byte, len(packet)-1)); !errors.Is(err, errOutBufferTooSmall) {
+		t.Fatal(err)
+	}
+
+	// Nothing is written past the length of a buffer with extra capacity
+	out := make([]byte, len(packet)-1, 100)
+	if _, err := r.OutRange(0, 1, out); !errors.Is(err, errOutBufferTooSmall) {
+		t.Fatal(err)
+	} else if extra := out[len(out):100]; !bytes.Equal(extra, make([]byte, len(extra))) {
+		t.Fatal(extra)
+	}
+}
+
+func TestRepacketizerExtensions(t *testing.T) {
+	var r Repacketizer
+
+	// Code 3 CBR packet of two frames with an extension for each frame
+	if err := r.Cat([]byte{0x4b, 0x42, 0x04, 0x01, 0x02, 3<<1 | 1, 0xAA, 1 << 1, 4 << 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Cat([]byte{0x48, 0x03}); err != nil {
+		t.Fatal(err)
+	}
+
+	out := make([]byte, 100)
+	n, err := r.OutRange(1, 3, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := ParsePacket(out[:n])
+	if err != nil {
+		t.Fatal(err)
+	}
+	extensions, err := p.Extensions()
+	switch {
+	case err != nil:
+		t.Fatal(err)
+	case !reflect.DeepEqual(p.Frames, [][]byte{{0x02}, {0x03}}):
+		t.Fatal(p.Frames)
+	case !reflect.DeepEqual(extensions, []Extension{{ID: 4, Frame: 0, Data: []byte{}}}):
+		t.Fatalf("%+v", extensions)
+	}
+
+	// The frame without extensions uses code 0
+	if n, err = r.OutRange(2, 3, out); err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(out[:n], []byte{0x48, 0x03}) {
+		t.Fatal(out[:n])
+	}
+
+	// Padding that doesn't hold valid extensions is dropped
+	r.Reset()
+	if err := r.Cat([]byte{0x4b, 0x41, 0x01, 0x01, 1 << 1}); err != nil {
+		t.Fatal(err)
+	}
+	if n, err = r.Out(out); err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(out[:n], []byte{0x48, 0x01}) {
+		t.Fatal(out[:n])
+	}
+}
+
+func TestRepacketizerErrors(t *testing.T) {
+	var r Repacketizer
+	if err := r.Cat([]byte{0x48, 0x01}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A stereo packet and a packet of another bandwidth
+	if err := r.Cat([]byte{0x4c, 0x01}); !errors.Is(err, errRepacketizerConfigurationMismatch) {
+		t.Fatal(err)
+	}
+	if err := r.Cat([]byte{0x08, 0x01}); !errors.Is(err, errRepacketizerConfigurationMismatch) {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 5; i++ {
+		if err := r.Cat([]byte{0x48, 0x01}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := r.Cat([]byte{0x48, 0x01}); !errors.Is(err, errPacketTooLong) {
+		t.Fatal(err)
+	}
+
+	out := make([]byte, 100)
+	for _, frameRange := range [][2]int{{-1, 1}, {1, 1}, {0, 7}} {
+		if _, err := r.OutRange(frameRange[0], frameRange[1], out); !errors.Is(err, errInvalidFrameRange) {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package opus
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/pion/opus/internal/silk"
+)
+
+const (
+	// The version of the format written by MarshalBinary. Version 2 adds
+	// the resampler of the fixed-point decoder to the SILK state, the CELT
+	// decoder adds its state under a new version once it is implemented.
+	stateVersion = 2
+
+	// The size of the header written by MarshalBinary, before the state of
+	// the SILK decoder
+	stateHeaderSize = 7
+
+	maxConfiguration = 31
+)
+
+// Reset returns the Decoder to the state of a newly created one, as when a
+// new stream starts. The Options it was created with are kept.
+func (d *Decoder) Reset() {
+	d.silkDecoder.Reset()
+	d.haveDecoded = false
+	d.previousConfiguration = 0
+	d.inDTX = false
+	d.finalRange = 0
+	d.voiceActivity = false
+}
+
+// Clone returns a deep copy of the Decoder, including the history of the
+// SILK decoder. Decoding with either doesn't affect the other, which allows
+// trying out packets, e.g. to decide between FEC and concealment.
+func (d *Decoder) Clone() Decoder {
+	return Decoder{
+		silkDecoder:           d.silkDecoder.Clone(),
+		silkBuffer:            make([]float32, len(d.silkBuffer)),
+		haveDecoded:           d.haveDecoded,
+		previousConfiguration: d.previousConfiguration,
+		inDTX:                 d.inDTX,
+		finalRange:            d.finalRange,
+		voiceActivity:         d.voiceActivity,
+		fixedPoint:            d.fixedPoint,
+		resampled:             make([]float32, len(d.resampled)),
+	}
+}
+
+// MarshalBinary encodes the state of the Decoder, so a stream can continue
+// decoding in another Decoder or process. The format is versioned, state
+// written by an older version of this package is rejected by UnmarshalBinary
+// instead of being misread.
+func (d *Decoder) MarshalBinary() ([]byte, error) {
+	silkState, err := d.silkDecoder.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	var flags byte
+	if d.haveDecoded {
+		flags |= 0b001
+	}
+	if d.inDTX {
+		flags |= 0b010
+	}
+	if d.fixedPoint {
+		flags |= 0b100
+	}
+	if d.voiceActivity {
+		flags |= 0b1000
+	}
+
+	data := make([]byte, stateHeaderSize, stateHeaderSize+len(silkState))
+	data[0] = stateVersion
+	data[1] = flags
+	data[2] = byte(d.previousConfiguration)
+	binary.LittleEndian.PutUint32(data[3:], d.finalRange)
+
+	return append(data, silkState...), nil
+}
+
+// UnmarshalBinary restores state written by MarshalBinary. The Decoder is
+// left unchanged if data is invalid.
+func (d *Decoder) UnmarshalBinary(data []byte) error {
+	if len(data) < stateHeaderSize {
+		return errInvalidState
+	}
+	if data[0] != stateVersion {
+		return fmt.Errorf("%w: %d", errUnsupportedStateVersion, data[0])
+	}
+
+	flags := data[1]
+	previousConfiguration := Configuration(data[2])
+	if flags&^0b1111 != 0 || previousConfiguration > maxConfiguration {
+		return errInvalidState
+	}
+
+	silkDecoder := silk.NewDecoder()
+	if err := silkDecoder.UnmarshalBinary(data[stateHeaderSize:]); err != nil {
+		return err
+	}
+
+	d.silkDecoder = silkDecoder
+	if len(d.silkBuffer) == 0 {
+		d.silkBuffer = make([]float32, 320)
+	}
+	d.haveDecoded = flags&0b001 != 0
+	d.inDTX = flags&0b010 != 0
+	d.fixedPoint = flags&0b100 != 0
+	if d.fixedPoint && len(d.resampled) == 0 {
+		d.resampled = make([]float32, 6*len(d.silkBuffer))
+	}
+	d.voiceActivity = flags&0b1000 != 0
+	d.previousConfiguration = previousConfiguration
+	d.finalRange = binary.LittleEndian.Uint32(data[3:])
+
+	return nil
+}
@@ -0,0 +1,103 @@
+package opus
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// decodeTestPackets decodes a packet and conceals the one after it
+func decodeTestPackets(t *testing.T, decoder *Decoder) []byte {
+	out := make([]byte, 1920*2)
+	if _, _, err := decoder.Decode(append([]byte{0x48}, testSilkFrame()...), out); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := decoder.Conceal(out[1920:]); err != nil {
+		t.Fatal(err)
+	}
+
+	return out
+}
+
+func TestDecoderState(t *testing.T) {
+	for _, options := range []Options{{}, {FixedPoint: true}} {
+		decoder := NewDecoderWithOptions(options)
+		decodeTestPackets(t, &decoder)
+
+		clone := decoder.Clone()
+		state, err := decoder.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		restored := NewDecoder()
+		if err = restored.UnmarshalBinary(state); err != nil {
+			t.Fatal(err)
+		}
+
+		expected := decodeTestPackets(t, &decoder)
+		switch {
+		case restored.fixedPoint != options.FixedPoint:
+			t.Fatal("fixed-point option wasn't restored")
+		case restored.FinalRange() != clone.FinalRange():
+			t.Fatal("final range wasn't restored")
+		case !bytes.Equal(decodeTestPackets(t, &clone), expected):
+			t.Fatal("clone decoded differently")
+		case !bytes.Equal(decodeTestPackets(t, &restored), expected):
+			t.Fatal("restored decoder decoded differently")
+		}
+
+		// The voice activity of the last packet is part of the state
+		if _, _, err = decoder.Decode(append([]byte{0x48}, testVoicedSilkFrame()...), make([]byte, 1920)); err != nil {
+			t.Fatal(err)
+		}
+		if state, err = decoder.MarshalBinary(); err != nil {
+			t.Fatal(err)
+		}
+		if err = restored.UnmarshalBinary(state); err != nil {
+			t.Fatal(err)
+		} else if !restored.VoiceActivity() {
+			t.Fatal("voice activity wasn't restored")
+		}
+
+		decoder.Reset()
+		if _, _, err = decoder.Conceal(make([]byte, 1920)); !errors.Is(err, errNoPacketDecoded) {
+			t.Fatal(err)
+		}
+
+		fresh := NewDecoderWithOptions(options)
+		if !bytes.Equal(decodeTestPackets(t, &decoder), decodeTestPackets(t, &fresh)) {
+			t.Fatal("reset decoder decoded differently")
+		}
+	}
+}
+
+func TestDecoderUnmarshalBinaryErrors(t *testing.T) {
+	decoder := NewDecoder()
+	decodeTestPackets(t, &decoder)
+	state, err := decoder.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		Name     string
+		State    []byte
+		Expected error
+	}{
+		{"Empty", nil, errInvalidState},
+		{"Version", append([]byte{stateVersion + 1}, state[1:]...), errUnsupportedStateVersion},
+		{"Flags", append([]byte{stateVersion, 0xFF}, state[2:]...), errInvalidState},
+		{"Configuration", append([]byte{stateVersion, state[1], 32}, state[3:]...), errInvalidState},
+	} {
+		t.Run(test.Name, func(t *testing.T) {
+			if err := decoder.UnmarshalBinary(test.State); !errors.Is(err, test.Expected) {
+				t.Fatal(err)
+			}
+		})
+	}
+
+	if err := decoder.UnmarshalBinary(state[:len(state)-1]); err == nil {
+		t.Fatal("truncated state was accepted")
+	}
+}
@@ -0,0 +1,525 @@
+package opus
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"github.com/pion/opus/internal/bitdepth"
+	"github.com/pion/opus/pkg/ambisonics"
+	"github.com/pion/opus/pkg/oggreader"
+)
+
+const (
+	// Packets contain at most 120 ms of audio
+	maxPacketSampleCount = outputSampleRate * 120 / 1000
+
+	// Streams using channel mapping family 0 carry a single mono or stereo
+	// stream
+	//
+	// https://datatracker.ietf.org/doc/html/rfc7845#section-5.1.1.1
+	maxRTPChannelCount = 2
+)
+
+// Stream decodes an Ogg Opus stream. It implements io.Reader, producing
+// interleaved 16-bit little-endian PCM at 48 kHz, so a file can be decoded
+// with io.Copy. ReadFloat32 produces float32 samples instead.
+//
+// The pre-skip and output gain of the ID header are applied, and the start
+// and end of the stream are trimmed to the granule positions of its first
+// and last page. Chained streams are decoded one after another, their
+// number of channels may differ.
+//
+// Every stream of a multistream packet is decoded by its own Decoder.
+// Streams of the ambisonics channel mapping families 2 and 3 are demixed
+// into ambisonic channels in ACN order. The Decoder doesn't decode stereo,
+// so streams with coupled streams are rejected when their ID header is
+// read. These are the stereo streams of channel mapping family 0, and the
+// ambisonics streams with a non-diegetic stereo pair.
+//
+// https://datatracker.ietf.org/doc/html/rfc7845
+type Stream struct {
+	in      io.Reader
+	reader  *oggreader.OggReader
+	header  *oggreader.OggHeader
+	options Options
+
+	// Did the current link of a chained stream end? err is the error that
+	// ended decoding.
+	ended bool
+	err   error
+
+	channels int
+	gain     float32
+
+	// Every stream of a multistream packet has its own decoder that
+	// decodes one channel. The output channels are selected from the
+	// decoded channels by mapping, or by the demixer of the ambisonics
+	// families.
+	decoders []Decoder
+	mapping  []uint8
+	demixer  *ambisonics.Demixer
+
+	packets  oggreader.PacketAssembler
+	haveTags bool
+
+	// lost simulates packet loss, concealment produces frames of the
+	// duration of the most recently decoded packet
+	lost          func() bool
+	haveDecoded   bool
+	frameDuration time.Duration
+
+	// The granule position of the end of the decoded samples, it's only
+	// known once the first page with audio has been decoded. preSkip
+	// counts the leading samples that are yet to be discarded.
+	position  uint64
+	haveAudio bool
+	preSkip   int
+
+	// The output of every stream, the decoded channels of a packet, and
+	// the decoded samples of the current page that haven't been read yet
+	streamBuffers []float32
+	decoded       []float32
+	samples       []float32
+	offset        int
+
+	// Read converts samples to PCM in pcm, the bytes of a sample that
+	// didn't fit in p are returned by the next call
+	pcm     [bytesPerSample]byte
+	pending []byte
+}
+
+// NewOggReader reads the ID header of the Ogg Opus stream read from in, and
+// returns a Stream decoding it with a Decoder configured by options
+func NewOggReader(in io.Reader, options Options) (*Stream, error) {
+	s := &Stream{
+		in:      in,
+		options: options,
+	}
+
+	if err := s.startLink(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Header returns the ID header of the current link of the stream
+func (s *Stream) Header() *oggreader.OggHeader {
+	return s.header
+}
+
+// Channels returns the number of interleaved channels of the current link
+// of the stream
+func (s *Stream) Channels() int {
+	return s.channels
+}
+
+// SetPacketLoss sets a function that is called for every audio packet, the
+// packets for which it returns true are concealed instead of decoded. It
+// simulates packet loss, packets are only concealed once a packet of the
+// link has been decoded.
+func (s *Stream) SetPacketLoss(lost func() bool) {
+	s.lost = lost
+}
+
+// Read reads interleaved 16-bit little-endian PCM at 48 kHz. It returns
+// io.EOF at the end of the last stream. A read doesn't continue into the
+// next link of a chained stream, Header and Channels describe the samples
+// that were read.
+func (s *Stream) Read(p []byte) (n int, err error) {
+	for n < len(p) {
+		if len(s.pending) != 0 {
+			copied := copy(p[n:], s.pending)
+			s.pending = s.pending[copied:]
+			n += copied
+			continue
+		}
+		if n > 0 && s.linkEnded() {
+			break
+		}
+
+		if err := s.fill(); err != nil {
+			if n > 0 {
+				return n, nil
+			}
+			return 0, err
+		}
+
+		samples := s.samples[s.offset:]
+		if count := (len(p) - n) / bytesPerSample; count < len(samples) {
+			samples = samples[:count]
+		}
+
+		if len(samples) == 0 {
+			// Only part of a sample fits
+			if err := s.convert(s.samples[s.offset:s.offset+1], s.pcm[:]); err != nil {
+				return n, err
+			}
+			s.offset++
+			s.pending = s.pcm[:]
+			continue
+		}
+
+		if err := s.convert(samples, p[n:]); err != nil {
+			return n, err
+		}
+		s.offset += len(samples)
+		n += len(samples) * bytesPerSample
+	}
+
+	return n, nil
+}
+
+// ReadFloat32 reads interleaved float32 samples at 48 kHz. It returns the
+// number of samples read, and io.EOF at the end of the last stream. Like
+// Read, it doesn't continue into the next link of a chained stream.
+func (s *Stream) ReadFloat32(p []float32) (n int, err error) {
+	for n < len(p) {
+		if n > 0 && s.linkEnded() {
+			break
+		}
+		if err := s.fill(); err != nil {
+			if n > 0 {
+				return n, nil
+			}
+			return 0, err
+		}
+
+		copied := copy(p[n:], s.samples[s.offset:])
+		s.offset += copied
+		n += copied
+	}
+
+	return n, nil
+}
+
+// convert converts samples to 16-bit PCM like Decode does, clipping the
+// samples amplified by the output gain
+func (s *Stream) convert(samples []float32, out []byte) error {
+	for i, sample := range samples {
+		if sample > 1 {
+			samples[i] = 1
+		} else if sample < -1 {
+			samples[i] = -1
+		}
+	}
+
+	if s.options.FixedPoint {
+		return bitdepth.ConvertFixedPointToSigned16LittleEndian(samples, out, 1)
+	}
+
+	return bitdepth.ConvertFloat32LittleEndianToSigned16LittleEndian(samples, out, 1)
+}
+
+// linkEnded reports if every sample of the current link has been read
+func (s *Stream) linkEnded() bool {
+	return s.ended && s.offset >= len(s.samples)
+}
+
+// fill decodes pages until there are samples to read. An error is kept
+// and returned again by later calls, so the samples read before it don't
+// hide it.
+func (s *Stream) fill() error {
+	for s.offset >= len(s.samples) {
+		if s.err != nil {
+			return s.err
+		}
+		s.err = s.nextPage()
+	}
+
+	return nil
+}
+
+// nextPage decodes the next page, starting the next link of a chained
+// stream after the last page of a link
+func (s *Stream) nextPage() error {
+	if s.ended {
+		if err := s.startLink(); err != nil {
+			return err
+		}
+	}
+
+	segments, pageHeader, err := s.reader.ParseNextPageNoCopy()
+	switch {
+	case errors.Is(err, oggreader.ErrChecksumMismatch):
+		// The page is lost, and with it any packet that continues on the
+		// next page
+		s.packets.DropPage()
+		return nil
+	case err != nil:
+		return err
+	}
+
+	if err := s.decodePage(segments, pageHeader); err != nil {
+		return err
+	}
+	s.ended = pageHeader.IsEndOfStream()
+
+	return nil
+}
+
+// startLink reads the ID header of the next link of a chained stream, and
+// creates the decoders for it
+//
+// https://datatracker.ietf.org/doc/html/rfc7845#section-3
+func (s *Stream) startLink() error {
+	reader, header, err := oggreader.NewWith(s.in)
+	if err != nil {
+		return err
+	}
+
+	channels, streamCount, coupledCount := int(header.Channels), int(header.StreamCount), int(header.CoupledCount)
+	if header.ChannelMap == oggreader.ChannelMappingFamilyRTP {
+		// A single stream, coupled when it is stereo
+		if channels == 0 || channels > maxRTPChannelCount {
+			return fmt.Errorf("%w: %d", errInvalidChannelCount, channels)
+		}
+		streamCount, coupledCount = 1, channels-1
+	}
+
+	// The Decoder only produces mono output, the two channels of a coupled
+	// stream can't be decoded
+	if coupledCount > 0 {
+		return fmt.Errorf("%w: %d coupled streams", errUnsupportedCoupledStreams, coupledCount)
+	}
+
+	s.demixer = nil
+	switch header.ChannelMap {
+	case oggreader.ChannelMappingFamilyRTP:
+		s.mapping = []uint8{0}
+	case oggreader.ChannelMappingFamilyVorbis, oggreader.ChannelMappingFamilyUndefined:
+		s.mapping = header.ChannelMapping
+	case oggreader.ChannelMappingFamilyAmbisonics, oggreader.ChannelMappingFamilyProjection:
+		demixer, err := ambisonics.NewDemixer(header)
+		if err != nil {
+			return err
+		}
+		s.demixer = demixer
+	default:
+		return fmt.Errorf("%w: %d", errUnsupportedChannelMappingFamily, header.ChannelMap)
+	}
+
+	s.reader, s.header = reader, header
+	s.ended = false
+	s.channels = channels
+
+	// The output gain is a Q7.8 value in dB
+	//
+	// https://datatracker.ietf.org/doc/html/rfc7845#section-5.1
+	s.gain = float32(math.Pow(10, float64(int16(header.OutputGain))/(256*20)))
+
+	s.decoders = make([]Decoder, streamCount)
+	for i := range s.decoders {
+		s.decoders[i] = NewDecoderWithOptions(s.options)
+	}
+	if len(s.streamBuffers) < streamCount*maxPacketSampleCount {
+		s.streamBuffers = make([]float32, streamCount*maxPacketSampleCount)
+	}
+
+	s.packets.Reset()
+	s.haveTags = false
+	s.haveDecoded = false
+	s.position = 0
+	s.haveAudio = false
+	s.preSkip = int(header.PreSkip)
+
+	return nil
+}
+
+// decodePage decodes the packets that end on a page, and applies the
+// pre-skip and trimming to their samples
+func (s *Stream) decodePage(segments [][]byte, pageHeader *oggreader.OggPageHeader) error {
+	s.samples, s.offset = s.samples[:0], 0
+
+	if err := s.packets.AddPage(segments, pageHeader, s.decodePacket); err != nil {
+		return err
+	}
+
+	s.offset = s.trim(pageHeader) * s.channels
+	return nil
+}
+
+// trim discards the samples of a page that are outside of the stream, and
+// returns the number of leading samples that are discarded by the pre-skip.
+//
+// The granule position of the first page with audio may be smaller than
+// the samples its packets contain, the stream then begins before granule
+// position 0 and the samples before it are discarded. The granule position
+// of the last page may be smaller as well, the samples after it are
+// discarded.
+//
+// https://datatracker.ietf.org/doc/html/rfc7845#section-4.5
+func (s *Stream) trim(pageHeader *oggreader.OggPageHeader) int {
+	count := uint64(len(s.samples) / s.channels)
+	start := s.position
+	s.position += count
+
+	granule := pageHeader.GranulePosition
+	if !s.haveAudio && count != 0 {
+		s.haveAudio = true
+		if !pageHeader.IsEndOfStream() && granule != oggreader.NoGranulePosition {
+			if granule < count {
+				s.preSkip += int(count - granule)
+			}
+			s.position = granule
+		}
+	}
+
+	if pageHeader.IsEndOfStream() && granule != oggreader.NoGranulePosition && granule < s.position {
+		keep := uint64(0)
+		if granule > start {
+			keep = granule - start
+		}
+		s.samples = s.samples[:keep*uint64(s.channels)]
+	}
+
+	skip := len(s.samples) / s.channels
+	if skip > s.preSkip {
+		skip = s.preSkip
+	}
+	s.preSkip -= skip
+
+	return skip
+}
+
+func (s *Stream) decodePacket(in []byte) error {
+	// The first packet after the ID header is the comment header
+	if !s.haveTags {
+		s.haveTags = true
+		if oggreader.IsCommentHeader(in) {
+			return nil
+		}
+	}
+
+	if s.lost != nil && s.haveDecoded && s.lost() {
+		return s.conceal(in)
+	}
+
+	sampleCount := 0
+	for i := range s.decoders {
+		out := s.streamBuffers[i*maxPacketSampleCount : (i+1)*maxPacketSampleCount]
+
+		// All but the last stream use self-delimited framing
+		//
+		// https://datatracker.ietf.org/doc/html/rfc7845#section-5.1.1.2
+		var (
+			packet Packet
+			n      int
+			err    error
+		)
+		if i == len(s.decoders)-1 {
+			if packet, err = ParsePacket(in); err == nil {
+				_, _, err = s.decoders[i].DecodeFloat32(in, out)
+			}
+		} else {
+			if packet, n, err = ParseSelfDelimitedPacket(in); err == nil {
+				_, _, _, err = s.decoders[i].DecodeSelfDelimitedFloat32(in, out)
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("stream %d: %w", i, err)
+		}
+		in = in[n:]
+
+		samples := int(packet.Duration() * outputSampleRate / time.Second)
+		switch {
+		case i == 0:
+			sampleCount = samples
+			s.frameDuration = packet.FrameDuration()
+		case samples != sampleCount:
+			return errStreamDurationMismatch
+		}
+	}
+
+	s.haveDecoded = true
+	return s.mix(sampleCount)
+}
+
+// conceal replaces a lost packet with concealed frames covering at least
+// its duration
+func (s *Stream) conceal(in []byte) error {
+	var (
+		packet Packet
+		err    error
+	)
+	if len(s.decoders) > 1 {
+		packet, _, err = ParseSelfDelimitedPacket(in)
+	} else {
+		packet, err = ParsePacket(in)
+	}
+
+	// A packet that fails to parse is replaced by a single frame
+	duration := time.Duration(0)
+	if err == nil {
+		duration = packet.Duration()
+	}
+
+	sampleCount := int(s.frameDuration * outputSampleRate / time.Second)
+	for concealed := time.Duration(0); concealed == 0 || concealed < duration; concealed += s.frameDuration {
+		for i := range s.decoders {
+			if _, _, err := s.decoders[i].ConcealFloat32(s.streamBuffers[i*maxPacketSampleCount : (i+1)*maxPacketSampleCount]); err != nil {
+				return fmt.Errorf("stream %d: %w", i, err)
+			}
+		}
+
+		if err := s.mix(sampleCount); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// mix interleaves the output of the streams into the decoded channels, and
+// appends the output channels with the output gain applied to the samples
+// of the page
+func (s *Stream) mix(sampleCount int) error {
+	decodedChannels := len(s.decoders)
+	s.decoded = resizeFloat32(s.decoded, sampleCount*decodedChannels)
+	for i := range s.decoders {
+		for j, sample := range s.streamBuffers[i*maxPacketSampleCount:][:sampleCount] {
+			s.decoded[j*decodedChannels+i] = sample
+		}
+	}
+
+	start := len(s.samples)
+	s.samples = resizeFloat32(s.samples, start+sampleCount*s.channels)
+	out := s.samples[start:]
+	if s.demixer != nil {
+		if err := s.demixer.Demix(s.decoded, out); err != nil {
+			return err
+		}
+		for i := range out {
+			out[i] *= s.gain
+		}
+
+		return nil
+	}
+
+	for j := 0; j < sampleCount; j++ {
+		for c, index := range s.mapping {
+			if index == oggreader.SilentChannel {
+				out[j*s.channels+c] = 0
+			} else {
+				out[j*s.channels+c] = s.decoded[j*decodedChannels+int(index)] * s.gain
+			}
+		}
+	}
+
+	return nil
+}
+
+// resizeFloat32 returns a slice of length n, keeping the samples of b and
+// reusing its array if it's large enough
+func resizeFloat32(b []float32, n int) []float32 {
+	if cap(b) < n {
+		grown := make([]float32, n, 2*n)
+		copy(grown, b)
+		return grown
+	}
+
+	return b[:n]
+}
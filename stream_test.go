@@ -0,0 +1,423 @@
+package opus
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+)
+
+const (
+	testPreSkip = 312
+
+	// The last page of the test stream is trimmed to 5 ms
+	testStreamSampleCount = 1920 + 240
+)
+
+func appendUint16(b []byte, v uint16) []byte {
+	return append(b, byte(v), byte(v>>8))
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	return appendUint32(appendUint32(b, uint32(v)), uint32(v>>32))
+}
+
+// oggPage builds an Ogg page with a valid checksum
+func oggPage(headerType byte, granule uint64, sequence uint32, segments ...[]byte) []byte {
+	page := []byte("OggS")
+	page = append(page, 0, headerType)
+	page = appendUint64(page, granule)
+	page = appendUint32(page, 0x1234)
+	page = appendUint32(page, sequence)
+	page = append(page, 0, 0, 0, 0, byte(len(segments)))
+	for _, segment := range segments {
+		page = append(page, byte(len(segment)))
+	}
+	for _, segment := range segments {
+		page = append(page, segment...)
+	}
+
+	var checksum uint32
+	for _, v := range page {
+		checksum ^= uint32(v) << 24
+		for i := 0; i < 8; i++ {
+			if checksum&0x80000000 != 0 {
+				checksum = (checksum << 1) ^ 0x04c11db7
+			} else {
+				checksum <<= 1
+			}
+		}
+	}
+	binary.LittleEndian.PutUint32(page[22:], checksum)
+
+	return page
+}
+
+// testOggStream builds an Ogg Opus stream of three 20 ms packets, trimmed
+// to testStreamSampleCount samples after the pre-skip
+func testOggStream(channels, channelMappingFamily byte, outputGain uint16) []byte {
+	head := testOpusHead(channels, channelMappingFamily, outputGain)
+	if channelMappingFamily != 0 {
+		head = append(head, 1, 0, 0)
+	}
+
+	return testOggStreamOf(head, append([]byte{0x48}, testSilkFrame()...), 1920, testPreSkip+testStreamSampleCount)
+}
+
+// testOpusHead builds an ID header without a channel mapping table
+func testOpusHead(channels, channelMappingFamily byte, outputGain uint16) []byte {
+	head := []byte("OpusHead")
+	head = append(head, 1, channels)
+	head = appendUint16(head, testPreSkip)
+	head = appendUint32(head, 16000)
+	head = appendUint16(head, outputGain)
+
+	return append(head, channelMappingFamily)
+}
+
+// testOggStreamOf builds an Ogg Opus stream of three packets, two ending on
+// the first audio page and one on the last
+func testOggStreamOf(head, packet []byte, firstGranule, lastGranule uint64) []byte {
+	var stream []byte
+	for _, page := range [][]byte{
+		oggPage(0x02, 0, 0, head),
+		oggPage(0, 0, 1, []byte("OpusTags\x00\x00\x00\x00\x00\x00\x00\x00")),
+		oggPage(0, firstGranule, 2, packet, packet),
+		oggPage(0x04, lastGranule, 3, packet),
+	} {
+		stream = append(stream, page...)
+	}
+
+	return stream
+}
+
+// expectedStreamPCM decodes the packets of the test stream with a Decoder,
+// and applies the pre-skip and end trimming
+func expectedStreamPCM(t *testing.T) []byte {
+	decoder := NewDecoder()
+	out := make([]byte, 3*1920)
+	for i := 0; i < 3; i++ {
+		if _, _, err := decoder.Decode(append([]byte{0x48}, testSilkFrame()...), out[i*1920:]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return out[testPreSkip*bytesPerSample : (testPreSkip+testStreamSampleCount)*bytesPerSample]
+}
+
+func TestStream(t *testing.T) {
+	expected := expectedStreamPCM(t)
+
+	stream, err := NewOggReader(bytes.NewReader(testOggStream(1, 0, 0)), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if _, err := io.Copy(&out, stream); err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(out.Bytes(), expected) {
+		t.Fatal("decoded PCM mismatch")
+	}
+
+	// Reads of an odd size split samples
+	stream, err = NewOggReader(bytes.NewReader(testOggStream(1, 0, 0)), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	p := make([]byte, 3)
+	for {
+		n, err := stream.Read(p)
+		out.Write(p[:n])
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !bytes.Equal(out.Bytes(), expected) {
+		t.Fatal("decoded PCM mismatch")
+	}
+}
+
+func TestStreamFloat32(t *testing.T) {
+	decoder := NewDecoder()
+	expected := make([]float32, 3*960)
+	for i := 0; i < 3; i++ {
+		if _, _, err := decoder.DecodeFloat32(append([]byte{0x48}, testSilkFrame()...), expected[i*960:]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expected = expected[testPreSkip : testPreSkip+testStreamSampleCount]
+
+	// The output gain of +6.02 dB doubles the samples
+	stream, err := NewOggReader(bytes.NewReader(testOggStream(1, 0, 0x0605)), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	samples := make([]float32, len(expected)+1)
+	n, err := stream.ReadFloat32(samples)
+	switch {
+	case err != nil:
+		t.Fatal(err)
+	case n != len(expected):
+		t.Fatal(n)
+	}
+	for i := range expected {
+		if diff := samples[i] - 2*expected[i]; diff > 1e-6 || diff < -1e-6 {
+			t.Fatalf("%d: %f != %f", i, samples[i], 2*expected[i])
+		}
+	}
+
+	if _, err := stream.ReadFloat32(samples); !errors.Is(err, io.EOF) {
+		t.Fatal(err)
+	}
+
+	// The truncated last page is reported after the samples before it
+	in := testOggStream(1, 0, 0)
+	if stream, err = NewOggReader(bytes.NewReader(in[:len(in)-4]), Options{}); err != nil {
+		t.Fatal(err)
+	}
+	if n, err = stream.ReadFloat32(samples); err != nil || n != 1920-testPreSkip {
+		t.Fatal(n, err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := stream.ReadFloat32(samples); !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestStreamChained(t *testing.T) {
+	expected := expectedStreamPCM(t)
+
+	// The second link has two channels that both carry its only stream
+	head := append(testOpusHead(2, 1, 0), 1, 0, 0, 0)
+	chained := append(testOggStream(1, 0, 0), testOggStreamOf(head, append([]byte{0x48}, testSilkFrame()...), 1920, testPreSkip+testStreamSampleCount)...)
+	stream, err := NewOggReader(bytes.NewReader(chained), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := io.ReadAll(stream)
+	switch {
+	case err != nil:
+		t.Fatal(err)
+	case len(out) != 3*len(expected):
+		t.Fatal(len(out))
+	case !bytes.Equal(out[:len(expected)], expected):
+		t.Fatal("first link decoded differently")
+	case stream.Channels() != 2:
+		t.Fatal(stream.Channels())
+	}
+
+	for i := 0; i < len(expected); i += bytesPerSample {
+		if !bytes.Equal(out[len(expected)+2*i:][:2], expected[i:i+2]) || !bytes.Equal(out[len(expected)+2*i+2:][:2], expected[i:i+2]) {
+			t.Fatalf("second link mismatch at %d", i)
+		}
+	}
+
+	if _, err := NewOggReader(bytes.NewReader(testOggStream(1, 4, 0)), Options{}); !errors.Is(err, errUnsupportedChannelMappingFamily) {
+		t.Fatal(err)
+	}
+}
+
+func TestStreamStartTrimming(t *testing.T) {
+	decoder := NewDecoder()
+	decoded := make([]float32, 3*960)
+	for i := 0; i < 3; i++ {
+		if _, _, err := decoder.DecodeFloat32(append([]byte{0x48}, testSilkFrame()...), decoded[i*960:]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The first page ends 10 ms before the end of its packets, the stream
+	// begins 10 ms into them and the pre-skip follows
+	const startTrim = 480
+	packet := append([]byte{0x48}, testSilkFrame()...)
+	in := testOggStreamOf(testOpusHead(1, 0, 0), packet, 1920-startTrim, 1920-startTrim+240)
+	stream, err := NewOggReader(bytes.NewReader(in), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	samples := make([]float32, len(decoded))
+	n, err := stream.ReadFloat32(samples)
+	switch {
+	case err != nil:
+		t.Fatal(err)
+	case n != 1920-startTrim-testPreSkip+240:
+		t.Fatal(n)
+	}
+	for i, sample := range samples[:n] {
+		if sample != decoded[startTrim+testPreSkip+i] {
+			t.Fatalf("%d: %f != %f", i, sample, decoded[startTrim+testPreSkip+i])
+		}
+	}
+
+	// On a page that ends the stream the end is trimmed instead
+	in = oggPage(0x02, 0, 0, testOpusHead(1, 0, 0))
+	in = append(in, oggPage(0, 0, 1, []byte("OpusTags\x00\x00\x00\x00\x00\x00\x00\x00"))...)
+	in = append(in, oggPage(0x04, testPreSkip+240, 2, packet, packet)...)
+	if stream, err = NewOggReader(bytes.NewReader(in), Options{}); err != nil {
+		t.Fatal(err)
+	}
+	if n, err = stream.ReadFloat32(samples); err != nil {
+		t.Fatal(err)
+	} else if n != 240 || samples[0] != decoded[testPreSkip] {
+		t.Fatal(n)
+	}
+}
+
+func TestStreamMultistream(t *testing.T) {
+	decoder := NewDecoder()
+	expected := make([]float32, 3*960)
+	for i := 0; i < 3; i++ {
+		if _, _, err := decoder.DecodeFloat32(append([]byte{0x48}, testSilkFrame()...), expected[i*960:]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expected = expected[testPreSkip : testPreSkip+testStreamSampleCount]
+
+	// Two streams, the first channel is the second stream, the second
+	// channel the first stream and the third is silent
+	head := append(testOpusHead(3, 1, 0), 2, 0, 1, 0, 255)
+	packet := append([]byte{0x48, byte(len(testSilkFrame()))}, testSilkFrame()...)
+	packet = append(append(packet, 0x48), testSilkFrame()...)
+
+	stream, err := NewOggReader(bytes.NewReader(testOggStreamOf(head, packet, 1920, testPreSkip+testStreamSampleCount)), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	samples := make([]float32, 3*len(expected)+1)
+	n, err := stream.ReadFloat32(samples)
+	switch {
+	case err != nil:
+		t.Fatal(err)
+	case n != 3*len(expected) || stream.Channels() != 3:
+		t.Fatal(n)
+	}
+	for i, sample := range expected {
+		if samples[3*i] != sample || samples[3*i+1] != sample || samples[3*i+2] != 0 {
+			t.Fatalf("%d: %v != %f", i, samples[3*i:3*i+3], sample)
+		}
+	}
+}
+
+func TestStreamCoupledStreams(t *testing.T) {
+	packet := append([]byte{0x48}, testSilkFrame()...)
+	for _, head := range [][]byte{
+		// A stereo stream of channel mapping family 0
+		testOpusHead(2, 0, 0),
+		// A coupled and an uncoupled stream
+		append(testOpusHead(3, 1, 0), 2, 1, 0, 1, 2),
+		// First order ambisonics with a non-diegetic stereo pair
+		append(testOpusHead(6, 2, 0), 5, 1, 1, 2, 3, 4, 5, 0),
+	} {
+		_, err := NewOggReader(bytes.NewReader(testOggStreamOf(head, packet, 1920, testPreSkip+testStreamSampleCount)), Options{})
+		if !errors.Is(err, errUnsupportedCoupledStreams) {
+			t.Fatal(err)
+		}
+	}
+
+	// Packets with stereo frames in a mono stream fail to decode
+	packet[0] |= 0x04
+	stream, err := NewOggReader(bytes.NewReader(testOggStreamOf(testOpusHead(1, 0, 0), packet, 1920, testPreSkip+testStreamSampleCount)), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = io.ReadAll(stream); err == nil || errors.Is(err, io.EOF) {
+		t.Fatal(err)
+	}
+}
+
+func TestStreamAmbisonics(t *testing.T) {
+	decoder := NewDecoder()
+	expected := make([]float32, 3*960)
+	for i := 0; i < 3; i++ {
+		if _, _, err := decoder.DecodeFloat32(append([]byte{0x48}, testSilkFrame()...), expected[i*960:]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expected = expected[testPreSkip : testPreSkip+testStreamSampleCount]
+
+	// Channel mapping family 2 maps the stream to the ACN channel, the
+	// demixing matrix of family 3 halves it
+	for _, test := range []struct {
+		head  []byte
+		scale float32
+	}{
+		{append(testOpusHead(1, 2, 0), 1, 0, 0), 1},
+		{append(testOpusHead(1, 3, 0), 1, 0, 0x00, 0x40), 0.5},
+	} {
+		packet := append([]byte{0x48}, testSilkFrame()...)
+		stream, err := NewOggReader(bytes.NewReader(testOggStreamOf(test.head, packet, 1920, testPreSkip+testStreamSampleCount)), Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		samples := make([]float32, len(expected)+1)
+		n, err := stream.ReadFloat32(samples)
+		switch {
+		case err != nil:
+			t.Fatal(err)
+		case n != len(expected):
+			t.Fatal(n)
+		}
+		for i, sample := range expected {
+			if samples[i] != sample*test.scale {
+				t.Fatalf("%d: %f != %f", i, samples[i], sample*test.scale)
+			}
+		}
+	}
+}
+
+func TestStreamPacketLoss(t *testing.T) {
+	decoder := NewDecoder()
+	expected := make([]float32, 3*960)
+	for i := 0; i < 3; i++ {
+		if _, _, err := decoder.DecodeFloat32(append([]byte{0x48}, testSilkFrame()...), expected[i*960:]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expected = expected[testPreSkip : testPreSkip+testStreamSampleCount]
+
+	// Every packet after the first is concealed, which keeps the length
+	// of the stream, and a read stops at the end of the first link
+	chained := append(testOggStream(1, 0, 0), testOggStream(1, 0, 0)...)
+	stream, err := NewOggReader(bytes.NewReader(chained), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lost := 0
+	stream.SetPacketLoss(func() bool {
+		lost++
+		return true
+	})
+
+	samples := make([]float32, 2*len(expected))
+	n, err := stream.ReadFloat32(samples)
+	switch {
+	case err != nil:
+		t.Fatal(err)
+	case n != len(expected) || lost != 2:
+		t.Fatal(n, lost)
+	case reflect.DeepEqual(samples[:n], expected):
+		t.Fatal("no packets were concealed")
+	}
+
+	if n, err = stream.ReadFloat32(samples); err != nil {
+		t.Fatal(err)
+	} else if n != len(expected) || lost != 4 {
+		t.Fatal(n, lost)
+	}
+}
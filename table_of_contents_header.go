@@ -194,7 +194,7 @@ func (f frameDuration) String() string {
 func (f frameDuration) nanoseconds() int {
 	switch f {
 	case frameDuration2500us:
-		return 2500
+		return 2500000
 	case frameDuration5ms:
 		return 5000000
 	case frameDuration10ms:
@@ -222,7 +222,7 @@ func (c Configuration) frameDuration() frameDuration {
 		return frameDuration10ms
 	case 1, 5, 9, 13, 15, 19, 23, 27, 31:
 		return frameDuration20ms
-	case 2, 6:
+	case 2, 6, 10:
 		return frameDuration40ms
 	case 3, 7, 11:
 		return frameDuration60ms
@@ -317,8 +317,8 @@ func (b Bandwidth) SampleRate() int {
 //
 //                  Figure 5: The frame count byte
 func parseFrameCountByte(in byte) (isVBR bool, hasPadding bool, frameCount byte) {
-	isVBR = (in & 0b10000000) == 1
-	hasPadding = (in & 0b01000000) == 1
+	isVBR = (in & 0b10000000) != 0
+	hasPadding = (in & 0b01000000) != 0
 	frameCount = byte(in & 0b00111111)
 	return
 }